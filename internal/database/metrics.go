@@ -0,0 +1,36 @@
+package database
+
+import (
+	"database/sql"
+
+	"mmispoc/internal/metrics"
+)
+
+// RegisterPoolMetrics exposes the connection pool statistics of db on reg.
+func RegisterPoolMetrics(reg *metrics.Registry, db *sql.DB) {
+	gauge := func(name, help string, value func(sql.DBStats) float64) {
+		reg.GaugeFunc(name, help, func() float64 { return value(db.Stats()) })
+	}
+	counter := func(name, help string, value func(sql.DBStats) float64) {
+		reg.CounterFunc(name, help, func() float64 { return value(db.Stats()) })
+	}
+
+	gauge("mmispoc_db_max_open_connections", "Maximum number of open connections to the database.",
+		func(s sql.DBStats) float64 { return float64(s.MaxOpenConnections) })
+	gauge("mmispoc_db_open_connections", "Established connections, both in use and idle.",
+		func(s sql.DBStats) float64 { return float64(s.OpenConnections) })
+	gauge("mmispoc_db_in_use_connections", "Connections currently in use.",
+		func(s sql.DBStats) float64 { return float64(s.InUse) })
+	gauge("mmispoc_db_idle_connections", "Idle connections.",
+		func(s sql.DBStats) float64 { return float64(s.Idle) })
+	counter("mmispoc_db_wait_count_total", "Connections waited for.",
+		func(s sql.DBStats) float64 { return float64(s.WaitCount) })
+	counter("mmispoc_db_wait_duration_seconds_total", "Time blocked waiting for a new connection.",
+		func(s sql.DBStats) float64 { return s.WaitDuration.Seconds() })
+	counter("mmispoc_db_max_idle_closed_total", "Connections closed due to the idle limit.",
+		func(s sql.DBStats) float64 { return float64(s.MaxIdleClosed) })
+	counter("mmispoc_db_max_idle_time_closed_total", "Connections closed due to the idle time limit.",
+		func(s sql.DBStats) float64 { return float64(s.MaxIdleTimeClosed) })
+	counter("mmispoc_db_max_lifetime_closed_total", "Connections closed due to the lifetime limit.",
+		func(s sql.DBStats) float64 { return float64(s.MaxLifetimeClosed) })
+}
@@ -1,10 +1,16 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 )
 
+// SchemaVersion is the schema revision produced by Migrate. Bump it whenever
+// a migration step is added so readiness checks can detect a stale schema.
+const SchemaVersion = 14
+
 // Migrate ensures the required tables exist in the PostgreSQL database.
 func Migrate(db *sql.DB) error {
 	const createRestaurants = `
@@ -43,6 +49,100 @@ ALTER TABLE users
 		return fmt.Errorf("ensure users.restaurant_id column: %w", err)
 	}
 
+	const ensureRoleColumn = `
+ALTER TABLE users
+	ADD COLUMN IF NOT EXISTS role TEXT NOT NULL DEFAULT 'staff';`
+
+	if _, err := db.Exec(ensureRoleColumn); err != nil {
+		return fmt.Errorf("ensure users.role column: %w", err)
+	}
+
+	const ensureTokenVersionColumn = `
+ALTER TABLE users
+	ADD COLUMN IF NOT EXISTS token_version INT NOT NULL DEFAULT 1;`
+
+	if _, err := db.Exec(ensureTokenVersionColumn); err != nil {
+		return fmt.Errorf("ensure users.token_version column: %w", err)
+	}
+
+	const ensureDisabledAtColumn = `
+ALTER TABLE users
+	ADD COLUMN IF NOT EXISTS disabled_at TIMESTAMPTZ;`
+
+	if _, err := db.Exec(ensureDisabledAtColumn); err != nil {
+		return fmt.Errorf("ensure users.disabled_at column: %w", err)
+	}
+
+	// Replicas cache token state per user and listen on token_state to drop
+	// an entry as soon as another replica revokes, disables or deletes the
+	// user. The trigger is replaced within one query, which runs as a single
+	// transaction, so replicas migrating together never see it missing.
+	const createTokenStateTrigger = `
+CREATE OR REPLACE FUNCTION notify_token_state() RETURNS trigger AS $$
+BEGIN
+	PERFORM pg_notify('token_state', OLD.id::text);
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS users_token_state ON users;
+CREATE TRIGGER users_token_state
+	AFTER UPDATE OF token_version, disabled_at OR DELETE ON users
+	FOR EACH ROW EXECUTE FUNCTION notify_token_state();`
+
+	if _, err := db.Exec(createTokenStateTrigger); err != nil {
+		return fmt.Errorf("create users token state trigger: %w", err)
+	}
+
+	const createUserMFA = `
+CREATE TABLE IF NOT EXISTS user_mfa (
+	user_id INT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+	secret TEXT NOT NULL,
+	enabled_at TIMESTAMPTZ,
+	last_used_step BIGINT,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);`
+
+	if _, err := db.Exec(createUserMFA); err != nil {
+		return fmt.Errorf("create user_mfa table: %w", err)
+	}
+
+	const createRecoveryCodes = `
+CREATE TABLE IF NOT EXISTS user_recovery_codes (
+	id SERIAL PRIMARY KEY,
+	user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	code_hash TEXT NOT NULL,
+	used_at TIMESTAMPTZ,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);`
+
+	if _, err := db.Exec(createRecoveryCodes); err != nil {
+		return fmt.Errorf("create user_recovery_codes table: %w", err)
+	}
+
+	// mfa_challenges counts the codes tried against each login challenge
+	// token so a stolen challenge cannot be used to guess codes until it
+	// expires.
+	const createMFAChallenges = `
+CREATE TABLE IF NOT EXISTS mfa_challenges (
+	jti TEXT PRIMARY KEY,
+	user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	attempts INT NOT NULL,
+	expires_at TIMESTAMPTZ NOT NULL
+);`
+
+	if _, err := db.Exec(createMFAChallenges); err != nil {
+		return fmt.Errorf("create mfa_challenges table: %w", err)
+	}
+
+	const createMFAChallengesExpiryIndex = `
+CREATE INDEX IF NOT EXISTS idx_mfa_challenges_expires_at ON mfa_challenges (expires_at);`
+
+	if _, err := db.Exec(createMFAChallengesExpiryIndex); err != nil {
+		return fmt.Errorf("create mfa_challenges expiry index: %w", err)
+	}
+
 	const createIngredients = `
 CREATE TABLE IF NOT EXISTS ingredients (
 	id SERIAL PRIMARY KEY,
@@ -76,5 +176,649 @@ CREATE TABLE IF NOT EXISTS orders (
 		return fmt.Errorf("create orders table: %w", err)
 	}
 
+	const ensureOrderCreatedByColumn = `
+ALTER TABLE orders
+	ADD COLUMN IF NOT EXISTS created_by INT REFERENCES users(id);`
+
+	if _, err := db.Exec(ensureOrderCreatedByColumn); err != nil {
+		return fmt.Errorf("ensure orders.created_by column: %w", err)
+	}
+
+	const createAuditEvents = `
+CREATE TABLE IF NOT EXISTS audit_events (
+	id SERIAL PRIMARY KEY,
+	actor_user_id INT REFERENCES users(id),
+	actor_username TEXT NOT NULL DEFAULT '',
+	action TEXT NOT NULL,
+	target_user_id INT REFERENCES users(id),
+	target_username TEXT NOT NULL DEFAULT '',
+	details TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);`
+
+	if _, err := db.Exec(createAuditEvents); err != nil {
+		return fmt.Errorf("create audit_events table: %w", err)
+	}
+
+	const createInvitations = `
+CREATE TABLE IF NOT EXISTS invitations (
+	id SERIAL PRIMARY KEY,
+	code_hash TEXT NOT NULL UNIQUE,
+	restaurant_id INT NOT NULL REFERENCES restaurants(id),
+	role TEXT NOT NULL,
+	created_by INT REFERENCES users(id),
+	expires_at TIMESTAMPTZ NOT NULL,
+	used_at TIMESTAMPTZ,
+	used_by INT REFERENCES users(id),
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);`
+
+	if _, err := db.Exec(createInvitations); err != nil {
+		return fmt.Errorf("create invitations table: %w", err)
+	}
+
+	const createAPIKeys = `
+CREATE TABLE IF NOT EXISTS api_keys (
+	id SERIAL PRIMARY KEY,
+	restaurant_id INT NOT NULL REFERENCES restaurants(id),
+	prefix TEXT NOT NULL UNIQUE,
+	secret_hash TEXT NOT NULL,
+	scopes TEXT NOT NULL DEFAULT '',
+	description TEXT NOT NULL DEFAULT '',
+	created_by INT REFERENCES users(id),
+	expires_at TIMESTAMPTZ,
+	last_used_at TIMESTAMPTZ,
+	revoked_at TIMESTAMPTZ,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);`
+
+	if _, err := db.Exec(createAPIKeys); err != nil {
+		return fmt.Errorf("create api_keys table: %w", err)
+	}
+
+	const createSuppliers = `
+CREATE TABLE IF NOT EXISTS suppliers (
+	id SERIAL PRIMARY KEY,
+	code TEXT NOT NULL UNIQUE,
+	name TEXT NOT NULL,
+	email TEXT NOT NULL DEFAULT '',
+	phone TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	deleted_at TIMESTAMPTZ
+);`
+
+	if _, err := db.Exec(createSuppliers); err != nil {
+		return fmt.Errorf("create suppliers table: %w", err)
+	}
+
+	const createSupplierIngredients = `
+CREATE TABLE IF NOT EXISTS supplier_ingredients (
+	supplier_id INT NOT NULL REFERENCES suppliers(id),
+	ingredient_id INT NOT NULL REFERENCES ingredients(id),
+	sku TEXT NOT NULL DEFAULT '',
+	pack_size INT NOT NULL DEFAULT 1 CHECK (pack_size > 0),
+	lead_time_days INT NOT NULL DEFAULT 0 CHECK (lead_time_days >= 0),
+	min_order_qty INT NOT NULL DEFAULT 0 CHECK (min_order_qty >= 0),
+	price NUMERIC(12, 4) NOT NULL DEFAULT 0 CHECK (price >= 0),
+	preferred BOOLEAN NOT NULL DEFAULT FALSE,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	PRIMARY KEY (supplier_id, ingredient_id)
+);`
+
+	if _, err := db.Exec(createSupplierIngredients); err != nil {
+		return fmt.Errorf("create supplier_ingredients table: %w", err)
+	}
+
+	const createSupplierIngredientsIndex = `
+CREATE INDEX IF NOT EXISTS idx_supplier_ingredients_ingredient ON supplier_ingredients (ingredient_id);`
+
+	if _, err := db.Exec(createSupplierIngredientsIndex); err != nil {
+		return fmt.Errorf("create supplier_ingredients index: %w", err)
+	}
+
+	const createPurchaseOrders = `
+CREATE TABLE IF NOT EXISTS purchase_orders (
+	id SERIAL PRIMARY KEY,
+	code TEXT NOT NULL UNIQUE,
+	restaurant_id INT NOT NULL REFERENCES restaurants(id),
+	supplier_id INT REFERENCES suppliers(id),
+	expected_delivery_on DATE,
+	created_by INT REFERENCES users(id),
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);`
+
+	if _, err := db.Exec(createPurchaseOrders); err != nil {
+		return fmt.Errorf("create purchase_orders table: %w", err)
+	}
+
+	const ensureOrderSupplierColumns = `
+ALTER TABLE orders
+	ADD COLUMN IF NOT EXISTS supplier_id INT REFERENCES suppliers(id),
+	ADD COLUMN IF NOT EXISTS purchase_order_id INT REFERENCES purchase_orders(id);`
+
+	if _, err := db.Exec(ensureOrderSupplierColumns); err != nil {
+		return fmt.Errorf("ensure orders supplier columns: %w", err)
+	}
+
+	const ensureIngredientTaxRateColumn = `
+ALTER TABLE ingredients
+	ADD COLUMN IF NOT EXISTS tax_rate NUMERIC(6, 4) NOT NULL DEFAULT 0 CHECK (tax_rate >= 0 AND tax_rate < 1);`
+
+	if _, err := db.Exec(ensureIngredientTaxRateColumn); err != nil {
+		return fmt.Errorf("ensure ingredients.tax_rate column: %w", err)
+	}
+
+	const createIngredientPrices = `
+CREATE TABLE IF NOT EXISTS ingredient_prices (
+	id SERIAL PRIMARY KEY,
+	ingredient_id INT NOT NULL REFERENCES ingredients(id),
+	supplier_id INT REFERENCES suppliers(id),
+	price NUMERIC(12, 4) NOT NULL CHECK (price >= 0),
+	effective_from TIMESTAMPTZ NOT NULL,
+	effective_to TIMESTAMPTZ,
+	created_by INT REFERENCES users(id),
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	CHECK (effective_to IS NULL OR effective_to > effective_from)
+);`
+
+	if _, err := db.Exec(createIngredientPrices); err != nil {
+		return fmt.Errorf("create ingredient_prices table: %w", err)
+	}
+
+	const createIngredientPricesIndex = `
+CREATE UNIQUE INDEX IF NOT EXISTS idx_ingredient_prices_period
+	ON ingredient_prices (ingredient_id, COALESCE(supplier_id, 0), effective_from);`
+
+	if _, err := db.Exec(createIngredientPricesIndex); err != nil {
+		return fmt.Errorf("create ingredient_prices index: %w", err)
+	}
+
+	// Supplier terms created before price history existed become its first entry.
+	const backfillSupplierPrices = `
+INSERT INTO ingredient_prices (ingredient_id, supplier_id, price, effective_from)
+SELECT si.ingredient_id, si.supplier_id, si.price, si.updated_at
+FROM supplier_ingredients si
+WHERE si.price > 0 AND NOT EXISTS (
+	SELECT 1 FROM ingredient_prices p
+	WHERE p.ingredient_id = si.ingredient_id AND p.supplier_id = si.supplier_id
+);`
+
+	if _, err := db.Exec(backfillSupplierPrices); err != nil {
+		return fmt.Errorf("backfill ingredient_prices: %w", err)
+	}
+
+	const ensureOrderPriceColumns = `
+ALTER TABLE orders
+	ADD COLUMN IF NOT EXISTS unit_price NUMERIC(12, 4),
+	ADD COLUMN IF NOT EXISTS tax_rate NUMERIC(6, 4);`
+
+	if _, err := db.Exec(ensureOrderPriceColumns); err != nil {
+		return fmt.Errorf("ensure orders price columns: %w", err)
+	}
+
+	const ensurePurchaseOrderStatusColumn = `
+ALTER TABLE purchase_orders
+	ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'placed';`
+
+	if _, err := db.Exec(ensurePurchaseOrderStatusColumn); err != nil {
+		return fmt.Errorf("ensure purchase_orders.status column: %w", err)
+	}
+
+	const createPurchaseOrdersRestaurantIndex = `
+CREATE INDEX IF NOT EXISTS idx_purchase_orders_restaurant_created ON purchase_orders (restaurant_id, created_at);`
+
+	if _, err := db.Exec(createPurchaseOrdersRestaurantIndex); err != nil {
+		return fmt.Errorf("create purchase_orders index: %w", err)
+	}
+
+	const createBudgets = `
+CREATE TABLE IF NOT EXISTS budgets (
+	id SERIAL PRIMARY KEY,
+	restaurant_id INT NOT NULL REFERENCES restaurants(id),
+	period TEXT NOT NULL CHECK (period IN ('week', 'month')),
+	amount NUMERIC(12, 2) NOT NULL CHECK (amount >= 0),
+	hard_limit BOOLEAN NOT NULL DEFAULT FALSE,
+	updated_by INT REFERENCES users(id),
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	UNIQUE (restaurant_id, period)
+);`
+
+	if _, err := db.Exec(createBudgets); err != nil {
+		return fmt.Errorf("create budgets table: %w", err)
+	}
+
+	const createApprovalRules = `
+CREATE TABLE IF NOT EXISTS approval_rules (
+	id SERIAL PRIMARY KEY,
+	name TEXT NOT NULL,
+	restaurant_id INT REFERENCES restaurants(id),
+	min_total NUMERIC(12, 2) CHECK (min_total >= 0),
+	ingredient_type TEXT,
+	steps TEXT NOT NULL,
+	created_by INT REFERENCES users(id),
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	deleted_at TIMESTAMPTZ
+);`
+
+	if _, err := db.Exec(createApprovalRules); err != nil {
+		return fmt.Errorf("create approval_rules table: %w", err)
+	}
+
+	const createPurchaseOrderApprovals = `
+CREATE TABLE IF NOT EXISTS purchase_order_approvals (
+	id SERIAL PRIMARY KEY,
+	purchase_order_id INT NOT NULL REFERENCES purchase_orders(id),
+	step INT NOT NULL CHECK (step > 0),
+	approver_role TEXT NOT NULL,
+	status TEXT NOT NULL CHECK (status IN ('waiting', 'pending', 'approved', 'rejected', 'expired', 'cancelled')),
+	reason TEXT NOT NULL DEFAULT '',
+	expires_at TIMESTAMPTZ,
+	decided_by INT REFERENCES users(id),
+	decided_at TIMESTAMPTZ,
+	comment TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	UNIQUE (purchase_order_id, step)
+);`
+
+	if _, err := db.Exec(createPurchaseOrderApprovals); err != nil {
+		return fmt.Errorf("create purchase_order_approvals table: %w", err)
+	}
+
+	const createPendingApprovalsIndex = `
+CREATE INDEX IF NOT EXISTS idx_purchase_order_approvals_pending
+	ON purchase_order_approvals (approver_role, expires_at) WHERE status = 'pending';`
+
+	if _, err := db.Exec(createPendingApprovalsIndex); err != nil {
+		return fmt.Errorf("create purchase_order_approvals index: %w", err)
+	}
+
+	// Orders held by a hard budget before approval chains existed wait for an
+	// area manager and do not expire.
+	const backfillBudgetApprovals = `
+INSERT INTO purchase_order_approvals (purchase_order_id, step, approver_role, status, reason)
+SELECT po.id, 1, 'area_manager', 'pending', 'budget'
+FROM purchase_orders po
+WHERE po.status = 'pending_approval' AND NOT EXISTS (
+	SELECT 1 FROM purchase_order_approvals a WHERE a.purchase_order_id = po.id
+);`
+
+	if _, err := db.Exec(backfillBudgetApprovals); err != nil {
+		return fmt.Errorf("backfill purchase_order_approvals: %w", err)
+	}
+
+	const ensurePurchaseOrderDeliveredColumn = `
+ALTER TABLE purchase_orders
+	ADD COLUMN IF NOT EXISTS delivered_at TIMESTAMPTZ;`
+
+	if _, err := db.Exec(ensurePurchaseOrderDeliveredColumn); err != nil {
+		return fmt.Errorf("ensure purchase_orders.delivered_at column: %w", err)
+	}
+
+	// stock_movements is an append-only ledger; quantities are signed deltas
+	// in the ingredient's ordering unit.
+	const createStockMovements = `
+CREATE TABLE IF NOT EXISTS stock_movements (
+	id BIGSERIAL PRIMARY KEY,
+	restaurant_id INT NOT NULL REFERENCES restaurants(id),
+	ingredient_id INT NOT NULL REFERENCES ingredients(id),
+	kind TEXT NOT NULL CHECK (kind IN ('receipt', 'consumption', 'waste', 'transfer', 'adjustment')),
+	quantity NUMERIC(14, 3) NOT NULL CHECK (quantity <> 0),
+	counterpart_restaurant_id INT REFERENCES restaurants(id),
+	purchase_order_id INT REFERENCES purchase_orders(id),
+	order_id INT REFERENCES orders(id),
+	note TEXT NOT NULL DEFAULT '',
+	created_by INT REFERENCES users(id),
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);`
+
+	if _, err := db.Exec(createStockMovements); err != nil {
+		return fmt.Errorf("create stock_movements table: %w", err)
+	}
+
+	const createStockMovementsIndex = `
+CREATE INDEX IF NOT EXISTS idx_stock_movements_restaurant_ingredient ON stock_movements (restaurant_id, ingredient_id, id);`
+
+	if _, err := db.Exec(createStockMovementsIndex); err != nil {
+		return fmt.Errorf("create stock_movements index: %w", err)
+	}
+
+	const createStockLevels = `
+CREATE TABLE IF NOT EXISTS stock_levels (
+	restaurant_id INT NOT NULL REFERENCES restaurants(id),
+	ingredient_id INT NOT NULL REFERENCES ingredients(id),
+	on_hand NUMERIC(14, 3) NOT NULL CHECK (on_hand >= 0),
+	last_movement_id BIGINT NOT NULL REFERENCES stock_movements(id),
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	PRIMARY KEY (restaurant_id, ingredient_id)
+);`
+
+	if _, err := db.Exec(createStockLevels); err != nil {
+		return fmt.Errorf("create stock_levels table: %w", err)
+	}
+
+	// A goods receipt records one delivery against a purchase order. Received
+	// quantities go into stock; rejected ones are sent back with a reason.
+	const createGoodsReceipts = `
+CREATE TABLE IF NOT EXISTS goods_receipts (
+	id BIGSERIAL PRIMARY KEY,
+	purchase_order_id INT NOT NULL REFERENCES purchase_orders(id),
+	note TEXT NOT NULL DEFAULT '',
+	received_by INT REFERENCES users(id),
+	received_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);`
+
+	if _, err := db.Exec(createGoodsReceipts); err != nil {
+		return fmt.Errorf("create goods_receipts table: %w", err)
+	}
+
+	const createGoodsReceiptsIndex = `
+CREATE INDEX IF NOT EXISTS idx_goods_receipts_purchase_order ON goods_receipts (purchase_order_id, id);`
+
+	if _, err := db.Exec(createGoodsReceiptsIndex); err != nil {
+		return fmt.Errorf("create goods_receipts index: %w", err)
+	}
+
+	const createGoodsReceiptLines = `
+CREATE TABLE IF NOT EXISTS goods_receipt_lines (
+	id BIGSERIAL PRIMARY KEY,
+	receipt_id BIGINT NOT NULL REFERENCES goods_receipts(id),
+	order_id INT NOT NULL REFERENCES orders(id),
+	received_quantity NUMERIC(14, 3) NOT NULL CHECK (received_quantity >= 0),
+	rejected_quantity NUMERIC(14, 3) NOT NULL CHECK (rejected_quantity >= 0),
+	reject_reason TEXT NOT NULL DEFAULT '' CHECK (reject_reason IN ('', 'damaged', 'wrong_item', 'short_dated', 'not_delivered')),
+	CHECK (received_quantity + rejected_quantity > 0),
+	CHECK ((rejected_quantity = 0) = (reject_reason = ''))
+);`
+
+	if _, err := db.Exec(createGoodsReceiptLines); err != nil {
+		return fmt.Errorf("create goods_receipt_lines table: %w", err)
+	}
+
+	// Tables created before short shipments could be closed lack the
+	// not_delivered reason. Replacing the check locks the table, so it is
+	// only done while the reason is missing. Existing rows satisfy the new
+	// check, so it is added without scanning them; one statement keeps
+	// replicas migrating at the same time from tripping over each other.
+	const hasNotDeliveredReason = `
+SELECT EXISTS (
+	SELECT 1 FROM pg_constraint
+	WHERE conrelid = 'goods_receipt_lines'::regclass
+		AND conname = 'goods_receipt_lines_reject_reason_check'
+		AND pg_get_constraintdef(oid) LIKE '%not_delivered%'
+);`
+
+	var rejectReasonCurrent bool
+	if err := db.QueryRow(hasNotDeliveredReason).Scan(&rejectReasonCurrent); err != nil {
+		return fmt.Errorf("check goods_receipt_lines reject reasons: %w", err)
+	}
+
+	const ensureRejectReasonCheck = `
+ALTER TABLE goods_receipt_lines
+	DROP CONSTRAINT IF EXISTS goods_receipt_lines_reject_reason_check,
+	ADD CONSTRAINT goods_receipt_lines_reject_reason_check
+		CHECK (reject_reason IN ('', 'damaged', 'wrong_item', 'short_dated', 'not_delivered')) NOT VALID;`
+
+	if !rejectReasonCurrent {
+		if _, err := db.Exec(ensureRejectReasonCheck); err != nil {
+			return fmt.Errorf("ensure goods_receipt_lines reject reasons: %w", err)
+		}
+	}
+
+	const createGoodsReceiptLinesIndex = `
+CREATE INDEX IF NOT EXISTS idx_goods_receipt_lines_order ON goods_receipt_lines (order_id);`
+
+	if _, err := db.Exec(createGoodsReceiptLinesIndex); err != nil {
+		return fmt.Errorf("create goods_receipt_lines index: %w", err)
+	}
+
+	const ensureStockMovementReceiptColumn = `
+ALTER TABLE stock_movements
+	ADD COLUMN IF NOT EXISTS goods_receipt_id BIGINT REFERENCES goods_receipts(id);`
+
+	if _, err := db.Exec(ensureStockMovementReceiptColumn); err != nil {
+		return fmt.Errorf("ensure stock_movements.goods_receipt_id column: %w", err)
+	}
+
+	// Orders delivered before receiving existed arrived in full: record one
+	// receipt per order covering every line, link the stock receipts to it
+	// and mark the order received.
+	const backfillDeliveredReceipts = `
+WITH delivered AS (
+	UPDATE purchase_orders SET status = 'received'
+	WHERE status = 'delivered'
+	RETURNING id, created_by, COALESCE(delivered_at, NOW()) AS delivered_at
+), receipts AS (
+	INSERT INTO goods_receipts (purchase_order_id, received_by, received_at)
+	SELECT id, created_by, delivered_at FROM delivered
+	RETURNING id, purchase_order_id
+), lines AS (
+	INSERT INTO goods_receipt_lines (receipt_id, order_id, received_quantity, rejected_quantity)
+	SELECT r.id, o.id, o.number, 0
+	FROM receipts r
+	JOIN orders o ON o.purchase_order_id = r.purchase_order_id
+)
+UPDATE stock_movements sm SET goods_receipt_id = r.id
+FROM receipts r
+WHERE sm.purchase_order_id = r.purchase_order_id AND sm.kind = 'receipt';`
+
+	if _, err := db.Exec(backfillDeliveredReceipts); err != nil {
+		return fmt.Errorf("backfill goods_receipts: %w", err)
+	}
+
+	// stock_lots splits stock on hand by supplier lot. Stock without a lot
+	// number or expiry date is kept in a lot with an empty number, so lots
+	// always add up to stock_levels.
+	const createStockLots = `
+CREATE TABLE IF NOT EXISTS stock_lots (
+	id BIGSERIAL PRIMARY KEY,
+	restaurant_id INT NOT NULL REFERENCES restaurants(id),
+	ingredient_id INT NOT NULL REFERENCES ingredients(id),
+	lot_number TEXT NOT NULL DEFAULT '',
+	expires_on DATE,
+	on_hand NUMERIC(14, 3) NOT NULL CHECK (on_hand >= 0),
+	received_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);`
+
+	if _, err := db.Exec(createStockLots); err != nil {
+		return fmt.Errorf("create stock_lots table: %w", err)
+	}
+
+	const createStockLotsIdentityIndex = `
+CREATE UNIQUE INDEX IF NOT EXISTS idx_stock_lots_identity
+	ON stock_lots (restaurant_id, ingredient_id, lot_number, (COALESCE(expires_on, 'infinity'::date)));`
+
+	if _, err := db.Exec(createStockLotsIdentityIndex); err != nil {
+		return fmt.Errorf("create stock_lots identity index: %w", err)
+	}
+
+	const createStockLotsNumberIndex = `
+CREATE INDEX IF NOT EXISTS idx_stock_lots_lot_number ON stock_lots (lot_number) WHERE lot_number <> '';`
+
+	if _, err := db.Exec(createStockLotsNumberIndex); err != nil {
+		return fmt.Errorf("create stock_lots lot number index: %w", err)
+	}
+
+	const createStockLotsExpiryIndex = `
+CREATE INDEX IF NOT EXISTS idx_stock_lots_expiry ON stock_lots (restaurant_id, expires_on) WHERE on_hand > 0;`
+
+	if _, err := db.Exec(createStockLotsExpiryIndex); err != nil {
+		return fmt.Errorf("create stock_lots expiry index: %w", err)
+	}
+
+	const ensureStockMovementLotColumn = `
+ALTER TABLE stock_movements
+	ADD COLUMN IF NOT EXISTS lot_id BIGINT REFERENCES stock_lots(id);`
+
+	if _, err := db.Exec(ensureStockMovementLotColumn); err != nil {
+		return fmt.Errorf("ensure stock_movements.lot_id column: %w", err)
+	}
+
+	const ensureReceiptLineLotColumns = `
+ALTER TABLE goods_receipt_lines
+	ADD COLUMN IF NOT EXISTS lot_number TEXT NOT NULL DEFAULT '',
+	ADD COLUMN IF NOT EXISTS expires_on DATE;`
+
+	if _, err := db.Exec(ensureReceiptLineLotColumns); err != nil {
+		return fmt.Errorf("ensure goods_receipt_lines lot columns: %w", err)
+	}
+
+	// Stock booked before lots existed becomes one lot without number or
+	// expiry per restaurant and ingredient.
+	const backfillStockLots = `
+INSERT INTO stock_lots (restaurant_id, ingredient_id, on_hand, received_at)
+SELECT sl.restaurant_id, sl.ingredient_id, sl.on_hand, sl.updated_at
+FROM stock_levels sl
+WHERE sl.on_hand > 0 AND NOT EXISTS (
+	SELECT 1 FROM stock_lots l WHERE l.restaurant_id = sl.restaurant_id AND l.ingredient_id = sl.ingredient_id
+);`
+
+	if _, err := db.Exec(backfillStockLots); err != nil {
+		return fmt.Errorf("backfill stock_lots: %w", err)
+	}
+
+	// A stocktake snapshots expected stock when opened. Counts are kept per
+	// device and summed per ingredient; posting records one adjustment per
+	// counted ingredient whose count differs from the snapshot.
+	const createStocktakes = `
+CREATE TABLE IF NOT EXISTS stocktakes (
+	id BIGSERIAL PRIMARY KEY,
+	restaurant_id INT NOT NULL REFERENCES restaurants(id),
+	status TEXT NOT NULL CHECK (status IN ('open', 'posted', 'cancelled')),
+	note TEXT NOT NULL DEFAULT '',
+	opened_by INT REFERENCES users(id),
+	opened_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	closed_by INT REFERENCES users(id),
+	closed_at TIMESTAMPTZ
+);`
+
+	if _, err := db.Exec(createStocktakes); err != nil {
+		return fmt.Errorf("create stocktakes table: %w", err)
+	}
+
+	const createOpenStocktakeIndex = `
+CREATE UNIQUE INDEX IF NOT EXISTS idx_stocktakes_open ON stocktakes (restaurant_id) WHERE status = 'open';`
+
+	if _, err := db.Exec(createOpenStocktakeIndex); err != nil {
+		return fmt.Errorf("create stocktakes open index: %w", err)
+	}
+
+	const createStocktakesIndex = `
+CREATE INDEX IF NOT EXISTS idx_stocktakes_restaurant_opened ON stocktakes (restaurant_id, opened_at);`
+
+	if _, err := db.Exec(createStocktakesIndex); err != nil {
+		return fmt.Errorf("create stocktakes index: %w", err)
+	}
+
+	const createStocktakeLines = `
+CREATE TABLE IF NOT EXISTS stocktake_lines (
+	stocktake_id BIGINT NOT NULL REFERENCES stocktakes(id),
+	ingredient_id INT NOT NULL REFERENCES ingredients(id),
+	expected NUMERIC(14, 3) NOT NULL,
+	adjustment NUMERIC(14, 3),
+	PRIMARY KEY (stocktake_id, ingredient_id)
+);`
+
+	if _, err := db.Exec(createStocktakeLines); err != nil {
+		return fmt.Errorf("create stocktake_lines table: %w", err)
+	}
+
+	const createStocktakeCounts = `
+CREATE TABLE IF NOT EXISTS stocktake_counts (
+	stocktake_id BIGINT NOT NULL,
+	ingredient_id INT NOT NULL,
+	device TEXT NOT NULL,
+	quantity NUMERIC(14, 3) NOT NULL CHECK (quantity >= 0),
+	counted_by INT REFERENCES users(id),
+	counted_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	PRIMARY KEY (stocktake_id, ingredient_id, device),
+	FOREIGN KEY (stocktake_id, ingredient_id) REFERENCES stocktake_lines(stocktake_id, ingredient_id)
+);`
+
+	if _, err := db.Exec(createStocktakeCounts); err != nil {
+		return fmt.Errorf("create stocktake_counts table: %w", err)
+	}
+
+	const ensureStockMovementStocktakeColumn = `
+ALTER TABLE stock_movements
+	ADD COLUMN IF NOT EXISTS stocktake_id BIGINT REFERENCES stocktakes(id);`
+
+	if _, err := db.Exec(ensureStockMovementStocktakeColumn); err != nil {
+		return fmt.Errorf("ensure stock_movements.stocktake_id column: %w", err)
+	}
+
+	const createParLevels = `
+CREATE TABLE IF NOT EXISTS par_levels (
+	restaurant_id INT NOT NULL REFERENCES restaurants(id),
+	ingredient_id INT NOT NULL REFERENCES ingredients(id),
+	par NUMERIC(14, 3) NOT NULL CHECK (par > 0),
+	reorder_point NUMERIC(14, 3) NOT NULL CHECK (reorder_point >= 0),
+	updated_by INT REFERENCES users(id),
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	PRIMARY KEY (restaurant_id, ingredient_id),
+	CHECK (reorder_point <= par)
+);`
+
+	if _, err := db.Exec(createParLevels); err != nil {
+		return fmt.Errorf("create par_levels table: %w", err)
+	}
+
+	// Rate limit buckets are disposable state shared between replicas, so
+	// the table skips the write-ahead log.
+	const createRateLimitBuckets = `
+CREATE UNLOGGED TABLE IF NOT EXISTS rate_limit_buckets (
+	key TEXT PRIMARY KEY,
+	tokens DOUBLE PRECISION NOT NULL,
+	allowed BOOLEAN NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);`
+
+	if _, err := db.Exec(createRateLimitBuckets); err != nil {
+		return fmt.Errorf("create rate_limit_buckets table: %w", err)
+	}
+
+	const createSchemaVersion = `
+CREATE TABLE IF NOT EXISTS schema_version (
+	id BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+	version INT NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);`
+
+	if _, err := db.Exec(createSchemaVersion); err != nil {
+		return fmt.Errorf("create schema_version table: %w", err)
+	}
+
+	const recordSchemaVersion = `
+INSERT INTO schema_version (id, version) VALUES (TRUE, $1)
+ON CONFLICT (id) DO UPDATE SET version = EXCLUDED.version, updated_at = NOW()
+WHERE schema_version.version < EXCLUDED.version;`
+
+	if _, err := db.Exec(recordSchemaVersion, SchemaVersion); err != nil {
+		return fmt.Errorf("record schema version: %w", err)
+	}
+
+	return nil
+}
+
+// CheckSchemaVersion fails unless the database schema is at SchemaVersion.
+// A newer schema is accepted while an older replica is still draining.
+func CheckSchemaVersion(ctx context.Context, db *sql.DB) error {
+	var version int
+	err := db.QueryRowContext(ctx, `SELECT version FROM schema_version`).Scan(&version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errors.New("schema version not recorded")
+	}
+	if err != nil {
+		return fmt.Errorf("query schema version: %w", err)
+	}
+
+	if version < SchemaVersion {
+		return fmt.Errorf("schema version %d, want %d", version, SchemaVersion)
+	}
+
 	return nil
 }
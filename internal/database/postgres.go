@@ -1,33 +1,111 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
+	"strconv"
+	"time"
 
-	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+
+	"mmispoc/internal/logging"
+)
+
+// Connect retry bounds. The delay doubles after every failed attempt.
+const (
+	initialRetryDelay = 250 * time.Millisecond
+	maxRetryDelay     = 5 * time.Second
 )
 
 // PostgresConfig describes the connection settings for a PostgreSQL database.
+// Zero values leave the database/sql or server default in place.
 type PostgresConfig struct {
 	URL string
+
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+
+	// StatementTimeout aborts any statement running longer than this on the server.
+	StatementTimeout time.Duration
+	// ApplicationName is reported in pg_stat_activity.
+	ApplicationName string
+
+	// ConnectTimeout bounds how long OpenPostgres keeps retrying the initial
+	// connection, e.g. while the database container is still starting.
+	ConnectTimeout time.Duration
 }
 
-// OpenPostgres returns a ready to use PostgreSQL handle.
-func OpenPostgres(cfg PostgresConfig) (*sql.DB, error) {
+// OpenPostgres returns a ready to use PostgreSQL handle. It retries the first
+// connection with exponential backoff until it succeeds, ctx is cancelled or
+// ConnectTimeout elapses.
+func OpenPostgres(ctx context.Context, cfg PostgresConfig) (*sql.DB, error) {
 	if cfg.URL == "" {
 		return nil, errors.New("database url must not be empty")
 	}
 
-	db, err := sql.Open("pgx", cfg.URL)
+	connConfig, err := pgx.ParseConfig(cfg.URL)
 	if err != nil {
-		return nil, fmt.Errorf("open postgres: %w", err)
+		return nil, fmt.Errorf("parse database url: %w", err)
+	}
+	if cfg.ApplicationName != "" {
+		connConfig.RuntimeParams["application_name"] = cfg.ApplicationName
+	}
+	if cfg.StatementTimeout > 0 {
+		connConfig.RuntimeParams["statement_timeout"] = strconv.FormatInt(cfg.StatementTimeout.Milliseconds(), 10)
+	}
+	connConfig.Tracer = queryTracer{}
+
+	db := stdlib.OpenDB(*connConfig)
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	if cfg.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(cfg.MaxIdleConns)
+	}
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+
+	if cfg.ConnectTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.ConnectTimeout)
+		defer cancel()
 	}
 
-	if err := db.Ping(); err != nil {
+	if err := pingWithRetry(ctx, db); err != nil {
 		db.Close()
-		return nil, fmt.Errorf("ping postgres: %w", err)
+		return nil, err
 	}
 
 	return db, nil
 }
+
+func pingWithRetry(ctx context.Context, db *sql.DB) error {
+	delay := initialRetryDelay
+	for attempt := 1; ; attempt++ {
+		err := db.PingContext(ctx)
+		if err == nil {
+			return nil
+		}
+
+		if ctx.Err() != nil {
+			return fmt.Errorf("ping postgres after %d attempts: %w", attempt, err)
+		}
+		logging.FromContext(ctx).Warn("postgres not ready, retrying", "attempt", attempt, "retry_in", delay.String(), "error", err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("ping postgres after %d attempts: %w", attempt, err)
+		case <-timer.C:
+		}
+
+		delay *= 2
+		if delay > maxRetryDelay {
+			delay = maxRetryDelay
+		}
+	}
+}
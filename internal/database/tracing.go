@@ -0,0 +1,91 @@
+package database
+
+import (
+	"context"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"mmispoc/internal/tracing"
+)
+
+const maxTracedStatementLength = 2048
+
+// queryTracer opens a client span for every statement pgx executes. Queries
+// are parameterised throughout the repositories, so the statement text never
+// contains user supplied values; arguments are not recorded.
+type queryTracer struct{}
+
+func (queryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	operation, table := statementName(data.SQL)
+	name := operation
+	if table != "" {
+		name += " " + table
+	}
+
+	statement := strings.Join(strings.Fields(data.SQL), " ")
+	if len(statement) > maxTracedStatementLength {
+		statement = statement[:maxTracedStatementLength]
+	}
+
+	ctx, _ = tracing.Tracer().Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "postgresql"),
+			attribute.String("db.operation.name", operation),
+			attribute.String("db.collection.name", table),
+			attribute.String("db.query.text", statement),
+		),
+	)
+	return ctx
+}
+
+func (queryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	span := trace.SpanFromContext(ctx)
+	if data.Err == nil {
+		span.SetAttributes(attribute.Int64("db.response.rows", data.CommandTag.RowsAffected()))
+	}
+	tracing.End(span, data.Err)
+}
+
+// statementName derives a low-cardinality span name such as "SELECT orders"
+// from a statement: the leading keyword and the first table it touches.
+func statementName(sql string) (operation, table string) {
+	words := strings.Fields(sql)
+	if len(words) == 0 {
+		return "QUERY", ""
+	}
+
+	operation = strings.ToUpper(words[0])
+	if operation == "WITH" {
+		operation = "QUERY"
+	}
+
+	var marker string
+	switch operation {
+	case "SELECT", "DELETE":
+		marker = "FROM"
+	case "INSERT":
+		marker = "INTO"
+	case "UPDATE":
+		return operation, identifier(words, 1)
+	default:
+		return operation, ""
+	}
+
+	for i, word := range words {
+		if strings.EqualFold(word, marker) {
+			return operation, identifier(words, i+1)
+		}
+	}
+	return operation, ""
+}
+
+func identifier(words []string, i int) string {
+	if i >= len(words) {
+		return ""
+	}
+	return strings.Trim(words[i], `"(),;`)
+}
@@ -0,0 +1,186 @@
+// Package forecast predicts daily demand from a history of daily quantities
+// with simple statistical models that run in process, and backtests them
+// against the most recent days of that history.
+package forecast
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Model names.
+const (
+	MovingAverageModel = "moving_average"
+	EWMAModel          = "ewma"
+	DayOfWeekModel     = "day_of_week"
+)
+
+// Series is a run of daily quantities, one per day starting at Start. Days
+// without demand are zero.
+type Series struct {
+	Start  time.Time
+	Values []float64
+}
+
+// End returns the day after the last day of the series.
+func (s Series) End() time.Time {
+	return s.Start.AddDate(0, 0, len(s.Values))
+}
+
+// Model forecasts the days following a series.
+type Model interface {
+	Name() string
+	// Forecast returns one value per day for horizon days after history.
+	Forecast(history Series, horizon int) []float64
+}
+
+// MovingAverage forecasts the mean of the last Window days for every day ahead.
+type MovingAverage struct {
+	Window int
+}
+
+// Name implements Model.
+func (m MovingAverage) Name() string { return MovingAverageModel }
+
+// Forecast implements Model.
+func (m MovingAverage) Forecast(history Series, horizon int) []float64 {
+	values := history.Values
+	if m.Window > 0 && len(values) > m.Window {
+		values = values[len(values)-m.Window:]
+	}
+	return flat(mean(values), horizon)
+}
+
+// EWMA forecasts an exponentially weighted moving average of the history for
+// every day ahead. Alpha in (0, 1] is the weight of the most recent day.
+type EWMA struct {
+	Alpha float64
+}
+
+// Name implements Model.
+func (m EWMA) Name() string { return EWMAModel }
+
+// Forecast implements Model.
+func (m EWMA) Forecast(history Series, horizon int) []float64 {
+	if len(history.Values) == 0 {
+		return flat(0, horizon)
+	}
+	level := history.Values[0]
+	for _, value := range history.Values[1:] {
+		level = m.Alpha*value + (1-m.Alpha)*level
+	}
+	return flat(level, horizon)
+}
+
+// DayOfWeek forecasts each day as the mean of the same weekday over the last
+// Weeks weeks, so a restaurant ordering on Mondays and Thursdays is
+// forecast to keep doing so. Weekdays missing from the history fall back to
+// the mean of the whole history.
+type DayOfWeek struct {
+	Weeks int
+}
+
+// Name implements Model.
+func (m DayOfWeek) Name() string { return DayOfWeekModel }
+
+// Forecast implements Model.
+func (m DayOfWeek) Forecast(history Series, horizon int) []float64 {
+	values, start := history.Values, history.Start
+	if m.Weeks > 0 && len(values) > 7*m.Weeks {
+		skip := len(values) - 7*m.Weeks
+		values, start = values[skip:], start.AddDate(0, 0, skip)
+	}
+
+	var (
+		sums   [7]float64
+		counts [7]int
+	)
+	for i, value := range values {
+		weekday := start.AddDate(0, 0, i).Weekday()
+		sums[weekday] += value
+		counts[weekday]++
+	}
+
+	fallback := mean(history.Values)
+	end := history.End()
+	forecast := make([]float64, horizon)
+	for i := range forecast {
+		weekday := end.AddDate(0, 0, i).Weekday()
+		if counts[weekday] == 0 {
+			forecast[i] = fallback
+			continue
+		}
+		forecast[i] = sums[weekday] / float64(counts[weekday])
+	}
+	return forecast
+}
+
+// Metrics measure how far a forecast was from what happened.
+type Metrics struct {
+	Days int
+	// MAE is the mean absolute error and RMSE the root mean squared error,
+	// both in units per day.
+	MAE  float64
+	RMSE float64
+	// WAPE is the absolute error as a share of the actual total; it is
+	// undefined, and reported as NaN, when nothing was ordered.
+	WAPE float64
+	// Bias is the mean of forecast minus actual; positive values mean the
+	// model over-forecasts.
+	Bias float64
+}
+
+// Backtest forecasts the last holdout days of history from the days before
+// them and measures the error.
+func Backtest(model Model, history Series, holdout int) (Metrics, error) {
+	if holdout <= 0 || holdout >= len(history.Values) {
+		return Metrics{}, fmt.Errorf("holdout of %d days needs a longer history than %d days", holdout, len(history.Values))
+	}
+
+	split := len(history.Values) - holdout
+	train := Series{Start: history.Start, Values: history.Values[:split]}
+	actual := history.Values[split:]
+	predicted := model.Forecast(train, holdout)
+
+	var absolute, squared, bias, total float64
+	for i, want := range actual {
+		diff := predicted[i] - want
+		absolute += math.Abs(diff)
+		squared += diff * diff
+		bias += diff
+		total += want
+	}
+
+	days := float64(holdout)
+	metrics := Metrics{
+		Days: holdout,
+		MAE:  absolute / days,
+		RMSE: math.Sqrt(squared / days),
+		WAPE: math.NaN(),
+		Bias: bias / days,
+	}
+	if total > 0 {
+		metrics.WAPE = absolute / total
+	}
+	return metrics, nil
+}
+
+func mean(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, value := range values {
+		sum += value
+	}
+	return sum / float64(len(values))
+}
+
+func flat(value float64, horizon int) []float64 {
+	forecast := make([]float64, horizon)
+	for i := range forecast {
+		forecast[i] = value
+	}
+	return forecast
+}
@@ -0,0 +1,204 @@
+package forecast
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// monday is the first day of a week, so offsets from it name weekdays.
+var monday = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+func day(offset int) time.Time {
+	return monday.AddDate(0, 0, offset)
+}
+
+func closeTo(got, want float64) bool {
+	return math.Abs(got-want) < 1e-9
+}
+
+func assertForecast(t *testing.T, got, want []float64) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("forecast = %v, want %v", got, want)
+	}
+	for i := range want {
+		if !closeTo(got[i], want[i]) {
+			t.Fatalf("forecast = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestMovingAverage(t *testing.T) {
+	tests := []struct {
+		name    string
+		window  int
+		values  []float64
+		horizon int
+		want    []float64
+	}{
+		{name: "window trims to the last days", window: 3, values: []float64{1, 2, 3, 4, 5}, horizon: 2, want: []float64{4, 4}},
+		{name: "window longer than history", window: 10, values: []float64{2, 4}, horizon: 1, want: []float64{3}},
+		{name: "zero window uses everything", values: []float64{1, 2, 3, 4, 5}, horizon: 1, want: []float64{3}},
+		{name: "empty history", window: 3, horizon: 2, want: []float64{0, 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := MovingAverage{Window: tt.window}.Forecast(Series{Start: monday, Values: tt.values}, tt.horizon)
+			assertForecast(t, got, tt.want)
+		})
+	}
+}
+
+func TestEWMA(t *testing.T) {
+	tests := []struct {
+		name    string
+		alpha   float64
+		values  []float64
+		horizon int
+		want    []float64
+	}{
+		// 10, then 0.5*20 + 0.5*10 = 15, then 0.5*30 + 0.5*15 = 22.5.
+		{name: "half weight", alpha: 0.5, values: []float64{10, 20, 30}, horizon: 2, want: []float64{22.5, 22.5}},
+		// 0.25*4 + 0.75*8 = 7.
+		{name: "quarter weight", alpha: 0.25, values: []float64{8, 4}, horizon: 1, want: []float64{7}},
+		{name: "full weight keeps the last day", alpha: 1, values: []float64{4, 8}, horizon: 1, want: []float64{8}},
+		{name: "single day", alpha: 0.5, values: []float64{6}, horizon: 1, want: []float64{6}},
+		{name: "empty history", alpha: 0.5, horizon: 2, want: []float64{0, 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := EWMA{Alpha: tt.alpha}.Forecast(Series{Start: monday, Values: tt.values}, tt.horizon)
+			assertForecast(t, got, tt.want)
+		})
+	}
+}
+
+func TestDayOfWeek(t *testing.T) {
+	tests := []struct {
+		name    string
+		weeks   int
+		history Series
+		horizon int
+		want    []float64
+	}{
+		{
+			// Monday 10 and 20, Thursday 6 and 8, nothing on other days.
+			name:    "averages each weekday",
+			weeks:   2,
+			history: Series{Start: monday, Values: []float64{10, 0, 0, 6, 0, 0, 0, 20, 0, 0, 8, 0, 0, 0}},
+			horizon: 7,
+			want:    []float64{15, 0, 0, 7, 0, 0, 0},
+		},
+		{
+			// Wednesday 1 and 8, Thursday 2 and 9, Friday 3 ... Tuesday 7.
+			// The history ends on a Friday, so the forecast starts on one
+			// rather than on the Wednesday the history started on.
+			name:    "forecast aligned to the end of the history",
+			history: Series{Start: day(2), Values: []float64{1, 2, 3, 4, 5, 6, 7, 8, 9}},
+			horizon: 7,
+			want:    []float64{3, 4, 5, 6, 7, 4.5, 5.5},
+		},
+		{
+			// One week keeps Friday 3 through Thursday 9 and drops the first
+			// Wednesday and Thursday.
+			name:    "weeks trim the history",
+			weeks:   1,
+			history: Series{Start: day(2), Values: []float64{1, 2, 3, 4, 5, 6, 7, 8, 9}},
+			horizon: 7,
+			want:    []float64{3, 4, 5, 6, 7, 8, 9},
+		},
+		{
+			// Monday 3, Tuesday 6, Wednesday 9; other weekdays fall back to
+			// the mean of 6.
+			name:    "missing weekdays fall back to the mean",
+			weeks:   4,
+			history: Series{Start: monday, Values: []float64{3, 6, 9}},
+			horizon: 6,
+			want:    []float64{6, 6, 6, 6, 3, 6},
+		},
+		{
+			name:    "empty history",
+			weeks:   4,
+			history: Series{Start: monday},
+			horizon: 2,
+			want:    []float64{0, 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DayOfWeek{Weeks: tt.weeks}.Forecast(tt.history, tt.horizon)
+			assertForecast(t, got, tt.want)
+		})
+	}
+}
+
+func TestBacktest(t *testing.T) {
+	tests := []struct {
+		name    string
+		model   Model
+		values  []float64
+		holdout int
+		want    Metrics
+	}{
+		{
+			// Trained on 2, 4, 6, 8 the forecast is 7 against 4 and 10.
+			name:    "errors cancel out in the bias",
+			model:   MovingAverage{Window: 2},
+			values:  []float64{2, 4, 6, 8, 4, 10},
+			holdout: 2,
+			want:    Metrics{Days: 2, MAE: 3, RMSE: 3, WAPE: 6.0 / 14, Bias: 0},
+		},
+		{
+			// The forecast is 5 against 2 and 4: errors of 3 and 1.
+			name:    "over-forecast",
+			model:   EWMA{Alpha: 1},
+			values:  []float64{5, 5, 2, 4},
+			holdout: 2,
+			want:    Metrics{Days: 2, MAE: 2, RMSE: math.Sqrt(5), WAPE: 4.0 / 6, Bias: 2},
+		},
+		{
+			name:    "nothing ordered leaves WAPE undefined",
+			model:   MovingAverage{},
+			values:  []float64{3, 3, 0, 0},
+			holdout: 2,
+			want:    Metrics{Days: 2, MAE: 3, RMSE: 3, WAPE: math.NaN(), Bias: 3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Backtest(tt.model, Series{Start: monday, Values: tt.values}, tt.holdout)
+			if err != nil {
+				t.Fatalf("Backtest: %v", err)
+			}
+			if got.Days != tt.want.Days {
+				t.Errorf("Days = %d, want %d", got.Days, tt.want.Days)
+			}
+			if !closeTo(got.MAE, tt.want.MAE) {
+				t.Errorf("MAE = %v, want %v", got.MAE, tt.want.MAE)
+			}
+			if !closeTo(got.RMSE, tt.want.RMSE) {
+				t.Errorf("RMSE = %v, want %v", got.RMSE, tt.want.RMSE)
+			}
+			if math.IsNaN(got.WAPE) != math.IsNaN(tt.want.WAPE) || (!math.IsNaN(got.WAPE) && !closeTo(got.WAPE, tt.want.WAPE)) {
+				t.Errorf("WAPE = %v, want %v", got.WAPE, tt.want.WAPE)
+			}
+			if !closeTo(got.Bias, tt.want.Bias) {
+				t.Errorf("Bias = %v, want %v", got.Bias, tt.want.Bias)
+			}
+		})
+	}
+}
+
+func TestBacktestRejectsHoldout(t *testing.T) {
+	history := Series{Start: monday, Values: []float64{1, 2, 3}}
+	for _, holdout := range []int{0, -1, 3, 4} {
+		if _, err := Backtest(MovingAverage{}, history, holdout); err == nil {
+			t.Errorf("Backtest accepted a holdout of %d days on %d days of history", holdout, len(history.Values))
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const defaultCheckTimeout = 2 * time.Second
+
+// Checker reports whether a dependency is usable.
+type Checker interface {
+	CheckHealth(ctx context.Context) error
+}
+
+// CheckFunc adapts a function to the Checker interface.
+type CheckFunc func(ctx context.Context) error
+
+// CheckHealth calls f(ctx).
+func (f CheckFunc) CheckHealth(ctx context.Context) error {
+	return f(ctx)
+}
+
+// Result is the outcome of a single named check.
+type Result struct {
+	Name  string
+	Error error
+}
+
+// Report summarises a readiness evaluation.
+type Report struct {
+	Ready        bool
+	ShuttingDown bool
+	Results      []Result
+}
+
+// Registry holds the readiness checks of the process. Checks run concurrently,
+// each bounded by the registry timeout.
+type Registry struct {
+	timeout      time.Duration
+	mu           sync.RWMutex
+	names        []string
+	checks       map[string]Checker
+	shuttingDown atomic.Bool
+}
+
+// NewRegistry constructs an empty registry. A non-positive timeout selects the default.
+func NewRegistry(timeout time.Duration) *Registry {
+	if timeout <= 0 {
+		timeout = defaultCheckTimeout
+	}
+	return &Registry{
+		timeout: timeout,
+		checks:  make(map[string]Checker),
+	}
+}
+
+// Register adds or replaces the check with the given name.
+func (r *Registry) Register(name string, check Checker) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.checks[name]; !exists {
+		r.names = append(r.names, name)
+	}
+	r.checks[name] = check
+}
+
+// SetShuttingDown makes every following readiness evaluation fail so load
+// balancers stop routing new traffic to the process.
+func (r *Registry) SetShuttingDown() {
+	r.shuttingDown.Store(true)
+}
+
+// ShuttingDown reports whether SetShuttingDown was called.
+func (r *Registry) ShuttingDown() bool {
+	return r.shuttingDown.Load()
+}
+
+// Ready runs all registered checks and reports whether the process can serve traffic.
+func (r *Registry) Ready(ctx context.Context) Report {
+	if r.ShuttingDown() {
+		return Report{ShuttingDown: true}
+	}
+
+	r.mu.RLock()
+	names := append([]string(nil), r.names...)
+	checks := make([]Checker, len(names))
+	for i, name := range names {
+		checks[i] = r.checks[name]
+	}
+	r.mu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	results := make([]Result, len(names))
+	var wg sync.WaitGroup
+	for i := range names {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = Result{Name: names[i], Error: checks[i].CheckHealth(ctx)}
+		}(i)
+	}
+	wg.Wait()
+
+	report := Report{Ready: true, Results: results}
+	for _, result := range results {
+		if result.Error != nil {
+			report.Ready = false
+		}
+	}
+
+	return report
+}
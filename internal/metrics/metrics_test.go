@@ -0,0 +1,108 @@
+package metrics
+
+import (
+	"bytes"
+	"flag"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files")
+
+func TestRegistryExposition(t *testing.T) {
+	r := NewRegistry()
+
+	// Registered out of name order; the output is sorted.
+	requests := r.NewCounterVec("app_requests_total", "Requests served.", "method", "path")
+	requests.Inc("GET", `/a"b`)
+	requests.Add(2, "POST", "c:\\d\nnext")
+	requests.Add(1500000, "PUT", "/big")
+
+	r.NewCounterVec("app_errors_total", "Errors seen.")
+
+	// Buckets are given unsorted; observations on a bound count in it.
+	latency := r.NewHistogramVec("app_latency_seconds", "Request latency in seconds.", []float64{1, 0.1, 0.5}, "route")
+	latency.Observe(0.0625, "/x")
+	latency.Observe(0.25, "/x")
+	latency.Observe(2, "/x")
+	latency.Observe(0.5, "/a")
+
+	r.GaugeFunc("app_queue_depth", "Jobs waiting.\nRead from C:\\queue at scrape time.", func() float64 { return 3.5 })
+	r.GaugeFunc("app_ratio", "Share of jobs that failed.", math.NaN)
+	r.CounterFunc("app_cache_evictions_total", "Entries evicted from the cache.", func() float64 { return 7 })
+
+	var buf bytes.Buffer
+	n, err := r.WriteTo(&buf)
+	if err != nil {
+		t.Fatalf("WriteTo: %v", err)
+	}
+	if n != int64(buf.Len()) {
+		t.Errorf("WriteTo reported %d bytes, wrote %d", n, buf.Len())
+	}
+
+	golden := filepath.Join("testdata", "exposition.golden")
+	if *update {
+		if err := os.WriteFile(golden, buf.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("exposition mismatch\n--- got\n%s\n--- want\n%s", buf.Bytes(), want)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounterVec("app_errors_total", "Errors seen.")
+
+	rec := httptest.NewRecorder()
+	r.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if got := rec.Header().Get("Content-Type"); got != "text/plain; version=0.0.4; charset=utf-8" {
+		t.Errorf("Content-Type = %q", got)
+	}
+	if !bytes.Contains(rec.Body.Bytes(), []byte("app_errors_total 0\n")) {
+		t.Errorf("body = %q", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	r.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/metrics", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST status = %d, want %d", rec.Code, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestMisuse(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   func(r *Registry)
+	}{
+		{"duplicate name", func(r *Registry) {
+			r.NewCounterVec("dup_total", "")
+			r.GaugeFunc("dup_total", "", func() float64 { return 0 })
+		}},
+		{"wrong label count", func(r *Registry) {
+			r.NewCounterVec("labelled_total", "", "a", "b").Inc("only one")
+		}},
+		{"negative counter increment", func(r *Registry) {
+			r.NewCounterVec("negative_total", "").Add(-1)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Fatal("expected a panic")
+				}
+			}()
+			tt.fn(NewRegistry())
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// pruneInterval is how often idle buckets are dropped from a MemoryStore.
+const pruneInterval = time.Minute
+
+// MemoryStore keeps buckets in process. Limits are per replica.
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastPrune time.Time
+	now       func() time.Time
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	// full is when the bucket will have refilled completely.
+	full time.Time
+}
+
+// NewMemoryStore constructs an empty in-memory store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]*bucket), now: time.Now}
+}
+
+// Take implements Store.
+func (s *MemoryStore) Take(_ context.Context, key string, limit Limit) (Decision, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if now.Sub(s.lastPrune) >= pruneInterval {
+		s.prune(now)
+	}
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Count), updated: now}
+		s.buckets[key] = b
+	}
+
+	elapsed := now.Sub(b.updated).Seconds()
+	if elapsed > 0 {
+		b.tokens += elapsed * limit.rate()
+		if b.tokens > float64(limit.Count) {
+			b.tokens = float64(limit.Count)
+		}
+	}
+	b.updated = now
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+
+	d := decide(limit, b.tokens, allowed)
+	b.full = now.Add(d.Reset)
+	return d, nil
+}
+
+// prune drops buckets that have refilled; a missing bucket is a full one.
+func (s *MemoryStore) prune(now time.Time) {
+	for key, b := range s.buckets {
+		if !now.Before(b.full) {
+			delete(s.buckets, key)
+		}
+	}
+	s.lastPrune = now
+}
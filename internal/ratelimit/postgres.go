@@ -0,0 +1,58 @@
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// PostgresStore keeps buckets in the rate_limit_buckets table so every
+// replica enforces the same limit. Each Take is a single atomic upsert that
+// refills the bucket from the database clock and takes a token if one is
+// available.
+type PostgresStore struct {
+	db *sql.DB
+}
+
+// NewPostgresStore wires the store to a sql.DB.
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+// Take implements Store.
+func (s *PostgresStore) Take(ctx context.Context, key string, limit Limit) (Decision, error) {
+	const query = `
+INSERT INTO rate_limit_buckets AS b (key, tokens, allowed, updated_at)
+VALUES ($1, $2::float8 - 1, TRUE, NOW())
+ON CONFLICT (key) DO UPDATE SET
+	tokens = CASE
+		WHEN LEAST($2::float8, b.tokens + GREATEST(EXTRACT(EPOCH FROM NOW() - b.updated_at), 0) * $3::float8) >= 1
+		THEN LEAST($2::float8, b.tokens + GREATEST(EXTRACT(EPOCH FROM NOW() - b.updated_at), 0) * $3::float8) - 1
+		ELSE LEAST($2::float8, b.tokens + GREATEST(EXTRACT(EPOCH FROM NOW() - b.updated_at), 0) * $3::float8)
+	END,
+	allowed = LEAST($2::float8, b.tokens + GREATEST(EXTRACT(EPOCH FROM NOW() - b.updated_at), 0) * $3::float8) >= 1,
+	updated_at = NOW()
+RETURNING tokens, allowed`
+
+	var (
+		tokens  float64
+		allowed bool
+	)
+	if err := s.db.QueryRowContext(ctx, query, key, float64(limit.Count), limit.rate()).Scan(&tokens, &allowed); err != nil {
+		return Decision{}, fmt.Errorf("take rate limit token: %w", err)
+	}
+
+	return decide(limit, tokens, allowed), nil
+}
+
+// Prune deletes buckets untouched for longer than idle. Callers pick an idle
+// time no shorter than the longest configured period, after which a bucket is
+// full and equivalent to a missing row.
+func (s *PostgresStore) Prune(ctx context.Context, idle time.Duration) (int64, error) {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM rate_limit_buckets WHERE updated_at < NOW() - $1::interval`, idle.String())
+	if err != nil {
+		return 0, fmt.Errorf("prune rate limit buckets: %w", err)
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,97 @@
+// Package ratelimit implements token buckets behind a pluggable Store so
+// limits can be kept in process or shared between replicas in PostgreSQL.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit allows Count requests per Period with bursts of up to Count.
+type Limit struct {
+	Count  int
+	Period time.Duration
+}
+
+// ParseLimit parses "<count>/<period>" where period is s, m, h or a Go
+// duration, e.g. "10/m", "100/h" or "5/30s".
+func ParseLimit(s string) (Limit, error) {
+	countPart, periodPart, ok := strings.Cut(strings.TrimSpace(s), "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("invalid rate limit %q: want <count>/<period>", s)
+	}
+
+	count, err := strconv.Atoi(strings.TrimSpace(countPart))
+	if err != nil || count <= 0 {
+		return Limit{}, fmt.Errorf("invalid rate limit %q: count must be a positive integer", s)
+	}
+
+	var period time.Duration
+	switch periodPart = strings.TrimSpace(periodPart); periodPart {
+	case "s":
+		period = time.Second
+	case "m":
+		period = time.Minute
+	case "h":
+		period = time.Hour
+	default:
+		period, err = time.ParseDuration(periodPart)
+		if err != nil || period <= 0 {
+			return Limit{}, fmt.Errorf("invalid rate limit %q: bad period", s)
+		}
+	}
+
+	return Limit{Count: count, Period: period}, nil
+}
+
+// String formats the limit in the form accepted by ParseLimit.
+func (l Limit) String() string {
+	return strconv.Itoa(l.Count) + "/" + l.Period.String()
+}
+
+// rate is the refill speed in tokens per second.
+func (l Limit) rate() float64 {
+	return float64(l.Count) / l.Period.Seconds()
+}
+
+// Decision is the outcome of taking a token.
+type Decision struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is the time until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is the time until the next token is available when denied.
+	RetryAfter time.Duration
+}
+
+// Store takes tokens from buckets identified by key.
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit) (Decision, error)
+}
+
+// decide builds the decision for a bucket holding tokens after the request.
+func decide(limit Limit, tokens float64, allowed bool) Decision {
+	rate := limit.rate()
+	d := Decision{
+		Allowed:   allowed,
+		Limit:     limit.Count,
+		Remaining: int(math.Max(0, math.Floor(tokens))),
+		Reset:     seconds((float64(limit.Count) - tokens) / rate),
+	}
+	if !allowed {
+		d.RetryAfter = seconds((1 - tokens) / rate)
+	}
+	return d
+}
+
+func seconds(s float64) time.Duration {
+	if s <= 0 {
+		return 0
+	}
+	return time.Duration(math.Ceil(s * float64(time.Second)))
+}
@@ -0,0 +1,190 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// APIKey represents the api_keys table row.
+type APIKey struct {
+	ID           int64
+	RestaurantID int64
+	Prefix       string
+	SecretHash   string
+	Scopes       []string
+	Description  string
+	CreatedBy    int64
+	ExpiresAt    time.Time
+	LastUsedAt   time.Time
+	RevokedAt    time.Time
+	CreatedAt    time.Time
+}
+
+// APIKeyRepository persists integration API keys.
+type APIKeyRepository struct {
+	db *sql.DB
+}
+
+// NewAPIKey wires the repository to a sql.DB.
+func NewAPIKey(db *sql.DB) *APIKeyRepository {
+	return &APIKeyRepository{db: db}
+}
+
+const apiKeyColumns = `id, restaurant_id, prefix, secret_hash, scopes, description, COALESCE(created_by, 0), expires_at, last_used_at, revoked_at, created_at`
+
+// Create inserts a new API key and fills in the generated fields.
+func (r *APIKeyRepository) Create(ctx context.Context, key *APIKey) error {
+	const query = `
+INSERT INTO api_keys (restaurant_id, prefix, secret_hash, scopes, description, created_by, expires_at)
+VALUES ($1, $2, $3, $4, $5, NULLIF($6, 0), $7)
+RETURNING id, created_at`
+
+	var expiresAt sql.NullTime
+	if !key.ExpiresAt.IsZero() {
+		expiresAt = sql.NullTime{Time: key.ExpiresAt, Valid: true}
+	}
+
+	err := r.db.QueryRowContext(ctx, query,
+		key.RestaurantID,
+		key.Prefix,
+		key.SecretHash,
+		strings.Join(key.Scopes, ","),
+		key.Description,
+		key.CreatedBy,
+		expiresAt,
+	).Scan(&key.ID, &key.CreatedAt)
+	if err != nil {
+		if isConstraintViolation(err) {
+			return ErrConflict
+		}
+		return fmt.Errorf("insert api key: %w", err)
+	}
+
+	key.CreatedAt = key.CreatedAt.UTC()
+	return nil
+}
+
+// GetByPrefix fetches an API key by its public prefix.
+func (r *APIKeyRepository) GetByPrefix(ctx context.Context, prefix string) (*APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE prefix = $1`
+
+	key, err := scanAPIKey(r.db.QueryRowContext(ctx, query, prefix))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, sql.ErrNoRows
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get api key: %w", err)
+	}
+
+	return key, nil
+}
+
+// ListByRestaurant returns every API key issued for a restaurant, newest first.
+func (r *APIKeyRepository) ListByRestaurant(ctx context.Context, restaurantID int64) ([]APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE restaurant_id = $1 ORDER BY id DESC`
+
+	rows, err := r.db.QueryContext(ctx, query, restaurantID)
+	if err != nil {
+		return nil, fmt.Errorf("query api keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []APIKey
+	for rows.Next() {
+		key, scanErr := scanAPIKey(rows)
+		if scanErr != nil {
+			return nil, fmt.Errorf("scan api key: %w", scanErr)
+		}
+		keys = append(keys, *key)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate api keys: %w", err)
+	}
+
+	return keys, nil
+}
+
+// Revoke marks an active key of the restaurant as revoked and reports whether one was found.
+func (r *APIKeyRepository) Revoke(ctx context.Context, restaurantID, id int64) (bool, error) {
+	const query = `
+UPDATE api_keys
+SET revoked_at = NOW()
+WHERE id = $1 AND restaurant_id = $2 AND revoked_at IS NULL`
+
+	result, err := r.db.ExecContext(ctx, query, id, restaurantID)
+	if err != nil {
+		return false, fmt.Errorf("revoke api key: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("revoke api key: %w", err)
+	}
+
+	return affected > 0, nil
+}
+
+// TouchLastUsed records usage of a key. Writes are throttled to one per minute
+// so busy integrations do not turn every request into an UPDATE.
+func (r *APIKeyRepository) TouchLastUsed(ctx context.Context, id int64) error {
+	const query = `
+UPDATE api_keys
+SET last_used_at = NOW()
+WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute')`
+
+	if _, err := r.db.ExecContext(ctx, query, id); err != nil {
+		return fmt.Errorf("touch api key: %w", err)
+	}
+
+	return nil
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanAPIKey(row rowScanner) (*APIKey, error) {
+	var (
+		key        APIKey
+		scopes     string
+		expiresAt  sql.NullTime
+		lastUsedAt sql.NullTime
+		revokedAt  sql.NullTime
+	)
+	if err := row.Scan(
+		&key.ID,
+		&key.RestaurantID,
+		&key.Prefix,
+		&key.SecretHash,
+		&scopes,
+		&key.Description,
+		&key.CreatedBy,
+		&expiresAt,
+		&lastUsedAt,
+		&revokedAt,
+		&key.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	if scopes != "" {
+		key.Scopes = strings.Split(scopes, ",")
+	}
+	key.CreatedAt = key.CreatedAt.UTC()
+	if expiresAt.Valid {
+		key.ExpiresAt = expiresAt.Time.UTC()
+	}
+	if lastUsedAt.Valid {
+		key.LastUsedAt = lastUsedAt.Time.UTC()
+	}
+	if revokedAt.Valid {
+		key.RevokedAt = revokedAt.Time.UTC()
+	}
+
+	return &key, nil
+}
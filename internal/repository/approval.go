@@ -0,0 +1,512 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Approval step statuses. Only the first undecided step of a chain is
+// pending; the steps after it are waiting.
+const (
+	ApprovalWaiting   = "waiting"
+	ApprovalPending   = "pending"
+	ApprovalApproved  = "approved"
+	ApprovalRejected  = "rejected"
+	ApprovalExpired   = "expired"
+	ApprovalCancelled = "cancelled"
+)
+
+// ApprovalRule represents the approval_rules table row. A purchase order
+// matches when it meets every condition that is set.
+type ApprovalRule struct {
+	ID   int64
+	Name string
+	// RestaurantID limits the rule to one restaurant; zero matches all.
+	RestaurantID int64
+	// MinTotal matches purchase orders whose net total is at least this amount.
+	MinTotal decimal.NullDecimal
+	// IngredientType matches purchase orders with a line of this ingredient type.
+	IngredientType string
+	// Steps lists the approver roles in the order they sign off.
+	Steps     []string
+	CreatedBy int64
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Approval represents the purchase_order_approvals table row: one step of a
+// purchase order's approval chain.
+type Approval struct {
+	ID              int64
+	PurchaseOrderID int64
+	Step            int
+	ApproverRole    string
+	Status          string
+	// Reason names the rules or budget that required the step.
+	Reason string
+	// ExpiresAt is set once the step becomes pending. Zero never expires.
+	ExpiresAt time.Time
+	DecidedBy int64
+	DecidedAt time.Time
+	Comment   string
+	CreatedAt time.Time
+}
+
+// PendingApproval is an approval step waiting for a decision together with
+// the purchase order it holds back.
+type PendingApproval struct {
+	Approval      Approval
+	PurchaseOrder PurchaseOrder
+	SupplierName  string
+	// Net is the value of the order's priced lines before tax.
+	Net decimal.Decimal
+}
+
+// ApprovalFilter narrows the pending approvals returned by ListPending.
+// Zero values match everything.
+type ApprovalFilter struct {
+	ApproverRole string
+	RestaurantID int64
+	// ExcludeCreator hides orders placed by this user.
+	ExcludeCreator int64
+}
+
+// ApprovalRepository persists approval rules and purchase order approvals.
+type ApprovalRepository struct {
+	db *sql.DB
+}
+
+// NewApproval wires the repository to a sql.DB.
+func NewApproval(db *sql.DB) *ApprovalRepository {
+	return &ApprovalRepository{db: db}
+}
+
+const approvalRuleColumns = `id, name, COALESCE(restaurant_id, 0), min_total, COALESCE(ingredient_type, ''), steps, COALESCE(created_by, 0), created_at, updated_at`
+
+const approvalColumns = `a.id, a.purchase_order_id, a.step, a.approver_role, a.status, a.reason, a.expires_at, COALESCE(a.decided_by, 0), a.decided_at, a.comment, a.created_at`
+
+// CreateRule inserts an approval rule and fills in the generated fields.
+func (r *ApprovalRepository) CreateRule(ctx context.Context, rule *ApprovalRule) error {
+	const query = `
+INSERT INTO approval_rules (name, restaurant_id, min_total, ingredient_type, steps, created_by)
+VALUES ($1, NULLIF($2, 0), $3, NULLIF($4, ''), $5, NULLIF($6, 0))
+RETURNING id, created_at, updated_at`
+
+	err := r.db.QueryRowContext(ctx, query,
+		rule.Name,
+		rule.RestaurantID,
+		rule.MinTotal,
+		rule.IngredientType,
+		strings.Join(rule.Steps, ","),
+		rule.CreatedBy,
+	).Scan(&rule.ID, &rule.CreatedAt, &rule.UpdatedAt)
+	if err != nil {
+		if isConstraintViolation(err) {
+			return ErrConflict
+		}
+		return fmt.Errorf("insert approval rule: %w", err)
+	}
+
+	rule.CreatedAt = rule.CreatedAt.UTC()
+	rule.UpdatedAt = rule.UpdatedAt.UTC()
+	return nil
+}
+
+// GetRule fetches an active approval rule by identifier.
+func (r *ApprovalRepository) GetRule(ctx context.Context, id int64) (*ApprovalRule, error) {
+	query := `SELECT ` + approvalRuleColumns + ` FROM approval_rules WHERE id = $1 AND deleted_at IS NULL`
+
+	rule, err := scanApprovalRule(r.db.QueryRowContext(ctx, query, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, sql.ErrNoRows
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get approval rule: %w", err)
+	}
+
+	return rule, nil
+}
+
+// ListRules returns every active approval rule ordered by id.
+func (r *ApprovalRepository) ListRules(ctx context.Context) ([]ApprovalRule, error) {
+	query := `SELECT ` + approvalRuleColumns + ` FROM approval_rules WHERE deleted_at IS NULL ORDER BY id`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("query approval rules: %w", err)
+	}
+	defer rows.Close()
+
+	var rules []ApprovalRule
+	for rows.Next() {
+		rule, scanErr := scanApprovalRule(rows)
+		if scanErr != nil {
+			return nil, fmt.Errorf("scan approval rule: %w", scanErr)
+		}
+		rules = append(rules, *rule)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate approval rules: %w", err)
+	}
+
+	return rules, nil
+}
+
+// UpdateRule overwrites an active approval rule. It returns sql.ErrNoRows
+// when no such rule exists. Chains already attached to orders are unchanged.
+func (r *ApprovalRepository) UpdateRule(ctx context.Context, rule *ApprovalRule) error {
+	const query = `
+UPDATE approval_rules
+SET name = $2, restaurant_id = NULLIF($3, 0), min_total = $4, ingredient_type = NULLIF($5, ''), steps = $6, updated_at = NOW()
+WHERE id = $1 AND deleted_at IS NULL
+RETURNING COALESCE(created_by, 0), created_at, updated_at`
+
+	err := r.db.QueryRowContext(ctx, query,
+		rule.ID,
+		rule.Name,
+		rule.RestaurantID,
+		rule.MinTotal,
+		rule.IngredientType,
+		strings.Join(rule.Steps, ","),
+	).Scan(&rule.CreatedBy, &rule.CreatedAt, &rule.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return sql.ErrNoRows
+	}
+	if err != nil {
+		if isConstraintViolation(err) {
+			return ErrConflict
+		}
+		return fmt.Errorf("update approval rule: %w", err)
+	}
+
+	rule.CreatedAt = rule.CreatedAt.UTC()
+	rule.UpdatedAt = rule.UpdatedAt.UTC()
+	return nil
+}
+
+// DeleteRule soft deletes an approval rule and reports whether it existed.
+func (r *ApprovalRepository) DeleteRule(ctx context.Context, id int64) (bool, error) {
+	const query = `UPDATE approval_rules SET deleted_at = NOW() WHERE id = $1 AND deleted_at IS NULL`
+
+	result, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return false, fmt.Errorf("delete approval rule: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("delete approval rule: %w", err)
+	}
+
+	return affected > 0, nil
+}
+
+// ListByPurchaseOrder returns the approval chain of a purchase order in step order.
+func (r *ApprovalRepository) ListByPurchaseOrder(ctx context.Context, purchaseOrderID int64) ([]Approval, error) {
+	query := `SELECT ` + approvalColumns + ` FROM purchase_order_approvals a WHERE a.purchase_order_id = $1 ORDER BY a.step`
+
+	rows, err := r.db.QueryContext(ctx, query, purchaseOrderID)
+	if err != nil {
+		return nil, fmt.Errorf("query approvals: %w", err)
+	}
+	defer rows.Close()
+
+	var approvals []Approval
+	for rows.Next() {
+		approval, scanErr := scanApproval(rows)
+		if scanErr != nil {
+			return nil, fmt.Errorf("scan approval: %w", scanErr)
+		}
+		approvals = append(approvals, *approval)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate approvals: %w", err)
+	}
+
+	return approvals, nil
+}
+
+// ListPending returns the unexpired pending steps matching filter, oldest first.
+func (r *ApprovalRepository) ListPending(ctx context.Context, filter ApprovalFilter) ([]PendingApproval, error) {
+	query := `
+SELECT ` + approvalColumns + `,
+	po.id, po.code, po.restaurant_id, COALESCE(po.supplier_id, 0), po.status, po.expected_delivery_on, COALESCE(po.created_by, 0), po.created_at,
+	COALESCE(s.name, ''),
+	COALESCE((SELECT SUM(ROUND(o.unit_price * o.number, 2)) FROM orders o WHERE o.purchase_order_id = po.id AND o.unit_price IS NOT NULL), 0)
+FROM purchase_order_approvals a
+JOIN purchase_orders po ON po.id = a.purchase_order_id
+LEFT JOIN suppliers s ON s.id = po.supplier_id
+WHERE a.status = 'pending'
+	AND (a.expires_at IS NULL OR a.expires_at > NOW())
+	AND ($1 = '' OR a.approver_role = $1)
+	AND ($2 = 0 OR po.restaurant_id = $2)
+	AND ($3 = 0 OR po.created_by IS DISTINCT FROM $3)
+ORDER BY a.created_at, a.id`
+
+	rows, err := r.db.QueryContext(ctx, query, filter.ApproverRole, filter.RestaurantID, filter.ExcludeCreator)
+	if err != nil {
+		return nil, fmt.Errorf("query pending approvals: %w", err)
+	}
+	defer rows.Close()
+
+	var pending []PendingApproval
+	for rows.Next() {
+		var (
+			item      PendingApproval
+			expiresAt sql.NullTime
+			decidedAt sql.NullTime
+			expected  sql.NullTime
+		)
+		if scanErr := rows.Scan(
+			&item.Approval.ID,
+			&item.Approval.PurchaseOrderID,
+			&item.Approval.Step,
+			&item.Approval.ApproverRole,
+			&item.Approval.Status,
+			&item.Approval.Reason,
+			&expiresAt,
+			&item.Approval.DecidedBy,
+			&decidedAt,
+			&item.Approval.Comment,
+			&item.Approval.CreatedAt,
+			&item.PurchaseOrder.ID,
+			&item.PurchaseOrder.Code,
+			&item.PurchaseOrder.RestaurantID,
+			&item.PurchaseOrder.SupplierID,
+			&item.PurchaseOrder.Status,
+			&expected,
+			&item.PurchaseOrder.CreatedBy,
+			&item.PurchaseOrder.CreatedAt,
+			&item.SupplierName,
+			&item.Net,
+		); scanErr != nil {
+			return nil, fmt.Errorf("scan pending approval: %w", scanErr)
+		}
+
+		item.Approval.CreatedAt = item.Approval.CreatedAt.UTC()
+		if expiresAt.Valid {
+			item.Approval.ExpiresAt = expiresAt.Time.UTC()
+		}
+		if decidedAt.Valid {
+			item.Approval.DecidedAt = decidedAt.Time.UTC()
+		}
+		item.PurchaseOrder.CreatedAt = item.PurchaseOrder.CreatedAt.UTC()
+		if expected.Valid {
+			item.PurchaseOrder.ExpectedDeliveryOn = expected.Time.UTC()
+		}
+
+		pending = append(pending, item)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate pending approvals: %w", err)
+	}
+
+	return pending, nil
+}
+
+// Pending fetches the unexpired pending step of a purchase order. It
+// returns sql.ErrNoRows when the order is not waiting for a decision.
+func (r *ApprovalRepository) Pending(ctx context.Context, purchaseOrderID int64) (*Approval, error) {
+	query := `
+SELECT ` + approvalColumns + `
+FROM purchase_order_approvals a
+WHERE a.purchase_order_id = $1 AND a.status = 'pending' AND (a.expires_at IS NULL OR a.expires_at > NOW())`
+
+	approval, err := scanApproval(r.db.QueryRowContext(ctx, query, purchaseOrderID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, sql.ErrNoRows
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get pending approval: %w", err)
+	}
+
+	return approval, nil
+}
+
+// Decide records the decision on a pending step. Approving activates the
+// next step, which expires at nextExpiresAt, or places the purchase order
+// after the last one; rejecting rejects the order and cancels the remaining
+// steps. It returns the resulting purchase order status, or ErrConflict when
+// the step was decided or expired in the meantime.
+func (r *ApprovalRepository) Decide(ctx context.Context, approvalID, decidedBy int64, approve bool, comment string, nextExpiresAt time.Time) (string, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("begin tx: %w", err)
+	}
+
+	decision := ApprovalRejected
+	if approve {
+		decision = ApprovalApproved
+	}
+
+	const decide = `
+UPDATE purchase_order_approvals
+SET status = $2, decided_by = NULLIF($3, 0), decided_at = NOW(), comment = $4
+WHERE id = $1 AND status = 'pending' AND (expires_at IS NULL OR expires_at > NOW())
+RETURNING purchase_order_id, step`
+
+	var (
+		purchaseOrderID int64
+		step            int
+	)
+	err = tx.QueryRowContext(ctx, decide, approvalID, decision, decidedBy, comment).Scan(&purchaseOrderID, &step)
+	if errors.Is(err, sql.ErrNoRows) {
+		tx.Rollback()
+		return "", ErrConflict
+	}
+	if err != nil {
+		tx.Rollback()
+		return "", fmt.Errorf("decide approval: %w", err)
+	}
+
+	status := PurchaseOrderRejected
+	if approve {
+		var expiresAt sql.NullTime
+		if !nextExpiresAt.IsZero() {
+			expiresAt = sql.NullTime{Time: nextExpiresAt, Valid: true}
+		}
+
+		const activateNext = `
+UPDATE purchase_order_approvals
+SET status = 'pending', expires_at = $3
+WHERE purchase_order_id = $1 AND step = $2 + 1 AND status = 'waiting'`
+
+		result, err := tx.ExecContext(ctx, activateNext, purchaseOrderID, step, expiresAt)
+		if err != nil {
+			tx.Rollback()
+			return "", fmt.Errorf("activate next approval: %w", err)
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			tx.Rollback()
+			return "", fmt.Errorf("activate next approval: %w", err)
+		}
+
+		status = PurchaseOrderPendingApproval
+		if affected == 0 {
+			status = PurchaseOrderPlaced
+		}
+	} else {
+		const cancelRemaining = `
+UPDATE purchase_order_approvals SET status = 'cancelled'
+WHERE purchase_order_id = $1 AND status = 'waiting'`
+
+		if _, err := tx.ExecContext(ctx, cancelRemaining, purchaseOrderID); err != nil {
+			tx.Rollback()
+			return "", fmt.Errorf("cancel remaining approvals: %w", err)
+		}
+	}
+
+	const updateOrder = `UPDATE purchase_orders SET status = $2 WHERE id = $1`
+
+	if _, err := tx.ExecContext(ctx, updateOrder, purchaseOrderID, status); err != nil {
+		tx.Rollback()
+		return "", fmt.Errorf("update purchase order status: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("commit approval: %w", err)
+	}
+
+	return status, nil
+}
+
+// Expire marks pending steps that expired before now as expired, expires
+// their purchase orders and cancels the remaining steps. It returns the
+// number of purchase orders expired.
+func (r *ApprovalRepository) Expire(ctx context.Context, now time.Time) (int64, error) {
+	const query = `
+WITH expired AS (
+	UPDATE purchase_order_approvals
+	SET status = 'expired', decided_at = $1
+	WHERE status = 'pending' AND expires_at <= $1
+	RETURNING purchase_order_id
+), cancelled AS (
+	UPDATE purchase_order_approvals
+	SET status = 'cancelled'
+	WHERE status = 'waiting' AND purchase_order_id IN (SELECT purchase_order_id FROM expired)
+)
+UPDATE purchase_orders
+SET status = 'expired'
+WHERE id IN (SELECT purchase_order_id FROM expired)`
+
+	result, err := r.db.ExecContext(ctx, query, now)
+	if err != nil {
+		return 0, fmt.Errorf("expire approvals: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("expire approvals: %w", err)
+	}
+
+	return affected, nil
+}
+
+func scanApprovalRule(row rowScanner) (*ApprovalRule, error) {
+	var (
+		rule  ApprovalRule
+		steps string
+	)
+	if err := row.Scan(
+		&rule.ID,
+		&rule.Name,
+		&rule.RestaurantID,
+		&rule.MinTotal,
+		&rule.IngredientType,
+		&steps,
+		&rule.CreatedBy,
+		&rule.CreatedAt,
+		&rule.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	if steps != "" {
+		rule.Steps = strings.Split(steps, ",")
+	}
+	rule.CreatedAt = rule.CreatedAt.UTC()
+	rule.UpdatedAt = rule.UpdatedAt.UTC()
+	return &rule, nil
+}
+
+func scanApproval(row rowScanner) (*Approval, error) {
+	var (
+		approval  Approval
+		expiresAt sql.NullTime
+		decidedAt sql.NullTime
+	)
+	if err := row.Scan(
+		&approval.ID,
+		&approval.PurchaseOrderID,
+		&approval.Step,
+		&approval.ApproverRole,
+		&approval.Status,
+		&approval.Reason,
+		&expiresAt,
+		&approval.DecidedBy,
+		&decidedAt,
+		&approval.Comment,
+		&approval.CreatedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	approval.CreatedAt = approval.CreatedAt.UTC()
+	if expiresAt.Valid {
+		approval.ExpiresAt = expiresAt.Time.UTC()
+	}
+	if decidedAt.Valid {
+		approval.DecidedAt = decidedAt.Time.UTC()
+	}
+	return &approval, nil
+}
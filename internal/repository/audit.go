@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// AuditEvent represents the audit_events table row.
+type AuditEvent struct {
+	ID             int64
+	ActorUserID    int64
+	ActorUsername  string
+	Action         string
+	TargetUserID   int64
+	TargetUsername string
+	Details        string
+	CreatedAt      time.Time
+}
+
+// AuditRepository persists audit events for administrative actions.
+type AuditRepository struct {
+	db *sql.DB
+}
+
+// NewAudit wires the repository to a sql.DB.
+func NewAudit(db *sql.DB) *AuditRepository {
+	return &AuditRepository{db: db}
+}
+
+// Record appends an audit event.
+func (r *AuditRepository) Record(ctx context.Context, event AuditEvent) error {
+	const query = `
+INSERT INTO audit_events (actor_user_id, actor_username, action, target_user_id, target_username, details)
+VALUES (NULLIF($1, 0), $2, $3, NULLIF($4, 0), $5, $6)`
+
+	_, err := r.db.ExecContext(ctx, query,
+		event.ActorUserID,
+		event.ActorUsername,
+		event.Action,
+		event.TargetUserID,
+		event.TargetUsername,
+		event.Details,
+	)
+	if err != nil {
+		return fmt.Errorf("insert audit event: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,166 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Budget represents the budgets table row: a spend cap for a restaurant per
+// calendar week or month.
+type Budget struct {
+	ID           int64
+	RestaurantID int64
+	// Period is "week" or "month".
+	Period string
+	Amount decimal.Decimal
+	// HardLimit routes orders exceeding the budget into approval instead of
+	// only warning.
+	HardLimit bool
+	UpdatedBy int64
+	UpdatedAt time.Time
+}
+
+// BudgetRepository persists restaurant budgets.
+type BudgetRepository struct {
+	db *sql.DB
+}
+
+// NewBudget wires the repository to a sql.DB.
+func NewBudget(db *sql.DB) *BudgetRepository {
+	return &BudgetRepository{db: db}
+}
+
+// Upsert creates or replaces the budget of a restaurant for a period.
+func (r *BudgetRepository) Upsert(ctx context.Context, budget *Budget) error {
+	const query = `
+INSERT INTO budgets (restaurant_id, period, amount, hard_limit, updated_by)
+VALUES ($1, $2, $3, $4, NULLIF($5, 0))
+ON CONFLICT (restaurant_id, period) DO UPDATE SET
+	amount = EXCLUDED.amount,
+	hard_limit = EXCLUDED.hard_limit,
+	updated_by = EXCLUDED.updated_by,
+	updated_at = NOW()
+RETURNING id, updated_at`
+
+	err := r.db.QueryRowContext(ctx, query, budget.RestaurantID, budget.Period, budget.Amount, budget.HardLimit, budget.UpdatedBy).
+		Scan(&budget.ID, &budget.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("upsert budget: %w", err)
+	}
+
+	budget.UpdatedAt = budget.UpdatedAt.UTC()
+	return nil
+}
+
+// Delete removes the budget of a restaurant for a period and reports whether it existed.
+func (r *BudgetRepository) Delete(ctx context.Context, restaurantID int64, period string) (bool, error) {
+	const query = `DELETE FROM budgets WHERE restaurant_id = $1 AND period = $2`
+
+	result, err := r.db.ExecContext(ctx, query, restaurantID, period)
+	if err != nil {
+		return false, fmt.Errorf("delete budget: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("delete budget: %w", err)
+	}
+
+	return affected > 0, nil
+}
+
+// ListByRestaurant returns the budgets of a restaurant.
+func (r *BudgetRepository) ListByRestaurant(ctx context.Context, restaurantID int64) ([]Budget, error) {
+	const query = `
+SELECT id, restaurant_id, period, amount, hard_limit, COALESCE(updated_by, 0), updated_at
+FROM budgets
+WHERE restaurant_id = $1
+ORDER BY period DESC`
+
+	rows, err := r.db.QueryContext(ctx, query, restaurantID)
+	if err != nil {
+		return nil, fmt.Errorf("query budgets: %w", err)
+	}
+	defer rows.Close()
+
+	var budgets []Budget
+	for rows.Next() {
+		var b Budget
+		if scanErr := rows.Scan(&b.ID, &b.RestaurantID, &b.Period, &b.Amount, &b.HardLimit, &b.UpdatedBy, &b.UpdatedAt); scanErr != nil {
+			return nil, fmt.Errorf("scan budget: %w", scanErr)
+		}
+		b.UpdatedAt = b.UpdatedAt.UTC()
+		budgets = append(budgets, b)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate budgets: %w", err)
+	}
+
+	return budgets, nil
+}
+
+// SpendByStatus returns the net value of a restaurant's purchase orders
+// created in [from, to), keyed by purchase order status. Quantities rejected
+// at receiving, including those closed short as not delivered, are not paid
+// for and do not count.
+func (r *BudgetRepository) SpendByStatus(ctx context.Context, restaurantID int64, from, to time.Time) (map[string]decimal.Decimal, error) {
+	return spendByStatus(ctx, r.db, restaurantID, from, to)
+}
+
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+func spendByStatus(ctx context.Context, q queryer, restaurantID int64, from, to time.Time) (map[string]decimal.Decimal, error) {
+	const query = `
+SELECT po.status, o.unit_price, o.number, COALESCE(grl.rejected, 0)
+FROM purchase_orders po
+JOIN orders o ON o.purchase_order_id = po.id
+LEFT JOIN (
+	SELECT order_id, SUM(rejected_quantity) AS rejected
+	FROM goods_receipt_lines
+	GROUP BY order_id
+) grl ON grl.order_id = o.id
+WHERE po.restaurant_id = $1 AND po.created_at >= $2 AND po.created_at < $3 AND o.unit_price IS NOT NULL`
+
+	rows, err := q.QueryContext(ctx, query, restaurantID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("query spend: %w", err)
+	}
+	defer rows.Close()
+
+	spend := make(map[string]decimal.Decimal)
+	for rows.Next() {
+		var (
+			status    string
+			unitPrice decimal.Decimal
+			number    int
+			rejected  decimal.Decimal
+		)
+		if err := rows.Scan(&status, &unitPrice, &number, &rejected); err != nil {
+			return nil, fmt.Errorf("scan spend: %w", err)
+		}
+		spend[status] = spend[status].Add(lineSpend(unitPrice, number, rejected))
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate spend: %w", err)
+	}
+
+	return spend, nil
+}
+
+// lineSpend is the net value of an order line, rounded to cents, without the
+// quantity rejected at receiving.
+func lineSpend(unitPrice decimal.Decimal, number int, rejected decimal.Decimal) decimal.Decimal {
+	quantity := decimal.NewFromInt(int64(number)).Sub(rejected)
+	if quantity.IsNegative() {
+		return decimal.Zero
+	}
+	return unitPrice.Mul(quantity).Round(2)
+}
@@ -0,0 +1,32 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestLineSpend(t *testing.T) {
+	tests := []struct {
+		name      string
+		unitPrice string
+		number    int
+		rejected  string
+		want      string
+	}{
+		{"nothing received yet", "2.50", 10, "0", "25"},
+		{"rejected as damaged", "2.50", 10, "2", "20"},
+		{"closed short as not delivered", "2.50", 10, "4", "15"},
+		{"fractional rejection", "1.99", 3, "0.5", "4.98"},
+		{"everything rejected", "2.50", 10, "10", "0"},
+		{"over-rejection counts as nothing", "2.50", 10, "12", "0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := lineSpend(decimal.RequireFromString(tt.unitPrice), tt.number, decimal.RequireFromString(tt.rejected))
+			if !got.Equal(decimal.RequireFromString(tt.want)) {
+				t.Fatalf("lineSpend = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+
+	"github.com/shopspring/decimal"
 )
 
 // IngredientRepository provides access to ingredient records.
@@ -32,3 +34,86 @@ func (r *IngredientRepository) Exists(ctx context.Context, id int64) (bool, erro
 		return true, nil
 	}
 }
+
+// TaxRates returns the tax rate of each of the given ingredients that exists.
+func (r *IngredientRepository) TaxRates(ctx context.Context, ids []int64) (map[int64]decimal.Decimal, error) {
+	rates := make(map[int64]decimal.Decimal, len(ids))
+	if len(ids) == 0 {
+		return rates, nil
+	}
+
+	const query = `SELECT id, tax_rate FROM ingredients WHERE id = ANY($1)`
+
+	rows, err := r.db.QueryContext(ctx, query, ids)
+	if err != nil {
+		return nil, fmt.Errorf("query tax rates: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			id   int64
+			rate decimal.Decimal
+		)
+		if err := rows.Scan(&id, &rate); err != nil {
+			return nil, fmt.Errorf("scan tax rate: %w", err)
+		}
+		rates[id] = rate
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate tax rates: %w", err)
+	}
+
+	return rates, nil
+}
+
+// Types returns the type of each of the given ingredients that exists.
+func (r *IngredientRepository) Types(ctx context.Context, ids []int64) (map[int64]string, error) {
+	types := make(map[int64]string, len(ids))
+	if len(ids) == 0 {
+		return types, nil
+	}
+
+	const query = `SELECT id, type FROM ingredients WHERE id = ANY($1)`
+
+	rows, err := r.db.QueryContext(ctx, query, ids)
+	if err != nil {
+		return nil, fmt.Errorf("query ingredient types: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			id  int64
+			typ string
+		)
+		if err := rows.Scan(&id, &typ); err != nil {
+			return nil, fmt.Errorf("scan ingredient type: %w", err)
+		}
+		types[id] = typ
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate ingredient types: %w", err)
+	}
+
+	return types, nil
+}
+
+// SetTaxRate updates the tax rate of an ingredient and reports whether it exists.
+func (r *IngredientRepository) SetTaxRate(ctx context.Context, id int64, rate decimal.Decimal) (bool, error) {
+	const query = `UPDATE ingredients SET tax_rate = $2, updated_at = NOW() WHERE id = $1`
+
+	result, err := r.db.ExecContext(ctx, query, id, rate)
+	if err != nil {
+		return false, fmt.Errorf("set tax rate: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("set tax rate: %w", err)
+	}
+
+	return affected > 0, nil
+}
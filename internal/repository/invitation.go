@@ -0,0 +1,157 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrInvitationUnavailable indicates the invitation does not exist, was already used or has expired.
+var ErrInvitationUnavailable = errors.New("invitation unavailable")
+
+// Invitation represents the invitations table row.
+type Invitation struct {
+	ID           int64
+	RestaurantID int64
+	Role         string
+	CreatedBy    int64
+	ExpiresAt    time.Time
+	UsedAt       time.Time
+	UsedBy       int64
+	CreatedAt    time.Time
+}
+
+// InvitationRepository persists signup invitations.
+type InvitationRepository struct {
+	db *sql.DB
+}
+
+// NewInvitation wires the repository to a sql.DB.
+func NewInvitation(db *sql.DB) *InvitationRepository {
+	return &InvitationRepository{db: db}
+}
+
+// Create stores a new invitation identified by the hash of its code.
+func (r *InvitationRepository) Create(ctx context.Context, codeHash string, inv *Invitation) error {
+	const query = `
+INSERT INTO invitations (code_hash, restaurant_id, role, created_by, expires_at)
+VALUES ($1, $2, $3, NULLIF($4, 0), $5)
+RETURNING id, created_at`
+
+	err := r.db.QueryRowContext(ctx, query, codeHash, inv.RestaurantID, inv.Role, inv.CreatedBy, inv.ExpiresAt).
+		Scan(&inv.ID, &inv.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("insert invitation: %w", err)
+	}
+
+	inv.CreatedAt = inv.CreatedAt.UTC()
+	return nil
+}
+
+// ListByRestaurant returns the invitations issued for a restaurant, newest first.
+func (r *InvitationRepository) ListByRestaurant(ctx context.Context, restaurantID int64) ([]Invitation, error) {
+	const query = `
+SELECT id, restaurant_id, role, COALESCE(created_by, 0), expires_at, used_at, COALESCE(used_by, 0), created_at
+FROM invitations
+WHERE restaurant_id = $1
+ORDER BY id DESC`
+
+	rows, err := r.db.QueryContext(ctx, query, restaurantID)
+	if err != nil {
+		return nil, fmt.Errorf("query invitations: %w", err)
+	}
+	defer rows.Close()
+
+	var invitations []Invitation
+	for rows.Next() {
+		var (
+			inv    Invitation
+			usedAt sql.NullTime
+		)
+		if scanErr := rows.Scan(
+			&inv.ID,
+			&inv.RestaurantID,
+			&inv.Role,
+			&inv.CreatedBy,
+			&inv.ExpiresAt,
+			&usedAt,
+			&inv.UsedBy,
+			&inv.CreatedAt,
+		); scanErr != nil {
+			return nil, fmt.Errorf("scan invitation: %w", scanErr)
+		}
+
+		inv.ExpiresAt = inv.ExpiresAt.UTC()
+		inv.CreatedAt = inv.CreatedAt.UTC()
+		if usedAt.Valid {
+			inv.UsedAt = usedAt.Time.UTC()
+		}
+
+		invitations = append(invitations, inv)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate invitations: %w", err)
+	}
+
+	return invitations, nil
+}
+
+// Redeem creates the user described by an invitation and marks the invitation
+// used in one transaction, so a code can never create two accounts.
+func (r *InvitationRepository) Redeem(ctx context.Context, codeHash, username, passwordHash string) (*User, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+
+	const lock = `
+SELECT id, restaurant_id, role
+FROM invitations
+WHERE code_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+FOR UPDATE`
+
+	var (
+		invitationID int64
+		user         = User{Username: username, PasswordHash: passwordHash}
+	)
+	err = tx.QueryRowContext(ctx, lock, codeHash).Scan(&invitationID, &user.RestaurantID, &user.Role)
+	if errors.Is(err, sql.ErrNoRows) {
+		tx.Rollback()
+		return nil, ErrInvitationUnavailable
+	}
+	if err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("lock invitation: %w", err)
+	}
+
+	const insertUser = `
+INSERT INTO users (username, password_hash, restaurant_id, role)
+VALUES ($1, $2, $3, $4)
+RETURNING id, token_version, created_at`
+
+	err = tx.QueryRowContext(ctx, insertUser, username, passwordHash, user.RestaurantID, user.Role).
+		Scan(&user.ID, &user.TokenVersion, &user.CreatedAt)
+	if err != nil {
+		tx.Rollback()
+		if isConstraintViolation(err) {
+			return nil, ErrConflict
+		}
+		return nil, fmt.Errorf("insert user: %w", err)
+	}
+
+	const markUsed = `UPDATE invitations SET used_at = NOW(), used_by = $2 WHERE id = $1`
+	if _, err := tx.ExecContext(ctx, markUsed, invitationID, user.ID); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("mark invitation used: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit invitation: %w", err)
+	}
+
+	user.CreatedAt = user.CreatedAt.UTC()
+	return &user, nil
+}
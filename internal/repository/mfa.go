@@ -0,0 +1,222 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// MFA represents the user_mfa table row.
+type MFA struct {
+	UserID       int64
+	Secret       string
+	EnabledAt    time.Time
+	LastUsedStep int64
+	CreatedAt    time.Time
+}
+
+// Enabled reports whether enrolment has been confirmed.
+func (m *MFA) Enabled() bool {
+	return !m.EnabledAt.IsZero()
+}
+
+// MFARepository persists TOTP secrets and recovery codes.
+type MFARepository struct {
+	db *sql.DB
+}
+
+// NewMFA wires the repository to a sql.DB.
+func NewMFA(db *sql.DB) *MFARepository {
+	return &MFARepository{db: db}
+}
+
+// Get returns the TOTP enrolment for a user.
+func (r *MFARepository) Get(ctx context.Context, userID int64) (*MFA, error) {
+	const query = `
+SELECT user_id, secret, enabled_at, last_used_step, created_at
+FROM user_mfa
+WHERE user_id = $1`
+
+	var (
+		mfa       MFA
+		enabledAt sql.NullTime
+		lastStep  sql.NullInt64
+	)
+	err := r.db.QueryRowContext(ctx, query, userID).
+		Scan(&mfa.UserID, &mfa.Secret, &enabledAt, &lastStep, &mfa.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get user mfa: %w", err)
+	}
+
+	mfa.CreatedAt = mfa.CreatedAt.UTC()
+	if enabledAt.Valid {
+		mfa.EnabledAt = enabledAt.Time.UTC()
+	}
+	if lastStep.Valid {
+		mfa.LastUsedStep = lastStep.Int64
+	}
+
+	return &mfa, nil
+}
+
+// SavePending stores a new, unconfirmed secret for the user. Confirmed
+// enrolments are left untouched and reported through ErrConflict.
+func (r *MFARepository) SavePending(ctx context.Context, userID int64, secret string) error {
+	const query = `
+INSERT INTO user_mfa (user_id, secret)
+VALUES ($1, $2)
+ON CONFLICT (user_id) DO UPDATE
+	SET secret = EXCLUDED.secret, last_used_step = NULL, updated_at = NOW()
+	WHERE user_mfa.enabled_at IS NULL`
+
+	result, err := r.db.ExecContext(ctx, query, userID, secret)
+	if err != nil {
+		return fmt.Errorf("save user mfa: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("save user mfa: %w", err)
+	}
+	if affected == 0 {
+		return ErrConflict
+	}
+
+	return nil
+}
+
+// Enable confirms the pending enrolment and replaces the recovery codes in a single transaction.
+func (r *MFARepository) Enable(ctx context.Context, userID, step int64, recoveryCodeHashes []string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+
+	const enable = `
+UPDATE user_mfa
+SET enabled_at = NOW(), last_used_step = $2, updated_at = NOW()
+WHERE user_id = $1 AND enabled_at IS NULL`
+
+	result, err := tx.ExecContext(ctx, enable, userID, step)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("enable user mfa: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("enable user mfa: %w", err)
+	}
+	if affected == 0 {
+		tx.Rollback()
+		return ErrConflict
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM user_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("delete recovery codes: %w", err)
+	}
+
+	const insertCode = `INSERT INTO user_recovery_codes (user_id, code_hash) VALUES ($1, $2)`
+	for _, hash := range recoveryCodeHashes {
+		if _, err := tx.ExecContext(ctx, insertCode, userID, hash); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("insert recovery code: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit user mfa: %w", err)
+	}
+
+	return nil
+}
+
+// MarkStepUsed records a successfully verified TOTP step. It returns false when
+// the step (or a later one) was already used, which rejects replayed codes.
+func (r *MFARepository) MarkStepUsed(ctx context.Context, userID, step int64) (bool, error) {
+	const query = `
+UPDATE user_mfa
+SET last_used_step = $2, updated_at = NOW()
+WHERE user_id = $1 AND (last_used_step IS NULL OR last_used_step < $2)`
+
+	result, err := r.db.ExecContext(ctx, query, userID, step)
+	if err != nil {
+		return false, fmt.Errorf("mark totp step: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("mark totp step: %w", err)
+	}
+
+	return affected == 1, nil
+}
+
+// ConsumeRecoveryCode marks an unused recovery code as used and reports whether one matched.
+func (r *MFARepository) ConsumeRecoveryCode(ctx context.Context, userID int64, codeHash string) (bool, error) {
+	const query = `
+UPDATE user_recovery_codes
+SET used_at = NOW()
+WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL`
+
+	result, err := r.db.ExecContext(ctx, query, userID, codeHash)
+	if err != nil {
+		return false, fmt.Errorf("consume recovery code: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("consume recovery code: %w", err)
+	}
+
+	return affected > 0, nil
+}
+
+// ReserveChallengeAttempt records one more code tried against the login
+// challenge jti, which expires at expiresAt. It returns false, recording
+// nothing, once maxAttempts codes were tried or the challenge was closed.
+// Expired challenges are purged on the way.
+func (r *MFARepository) ReserveChallengeAttempt(ctx context.Context, jti string, userID int64, expiresAt time.Time, maxAttempts int) (bool, error) {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM mfa_challenges WHERE expires_at < NOW()`); err != nil {
+		return false, fmt.Errorf("purge mfa challenges: %w", err)
+	}
+
+	const query = `
+INSERT INTO mfa_challenges (jti, user_id, attempts, expires_at)
+VALUES ($1, $2, 1, $3)
+ON CONFLICT (jti) DO UPDATE
+	SET attempts = mfa_challenges.attempts + 1
+	WHERE mfa_challenges.attempts < $4`
+
+	result, err := r.db.ExecContext(ctx, query, jti, userID, expiresAt, maxAttempts)
+	if err != nil {
+		return false, fmt.Errorf("reserve mfa challenge attempt: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("reserve mfa challenge attempt: %w", err)
+	}
+
+	return affected == 1, nil
+}
+
+// CloseChallenge uses up the remaining attempts of a login challenge so it
+// cannot be exchanged again.
+func (r *MFARepository) CloseChallenge(ctx context.Context, jti string, maxAttempts int) error {
+	const query = `
+UPDATE mfa_challenges
+SET attempts = GREATEST(attempts, $2)
+WHERE jti = $1`
+
+	if _, err := r.db.ExecContext(ctx, query, jti, maxAttempts); err != nil {
+		return fmt.Errorf("close mfa challenge: %w", err)
+	}
+	return nil
+}
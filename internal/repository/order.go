@@ -5,7 +5,10 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
+
+	"github.com/shopspring/decimal"
 )
 
 // Order represents the orders table row.
@@ -15,8 +18,48 @@ type Order struct {
 	RestaurantID int64
 	IngredientID int64
 	Number       int
-	CreatedAt    time.Time
-	UpdatedAt    time.Time
+	CreatedBy    int64
+	// SupplierID and PurchaseOrderID are zero for orders placed before
+	// suppliers existed and for lines no supplier delivers.
+	SupplierID      int64
+	PurchaseOrderID int64
+	// UnitPrice and TaxRate are snapshotted when the order is placed. They
+	// are null for lines that had no price.
+	UnitPrice decimal.NullDecimal
+	TaxRate   decimal.NullDecimal
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Purchase order statuses. Orders leave pending_approval as placed once
+// every approval step signed off, or as rejected or expired. Placed orders
+// become partially_received with their first goods receipt and received
+// once every line is settled.
+const (
+	PurchaseOrderPlaced            = "placed"
+	PurchaseOrderPendingApproval   = "pending_approval"
+	PurchaseOrderRejected          = "rejected"
+	PurchaseOrderExpired           = "expired"
+	PurchaseOrderPartiallyReceived = "partially_received"
+	PurchaseOrderReceived          = "received"
+)
+
+// PurchaseOrder represents the purchase_orders table row: the lines of one
+// order request sent to a single supplier.
+type PurchaseOrder struct {
+	ID                 int64
+	Code               string
+	RestaurantID       int64
+	SupplierID         int64
+	Status             string
+	ExpectedDeliveryOn time.Time
+	CreatedBy          int64
+	CreatedAt          time.Time
+	// DeliveredAt is when the order became fully received.
+	DeliveredAt time.Time
+	Lines       []Order
+	// Approvals is the approval chain the order waits for, in step order.
+	Approvals []Approval
 }
 
 // OrderRepository persists orders.
@@ -29,9 +72,21 @@ func NewOrder(db *sql.DB) *OrderRepository {
 	return &OrderRepository{db: db}
 }
 
-// CreateBulk inserts multiple orders for a restaurant.
-func (r *OrderRepository) CreateBulk(ctx context.Context, restaurantID int64, items []Order) error {
-	if len(items) == 0 {
+// SpendFunc returns the net value of a restaurant's purchase orders created
+// in [from, to), keyed by status.
+type SpendFunc func(from, to time.Time) (map[string]decimal.Decimal, error)
+
+// PlacementCheck runs inside the placement transaction, while placements for
+// the restaurant are serialized, and may set the approval chain of the new
+// purchase orders.
+type PlacementCheck func(spend SpendFunc, purchaseOrders []PurchaseOrder) error
+
+// CreatePurchaseOrders inserts the purchase orders of a restaurant together
+// with their lines and approval chains in one transaction and fills in the
+// generated ids and status. Orders with an approval chain wait in
+// pending_approval; the others are placed directly. check may be nil.
+func (r *OrderRepository) CreatePurchaseOrders(ctx context.Context, restaurantID int64, purchaseOrders []PurchaseOrder, check PlacementCheck) error {
+	if len(purchaseOrders) == 0 {
 		return nil
 	}
 
@@ -40,15 +95,105 @@ func (r *OrderRepository) CreateBulk(ctx context.Context, restaurantID int64, it
 		return fmt.Errorf("begin tx: %w", err)
 	}
 
-	const query = `
-INSERT INTO orders (code, restaurant_id, ingredient_id, number)
-VALUES ($1, $2, $3, $4)`
+	if check != nil {
+		// Serialize placements per restaurant so two orders cannot both fit
+		// into the same remaining budget.
+		const lockRestaurant = `SELECT 1 FROM restaurants WHERE id = $1 FOR NO KEY UPDATE`
+
+		var marker int
+		if err := tx.QueryRowContext(ctx, lockRestaurant, restaurantID).Scan(&marker); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("lock restaurant: %w", err)
+		}
+
+		err = check(func(from, to time.Time) (map[string]decimal.Decimal, error) {
+			return spendByStatus(ctx, tx, restaurantID, from, to)
+		}, purchaseOrders)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	const insertPurchaseOrder = `
+INSERT INTO purchase_orders (code, restaurant_id, supplier_id, expected_delivery_on, created_by, status)
+VALUES ($1, $2, NULLIF($3, 0), $4, NULLIF($5, 0), $6)
+RETURNING id, created_at`
+
+	const insertLine = `
+INSERT INTO orders (code, restaurant_id, ingredient_id, number, created_by, supplier_id, purchase_order_id, unit_price, tax_rate)
+VALUES ($1, $2, $3, $4, NULLIF($5, 0), NULLIF($6, 0), $7, $8, $9)
+RETURNING id`
+
+	const insertApproval = `
+INSERT INTO purchase_order_approvals (purchase_order_id, step, approver_role, status, reason, expires_at)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING id, created_at`
+
+	for i := range purchaseOrders {
+		po := &purchaseOrders[i]
+
+		status := PurchaseOrderPlaced
+		if len(po.Approvals) > 0 {
+			status = PurchaseOrderPendingApproval
+		}
+
+		var expected sql.NullTime
+		if !po.ExpectedDeliveryOn.IsZero() {
+			expected = sql.NullTime{Time: po.ExpectedDeliveryOn, Valid: true}
+		}
 
-	for _, item := range items {
-		_, execErr := tx.ExecContext(ctx, query, item.Code, restaurantID, item.IngredientID, item.Number)
-		if execErr != nil {
+		if err := tx.QueryRowContext(ctx, insertPurchaseOrder, po.Code, restaurantID, po.SupplierID, expected, po.CreatedBy, status).
+			Scan(&po.ID, &po.CreatedAt); err != nil {
 			tx.Rollback()
-			return fmt.Errorf("insert order: %w", execErr)
+			return fmt.Errorf("insert purchase order: %w", err)
+		}
+		po.RestaurantID = restaurantID
+		po.Status = status
+		po.CreatedAt = po.CreatedAt.UTC()
+
+		for j := range po.Lines {
+			line := &po.Lines[j]
+			if err := tx.QueryRowContext(ctx, insertLine,
+				line.Code,
+				restaurantID,
+				line.IngredientID,
+				line.Number,
+				line.CreatedBy,
+				po.SupplierID,
+				po.ID,
+				line.UnitPrice,
+				line.TaxRate,
+			).Scan(&line.ID); err != nil {
+				tx.Rollback()
+				return fmt.Errorf("insert order: %w", err)
+			}
+			line.RestaurantID = restaurantID
+			line.SupplierID = po.SupplierID
+			line.PurchaseOrderID = po.ID
+		}
+
+		for j := range po.Approvals {
+			approval := &po.Approvals[j]
+			approval.PurchaseOrderID = po.ID
+			approval.Step = j + 1
+
+			var expiresAt sql.NullTime
+			if !approval.ExpiresAt.IsZero() {
+				expiresAt = sql.NullTime{Time: approval.ExpiresAt, Valid: true}
+			}
+			if err := tx.QueryRowContext(ctx, insertApproval,
+				po.ID,
+				approval.Step,
+				approval.ApproverRole,
+				approval.Status,
+				approval.Reason,
+				expiresAt,
+			).Scan(&approval.ID, &approval.CreatedAt); err != nil {
+				tx.Rollback()
+				return fmt.Errorf("insert approval: %w", err)
+			}
+			approval.CreatedAt = approval.CreatedAt.UTC()
 		}
 	}
 
@@ -62,7 +207,7 @@ VALUES ($1, $2, $3, $4)`
 // ListByRestaurant fetches all orders for a restaurant.
 func (r *OrderRepository) ListByRestaurant(ctx context.Context, restaurantID int64) ([]Order, error) {
 	const query = `
-SELECT id, code, restaurant_id, ingredient_id, number, created_at, updated_at
+SELECT id, code, restaurant_id, ingredient_id, number, COALESCE(created_by, 0), COALESCE(supplier_id, 0), COALESCE(purchase_order_id, 0), unit_price, tax_rate, created_at, updated_at
 FROM orders
 WHERE restaurant_id = $1
 ORDER BY id`
@@ -85,6 +230,11 @@ ORDER BY id`
 			&order.RestaurantID,
 			&order.IngredientID,
 			&order.Number,
+			&order.CreatedBy,
+			&order.SupplierID,
+			&order.PurchaseOrderID,
+			&order.UnitPrice,
+			&order.TaxRate,
 			&order.CreatedAt,
 			&updatedAt,
 		); scanErr != nil {
@@ -109,7 +259,7 @@ ORDER BY id`
 // Get fetches an order by identifier.
 func (r *OrderRepository) Get(ctx context.Context, id int64) (*Order, error) {
 	const query = `
-SELECT id, code, restaurant_id, ingredient_id, number, created_at, updated_at
+SELECT id, code, restaurant_id, ingredient_id, number, COALESCE(created_by, 0), COALESCE(supplier_id, 0), COALESCE(purchase_order_id, 0), unit_price, tax_rate, created_at, updated_at
 FROM orders
 WHERE id = $1`
 
@@ -123,6 +273,11 @@ WHERE id = $1`
 		&order.RestaurantID,
 		&order.IngredientID,
 		&order.Number,
+		&order.CreatedBy,
+		&order.SupplierID,
+		&order.PurchaseOrderID,
+		&order.UnitPrice,
+		&order.TaxRate,
 		&order.CreatedAt,
 		&updatedAt,
 	)
@@ -140,3 +295,158 @@ WHERE id = $1`
 
 	return &order, nil
 }
+
+// GetPurchaseOrder fetches a purchase order with its lines.
+func (r *OrderRepository) GetPurchaseOrder(ctx context.Context, id int64) (*PurchaseOrder, error) {
+	const query = `
+SELECT id, code, restaurant_id, COALESCE(supplier_id, 0), status, expected_delivery_on, COALESCE(created_by, 0), created_at, delivered_at
+FROM purchase_orders
+WHERE id = $1`
+
+	var (
+		po          PurchaseOrder
+		expected    sql.NullTime
+		deliveredAt sql.NullTime
+	)
+	err := r.db.QueryRowContext(ctx, query, id).Scan(
+		&po.ID,
+		&po.Code,
+		&po.RestaurantID,
+		&po.SupplierID,
+		&po.Status,
+		&expected,
+		&po.CreatedBy,
+		&po.CreatedAt,
+		&deliveredAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, sql.ErrNoRows
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get purchase order: %w", err)
+	}
+
+	po.CreatedAt = po.CreatedAt.UTC()
+	if expected.Valid {
+		po.ExpectedDeliveryOn = expected.Time.UTC()
+	}
+	if deliveredAt.Valid {
+		po.DeliveredAt = deliveredAt.Time.UTC()
+	}
+
+	const linesQuery = `
+SELECT id, code, restaurant_id, ingredient_id, number, COALESCE(created_by, 0), COALESCE(supplier_id, 0), COALESCE(purchase_order_id, 0), unit_price, tax_rate, created_at, updated_at
+FROM orders
+WHERE purchase_order_id = $1
+ORDER BY id`
+
+	rows, err := r.db.QueryContext(ctx, linesQuery, id)
+	if err != nil {
+		return nil, fmt.Errorf("query purchase order lines: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			line      Order
+			updatedAt sql.NullTime
+		)
+		if scanErr := rows.Scan(
+			&line.ID,
+			&line.Code,
+			&line.RestaurantID,
+			&line.IngredientID,
+			&line.Number,
+			&line.CreatedBy,
+			&line.SupplierID,
+			&line.PurchaseOrderID,
+			&line.UnitPrice,
+			&line.TaxRate,
+			&line.CreatedAt,
+			&updatedAt,
+		); scanErr != nil {
+			return nil, fmt.Errorf("scan purchase order line: %w", scanErr)
+		}
+
+		line.CreatedAt = line.CreatedAt.UTC()
+		if updatedAt.Valid {
+			line.UpdatedAt = updatedAt.Time.UTC()
+		}
+
+		po.Lines = append(po.Lines, line)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate purchase order lines: %w", err)
+	}
+
+	return &po, nil
+}
+
+// DailyDemand is the quantity of an ingredient a restaurant ordered on one
+// UTC day.
+type DailyDemand struct {
+	IngredientID   int64
+	IngredientCode string
+	IngredientName string
+	Day            time.Time
+	Quantity       int64
+}
+
+// DemandFilter narrows down the demand history of a restaurant to orders
+// created in [From, To). A zero IngredientID matches every ingredient.
+type DemandFilter struct {
+	IngredientID int64
+	From         time.Time
+	To           time.Time
+}
+
+// DailyDemand sums the quantities a restaurant ordered per ingredient and
+// UTC day, ordered by ingredient code and day. Days without orders are
+// omitted, and so are deleted orders and purchase orders that were rejected
+// or expired.
+func (r *OrderRepository) DailyDemand(ctx context.Context, restaurantID int64, filter DemandFilter) ([]DailyDemand, error) {
+	conditions := []string{
+		"o.restaurant_id = $1",
+		"o.deleted_at IS NULL",
+		"o.created_at >= $2",
+		"o.created_at < $3",
+		"(po.status IS NULL OR po.status <> ALL($4))",
+	}
+	args := []interface{}{restaurantID, filter.From, filter.To, []string{PurchaseOrderRejected, PurchaseOrderExpired}}
+	if filter.IngredientID != 0 {
+		args = append(args, filter.IngredientID)
+		conditions = append(conditions, fmt.Sprintf("o.ingredient_id = $%d", len(args)))
+	}
+
+	query := `
+SELECT o.ingredient_id, i.code, i.name, (o.created_at AT TIME ZONE 'UTC')::date AS day, SUM(o.number)
+FROM orders o
+JOIN ingredients i ON i.id = o.ingredient_id
+LEFT JOIN purchase_orders po ON po.id = o.purchase_order_id
+WHERE ` + strings.Join(conditions, " AND ") + `
+GROUP BY o.ingredient_id, i.code, i.name, day
+ORDER BY i.code, o.ingredient_id, day`
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query daily demand: %w", err)
+	}
+	defer rows.Close()
+
+	var demand []DailyDemand
+	for rows.Next() {
+		var d DailyDemand
+		if scanErr := rows.Scan(&d.IngredientID, &d.IngredientCode, &d.IngredientName, &d.Day, &d.Quantity); scanErr != nil {
+			return nil, fmt.Errorf("scan daily demand: %w", scanErr)
+		}
+		d.Day = time.Date(d.Day.Year(), d.Day.Month(), d.Day.Day(), 0, 0, 0, 0, time.UTC)
+		demand = append(demand, d)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate daily demand: %w", err)
+	}
+
+	return demand, nil
+}
@@ -0,0 +1,152 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// ParLevel is the stock a restaurant aims to hold of an ingredient. Once
+// stock on hand plus stock on order falls to ReorderPoint, it is topped up
+// to Par.
+type ParLevel struct {
+	RestaurantID   int64
+	IngredientID   int64
+	IngredientCode string
+	IngredientName string
+	Par            decimal.Decimal
+	ReorderPoint   decimal.Decimal
+	UpdatedBy      int64
+	UpdatedAt      time.Time
+}
+
+// StockPosition compares a par level with the stock on hand and the stock
+// still due on open purchase orders.
+type StockPosition struct {
+	ParLevel
+	OnHand decimal.Decimal
+	// OnOrder is ordered on purchase orders awaiting approval or delivery
+	// and neither received nor rejected yet.
+	OnOrder decimal.Decimal
+}
+
+// Available returns the stock on hand plus the stock on order.
+func (p StockPosition) Available() decimal.Decimal {
+	return p.OnHand.Add(p.OnOrder)
+}
+
+// ParLevelRepository persists par levels.
+type ParLevelRepository struct {
+	db *sql.DB
+}
+
+// NewParLevel wires the repository to a sql.DB.
+func NewParLevel(db *sql.DB) *ParLevelRepository {
+	return &ParLevelRepository{db: db}
+}
+
+// Upsert creates or replaces the par level of a restaurant and ingredient.
+func (r *ParLevelRepository) Upsert(ctx context.Context, level *ParLevel) error {
+	const query = `
+INSERT INTO par_levels (restaurant_id, ingredient_id, par, reorder_point, updated_by)
+VALUES ($1, $2, $3, $4, NULLIF($5, 0))
+ON CONFLICT (restaurant_id, ingredient_id) DO UPDATE SET
+	par = EXCLUDED.par,
+	reorder_point = EXCLUDED.reorder_point,
+	updated_by = EXCLUDED.updated_by,
+	updated_at = NOW()
+RETURNING updated_at`
+
+	err := r.db.QueryRowContext(ctx, query, level.RestaurantID, level.IngredientID, level.Par, level.ReorderPoint, level.UpdatedBy).
+		Scan(&level.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("upsert par level: %w", err)
+	}
+
+	level.UpdatedAt = level.UpdatedAt.UTC()
+	return nil
+}
+
+// Delete removes the par level of a restaurant and ingredient and reports
+// whether it existed.
+func (r *ParLevelRepository) Delete(ctx context.Context, restaurantID, ingredientID int64) (bool, error) {
+	const query = `DELETE FROM par_levels WHERE restaurant_id = $1 AND ingredient_id = $2`
+
+	result, err := r.db.ExecContext(ctx, query, restaurantID, ingredientID)
+	if err != nil {
+		return false, fmt.Errorf("delete par level: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("delete par level: %w", err)
+	}
+
+	return affected > 0, nil
+}
+
+// Positions returns every par level of a restaurant, ordered by ingredient
+// code, with the stock on hand and on order. Ingredients that were deleted
+// are skipped.
+func (r *ParLevelRepository) Positions(ctx context.Context, restaurantID int64) ([]StockPosition, error) {
+	const query = `
+SELECT p.restaurant_id, p.ingredient_id, i.code, i.name, p.par, p.reorder_point,
+	COALESCE(p.updated_by, 0), p.updated_at,
+	COALESCE(sl.on_hand, 0),
+	COALESCE(oo.on_order, 0)
+FROM par_levels p
+JOIN ingredients i ON i.id = p.ingredient_id
+LEFT JOIN stock_levels sl ON sl.restaurant_id = p.restaurant_id AND sl.ingredient_id = p.ingredient_id
+LEFT JOIN (
+	SELECT o.ingredient_id,
+		SUM(GREATEST(o.number - COALESCE(grl.received, 0) - COALESCE(grl.rejected, 0), 0)) AS on_order
+	FROM orders o
+	JOIN purchase_orders po ON po.id = o.purchase_order_id
+	LEFT JOIN (
+		SELECT order_id, SUM(received_quantity) AS received, SUM(rejected_quantity) AS rejected
+		FROM goods_receipt_lines
+		GROUP BY order_id
+	) grl ON grl.order_id = o.id
+	WHERE o.restaurant_id = $1 AND o.deleted_at IS NULL AND po.status = ANY($2)
+	GROUP BY o.ingredient_id
+) oo ON oo.ingredient_id = p.ingredient_id
+WHERE p.restaurant_id = $1 AND i.deleted_at IS NULL
+ORDER BY i.code, p.ingredient_id`
+
+	open := []string{PurchaseOrderPendingApproval, PurchaseOrderPlaced, PurchaseOrderPartiallyReceived}
+	rows, err := r.db.QueryContext(ctx, query, restaurantID, open)
+	if err != nil {
+		return nil, fmt.Errorf("query stock positions: %w", err)
+	}
+	defer rows.Close()
+
+	var positions []StockPosition
+	for rows.Next() {
+		var p StockPosition
+		if scanErr := rows.Scan(
+			&p.RestaurantID,
+			&p.IngredientID,
+			&p.IngredientCode,
+			&p.IngredientName,
+			&p.Par,
+			&p.ReorderPoint,
+			&p.UpdatedBy,
+			&p.UpdatedAt,
+			&p.OnHand,
+			&p.OnOrder,
+		); scanErr != nil {
+			return nil, fmt.Errorf("scan stock position: %w", scanErr)
+		}
+		p.UpdatedAt = p.UpdatedAt.UTC()
+		positions = append(positions, p)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate stock positions: %w", err)
+	}
+
+	return positions, nil
+}
@@ -0,0 +1,186 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// IngredientPrice represents an ingredient_prices row. SupplierID is zero for
+// the ingredient's general price. EffectiveTo is zero while the price is open
+// ended.
+type IngredientPrice struct {
+	ID            int64
+	IngredientID  int64
+	SupplierID    int64
+	Price         decimal.Decimal
+	EffectiveFrom time.Time
+	EffectiveTo   time.Time
+	CreatedBy     int64
+	CreatedAt     time.Time
+}
+
+// ActiveAt reports whether the price applies at t.
+func (p *IngredientPrice) ActiveAt(t time.Time) bool {
+	return !t.Before(p.EffectiveFrom) && (p.EffectiveTo.IsZero() || t.Before(p.EffectiveTo))
+}
+
+// PriceRepository persists the price history of ingredients.
+type PriceRepository struct {
+	db *sql.DB
+}
+
+// NewPrice wires the repository to a sql.DB.
+func NewPrice(db *sql.DB) *PriceRepository {
+	return &PriceRepository{db: db}
+}
+
+const priceColumns = `id, ingredient_id, COALESCE(supplier_id, 0), price, effective_from, effective_to, COALESCE(created_by, 0), created_at`
+
+// Create appends a price to the history of an ingredient and supplier. The
+// previous price ends where the new one starts. Prices can only be appended:
+// a price starting at or before the latest one is reported as ErrConflict.
+func (r *PriceRepository) Create(ctx context.Context, price *IngredientPrice) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+
+	const latest = `
+SELECT id, effective_from
+FROM ingredient_prices
+WHERE ingredient_id = $1 AND COALESCE(supplier_id, 0) = $2
+ORDER BY effective_from DESC
+LIMIT 1
+FOR UPDATE`
+
+	var (
+		latestID   int64
+		latestFrom time.Time
+	)
+	err = tx.QueryRowContext(ctx, latest, price.IngredientID, price.SupplierID).Scan(&latestID, &latestFrom)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+	case err != nil:
+		tx.Rollback()
+		return fmt.Errorf("lock latest price: %w", err)
+	case !price.EffectiveFrom.After(latestFrom):
+		tx.Rollback()
+		return ErrConflict
+	default:
+		const closeLatest = `
+UPDATE ingredient_prices
+SET effective_to = $2
+WHERE id = $1 AND (effective_to IS NULL OR effective_to > $2)`
+
+		if _, err := tx.ExecContext(ctx, closeLatest, latestID, price.EffectiveFrom); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("close previous price: %w", err)
+		}
+	}
+
+	var effectiveTo sql.NullTime
+	if !price.EffectiveTo.IsZero() {
+		effectiveTo = sql.NullTime{Time: price.EffectiveTo, Valid: true}
+	}
+
+	const insert = `
+INSERT INTO ingredient_prices (ingredient_id, supplier_id, price, effective_from, effective_to, created_by)
+VALUES ($1, NULLIF($2, 0), $3, $4, $5, NULLIF($6, 0))
+RETURNING id, created_at`
+
+	err = tx.QueryRowContext(ctx, insert,
+		price.IngredientID,
+		price.SupplierID,
+		price.Price,
+		price.EffectiveFrom,
+		effectiveTo,
+		price.CreatedBy,
+	).Scan(&price.ID, &price.CreatedAt)
+	if err != nil {
+		tx.Rollback()
+		if isConstraintViolation(err) {
+			return ErrConflict
+		}
+		return fmt.Errorf("insert price: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit price: %w", err)
+	}
+
+	price.CreatedAt = price.CreatedAt.UTC()
+	return nil
+}
+
+// ListByIngredient returns the full price history of an ingredient, general
+// and supplier specific, newest first.
+func (r *PriceRepository) ListByIngredient(ctx context.Context, ingredientID int64) ([]IngredientPrice, error) {
+	query := `
+SELECT ` + priceColumns + `
+FROM ingredient_prices
+WHERE ingredient_id = $1
+ORDER BY effective_from DESC, id DESC`
+
+	return r.query(ctx, query, ingredientID)
+}
+
+// ListActive returns the prices of the given ingredients in effect at t.
+func (r *PriceRepository) ListActive(ctx context.Context, ingredientIDs []int64, at time.Time) ([]IngredientPrice, error) {
+	if len(ingredientIDs) == 0 {
+		return nil, nil
+	}
+
+	query := `
+SELECT ` + priceColumns + `
+FROM ingredient_prices
+WHERE ingredient_id = ANY($1) AND effective_from <= $2 AND (effective_to IS NULL OR effective_to > $2)
+ORDER BY ingredient_id, id`
+
+	return r.query(ctx, query, ingredientIDs, at)
+}
+
+func (r *PriceRepository) query(ctx context.Context, query string, args ...interface{}) ([]IngredientPrice, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query prices: %w", err)
+	}
+	defer rows.Close()
+
+	var prices []IngredientPrice
+	for rows.Next() {
+		var (
+			p           IngredientPrice
+			effectiveTo sql.NullTime
+		)
+		if scanErr := rows.Scan(
+			&p.ID,
+			&p.IngredientID,
+			&p.SupplierID,
+			&p.Price,
+			&p.EffectiveFrom,
+			&effectiveTo,
+			&p.CreatedBy,
+			&p.CreatedAt,
+		); scanErr != nil {
+			return nil, fmt.Errorf("scan price: %w", scanErr)
+		}
+
+		p.EffectiveFrom = p.EffectiveFrom.UTC()
+		if effectiveTo.Valid {
+			p.EffectiveTo = effectiveTo.Time.UTC()
+		}
+		p.CreatedAt = p.CreatedAt.UTC()
+		prices = append(prices, p)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate prices: %w", err)
+	}
+
+	return prices, nil
+}
@@ -0,0 +1,425 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Reasons for rejecting delivered goods. RejectNotDelivered settles
+// quantities the supplier shipped short and will not deliver.
+const (
+	RejectDamaged      = "damaged"
+	RejectWrongItem    = "wrong_item"
+	RejectShortDated   = "short_dated"
+	RejectNotDelivered = "not_delivered"
+)
+
+// GoodsReceipt represents the goods_receipts table row: one delivery
+// recorded against a purchase order.
+type GoodsReceipt struct {
+	ID              int64
+	PurchaseOrderID int64
+	Note            string
+	ReceivedBy      int64
+	ReceivedAt      time.Time
+	Lines           []GoodsReceiptLine
+	// Movements are the stock receipts posted for the accepted quantities.
+	Movements []StockMovement
+}
+
+// GoodsReceiptLine represents the goods_receipt_lines table row.
+type GoodsReceiptLine struct {
+	ID           int64
+	ReceiptID    int64
+	OrderID      int64
+	IngredientID int64
+	Received     decimal.Decimal
+	Rejected     decimal.Decimal
+	// RejectReason is empty when nothing was rejected.
+	RejectReason string
+	// LotNumber and ExpiresOn identify the supplier lot delivered; both are
+	// optional.
+	LotNumber string
+	ExpiresOn time.Time
+}
+
+// ReceivableLine is an order line of a purchase order being received, with
+// the quantities booked by earlier receipts.
+type ReceivableLine struct {
+	OrderID      int64
+	IngredientID int64
+	Ordered      decimal.Decimal
+	Received     decimal.Decimal
+	Rejected     decimal.Decimal
+}
+
+// Outstanding returns the quantity neither received nor rejected yet.
+func (l ReceivableLine) Outstanding() decimal.Decimal {
+	return l.Ordered.Sub(l.Received).Sub(l.Rejected)
+}
+
+// ReceiptCheck runs inside the receiving transaction, while the purchase
+// order is locked, and returns the lines to record for the receipt.
+type ReceiptCheck func(lines []ReceivableLine) ([]GoodsReceiptLine, error)
+
+// SupplierDiscrepancy compares ordered and received quantities of the
+// purchase orders sent to one supplier.
+type SupplierDiscrepancy struct {
+	// SupplierID is zero for lines no supplier delivers.
+	SupplierID     int64
+	SupplierName   string
+	PurchaseOrders int
+	Lines          int
+	// DiscrepantLines counts lines where received differs from ordered.
+	DiscrepantLines int
+	Ordered         decimal.Decimal
+	Received        decimal.Decimal
+	// Rejected is keyed by reject reason.
+	Rejected map[string]decimal.Decimal
+}
+
+// DiscrepancyFilter narrows down the discrepancy report. Zero values match
+// everything; From and To bound the purchase order creation time to [From, To).
+type DiscrepancyFilter struct {
+	RestaurantID int64
+	SupplierID   int64
+	From         time.Time
+	To           time.Time
+}
+
+// ReceiptRepository persists goods receipts.
+type ReceiptRepository struct {
+	db *sql.DB
+}
+
+// NewReceipt wires the repository to a sql.DB.
+func NewReceipt(db *sql.DB) *ReceiptRepository {
+	return &ReceiptRepository{db: db}
+}
+
+// Create records a goods receipt against receipt.PurchaseOrderID in one
+// transaction: it stores the lines returned by check, posts the accepted
+// quantities into the restaurant's stock and moves the purchase order to
+// partially_received or, once every line is settled, received. It fills in
+// the generated fields and returns the new status. sql.ErrNoRows means the
+// order does not exist and ErrConflict that it is not awaiting delivery.
+func (r *ReceiptRepository) Create(ctx context.Context, receipt *GoodsReceipt, check ReceiptCheck) (string, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("begin tx: %w", err)
+	}
+
+	const lockPurchaseOrder = `SELECT restaurant_id, status FROM purchase_orders WHERE id = $1 FOR UPDATE`
+
+	var (
+		restaurantID int64
+		status       string
+	)
+	err = tx.QueryRowContext(ctx, lockPurchaseOrder, receipt.PurchaseOrderID).Scan(&restaurantID, &status)
+	if errors.Is(err, sql.ErrNoRows) {
+		tx.Rollback()
+		return "", sql.ErrNoRows
+	}
+	if err != nil {
+		tx.Rollback()
+		return "", fmt.Errorf("lock purchase order: %w", err)
+	}
+	if status != PurchaseOrderPlaced && status != PurchaseOrderPartiallyReceived {
+		tx.Rollback()
+		return "", ErrConflict
+	}
+
+	receivable, err := receivableLines(ctx, tx, receipt.PurchaseOrderID)
+	if err != nil {
+		tx.Rollback()
+		return "", err
+	}
+
+	lines, err := check(receivable)
+	if err != nil {
+		tx.Rollback()
+		return "", err
+	}
+
+	const insertReceipt = `
+INSERT INTO goods_receipts (purchase_order_id, note, received_by)
+VALUES ($1, $2, NULLIF($3, 0))
+RETURNING id, received_at`
+
+	if err := tx.QueryRowContext(ctx, insertReceipt, receipt.PurchaseOrderID, receipt.Note, receipt.ReceivedBy).
+		Scan(&receipt.ID, &receipt.ReceivedAt); err != nil {
+		tx.Rollback()
+		return "", fmt.Errorf("insert goods receipt: %w", err)
+	}
+	receipt.ReceivedAt = receipt.ReceivedAt.UTC()
+
+	const insertLine = `
+INSERT INTO goods_receipt_lines (receipt_id, order_id, received_quantity, rejected_quantity, reject_reason, lot_number, expires_on)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+RETURNING id`
+
+	settled := make(map[int64]decimal.Decimal, len(lines))
+	var movements []StockMovement
+	for i := range lines {
+		line := &lines[i]
+		line.ReceiptID = receipt.ID
+
+		var expiresOn sql.NullTime
+		if !line.ExpiresOn.IsZero() {
+			expiresOn = sql.NullTime{Time: line.ExpiresOn, Valid: true}
+		}
+		if err := tx.QueryRowContext(ctx, insertLine, receipt.ID, line.OrderID, line.Received, line.Rejected, line.RejectReason, line.LotNumber, expiresOn).
+			Scan(&line.ID); err != nil {
+			tx.Rollback()
+			return "", fmt.Errorf("insert goods receipt line: %w", err)
+		}
+		settled[line.OrderID] = settled[line.OrderID].Add(line.Received).Add(line.Rejected)
+
+		if line.Received.IsPositive() {
+			movements = append(movements, StockMovement{
+				RestaurantID:    restaurantID,
+				IngredientID:    line.IngredientID,
+				Kind:            StockReceipt,
+				Quantity:        line.Received,
+				PurchaseOrderID: receipt.PurchaseOrderID,
+				OrderID:         line.OrderID,
+				GoodsReceiptID:  receipt.ID,
+				LotNumber:       line.LotNumber,
+				ExpiresOn:       line.ExpiresOn,
+				CreatedBy:       receipt.ReceivedBy,
+			})
+		}
+	}
+
+	movements, err = postMovements(ctx, tx, movements)
+	if err != nil {
+		tx.Rollback()
+		return "", err
+	}
+
+	status = PurchaseOrderReceived
+	for _, line := range receivable {
+		if line.Outstanding().Sub(settled[line.OrderID]).IsPositive() {
+			status = PurchaseOrderPartiallyReceived
+			break
+		}
+	}
+
+	const updateStatus = `
+UPDATE purchase_orders
+SET status = $2, delivered_at = CASE WHEN $2 = 'received' THEN $3::timestamptz END
+WHERE id = $1`
+
+	if _, err := tx.ExecContext(ctx, updateStatus, receipt.PurchaseOrderID, status, receipt.ReceivedAt); err != nil {
+		tx.Rollback()
+		return "", fmt.Errorf("update purchase order status: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("commit goods receipt: %w", err)
+	}
+
+	receipt.Lines = lines
+	receipt.Movements = movements
+	return status, nil
+}
+
+// ReceivableLines returns the lines of a purchase order with the quantities
+// received and rejected so far.
+func (r *ReceiptRepository) ReceivableLines(ctx context.Context, purchaseOrderID int64) ([]ReceivableLine, error) {
+	return receivableLines(ctx, r.db, purchaseOrderID)
+}
+
+func receivableLines(ctx context.Context, q queryer, purchaseOrderID int64) ([]ReceivableLine, error) {
+	const query = `
+SELECT o.id, o.ingredient_id, o.number,
+	COALESCE(SUM(grl.received_quantity), 0),
+	COALESCE(SUM(grl.rejected_quantity), 0)
+FROM orders o
+LEFT JOIN goods_receipt_lines grl ON grl.order_id = o.id
+WHERE o.purchase_order_id = $1
+GROUP BY o.id, o.ingredient_id, o.number
+ORDER BY o.id`
+
+	rows, err := q.QueryContext(ctx, query, purchaseOrderID)
+	if err != nil {
+		return nil, fmt.Errorf("query receivable lines: %w", err)
+	}
+	defer rows.Close()
+
+	var lines []ReceivableLine
+	for rows.Next() {
+		var (
+			line    ReceivableLine
+			ordered int64
+		)
+		if scanErr := rows.Scan(&line.OrderID, &line.IngredientID, &ordered, &line.Received, &line.Rejected); scanErr != nil {
+			return nil, fmt.Errorf("scan receivable line: %w", scanErr)
+		}
+		line.Ordered = decimal.NewFromInt(ordered)
+		lines = append(lines, line)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate receivable lines: %w", err)
+	}
+
+	return lines, nil
+}
+
+// ListByPurchaseOrder returns the receipts of a purchase order with their
+// lines, oldest first.
+func (r *ReceiptRepository) ListByPurchaseOrder(ctx context.Context, purchaseOrderID int64) ([]GoodsReceipt, error) {
+	const query = `
+SELECT gr.id, gr.purchase_order_id, gr.note, COALESCE(gr.received_by, 0), gr.received_at,
+	grl.id, grl.order_id, o.ingredient_id, grl.received_quantity, grl.rejected_quantity, grl.reject_reason, grl.lot_number, grl.expires_on
+FROM goods_receipts gr
+JOIN goods_receipt_lines grl ON grl.receipt_id = gr.id
+JOIN orders o ON o.id = grl.order_id
+WHERE gr.purchase_order_id = $1
+ORDER BY gr.id, grl.id`
+
+	rows, err := r.db.QueryContext(ctx, query, purchaseOrderID)
+	if err != nil {
+		return nil, fmt.Errorf("query goods receipts: %w", err)
+	}
+	defer rows.Close()
+
+	var receipts []GoodsReceipt
+	for rows.Next() {
+		var (
+			receipt   GoodsReceipt
+			line      GoodsReceiptLine
+			expiresOn sql.NullTime
+		)
+		if scanErr := rows.Scan(
+			&receipt.ID,
+			&receipt.PurchaseOrderID,
+			&receipt.Note,
+			&receipt.ReceivedBy,
+			&receipt.ReceivedAt,
+			&line.ID,
+			&line.OrderID,
+			&line.IngredientID,
+			&line.Received,
+			&line.Rejected,
+			&line.RejectReason,
+			&line.LotNumber,
+			&expiresOn,
+		); scanErr != nil {
+			return nil, fmt.Errorf("scan goods receipt: %w", scanErr)
+		}
+		line.ReceiptID = receipt.ID
+		if expiresOn.Valid {
+			line.ExpiresOn = expiresOn.Time.UTC()
+		}
+
+		if n := len(receipts); n == 0 || receipts[n-1].ID != receipt.ID {
+			receipt.ReceivedAt = receipt.ReceivedAt.UTC()
+			receipts = append(receipts, receipt)
+		}
+		last := &receipts[len(receipts)-1]
+		last.Lines = append(last.Lines, line)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate goods receipts: %w", err)
+	}
+
+	return receipts, nil
+}
+
+// Discrepancies compares ordered and received quantities per supplier over
+// purchase orders that received at least one delivery, ordered by supplier
+// name. Outstanding quantities of partially received orders count as
+// discrepancies.
+func (r *ReceiptRepository) Discrepancies(ctx context.Context, filter DiscrepancyFilter) ([]SupplierDiscrepancy, error) {
+	conditions := []string{"po.status IN ('partially_received', 'received')"}
+	var args []interface{}
+	if filter.RestaurantID > 0 {
+		args = append(args, filter.RestaurantID)
+		conditions = append(conditions, fmt.Sprintf("po.restaurant_id = $%d", len(args)))
+	}
+	if filter.SupplierID > 0 {
+		args = append(args, filter.SupplierID)
+		conditions = append(conditions, fmt.Sprintf("po.supplier_id = $%d", len(args)))
+	}
+	if !filter.From.IsZero() {
+		args = append(args, filter.From)
+		conditions = append(conditions, fmt.Sprintf("po.created_at >= $%d", len(args)))
+	}
+	if !filter.To.IsZero() {
+		args = append(args, filter.To)
+		conditions = append(conditions, fmt.Sprintf("po.created_at < $%d", len(args)))
+	}
+
+	query := `
+WITH lines AS (
+	SELECT po.supplier_id, po.id AS purchase_order_id, o.number AS ordered,
+		COALESCE(SUM(grl.received_quantity), 0) AS received,
+		COALESCE(SUM(grl.rejected_quantity) FILTER (WHERE grl.reject_reason = 'damaged'), 0) AS damaged,
+		COALESCE(SUM(grl.rejected_quantity) FILTER (WHERE grl.reject_reason = 'wrong_item'), 0) AS wrong_item,
+		COALESCE(SUM(grl.rejected_quantity) FILTER (WHERE grl.reject_reason = 'short_dated'), 0) AS short_dated,
+		COALESCE(SUM(grl.rejected_quantity) FILTER (WHERE grl.reject_reason = 'not_delivered'), 0) AS not_delivered
+	FROM purchase_orders po
+	JOIN orders o ON o.purchase_order_id = po.id
+	LEFT JOIN goods_receipt_lines grl ON grl.order_id = o.id
+	WHERE ` + strings.Join(conditions, " AND ") + `
+	GROUP BY po.supplier_id, po.id, o.id, o.number
+)
+SELECT COALESCE(l.supplier_id, 0), COALESCE(s.name, ''),
+	COUNT(DISTINCT l.purchase_order_id), COUNT(*), COUNT(*) FILTER (WHERE l.received <> l.ordered),
+	SUM(l.ordered), SUM(l.received), SUM(l.damaged), SUM(l.wrong_item), SUM(l.short_dated), SUM(l.not_delivered)
+FROM lines l
+LEFT JOIN suppliers s ON s.id = l.supplier_id
+GROUP BY l.supplier_id, s.name
+ORDER BY s.name NULLS LAST, l.supplier_id`
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query discrepancies: %w", err)
+	}
+	defer rows.Close()
+
+	var report []SupplierDiscrepancy
+	for rows.Next() {
+		var (
+			item                                         SupplierDiscrepancy
+			damaged, wrongItem, shortDated, notDelivered decimal.Decimal
+		)
+		if scanErr := rows.Scan(
+			&item.SupplierID,
+			&item.SupplierName,
+			&item.PurchaseOrders,
+			&item.Lines,
+			&item.DiscrepantLines,
+			&item.Ordered,
+			&item.Received,
+			&damaged,
+			&wrongItem,
+			&shortDated,
+			&notDelivered,
+		); scanErr != nil {
+			return nil, fmt.Errorf("scan discrepancy: %w", scanErr)
+		}
+		item.Rejected = map[string]decimal.Decimal{
+			RejectDamaged:      damaged,
+			RejectWrongItem:    wrongItem,
+			RejectShortDated:   shortDated,
+			RejectNotDelivered: notDelivered,
+		}
+		report = append(report, item)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate discrepancies: %w", err)
+	}
+
+	return report, nil
+}
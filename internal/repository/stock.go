@@ -0,0 +1,658 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Stock movement kinds.
+const (
+	StockReceipt     = "receipt"
+	StockConsumption = "consumption"
+	StockWaste       = "waste"
+	StockTransfer    = "transfer"
+	StockAdjustment  = "adjustment"
+)
+
+var (
+	// ErrInsufficientStock indicates a movement would take stock on hand below zero.
+	ErrInsufficientStock = errors.New("insufficient stock")
+	// ErrLotNotFound indicates a lot that does not hold the movement's
+	// ingredient at the movement's restaurant.
+	ErrLotNotFound = errors.New("lot not found")
+)
+
+// StockMovement represents the stock_movements table row. Movements are
+// never updated or deleted; corrections are new adjustment movements.
+type StockMovement struct {
+	ID           int64
+	RestaurantID int64
+	IngredientID int64
+	Kind         string
+	// Quantity is signed: positive movements add stock, negative ones remove it.
+	Quantity decimal.Decimal
+	// CounterpartRestaurantID is the other side of a transfer.
+	CounterpartRestaurantID int64
+	// PurchaseOrderID, OrderID and GoodsReceiptID link receipts to the order
+	// line delivered and the goods receipt that recorded it.
+	PurchaseOrderID int64
+	OrderID         int64
+	GoodsReceiptID  int64
+	// StocktakeID links adjustments to the stocktake that posted them.
+	StocktakeID int64
+	// LotID is the lot the movement added to or drew from. LotNumber and
+	// ExpiresOn describe it; on new stock they pick the lot to add to.
+	LotID     int64
+	LotNumber string
+	// ExpiresOn is zero for stock without an expiry date.
+	ExpiresOn time.Time
+	Note      string
+	CreatedBy int64
+	CreatedAt time.Time
+}
+
+// StockLevel is the stock on hand of one ingredient at a restaurant.
+type StockLevel struct {
+	RestaurantID   int64
+	IngredientID   int64
+	IngredientCode string
+	IngredientName string
+	IngredientType string
+	OnHand         decimal.Decimal
+	LastMovementID int64
+	UpdatedAt      time.Time
+}
+
+// StockLot is the stock on hand of one supplier lot of an ingredient at a
+// restaurant.
+type StockLot struct {
+	ID             int64
+	RestaurantID   int64
+	RestaurantName string
+	IngredientID   int64
+	IngredientCode string
+	IngredientName string
+	// LotNumber is empty for stock received without one.
+	LotNumber string
+	// ExpiresOn is zero for stock without an expiry date.
+	ExpiresOn  time.Time
+	OnHand     decimal.Decimal
+	ReceivedAt time.Time
+	UpdatedAt  time.Time
+}
+
+// LotFilter narrows down lot listings. Zero values match everything.
+type LotFilter struct {
+	RestaurantID int64
+	IngredientID int64
+	LotNumber    string
+	// ExpiresBy keeps lots expiring on or before the date, which excludes
+	// lots without an expiry date.
+	ExpiresBy time.Time
+	// InStock hides lots with nothing on hand.
+	InStock bool
+}
+
+// StockFilter narrows down stock level listings.
+type StockFilter struct {
+	IngredientIDs []int64
+	// Type matches the ingredient type exactly.
+	Type string
+	// Query matches ingredient names case-insensitively by substring.
+	Query string
+	// InStock hides ingredients with nothing on hand.
+	InStock bool
+}
+
+// MovementFilter narrows down stock movement listings. Zero values match everything.
+type MovementFilter struct {
+	IngredientID int64
+	LotID        int64
+	Kind         string
+	// From and To bound created_at to [From, To).
+	From   time.Time
+	To     time.Time
+	Limit  int
+	Offset int
+}
+
+// StockRepository persists the stock ledger and the stock on hand derived from it.
+type StockRepository struct {
+	db *sql.DB
+}
+
+// NewStock wires the repository to a sql.DB.
+func NewStock(db *sql.DB) *StockRepository {
+	return &StockRepository{db: db}
+}
+
+const stockMovementColumns = `sm.id, sm.restaurant_id, sm.ingredient_id, sm.kind, sm.quantity, COALESCE(sm.counterpart_restaurant_id, 0), COALESCE(sm.purchase_order_id, 0), COALESCE(sm.order_id, 0), COALESCE(sm.goods_receipt_id, 0), COALESCE(sm.stocktake_id, 0), COALESCE(sm.lot_id, 0), COALESCE(l.lot_number, ''), l.expires_on, sm.note, COALESCE(sm.created_by, 0), sm.created_at`
+
+// Post appends movements to the ledger and applies them to lots and stock
+// on hand in one transaction. Removals may be split into one movement per
+// lot they draw from; the posted movements are returned with their
+// generated fields. Nothing is stored when any lot or level would drop
+// below zero; ErrInsufficientStock is returned instead.
+func (r *StockRepository) Post(ctx context.Context, movements []StockMovement) ([]StockMovement, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+
+	posted, err := postMovements(ctx, tx, movements)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit stock movements: %w", err)
+	}
+	return posted, nil
+}
+
+// stockKey identifies the stock of one ingredient at one restaurant.
+type stockKey struct{ restaurantID, ingredientID int64 }
+
+// lockStock serializes postings per restaurant and ingredient with
+// transaction-scoped advisory locks, taken in key order so concurrent
+// postings touching the same ingredients cannot deadlock.
+func lockStock(ctx context.Context, tx *sql.Tx, keys []stockKey) error {
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].restaurantID != keys[j].restaurantID {
+			return keys[i].restaurantID < keys[j].restaurantID
+		}
+		return keys[i].ingredientID < keys[j].ingredientID
+	})
+
+	for _, key := range keys {
+		if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1::int, $2::int)`, key.restaurantID, key.ingredientID); err != nil {
+			return fmt.Errorf("lock stock: %w", err)
+		}
+	}
+	return nil
+}
+
+// postMovements applies movements to stock_lots, inserts one ledger row per
+// lot touched and applies their net effect to stock_levels within tx.
+func postMovements(ctx context.Context, tx *sql.Tx, movements []StockMovement) ([]StockMovement, error) {
+	seen := make(map[stockKey]bool)
+	var keys []stockKey
+	for _, m := range movements {
+		key := stockKey{m.RestaurantID, m.IngredientID}
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	if err := lockStock(ctx, tx, keys); err != nil {
+		return nil, err
+	}
+
+	// Transfers move the lots they draw from: the incoming side of a transfer
+	// mirrors the lots of the outgoing side posted before it.
+	type transferKey struct{ from, to, ingredientID int64 }
+	transferred := make(map[transferKey][]StockMovement)
+
+	var posted []StockMovement
+	for _, m := range movements {
+		var (
+			pieces []StockMovement
+			err    error
+		)
+		switch {
+		case m.Quantity.IsNegative():
+			pieces, err = removeFromLots(ctx, tx, m)
+			if err == nil && m.Kind == StockTransfer {
+				key := transferKey{m.RestaurantID, m.CounterpartRestaurantID, m.IngredientID}
+				transferred[key] = append(transferred[key], pieces...)
+			}
+		case m.Kind == StockTransfer && len(transferred[transferKey{m.CounterpartRestaurantID, m.RestaurantID, m.IngredientID}]) > 0:
+			key := transferKey{m.CounterpartRestaurantID, m.RestaurantID, m.IngredientID}
+			for _, out := range transferred[key] {
+				in := m
+				in.Quantity = out.Quantity.Neg()
+				in.LotID, in.LotNumber, in.ExpiresOn = 0, out.LotNumber, out.ExpiresOn
+				if in.LotID, err = addToLot(ctx, tx, in); err != nil {
+					break
+				}
+				pieces = append(pieces, in)
+			}
+			delete(transferred, key)
+		default:
+			m.LotID, err = addToLot(ctx, tx, m)
+			pieces = []StockMovement{m}
+		}
+		if err != nil {
+			return nil, err
+		}
+		posted = append(posted, pieces...)
+	}
+
+	const insertMovement = `
+INSERT INTO stock_movements (restaurant_id, ingredient_id, kind, quantity, counterpart_restaurant_id, purchase_order_id, order_id, goods_receipt_id, stocktake_id, lot_id, note, created_by)
+VALUES ($1, $2, $3, $4, NULLIF($5, 0), NULLIF($6, 0), NULLIF($7, 0), NULLIF($8, 0), NULLIF($9, 0), $10, $11, NULLIF($12, 0))
+RETURNING id, created_at`
+
+	type levelDelta struct {
+		quantity       decimal.Decimal
+		lastMovementID int64
+	}
+	deltas := make(map[stockKey]*levelDelta)
+
+	for i := range posted {
+		m := &posted[i]
+		if err := tx.QueryRowContext(ctx, insertMovement,
+			m.RestaurantID,
+			m.IngredientID,
+			m.Kind,
+			m.Quantity,
+			m.CounterpartRestaurantID,
+			m.PurchaseOrderID,
+			m.OrderID,
+			m.GoodsReceiptID,
+			m.StocktakeID,
+			m.LotID,
+			m.Note,
+			m.CreatedBy,
+		).Scan(&m.ID, &m.CreatedAt); err != nil {
+			return nil, fmt.Errorf("insert stock movement: %w", err)
+		}
+		m.CreatedAt = m.CreatedAt.UTC()
+
+		key := stockKey{m.RestaurantID, m.IngredientID}
+		delta, ok := deltas[key]
+		if !ok {
+			delta = &levelDelta{}
+			deltas[key] = delta
+		}
+		delta.quantity = delta.quantity.Add(m.Quantity)
+		delta.lastMovementID = m.ID
+	}
+
+	const addStock = `
+INSERT INTO stock_levels (restaurant_id, ingredient_id, on_hand, last_movement_id)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (restaurant_id, ingredient_id) DO UPDATE SET
+	on_hand = stock_levels.on_hand + EXCLUDED.on_hand,
+	last_movement_id = EXCLUDED.last_movement_id,
+	updated_at = NOW()`
+
+	// Lots already refused to go below zero; the condition keeps the levels
+	// honest should they ever disagree with the lots.
+	const removeStock = `
+UPDATE stock_levels
+SET on_hand = on_hand + $3, last_movement_id = $4, updated_at = NOW()
+WHERE restaurant_id = $1 AND ingredient_id = $2 AND on_hand + $3 >= 0`
+
+	for _, key := range keys {
+		delta := deltas[key]
+		if !delta.quantity.IsNegative() {
+			if _, err := tx.ExecContext(ctx, addStock, key.restaurantID, key.ingredientID, delta.quantity, delta.lastMovementID); err != nil {
+				return nil, fmt.Errorf("add stock: %w", err)
+			}
+			continue
+		}
+
+		result, err := tx.ExecContext(ctx, removeStock, key.restaurantID, key.ingredientID, delta.quantity, delta.lastMovementID)
+		if err != nil {
+			return nil, fmt.Errorf("remove stock: %w", err)
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return nil, fmt.Errorf("remove stock: %w", err)
+		}
+		if affected == 0 {
+			return nil, fmt.Errorf("%w: ingredient %d at restaurant %d", ErrInsufficientStock, key.ingredientID, key.restaurantID)
+		}
+	}
+
+	return posted, nil
+}
+
+// addToLot adds a positive movement to its lot and returns the lot id. The
+// lot is m.LotID when set and otherwise the lot matching m.LotNumber and
+// m.ExpiresOn, created as needed.
+func addToLot(ctx context.Context, tx *sql.Tx, m StockMovement) (int64, error) {
+	if m.LotID != 0 {
+		const addToExisting = `
+UPDATE stock_lots SET on_hand = on_hand + $4, updated_at = NOW()
+WHERE id = $1 AND restaurant_id = $2 AND ingredient_id = $3`
+
+		result, err := tx.ExecContext(ctx, addToExisting, m.LotID, m.RestaurantID, m.IngredientID, m.Quantity)
+		if err != nil {
+			return 0, fmt.Errorf("add to lot: %w", err)
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return 0, fmt.Errorf("add to lot: %w", err)
+		}
+		if affected == 0 {
+			return 0, fmt.Errorf("%w: lot %d", ErrLotNotFound, m.LotID)
+		}
+		return m.LotID, nil
+	}
+
+	const upsertLot = `
+INSERT INTO stock_lots (restaurant_id, ingredient_id, lot_number, expires_on, on_hand)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (restaurant_id, ingredient_id, lot_number, (COALESCE(expires_on, 'infinity'::date))) DO UPDATE SET
+	on_hand = stock_lots.on_hand + EXCLUDED.on_hand,
+	updated_at = NOW()
+RETURNING id`
+
+	var expiresOn sql.NullTime
+	if !m.ExpiresOn.IsZero() {
+		expiresOn = sql.NullTime{Time: m.ExpiresOn, Valid: true}
+	}
+
+	var lotID int64
+	if err := tx.QueryRowContext(ctx, upsertLot, m.RestaurantID, m.IngredientID, m.LotNumber, expiresOn, m.Quantity).Scan(&lotID); err != nil {
+		return 0, fmt.Errorf("upsert lot: %w", err)
+	}
+	return lotID, nil
+}
+
+// removeFromLots takes a negative movement out of m.LotID or, when no lot
+// is given, out of the lots expiring first, and returns one movement per
+// lot drawn from. Consumption skips expired lots.
+func removeFromLots(ctx context.Context, tx *sql.Tx, m StockMovement) ([]StockMovement, error) {
+	const selectLots = `
+SELECT id, lot_number, expires_on, on_hand
+FROM stock_lots
+WHERE restaurant_id = $1 AND ingredient_id = $2 AND on_hand > 0
+	AND ($3 = 0 OR id = $3)
+	AND (NOT $4 OR expires_on IS NULL OR expires_on >= CURRENT_DATE)
+ORDER BY expires_on NULLS LAST, received_at, id`
+
+	type lotBalance struct {
+		id        int64
+		lotNumber string
+		expiresOn time.Time
+		onHand    decimal.Decimal
+	}
+
+	rows, err := tx.QueryContext(ctx, selectLots, m.RestaurantID, m.IngredientID, m.LotID, m.Kind == StockConsumption && m.LotID == 0)
+	if err != nil {
+		return nil, fmt.Errorf("query lots: %w", err)
+	}
+
+	var lots []lotBalance
+	for rows.Next() {
+		var (
+			lot       lotBalance
+			expiresOn sql.NullTime
+		)
+		if err := rows.Scan(&lot.id, &lot.lotNumber, &expiresOn, &lot.onHand); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scan lot: %w", err)
+		}
+		if expiresOn.Valid {
+			lot.expiresOn = expiresOn.Time.UTC()
+		}
+		lots = append(lots, lot)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate lots: %w", err)
+	}
+
+	if m.LotID != 0 && len(lots) == 0 {
+		var exists bool
+		const lotExists = `SELECT EXISTS (SELECT 1 FROM stock_lots WHERE id = $1 AND restaurant_id = $2 AND ingredient_id = $3)`
+		if err := tx.QueryRowContext(ctx, lotExists, m.LotID, m.RestaurantID, m.IngredientID).Scan(&exists); err != nil {
+			return nil, fmt.Errorf("check lot: %w", err)
+		}
+		if !exists {
+			return nil, fmt.Errorf("%w: lot %d", ErrLotNotFound, m.LotID)
+		}
+	}
+
+	const takeFromLot = `UPDATE stock_lots SET on_hand = on_hand - $2, updated_at = NOW() WHERE id = $1`
+
+	remaining := m.Quantity.Neg()
+	var pieces []StockMovement
+	for _, lot := range lots {
+		if !remaining.IsPositive() {
+			break
+		}
+		take := decimal.Min(remaining, lot.onHand)
+		if _, err := tx.ExecContext(ctx, takeFromLot, lot.id, take); err != nil {
+			return nil, fmt.Errorf("take from lot: %w", err)
+		}
+
+		piece := m
+		piece.Quantity = take.Neg()
+		piece.LotID, piece.LotNumber, piece.ExpiresOn = lot.id, lot.lotNumber, lot.expiresOn
+		pieces = append(pieces, piece)
+		remaining = remaining.Sub(take)
+	}
+	if remaining.IsPositive() {
+		return nil, fmt.Errorf("%w: ingredient %d at restaurant %d", ErrInsufficientStock, m.IngredientID, m.RestaurantID)
+	}
+
+	return pieces, nil
+}
+
+// Levels returns the stock on hand of a restaurant ordered by ingredient name.
+// Ingredients that never had a movement are not listed.
+func (r *StockRepository) Levels(ctx context.Context, restaurantID int64, filter StockFilter) ([]StockLevel, error) {
+	conditions := []string{"sl.restaurant_id = $1"}
+	args := []interface{}{restaurantID}
+	if len(filter.IngredientIDs) > 0 {
+		args = append(args, filter.IngredientIDs)
+		conditions = append(conditions, fmt.Sprintf("sl.ingredient_id = ANY($%d)", len(args)))
+	}
+	if t := strings.TrimSpace(filter.Type); t != "" {
+		args = append(args, t)
+		conditions = append(conditions, fmt.Sprintf("i.type = $%d", len(args)))
+	}
+	if q := strings.TrimSpace(filter.Query); q != "" {
+		args = append(args, "%"+escapeLike(q)+"%")
+		conditions = append(conditions, fmt.Sprintf("i.name ILIKE $%d", len(args)))
+	}
+	if filter.InStock {
+		conditions = append(conditions, "sl.on_hand > 0")
+	}
+
+	query := `
+SELECT sl.restaurant_id, sl.ingredient_id, i.code, i.name, i.type, sl.on_hand, sl.last_movement_id, sl.updated_at
+FROM stock_levels sl
+JOIN ingredients i ON i.id = sl.ingredient_id
+WHERE ` + strings.Join(conditions, " AND ") + `
+ORDER BY i.name, i.id`
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query stock levels: %w", err)
+	}
+	defer rows.Close()
+
+	var levels []StockLevel
+	for rows.Next() {
+		var level StockLevel
+		if scanErr := rows.Scan(
+			&level.RestaurantID,
+			&level.IngredientID,
+			&level.IngredientCode,
+			&level.IngredientName,
+			&level.IngredientType,
+			&level.OnHand,
+			&level.LastMovementID,
+			&level.UpdatedAt,
+		); scanErr != nil {
+			return nil, fmt.Errorf("scan stock level: %w", scanErr)
+		}
+		level.UpdatedAt = level.UpdatedAt.UTC()
+		levels = append(levels, level)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate stock levels: %w", err)
+	}
+
+	return levels, nil
+}
+
+// Movements returns the ledger of a restaurant matching the filter, newest first.
+func (r *StockRepository) Movements(ctx context.Context, restaurantID int64, filter MovementFilter) ([]StockMovement, error) {
+	conditions := []string{"sm.restaurant_id = $1"}
+	args := []interface{}{restaurantID}
+	if filter.IngredientID > 0 {
+		args = append(args, filter.IngredientID)
+		conditions = append(conditions, fmt.Sprintf("sm.ingredient_id = $%d", len(args)))
+	}
+	if filter.LotID > 0 {
+		args = append(args, filter.LotID)
+		conditions = append(conditions, fmt.Sprintf("sm.lot_id = $%d", len(args)))
+	}
+	if filter.Kind != "" {
+		args = append(args, filter.Kind)
+		conditions = append(conditions, fmt.Sprintf("sm.kind = $%d", len(args)))
+	}
+	if !filter.From.IsZero() {
+		args = append(args, filter.From)
+		conditions = append(conditions, fmt.Sprintf("sm.created_at >= $%d", len(args)))
+	}
+	if !filter.To.IsZero() {
+		args = append(args, filter.To)
+		conditions = append(conditions, fmt.Sprintf("sm.created_at < $%d", len(args)))
+	}
+
+	query := `SELECT ` + stockMovementColumns + ` FROM stock_movements sm LEFT JOIN stock_lots l ON l.id = sm.lot_id WHERE ` + strings.Join(conditions, " AND ")
+	args = append(args, filter.Limit, filter.Offset)
+	query += fmt.Sprintf(" ORDER BY sm.id DESC LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query stock movements: %w", err)
+	}
+	defer rows.Close()
+
+	var movements []StockMovement
+	for rows.Next() {
+		var (
+			m         StockMovement
+			expiresOn sql.NullTime
+		)
+		if scanErr := rows.Scan(
+			&m.ID,
+			&m.RestaurantID,
+			&m.IngredientID,
+			&m.Kind,
+			&m.Quantity,
+			&m.CounterpartRestaurantID,
+			&m.PurchaseOrderID,
+			&m.OrderID,
+			&m.GoodsReceiptID,
+			&m.StocktakeID,
+			&m.LotID,
+			&m.LotNumber,
+			&expiresOn,
+			&m.Note,
+			&m.CreatedBy,
+			&m.CreatedAt,
+		); scanErr != nil {
+			return nil, fmt.Errorf("scan stock movement: %w", scanErr)
+		}
+		m.CreatedAt = m.CreatedAt.UTC()
+		if expiresOn.Valid {
+			m.ExpiresOn = expiresOn.Time.UTC()
+		}
+		movements = append(movements, m)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate stock movements: %w", err)
+	}
+
+	return movements, nil
+}
+
+// ListLots returns lots matching the filter ordered by expiry date, lots
+// without one last.
+func (r *StockRepository) ListLots(ctx context.Context, filter LotFilter) ([]StockLot, error) {
+	var (
+		conditions []string
+		args       []interface{}
+	)
+	if filter.RestaurantID > 0 {
+		args = append(args, filter.RestaurantID)
+		conditions = append(conditions, fmt.Sprintf("l.restaurant_id = $%d", len(args)))
+	}
+	if filter.IngredientID > 0 {
+		args = append(args, filter.IngredientID)
+		conditions = append(conditions, fmt.Sprintf("l.ingredient_id = $%d", len(args)))
+	}
+	if filter.LotNumber != "" {
+		args = append(args, filter.LotNumber)
+		conditions = append(conditions, fmt.Sprintf("l.lot_number = $%d", len(args)))
+	}
+	if !filter.ExpiresBy.IsZero() {
+		args = append(args, filter.ExpiresBy)
+		conditions = append(conditions, fmt.Sprintf("l.expires_on <= $%d", len(args)))
+	}
+	if filter.InStock {
+		conditions = append(conditions, "l.on_hand > 0")
+	}
+
+	query := `
+SELECT l.id, l.restaurant_id, r.name, l.ingredient_id, i.code, i.name, l.lot_number, l.expires_on, l.on_hand, l.received_at, l.updated_at
+FROM stock_lots l
+JOIN restaurants r ON r.id = l.restaurant_id
+JOIN ingredients i ON i.id = l.ingredient_id`
+	if len(conditions) > 0 {
+		query += `
+WHERE ` + strings.Join(conditions, " AND ")
+	}
+	query += `
+ORDER BY l.expires_on NULLS LAST, i.name, l.restaurant_id, l.id`
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query stock lots: %w", err)
+	}
+	defer rows.Close()
+
+	var lots []StockLot
+	for rows.Next() {
+		var (
+			lot       StockLot
+			expiresOn sql.NullTime
+		)
+		if scanErr := rows.Scan(
+			&lot.ID,
+			&lot.RestaurantID,
+			&lot.RestaurantName,
+			&lot.IngredientID,
+			&lot.IngredientCode,
+			&lot.IngredientName,
+			&lot.LotNumber,
+			&expiresOn,
+			&lot.OnHand,
+			&lot.ReceivedAt,
+			&lot.UpdatedAt,
+		); scanErr != nil {
+			return nil, fmt.Errorf("scan stock lot: %w", scanErr)
+		}
+		if expiresOn.Valid {
+			lot.ExpiresOn = expiresOn.Time.UTC()
+		}
+		lot.ReceivedAt = lot.ReceivedAt.UTC()
+		lot.UpdatedAt = lot.UpdatedAt.UTC()
+		lots = append(lots, lot)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate stock lots: %w", err)
+	}
+
+	return lots, nil
+}
@@ -0,0 +1,525 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Stocktake statuses.
+const (
+	StocktakeOpen      = "open"
+	StocktakePosted    = "posted"
+	StocktakeCancelled = "cancelled"
+)
+
+// Stocktake represents the stocktakes table row: one count of a
+// restaurant's stock.
+type Stocktake struct {
+	ID           int64
+	RestaurantID int64
+	Status       string
+	Note         string
+	OpenedBy     int64
+	OpenedAt     time.Time
+	// ClosedBy and ClosedAt record who posted or cancelled the stocktake.
+	ClosedBy int64
+	ClosedAt time.Time
+	Lines    []StocktakeLine
+	// Summary is filled in by List.
+	Summary StocktakeSummary
+}
+
+// StocktakeLine compares the snapshot of one ingredient with its count.
+type StocktakeLine struct {
+	IngredientID   int64
+	IngredientCode string
+	IngredientName string
+	// Expected is the stock on hand when the stocktake was opened; zero for
+	// ingredients counted without being in stock.
+	Expected decimal.Decimal
+	// Counted sums the counts of all devices; it is null until counted.
+	Counted decimal.NullDecimal
+	Devices int
+	// Adjustment is the quantity posted; null while the stocktake is open
+	// and for lines that were not counted.
+	Adjustment decimal.NullDecimal
+}
+
+// Variance returns the counted quantity minus the expected one, or zero
+// for lines that were not counted.
+func (l StocktakeLine) Variance() decimal.Decimal {
+	if !l.Counted.Valid {
+		return decimal.Zero
+	}
+	return l.Counted.Decimal.Sub(l.Expected)
+}
+
+// StocktakeSummary aggregates the lines of a stocktake.
+type StocktakeSummary struct {
+	Lines   int
+	Counted int
+	// Variance nets surpluses against shortages; Shrinkage only sums the
+	// shortages, as a positive quantity.
+	Variance  decimal.Decimal
+	Shrinkage decimal.Decimal
+}
+
+// StocktakeCount is the quantity of an ingredient counted on one device.
+type StocktakeCount struct {
+	IngredientID int64
+	Quantity     decimal.Decimal
+}
+
+// StocktakeFilter narrows down stocktake listings. Zero values match everything.
+type StocktakeFilter struct {
+	Status string
+	Limit  int
+	Offset int
+}
+
+// IngredientVariance aggregates the posted variances of one ingredient.
+type IngredientVariance struct {
+	IngredientID   int64
+	IngredientCode string
+	IngredientName string
+	Stocktakes     int
+	Expected       decimal.Decimal
+	Counted        decimal.Decimal
+	// Variance nets surpluses against shortages; Shrinkage only sums the
+	// shortages, as a positive quantity.
+	Variance  decimal.Decimal
+	Shrinkage decimal.Decimal
+}
+
+// VarianceFilter narrows down the variance report to posted stocktakes.
+// Zero values match everything; From and To bound the posting time to [From, To).
+type VarianceFilter struct {
+	IngredientID int64
+	From         time.Time
+	To           time.Time
+}
+
+// StocktakeRepository persists stocktakes.
+type StocktakeRepository struct {
+	db *sql.DB
+}
+
+// NewStocktake wires the repository to a sql.DB.
+func NewStocktake(db *sql.DB) *StocktakeRepository {
+	return &StocktakeRepository{db: db}
+}
+
+const stocktakeColumns = `id, restaurant_id, status, note, COALESCE(opened_by, 0), opened_at, COALESCE(closed_by, 0), closed_at`
+
+func scanStocktake(row rowScanner, extra ...interface{}) (*Stocktake, error) {
+	var (
+		st       Stocktake
+		closedAt sql.NullTime
+	)
+	dest := append([]interface{}{
+		&st.ID,
+		&st.RestaurantID,
+		&st.Status,
+		&st.Note,
+		&st.OpenedBy,
+		&st.OpenedAt,
+		&st.ClosedBy,
+		&closedAt,
+	}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	st.OpenedAt = st.OpenedAt.UTC()
+	if closedAt.Valid {
+		st.ClosedAt = closedAt.Time.UTC()
+	}
+	return &st, nil
+}
+
+// Open starts a stocktake and snapshots the restaurant's stock on hand as
+// the expected quantities. It returns ErrConflict when the restaurant
+// already has an open stocktake.
+func (r *StocktakeRepository) Open(ctx context.Context, st *Stocktake) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+
+	const insertStocktake = `
+INSERT INTO stocktakes (restaurant_id, status, note, opened_by)
+VALUES ($1, 'open', $2, NULLIF($3, 0))
+RETURNING id, status, opened_at`
+
+	err = tx.QueryRowContext(ctx, insertStocktake, st.RestaurantID, st.Note, st.OpenedBy).Scan(&st.ID, &st.Status, &st.OpenedAt)
+	if err != nil {
+		tx.Rollback()
+		if isConstraintViolation(err) {
+			return ErrConflict
+		}
+		return fmt.Errorf("insert stocktake: %w", err)
+	}
+	st.OpenedAt = st.OpenedAt.UTC()
+
+	const snapshot = `
+INSERT INTO stocktake_lines (stocktake_id, ingredient_id, expected)
+SELECT $1, ingredient_id, on_hand FROM stock_levels WHERE restaurant_id = $2 AND on_hand > 0`
+
+	if _, err := tx.ExecContext(ctx, snapshot, st.ID, st.RestaurantID); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("snapshot stock: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit stocktake: %w", err)
+	}
+	return nil
+}
+
+// Get fetches a stocktake with its lines ordered by ingredient name.
+func (r *StocktakeRepository) Get(ctx context.Context, id int64) (*Stocktake, error) {
+	st, err := scanStocktake(r.db.QueryRowContext(ctx, `SELECT `+stocktakeColumns+` FROM stocktakes WHERE id = $1`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, sql.ErrNoRows
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get stocktake: %w", err)
+	}
+
+	st.Lines, err = stocktakeLines(ctx, r.db, id)
+	if err != nil {
+		return nil, err
+	}
+	return st, nil
+}
+
+func stocktakeLines(ctx context.Context, q queryer, stocktakeID int64) ([]StocktakeLine, error) {
+	const query = `
+SELECT sl.ingredient_id, i.code, i.name, sl.expected, SUM(c.quantity), COUNT(c.device), sl.adjustment
+FROM stocktake_lines sl
+JOIN ingredients i ON i.id = sl.ingredient_id
+LEFT JOIN stocktake_counts c ON c.stocktake_id = sl.stocktake_id AND c.ingredient_id = sl.ingredient_id
+WHERE sl.stocktake_id = $1
+GROUP BY sl.ingredient_id, i.code, i.name, sl.expected, sl.adjustment
+ORDER BY i.name, sl.ingredient_id`
+
+	rows, err := q.QueryContext(ctx, query, stocktakeID)
+	if err != nil {
+		return nil, fmt.Errorf("query stocktake lines: %w", err)
+	}
+	defer rows.Close()
+
+	var lines []StocktakeLine
+	for rows.Next() {
+		var line StocktakeLine
+		if scanErr := rows.Scan(
+			&line.IngredientID,
+			&line.IngredientCode,
+			&line.IngredientName,
+			&line.Expected,
+			&line.Counted,
+			&line.Devices,
+			&line.Adjustment,
+		); scanErr != nil {
+			return nil, fmt.Errorf("scan stocktake line: %w", scanErr)
+		}
+		lines = append(lines, line)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate stocktake lines: %w", err)
+	}
+
+	return lines, nil
+}
+
+// List returns the stocktakes of a restaurant with their summaries, newest first.
+func (r *StocktakeRepository) List(ctx context.Context, restaurantID int64, filter StocktakeFilter) ([]Stocktake, error) {
+	conditions := []string{"st.restaurant_id = $1"}
+	args := []interface{}{restaurantID}
+	if filter.Status != "" {
+		args = append(args, filter.Status)
+		conditions = append(conditions, fmt.Sprintf("st.status = $%d", len(args)))
+	}
+
+	query := `
+WITH lines AS (
+	SELECT sl.stocktake_id, sl.expected, SUM(c.quantity) AS counted
+	FROM stocktake_lines sl
+	JOIN stocktakes st ON st.id = sl.stocktake_id
+	LEFT JOIN stocktake_counts c ON c.stocktake_id = sl.stocktake_id AND c.ingredient_id = sl.ingredient_id
+	WHERE ` + strings.Join(conditions, " AND ") + `
+	GROUP BY sl.stocktake_id, sl.ingredient_id, sl.expected
+)
+SELECT st.id, st.restaurant_id, st.status, st.note, COALESCE(st.opened_by, 0), st.opened_at, COALESCE(st.closed_by, 0), st.closed_at,
+	COUNT(l.stocktake_id), COUNT(l.counted),
+	COALESCE(SUM(l.counted - l.expected), 0),
+	COALESCE(SUM(l.expected - l.counted) FILTER (WHERE l.counted < l.expected), 0)
+FROM stocktakes st
+LEFT JOIN lines l ON l.stocktake_id = st.id
+WHERE ` + strings.Join(conditions, " AND ") + `
+GROUP BY st.id`
+	args = append(args, filter.Limit, filter.Offset)
+	query += fmt.Sprintf(" ORDER BY st.id DESC LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query stocktakes: %w", err)
+	}
+	defer rows.Close()
+
+	var stocktakes []Stocktake
+	for rows.Next() {
+		var summary StocktakeSummary
+		st, scanErr := scanStocktake(rows, &summary.Lines, &summary.Counted, &summary.Variance, &summary.Shrinkage)
+		if scanErr != nil {
+			return nil, fmt.Errorf("scan stocktake: %w", scanErr)
+		}
+		st.Summary = summary
+		stocktakes = append(stocktakes, *st)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate stocktakes: %w", err)
+	}
+
+	return stocktakes, nil
+}
+
+// SaveCounts records the counts of one device, replacing what the device
+// submitted earlier for the same ingredients. Ingredients missing from the
+// snapshot are added with nothing expected. It returns sql.ErrNoRows when
+// the stocktake does not exist and ErrConflict when it is no longer open.
+func (r *StocktakeRepository) SaveCounts(ctx context.Context, stocktakeID int64, device string, countedBy int64, counts []StocktakeCount) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+
+	// A shared lock lets devices count concurrently while keeping Post,
+	// which locks the row exclusively, from running in between.
+	var status string
+	err = tx.QueryRowContext(ctx, `SELECT status FROM stocktakes WHERE id = $1 FOR SHARE`, stocktakeID).Scan(&status)
+	if errors.Is(err, sql.ErrNoRows) {
+		tx.Rollback()
+		return sql.ErrNoRows
+	}
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("lock stocktake: %w", err)
+	}
+	if status != StocktakeOpen {
+		tx.Rollback()
+		return ErrConflict
+	}
+
+	const ensureLine = `
+INSERT INTO stocktake_lines (stocktake_id, ingredient_id, expected)
+VALUES ($1, $2, 0)
+ON CONFLICT (stocktake_id, ingredient_id) DO NOTHING`
+
+	const upsertCount = `
+INSERT INTO stocktake_counts (stocktake_id, ingredient_id, device, quantity, counted_by)
+VALUES ($1, $2, $3, $4, NULLIF($5, 0))
+ON CONFLICT (stocktake_id, ingredient_id, device) DO UPDATE SET
+	quantity = EXCLUDED.quantity,
+	counted_by = EXCLUDED.counted_by,
+	counted_at = NOW()`
+
+	for _, count := range counts {
+		if _, err := tx.ExecContext(ctx, ensureLine, stocktakeID, count.IngredientID); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("add stocktake line: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, upsertCount, stocktakeID, count.IngredientID, device, count.Quantity, countedBy); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("save stocktake count: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit stocktake counts: %w", err)
+	}
+	return nil
+}
+
+// Post closes an open stocktake in one transaction: every counted line
+// whose count differs from the snapshot becomes an adjustment movement, so
+// movements booked while counting are kept. The stocktake row is locked
+// for the duration, so it is posted at most once and no count slips in.
+// It returns the stocktake with its lines and the posted movements,
+// sql.ErrNoRows when it does not exist and ErrConflict when it is not open.
+func (r *StocktakeRepository) Post(ctx context.Context, id, postedBy int64) (*Stocktake, []StockMovement, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("begin tx: %w", err)
+	}
+
+	st, err := scanStocktake(tx.QueryRowContext(ctx, `SELECT `+stocktakeColumns+` FROM stocktakes WHERE id = $1 FOR UPDATE`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		tx.Rollback()
+		return nil, nil, sql.ErrNoRows
+	}
+	if err != nil {
+		tx.Rollback()
+		return nil, nil, fmt.Errorf("lock stocktake: %w", err)
+	}
+	if st.Status != StocktakeOpen {
+		tx.Rollback()
+		return nil, nil, ErrConflict
+	}
+
+	lines, err := stocktakeLines(ctx, tx, id)
+	if err != nil {
+		tx.Rollback()
+		return nil, nil, err
+	}
+
+	var movements []StockMovement
+	for _, line := range lines {
+		if variance := line.Variance(); !variance.IsZero() {
+			movements = append(movements, StockMovement{
+				RestaurantID: st.RestaurantID,
+				IngredientID: line.IngredientID,
+				Kind:         StockAdjustment,
+				Quantity:     variance,
+				StocktakeID:  id,
+				Note:         fmt.Sprintf("stocktake %d", id),
+				CreatedBy:    postedBy,
+			})
+		}
+	}
+
+	movements, err = postMovements(ctx, tx, movements)
+	if err != nil {
+		tx.Rollback()
+		return nil, nil, err
+	}
+
+	const recordAdjustments = `
+UPDATE stocktake_lines sl
+SET adjustment = counted.quantity - sl.expected
+FROM (
+	SELECT ingredient_id, SUM(quantity) AS quantity
+	FROM stocktake_counts
+	WHERE stocktake_id = $1
+	GROUP BY ingredient_id
+) counted
+WHERE sl.stocktake_id = $1 AND sl.ingredient_id = counted.ingredient_id`
+
+	if _, err := tx.ExecContext(ctx, recordAdjustments, id); err != nil {
+		tx.Rollback()
+		return nil, nil, fmt.Errorf("record stocktake adjustments: %w", err)
+	}
+
+	const closeStocktake = `
+UPDATE stocktakes SET status = 'posted', closed_by = NULLIF($2, 0), closed_at = NOW()
+WHERE id = $1
+RETURNING closed_at`
+
+	if err := tx.QueryRowContext(ctx, closeStocktake, id, postedBy).Scan(&st.ClosedAt); err != nil {
+		tx.Rollback()
+		return nil, nil, fmt.Errorf("post stocktake: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, nil, fmt.Errorf("commit stocktake: %w", err)
+	}
+
+	st.Status = StocktakePosted
+	st.ClosedBy = postedBy
+	st.ClosedAt = st.ClosedAt.UTC()
+	for i := range lines {
+		if lines[i].Counted.Valid {
+			lines[i].Adjustment = decimal.NewNullDecimal(lines[i].Variance())
+		}
+	}
+	st.Lines = lines
+	return st, movements, nil
+}
+
+// Cancel closes an open stocktake without touching stock. It returns
+// ErrConflict when the stocktake is not open.
+func (r *StocktakeRepository) Cancel(ctx context.Context, id, cancelledBy int64) error {
+	const query = `
+UPDATE stocktakes SET status = 'cancelled', closed_by = NULLIF($2, 0), closed_at = NOW()
+WHERE id = $1 AND status = 'open'`
+
+	result, err := r.db.ExecContext(ctx, query, id, cancelledBy)
+	if err != nil {
+		return fmt.Errorf("cancel stocktake: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("cancel stocktake: %w", err)
+	}
+	if affected == 0 {
+		return ErrConflict
+	}
+	return nil
+}
+
+// Variances aggregates the posted variances of a restaurant per
+// ingredient, largest shrinkage first.
+func (r *StocktakeRepository) Variances(ctx context.Context, restaurantID int64, filter VarianceFilter) ([]IngredientVariance, error) {
+	conditions := []string{"st.restaurant_id = $1", "st.status = 'posted'", "sl.adjustment IS NOT NULL"}
+	args := []interface{}{restaurantID}
+	if filter.IngredientID > 0 {
+		args = append(args, filter.IngredientID)
+		conditions = append(conditions, fmt.Sprintf("sl.ingredient_id = $%d", len(args)))
+	}
+	if !filter.From.IsZero() {
+		args = append(args, filter.From)
+		conditions = append(conditions, fmt.Sprintf("st.closed_at >= $%d", len(args)))
+	}
+	if !filter.To.IsZero() {
+		args = append(args, filter.To)
+		conditions = append(conditions, fmt.Sprintf("st.closed_at < $%d", len(args)))
+	}
+
+	query := `
+SELECT sl.ingredient_id, i.code, i.name, COUNT(*),
+	SUM(sl.expected), SUM(sl.expected + sl.adjustment), SUM(sl.adjustment),
+	COALESCE(-SUM(sl.adjustment) FILTER (WHERE sl.adjustment < 0), 0)
+FROM stocktake_lines sl
+JOIN stocktakes st ON st.id = sl.stocktake_id
+JOIN ingredients i ON i.id = sl.ingredient_id
+WHERE ` + strings.Join(conditions, " AND ") + `
+GROUP BY sl.ingredient_id, i.code, i.name
+ORDER BY 8 DESC, i.name`
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query stocktake variances: %w", err)
+	}
+	defer rows.Close()
+
+	var variances []IngredientVariance
+	for rows.Next() {
+		var v IngredientVariance
+		if scanErr := rows.Scan(
+			&v.IngredientID,
+			&v.IngredientCode,
+			&v.IngredientName,
+			&v.Stocktakes,
+			&v.Expected,
+			&v.Counted,
+			&v.Variance,
+			&v.Shrinkage,
+		); scanErr != nil {
+			return nil, fmt.Errorf("scan stocktake variance: %w", scanErr)
+		}
+		variances = append(variances, v)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate stocktake variances: %w", err)
+	}
+
+	return variances, nil
+}
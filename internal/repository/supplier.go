@@ -0,0 +1,319 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Supplier represents the suppliers table row.
+type Supplier struct {
+	ID        int64
+	Code      string
+	Name      string
+	Email     string
+	Phone     string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// SupplierIngredient holds the terms under which a supplier delivers an
+// ingredient. Quantities are in the ingredient's ordering unit.
+type SupplierIngredient struct {
+	SupplierID   int64
+	SupplierName string
+	IngredientID int64
+	SKU          string
+	// PackSize is the number of units per pack; orders must be whole packs.
+	PackSize     int
+	LeadTimeDays int
+	MinOrderQty  int
+	Price        decimal.Decimal
+	// Preferred marks the supplier chosen when an order line does not name one.
+	Preferred bool
+	UpdatedAt time.Time
+}
+
+// SupplierRepository persists suppliers and their ingredient terms.
+type SupplierRepository struct {
+	db *sql.DB
+}
+
+// NewSupplier wires the repository to a sql.DB.
+func NewSupplier(db *sql.DB) *SupplierRepository {
+	return &SupplierRepository{db: db}
+}
+
+const supplierColumns = `id, code, name, email, phone, created_at, updated_at`
+
+// Create inserts a supplier and fills in the generated fields. A duplicate
+// code is reported as ErrConflict.
+func (r *SupplierRepository) Create(ctx context.Context, supplier *Supplier) error {
+	const query = `
+INSERT INTO suppliers (code, name, email, phone)
+VALUES ($1, $2, $3, $4)
+RETURNING id, created_at, updated_at`
+
+	err := r.db.QueryRowContext(ctx, query, supplier.Code, supplier.Name, supplier.Email, supplier.Phone).
+		Scan(&supplier.ID, &supplier.CreatedAt, &supplier.UpdatedAt)
+	if err != nil {
+		if isConstraintViolation(err) {
+			return ErrConflict
+		}
+		return fmt.Errorf("insert supplier: %w", err)
+	}
+
+	supplier.CreatedAt = supplier.CreatedAt.UTC()
+	supplier.UpdatedAt = supplier.UpdatedAt.UTC()
+	return nil
+}
+
+// Get fetches an active supplier by identifier.
+func (r *SupplierRepository) Get(ctx context.Context, id int64) (*Supplier, error) {
+	query := `SELECT ` + supplierColumns + ` FROM suppliers WHERE id = $1 AND deleted_at IS NULL`
+
+	supplier, err := scanSupplier(r.db.QueryRowContext(ctx, query, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, sql.ErrNoRows
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get supplier: %w", err)
+	}
+
+	return supplier, nil
+}
+
+// List returns every active supplier ordered by name.
+func (r *SupplierRepository) List(ctx context.Context) ([]Supplier, error) {
+	query := `SELECT ` + supplierColumns + ` FROM suppliers WHERE deleted_at IS NULL ORDER BY name, id`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("query suppliers: %w", err)
+	}
+	defer rows.Close()
+
+	var suppliers []Supplier
+	for rows.Next() {
+		supplier, scanErr := scanSupplier(rows)
+		if scanErr != nil {
+			return nil, fmt.Errorf("scan supplier: %w", scanErr)
+		}
+		suppliers = append(suppliers, *supplier)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate suppliers: %w", err)
+	}
+
+	return suppliers, nil
+}
+
+// Update overwrites the editable fields of an active supplier. It returns
+// sql.ErrNoRows when no such supplier exists and ErrConflict when the new
+// code is taken.
+func (r *SupplierRepository) Update(ctx context.Context, supplier *Supplier) error {
+	const query = `
+UPDATE suppliers
+SET code = $2, name = $3, email = $4, phone = $5, updated_at = NOW()
+WHERE id = $1 AND deleted_at IS NULL
+RETURNING created_at, updated_at`
+
+	err := r.db.QueryRowContext(ctx, query, supplier.ID, supplier.Code, supplier.Name, supplier.Email, supplier.Phone).
+		Scan(&supplier.CreatedAt, &supplier.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return sql.ErrNoRows
+	}
+	if err != nil {
+		if isConstraintViolation(err) {
+			return ErrConflict
+		}
+		return fmt.Errorf("update supplier: %w", err)
+	}
+
+	supplier.CreatedAt = supplier.CreatedAt.UTC()
+	supplier.UpdatedAt = supplier.UpdatedAt.UTC()
+	return nil
+}
+
+// Delete soft-deletes an active supplier and reports whether one was found.
+// Historical orders keep referencing the row.
+func (r *SupplierRepository) Delete(ctx context.Context, id int64) (bool, error) {
+	const query = `UPDATE suppliers SET deleted_at = NOW(), updated_at = NOW() WHERE id = $1 AND deleted_at IS NULL`
+
+	result, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return false, fmt.Errorf("delete supplier: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("delete supplier: %w", err)
+	}
+
+	return affected > 0, nil
+}
+
+const supplierIngredientColumns = `si.supplier_id, s.name, si.ingredient_id, si.sku, si.pack_size, si.lead_time_days, si.min_order_qty, si.price, si.preferred, si.updated_at`
+
+// UpsertIngredient creates or replaces the supplier's terms for an ingredient.
+// Marking a supplier preferred clears the flag on every other supplier of the
+// same ingredient.
+func (r *SupplierRepository) UpsertIngredient(ctx context.Context, terms *SupplierIngredient) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+
+	if terms.Preferred {
+		const clearPreferred = `
+UPDATE supplier_ingredients
+SET preferred = FALSE, updated_at = NOW()
+WHERE ingredient_id = $1 AND supplier_id <> $2 AND preferred`
+
+		if _, err := tx.ExecContext(ctx, clearPreferred, terms.IngredientID, terms.SupplierID); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("clear preferred supplier: %w", err)
+		}
+	}
+
+	const upsert = `
+INSERT INTO supplier_ingredients (supplier_id, ingredient_id, sku, pack_size, lead_time_days, min_order_qty, price, preferred)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+ON CONFLICT (supplier_id, ingredient_id) DO UPDATE SET
+	sku = EXCLUDED.sku,
+	pack_size = EXCLUDED.pack_size,
+	lead_time_days = EXCLUDED.lead_time_days,
+	min_order_qty = EXCLUDED.min_order_qty,
+	price = EXCLUDED.price,
+	preferred = EXCLUDED.preferred,
+	updated_at = NOW()
+RETURNING updated_at`
+
+	err = tx.QueryRowContext(ctx, upsert,
+		terms.SupplierID,
+		terms.IngredientID,
+		terms.SKU,
+		terms.PackSize,
+		terms.LeadTimeDays,
+		terms.MinOrderQty,
+		terms.Price,
+		terms.Preferred,
+	).Scan(&terms.UpdatedAt)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("upsert supplier ingredient: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit supplier ingredient: %w", err)
+	}
+
+	terms.UpdatedAt = terms.UpdatedAt.UTC()
+	return nil
+}
+
+// ListIngredients returns the terms of every ingredient the supplier delivers.
+func (r *SupplierRepository) ListIngredients(ctx context.Context, supplierID int64) ([]SupplierIngredient, error) {
+	query := `
+SELECT ` + supplierIngredientColumns + `
+FROM supplier_ingredients si
+JOIN suppliers s ON s.id = si.supplier_id
+WHERE si.supplier_id = $1
+ORDER BY si.ingredient_id`
+
+	return r.queryIngredients(ctx, query, supplierID)
+}
+
+// ListOffers returns the terms of every active supplier delivering any of the
+// given ingredients.
+func (r *SupplierRepository) ListOffers(ctx context.Context, ingredientIDs []int64) ([]SupplierIngredient, error) {
+	if len(ingredientIDs) == 0 {
+		return nil, nil
+	}
+
+	query := `
+SELECT ` + supplierIngredientColumns + `
+FROM supplier_ingredients si
+JOIN suppliers s ON s.id = si.supplier_id
+WHERE si.ingredient_id = ANY($1) AND s.deleted_at IS NULL
+ORDER BY si.ingredient_id, si.supplier_id`
+
+	return r.queryIngredients(ctx, query, ingredientIDs)
+}
+
+// DeleteIngredient removes the supplier's terms for an ingredient and
+// reports whether they existed.
+func (r *SupplierRepository) DeleteIngredient(ctx context.Context, supplierID, ingredientID int64) (bool, error) {
+	const query = `DELETE FROM supplier_ingredients WHERE supplier_id = $1 AND ingredient_id = $2`
+
+	result, err := r.db.ExecContext(ctx, query, supplierID, ingredientID)
+	if err != nil {
+		return false, fmt.Errorf("delete supplier ingredient: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("delete supplier ingredient: %w", err)
+	}
+
+	return affected > 0, nil
+}
+
+func (r *SupplierRepository) queryIngredients(ctx context.Context, query string, arg interface{}) ([]SupplierIngredient, error) {
+	rows, err := r.db.QueryContext(ctx, query, arg)
+	if err != nil {
+		return nil, fmt.Errorf("query supplier ingredients: %w", err)
+	}
+	defer rows.Close()
+
+	var terms []SupplierIngredient
+	for rows.Next() {
+		var t SupplierIngredient
+		if scanErr := rows.Scan(
+			&t.SupplierID,
+			&t.SupplierName,
+			&t.IngredientID,
+			&t.SKU,
+			&t.PackSize,
+			&t.LeadTimeDays,
+			&t.MinOrderQty,
+			&t.Price,
+			&t.Preferred,
+			&t.UpdatedAt,
+		); scanErr != nil {
+			return nil, fmt.Errorf("scan supplier ingredient: %w", scanErr)
+		}
+		t.UpdatedAt = t.UpdatedAt.UTC()
+		terms = append(terms, t)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate supplier ingredients: %w", err)
+	}
+
+	return terms, nil
+}
+
+func scanSupplier(row rowScanner) (*Supplier, error) {
+	var supplier Supplier
+	if err := row.Scan(
+		&supplier.ID,
+		&supplier.Code,
+		&supplier.Name,
+		&supplier.Email,
+		&supplier.Phone,
+		&supplier.CreatedAt,
+		&supplier.UpdatedAt,
+	); err != nil {
+		return nil, err
+	}
+
+	supplier.CreatedAt = supplier.CreatedAt.UTC()
+	supplier.UpdatedAt = supplier.UpdatedAt.UTC()
+	return &supplier, nil
+}
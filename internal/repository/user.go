@@ -5,10 +5,12 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/stdlib"
 )
 
 // ErrConflict indicates a user with the same username already exists.
@@ -23,9 +25,28 @@ type User struct {
 	Username     string
 	PasswordHash string
 	RestaurantID int64
+	Role         string
+	TokenVersion int
+	DisabledAt   time.Time
 	CreatedAt    time.Time
 }
 
+// Disabled reports whether the account has been deactivated.
+func (u *User) Disabled() bool {
+	return !u.DisabledAt.IsZero()
+}
+
+// UserFilter narrows down user listings.
+type UserFilter struct {
+	// RestaurantID limits results to one restaurant when positive.
+	RestaurantID int64
+	// Query matches usernames case-insensitively by substring.
+	Query           string
+	IncludeDisabled bool
+	Limit           int
+	Offset          int
+}
+
 // UserRepository persists users.
 type UserRepository struct {
 	db *sql.DB
@@ -52,13 +73,13 @@ func (r *UserRepository) Exists(ctx context.Context, username string) (bool, err
 	}
 }
 
+const userColumns = `id, username, password_hash, COALESCE(restaurant_id, 0), role, token_version, disabled_at, created_at`
+
 // GetByUsername fetches a user record by username.
 func (r *UserRepository) GetByUsername(ctx context.Context, username string) (*User, error) {
-	const query = `SELECT id, username, password_hash, COALESCE(restaurant_id, 0), created_at FROM users WHERE username = $1`
+	query := `SELECT ` + userColumns + ` FROM users WHERE username = $1`
 
-	var user User
-	err := r.db.QueryRowContext(ctx, query, username).
-		Scan(&user.ID, &user.Username, &user.PasswordHash, &user.RestaurantID, &user.CreatedAt)
+	user, err := scanUser(r.db.QueryRowContext(ctx, query, username))
 	if errors.Is(err, sql.ErrNoRows) {
 		return nil, ErrNotFound
 	}
@@ -66,17 +87,14 @@ func (r *UserRepository) GetByUsername(ctx context.Context, username string) (*U
 		return nil, fmt.Errorf("get user: %w", err)
 	}
 
-	user.CreatedAt = user.CreatedAt.UTC()
-	return &user, nil
+	return user, nil
 }
 
 // GetByID returns a user by identifier.
 func (r *UserRepository) GetByID(ctx context.Context, id int64) (*User, error) {
-	const query = `SELECT id, username, password_hash, COALESCE(restaurant_id, 0), created_at FROM users WHERE id = $1`
+	query := `SELECT ` + userColumns + ` FROM users WHERE id = $1`
 
-	var user User
-	err := r.db.QueryRowContext(ctx, query, id).
-		Scan(&user.ID, &user.Username, &user.PasswordHash, &user.RestaurantID, &user.CreatedAt)
+	user, err := scanUser(r.db.QueryRowContext(ctx, query, id))
 	if errors.Is(err, sql.ErrNoRows) {
 		return nil, ErrNotFound
 	}
@@ -84,22 +102,34 @@ func (r *UserRepository) GetByID(ctx context.Context, id int64) (*User, error) {
 		return nil, fmt.Errorf("get user by id: %w", err)
 	}
 
-	user.CreatedAt = user.CreatedAt.UTC()
-	return &user, nil
+	return user, nil
+}
+
+// HasActiveRole reports whether any user with the role is not disabled.
+func (r *UserRepository) HasActiveRole(ctx context.Context, role string) (bool, error) {
+	const query = `SELECT EXISTS (SELECT 1 FROM users WHERE role = $1 AND disabled_at IS NULL)`
+
+	var exists bool
+	if err := r.db.QueryRowContext(ctx, query, role).Scan(&exists); err != nil {
+		return false, fmt.Errorf("check active %s: %w", role, err)
+	}
+	return exists, nil
 }
 
-// Create inserts a new user row.
-func (r *UserRepository) Create(ctx context.Context, username, passwordHash string, restaurantID int64) (*User, error) {
-	const query = `INSERT INTO users (username, password_hash, restaurant_id) VALUES ($1, $2, $3) RETURNING id, restaurant_id, created_at`
+// Create inserts a new user row. A zero restaurantID leaves the user without
+// a restaurant.
+func (r *UserRepository) Create(ctx context.Context, username, passwordHash string, restaurantID int64, role string) (*User, error) {
+	const query = `INSERT INTO users (username, password_hash, restaurant_id, role) VALUES ($1, $2, NULLIF($3, 0), $4) RETURNING id, COALESCE(restaurant_id, 0), token_version, created_at`
 
 	var (
-		id        int64
-		rID       int64
-		createdAt time.Time
+		id           int64
+		rID          int64
+		tokenVersion int
+		createdAt    time.Time
 	)
 	if err := r.db.
-		QueryRowContext(ctx, query, username, passwordHash, restaurantID).
-		Scan(&id, &rID, &createdAt); err != nil {
+		QueryRowContext(ctx, query, username, passwordHash, restaurantID, role).
+		Scan(&id, &rID, &tokenVersion, &createdAt); err != nil {
 		if isConstraintViolation(err) {
 			return nil, ErrConflict
 		}
@@ -111,10 +141,314 @@ func (r *UserRepository) Create(ctx context.Context, username, passwordHash stri
 		Username:     username,
 		PasswordHash: passwordHash,
 		RestaurantID: rID,
+		Role:         role,
+		TokenVersion: tokenVersion,
 		CreatedAt:    createdAt.UTC(),
 	}, nil
 }
 
+// GetWithRestaurantName returns a user together with the name of the assigned restaurant, if any.
+func (r *UserRepository) GetWithRestaurantName(ctx context.Context, id int64) (*User, string, error) {
+	const query = `
+SELECT u.id, u.username, u.password_hash, COALESCE(u.restaurant_id, 0), u.role, u.token_version, u.disabled_at, u.created_at, COALESCE(rs.name, '')
+FROM users u
+LEFT JOIN restaurants rs ON rs.id = u.restaurant_id
+WHERE u.id = $1`
+
+	var name string
+	user, err := scanUser(r.db.QueryRowContext(ctx, query, id), &name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, "", ErrNotFound
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("get user with restaurant: %w", err)
+	}
+
+	return user, name, nil
+}
+
+// GetTokenState returns the current token version of a user and whether the account is disabled.
+func (r *UserRepository) GetTokenState(ctx context.Context, id int64) (int, bool, error) {
+	const query = `SELECT token_version, disabled_at IS NOT NULL FROM users WHERE id = $1`
+
+	var (
+		version  int
+		disabled bool
+	)
+	err := r.db.QueryRowContext(ctx, query, id).Scan(&version, &disabled)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, ErrNotFound
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("get token state: %w", err)
+	}
+
+	return version, disabled, nil
+}
+
+// List returns users matching the filter ordered by id.
+func (r *UserRepository) List(ctx context.Context, filter UserFilter) ([]User, error) {
+	var (
+		conditions []string
+		args       []interface{}
+	)
+	if filter.RestaurantID > 0 {
+		args = append(args, filter.RestaurantID)
+		conditions = append(conditions, fmt.Sprintf("restaurant_id = $%d", len(args)))
+	}
+	if q := strings.TrimSpace(filter.Query); q != "" {
+		args = append(args, "%"+escapeLike(q)+"%")
+		conditions = append(conditions, fmt.Sprintf("username ILIKE $%d", len(args)))
+	}
+	if !filter.IncludeDisabled {
+		conditions = append(conditions, "disabled_at IS NULL")
+	}
+
+	query := `SELECT ` + userColumns + ` FROM users`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, filter.Limit, filter.Offset)
+	query += fmt.Sprintf(" ORDER BY id LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query users: %w", err)
+	}
+	defer rows.Close()
+
+	var users []User
+	for rows.Next() {
+		user, scanErr := scanUser(rows)
+		if scanErr != nil {
+			return nil, fmt.Errorf("scan user: %w", scanErr)
+		}
+		users = append(users, *user)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate users: %w", err)
+	}
+
+	return users, nil
+}
+
+// SetDisabled deactivates or reactivates an account. Deactivation also bumps
+// the token version so outstanding tokens stop working. The new version is returned.
+func (r *UserRepository) SetDisabled(ctx context.Context, id int64, disabled bool) (int, error) {
+	const query = `
+UPDATE users
+SET disabled_at = CASE WHEN $2 THEN COALESCE(disabled_at, NOW()) ELSE NULL END,
+	token_version = CASE WHEN $2 THEN token_version + 1 ELSE token_version END
+WHERE id = $1
+RETURNING token_version`
+
+	var version int
+	err := r.db.QueryRowContext(ctx, query, id, disabled).Scan(&version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("set user disabled: %w", err)
+	}
+
+	return version, nil
+}
+
+// SetRestaurant moves the user to another restaurant and bumps the token
+// version because tokens carry the restaurant id. The new version is returned.
+func (r *UserRepository) SetRestaurant(ctx context.Context, id, restaurantID int64) (int, error) {
+	const query = `
+UPDATE users
+SET restaurant_id = $2, token_version = token_version + 1
+WHERE id = $1
+RETURNING token_version`
+
+	var version int
+	err := r.db.QueryRowContext(ctx, query, id, restaurantID).Scan(&version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("set user restaurant: %w", err)
+	}
+
+	return version, nil
+}
+
+// Delete removes the user row after detaching every reference to it. Orders,
+// API keys, invitations and every other record the user created, decided or
+// counted keep their data with the user cleared, and audit events replace
+// the stored username with an anonymous placeholder. Tables gaining a
+// reference to users must be added here.
+func (r *UserRepository) Delete(ctx context.Context, id int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+
+	anonymised := fmt.Sprintf("deleted-user-%d", id)
+	statements := []struct {
+		name  string
+		query string
+		args  []interface{}
+	}{
+		{"anonymise orders", `UPDATE orders SET created_by = NULL WHERE created_by = $1`, []interface{}{id}},
+		{"anonymise api keys", `UPDATE api_keys SET created_by = NULL WHERE created_by = $1`, []interface{}{id}},
+		{"anonymise invitation issuers", `UPDATE invitations SET created_by = NULL WHERE created_by = $1`, []interface{}{id}},
+		{"anonymise invitation redeemers", `UPDATE invitations SET used_by = NULL WHERE used_by = $1`, []interface{}{id}},
+		{"anonymise purchase order creators", `UPDATE purchase_orders SET created_by = NULL WHERE created_by = $1`, []interface{}{id}},
+		{"anonymise price authors", `UPDATE ingredient_prices SET created_by = NULL WHERE created_by = $1`, []interface{}{id}},
+		{"anonymise budget editors", `UPDATE budgets SET updated_by = NULL WHERE updated_by = $1`, []interface{}{id}},
+		{"anonymise approval rule authors", `UPDATE approval_rules SET created_by = NULL WHERE created_by = $1`, []interface{}{id}},
+		{"anonymise approvers", `UPDATE purchase_order_approvals SET decided_by = NULL WHERE decided_by = $1`, []interface{}{id}},
+		{"anonymise stock movement authors", `UPDATE stock_movements SET created_by = NULL WHERE created_by = $1`, []interface{}{id}},
+		{"anonymise goods receivers", `UPDATE goods_receipts SET received_by = NULL WHERE received_by = $1`, []interface{}{id}},
+		{"anonymise stocktake openers", `UPDATE stocktakes SET opened_by = NULL WHERE opened_by = $1`, []interface{}{id}},
+		{"anonymise stocktake closers", `UPDATE stocktakes SET closed_by = NULL WHERE closed_by = $1`, []interface{}{id}},
+		{"anonymise stocktake counters", `UPDATE stocktake_counts SET counted_by = NULL WHERE counted_by = $1`, []interface{}{id}},
+		{"anonymise par level editors", `UPDATE par_levels SET updated_by = NULL WHERE updated_by = $1`, []interface{}{id}},
+		{"anonymise audit actors", `UPDATE audit_events SET actor_user_id = NULL, actor_username = $2 WHERE actor_user_id = $1`, []interface{}{id, anonymised}},
+		{"anonymise audit targets", `UPDATE audit_events SET target_user_id = NULL, target_username = $2 WHERE target_user_id = $1`, []interface{}{id, anonymised}},
+	}
+	for _, stmt := range statements {
+		if _, err := tx.ExecContext(ctx, stmt.query, stmt.args...); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("%s: %w", stmt.name, err)
+		}
+	}
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM users WHERE id = $1`, id)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("delete user: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("delete user: %w", err)
+	}
+	if affected == 0 {
+		tx.Rollback()
+		return ErrNotFound
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit user deletion: %w", err)
+	}
+
+	return nil
+}
+
+// tokenStateChannel is notified with the user id whenever a user's token
+// version or disabled state changes or the user is deleted.
+const tokenStateChannel = "token_state"
+
+// ListenTokenState holds a connection listening for token state changes and
+// calls changed with the id of every user whose state changed. ready is
+// called once the connection listens; changes made before that are not
+// reported. It returns when ctx ends or the connection fails, always with an
+// error.
+func (r *UserRepository) ListenTokenState(ctx context.Context, ready func(), changed func(userID int64)) error {
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("acquire listener connection: %w", err)
+	}
+	defer conn.Close()
+
+	return conn.Raw(func(driverConn interface{}) error {
+		pgxConn := driverConn.(*stdlib.Conn).Conn()
+		// The session keeps listening, so it must not return to the pool.
+		defer pgxConn.Close(context.Background())
+
+		if _, err := pgxConn.Exec(ctx, "LISTEN "+tokenStateChannel); err != nil {
+			return fmt.Errorf("listen %s: %w", tokenStateChannel, err)
+		}
+		ready()
+
+		for {
+			notification, err := pgxConn.WaitForNotification(ctx)
+			if err != nil {
+				return fmt.Errorf("wait for %s: %w", tokenStateChannel, err)
+			}
+			userID, err := strconv.ParseInt(notification.Payload, 10, 64)
+			if err != nil {
+				continue
+			}
+			changed(userID)
+		}
+	})
+}
+
+// UpdatePassword stores a new password hash and bumps the token version,
+// returning the new version.
+func (r *UserRepository) UpdatePassword(ctx context.Context, id int64, passwordHash string) (int, error) {
+	const query = `
+UPDATE users
+SET password_hash = $2, token_version = token_version + 1
+WHERE id = $1
+RETURNING token_version`
+
+	var version int
+	err := r.db.QueryRowContext(ctx, query, id, passwordHash).Scan(&version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("update password: %w", err)
+	}
+
+	return version, nil
+}
+
+// BumpTokenVersion invalidates every token issued so far for the user and returns the new version.
+func (r *UserRepository) BumpTokenVersion(ctx context.Context, id int64) (int, error) {
+	const query = `UPDATE users SET token_version = token_version + 1 WHERE id = $1 RETURNING token_version`
+
+	var version int
+	err := r.db.QueryRowContext(ctx, query, id).Scan(&version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("bump token version: %w", err)
+	}
+
+	return version, nil
+}
+
+func scanUser(row rowScanner, extra ...interface{}) (*User, error) {
+	var (
+		user       User
+		disabledAt sql.NullTime
+	)
+	dest := []interface{}{
+		&user.ID,
+		&user.Username,
+		&user.PasswordHash,
+		&user.RestaurantID,
+		&user.Role,
+		&user.TokenVersion,
+		&disabledAt,
+		&user.CreatedAt,
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+
+	user.CreatedAt = user.CreatedAt.UTC()
+	if disabledAt.Valid {
+		user.DisabledAt = disabledAt.Time.UTC()
+	}
+
+	return &user, nil
+}
+
+func escapeLike(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+	return replacer.Replace(value)
+}
+
 func isConstraintViolation(err error) bool {
 	var pgErr *pgconn.PgError
 	if errors.As(err, &pgErr) {
@@ -0,0 +1,234 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"mmispoc/internal/repository"
+	"mmispoc/internal/tracing"
+)
+
+// ErrInvalidAPIKey indicates the supplied API key is malformed, unknown, revoked or expired.
+var ErrInvalidAPIKey = errors.New("invalid api key")
+
+// ErrInvalidScope indicates an unknown scope was requested.
+var ErrInvalidScope = errors.New("invalid scope")
+
+// ErrInvalidExpiry indicates the requested expiry lies in the past.
+var ErrInvalidExpiry = errors.New("invalid expiry")
+
+// ErrAPIKeyNotFound indicates no active key with the given id belongs to the restaurant.
+var ErrAPIKeyNotFound = errors.New("api key not found")
+
+// ErrForbidden indicates the principal is not allowed to perform the action.
+var ErrForbidden = errors.New("forbidden")
+
+// API keys look like "mmk_<prefix>_<secret>". The prefix is stored in clear
+// text for lookup; only a hash of the secret is persisted.
+const (
+	apiKeyScheme      = "mmk"
+	apiKeyPrefixBytes = 6
+	apiKeySecretBytes = 32
+)
+
+// APIKeyService manages restaurant-scoped keys for service-to-service calls.
+type APIKeyService struct {
+	repo *repository.APIKeyRepository
+}
+
+// NewAPIKey constructs the service.
+func NewAPIKey(repo *repository.APIKeyRepository) *APIKeyService {
+	return &APIKeyService{repo: repo}
+}
+
+// CreatedAPIKey is returned once on creation and is the only time the plain key is available.
+type CreatedAPIKey struct {
+	repository.APIKey
+	Key string
+}
+
+// Create issues a new key for the principal's restaurant.
+func (s *APIKeyService) Create(ctx context.Context, principal *Principal, description string, scopes []string, expiresAt time.Time) (created *CreatedAPIKey, err error) {
+	ctx, span := tracing.Start(ctx, "APIKeyService.Create")
+	defer func() { tracing.End(span, err) }()
+
+	if err := requireKeyManager(principal); err != nil {
+		return nil, err
+	}
+
+	scopes, err = normalizeScopes(scopes)
+	if err != nil {
+		return nil, err
+	}
+	if !expiresAt.IsZero() && !expiresAt.After(time.Now()) {
+		return nil, ErrInvalidExpiry
+	}
+
+	prefixRaw := make([]byte, apiKeyPrefixBytes)
+	secretRaw := make([]byte, apiKeySecretBytes)
+	if _, err := rand.Read(prefixRaw); err != nil {
+		return nil, fmt.Errorf("read random: %w", err)
+	}
+	if _, err := rand.Read(secretRaw); err != nil {
+		return nil, fmt.Errorf("read random: %w", err)
+	}
+	prefix := hex.EncodeToString(prefixRaw)
+	secret := base64.RawURLEncoding.EncodeToString(secretRaw)
+
+	key := repository.APIKey{
+		RestaurantID: principal.RestaurantID,
+		Prefix:       prefix,
+		SecretHash:   hashPassword(secret),
+		Scopes:       scopes,
+		Description:  strings.TrimSpace(description),
+		CreatedBy:    principal.UserID,
+		ExpiresAt:    expiresAt.UTC(),
+	}
+	if err := s.repo.Create(ctx, &key); err != nil {
+		return nil, fmt.Errorf("store api key: %w", err)
+	}
+
+	return &CreatedAPIKey{
+		APIKey: key,
+		Key:    apiKeyScheme + "_" + prefix + "_" + secret,
+	}, nil
+}
+
+// List returns the keys of the principal's restaurant.
+func (s *APIKeyService) List(ctx context.Context, principal *Principal) (keys []repository.APIKey, err error) {
+	ctx, span := tracing.Start(ctx, "APIKeyService.List")
+	defer func() { tracing.End(span, err) }()
+
+	if err := requireKeyManager(principal); err != nil {
+		return nil, err
+	}
+
+	keys, err = s.repo.ListByRestaurant(ctx, principal.RestaurantID)
+	if err != nil {
+		return nil, fmt.Errorf("list api keys: %w", err)
+	}
+
+	return keys, nil
+}
+
+// Revoke disables a key of the principal's restaurant immediately.
+func (s *APIKeyService) Revoke(ctx context.Context, principal *Principal, id int64) (err error) {
+	ctx, span := tracing.Start(ctx, "APIKeyService.Revoke")
+	defer func() { tracing.End(span, err) }()
+
+	if err := requireKeyManager(principal); err != nil {
+		return err
+	}
+	if id <= 0 {
+		return ErrAPIKeyNotFound
+	}
+
+	revoked, err := s.repo.Revoke(ctx, principal.RestaurantID, id)
+	if err != nil {
+		return fmt.Errorf("revoke api key: %w", err)
+	}
+	if !revoked {
+		return ErrAPIKeyNotFound
+	}
+
+	return nil
+}
+
+// Authenticate resolves a presented key into a principal.
+func (s *APIKeyService) Authenticate(ctx context.Context, rawKey string) (principal *Principal, err error) {
+	ctx, span := tracing.Start(ctx, "APIKeyService.Authenticate")
+	defer func() { tracing.End(span, err) }()
+
+	key, err := s.Verify(ctx, rawKey)
+	if err != nil {
+		return nil, err
+	}
+	return s.authenticateKey(ctx, key)
+}
+
+// AuthenticateVerified resolves a key already checked by Verify into a
+// principal, recording its use without looking the key up again.
+func (s *APIKeyService) AuthenticateVerified(ctx context.Context, key *repository.APIKey) (principal *Principal, err error) {
+	ctx, span := tracing.Start(ctx, "APIKeyService.AuthenticateVerified")
+	defer func() { tracing.End(span, err) }()
+
+	return s.authenticateKey(ctx, key)
+}
+
+func (s *APIKeyService) authenticateKey(ctx context.Context, key *repository.APIKey) (*Principal, error) {
+	if err := s.repo.TouchLastUsed(ctx, key.ID); err != nil {
+		return nil, fmt.Errorf("record api key usage: %w", err)
+	}
+
+	return &Principal{
+		RestaurantID: key.RestaurantID,
+		APIKeyID:     key.ID,
+		Scopes:       key.Scopes,
+	}, nil
+}
+
+// Verify checks a presented key, secret included, without recording its
+// use. It returns ErrInvalidAPIKey for unknown, revoked and expired keys.
+func (s *APIKeyService) Verify(ctx context.Context, rawKey string) (*repository.APIKey, error) {
+	parts := strings.SplitN(strings.TrimSpace(rawKey), "_", 3)
+	if len(parts) != 3 || parts[0] != apiKeyScheme || parts[1] == "" || parts[2] == "" {
+		return nil, ErrInvalidAPIKey
+	}
+
+	key, err := s.repo.GetByPrefix(ctx, parts[1])
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvalidAPIKey
+		}
+		return nil, fmt.Errorf("fetch api key: %w", err)
+	}
+
+	if subtle.ConstantTimeCompare([]byte(key.SecretHash), []byte(hashPassword(parts[2]))) != 1 {
+		return nil, ErrInvalidAPIKey
+	}
+	if !key.RevokedAt.IsZero() {
+		return nil, ErrInvalidAPIKey
+	}
+	if !key.ExpiresAt.IsZero() && time.Now().After(key.ExpiresAt) {
+		return nil, ErrInvalidAPIKey
+	}
+	return key, nil
+}
+
+// requireKeyManager allows restaurant managers signed in as users to manage keys.
+func requireKeyManager(principal *Principal) error {
+	if !isManager(principal) {
+		return ErrForbidden
+	}
+	return nil
+}
+
+func normalizeScopes(scopes []string) ([]string, error) {
+	if len(scopes) == 0 {
+		return nil, ErrInvalidScope
+	}
+
+	seen := make(map[string]bool, len(scopes))
+	normalized := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		scope = strings.ToLower(strings.TrimSpace(scope))
+		if !knownScopes[scope] {
+			return nil, ErrInvalidScope
+		}
+		if seen[scope] {
+			continue
+		}
+		seen[scope] = true
+		normalized = append(normalized, scope)
+	}
+
+	return normalized, nil
+}
@@ -0,0 +1,466 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"mmispoc/internal/repository"
+	"mmispoc/internal/tracing"
+)
+
+var (
+	// ErrApprovalRuleNotFound indicates the approval rule does not exist or was deleted.
+	ErrApprovalRuleNotFound = errors.New("approval rule not found")
+	// ErrInvalidApprovalRule indicates a missing name, a negative total or
+	// steps that are not approver roles.
+	ErrInvalidApprovalRule = errors.New("invalid approval rule")
+	// ErrApprovalNotPending indicates the order is not waiting for a decision,
+	// for example because it was already decided or expired.
+	ErrApprovalNotPending = errors.New("order is not awaiting approval")
+)
+
+// ApprovalConfig tunes approval chains.
+type ApprovalConfig struct {
+	// TTL is how long a step may stay pending before its order expires.
+	TTL time.Duration
+}
+
+// approverRank orders approver roles within a chain: a restaurant manager
+// signs off before an area manager, who signs off before an admin.
+var approverRank = map[string]int{
+	RoleManager:     1,
+	RoleAreaManager: 2,
+	RoleAdmin:       3,
+}
+
+// budgetApproval is the step an order exceeding a hard budget waits for.
+var budgetApproval = approvalStep{role: RoleAreaManager, reason: "budget"}
+
+type approvalStep struct {
+	role   string
+	reason string
+}
+
+// ApprovalService manages approval rules and the decisions on purchase
+// orders held in pending_approval.
+type ApprovalService struct {
+	approvalRepo   *repository.ApprovalRepository
+	orderRepo      *repository.OrderRepository
+	ingredientRepo *repository.IngredientRepository
+	restaurantRepo *repository.RestaurantRepository
+	ttl            time.Duration
+}
+
+// NewApproval constructs an approval service.
+func NewApproval(approvalRepo *repository.ApprovalRepository, orderRepo *repository.OrderRepository, ingredientRepo *repository.IngredientRepository, restaurantRepo *repository.RestaurantRepository, cfg ApprovalConfig) *ApprovalService {
+	return &ApprovalService{
+		approvalRepo:   approvalRepo,
+		orderRepo:      orderRepo,
+		ingredientRepo: ingredientRepo,
+		restaurantRepo: restaurantRepo,
+		ttl:            cfg.TTL,
+	}
+}
+
+// ListRules returns every active approval rule.
+func (s *ApprovalService) ListRules(ctx context.Context, principal *Principal) (rules []repository.ApprovalRule, err error) {
+	ctx, span := tracing.Start(ctx, "ApprovalService.ListRules")
+	defer func() { tracing.End(span, err) }()
+
+	if !principal.HasScope(ScopeOrdersRead) {
+		return nil, ErrForbidden
+	}
+
+	rules, err = s.approvalRepo.ListRules(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list approval rules: %w", err)
+	}
+	return rules, nil
+}
+
+// GetRule returns an active approval rule.
+func (s *ApprovalService) GetRule(ctx context.Context, principal *Principal, id int64) (rule *repository.ApprovalRule, err error) {
+	ctx, span := tracing.Start(ctx, "ApprovalService.GetRule")
+	defer func() { tracing.End(span, err) }()
+
+	if !principal.HasScope(ScopeOrdersRead) {
+		return nil, ErrForbidden
+	}
+
+	rule, err = s.approvalRepo.GetRule(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrApprovalRuleNotFound
+		}
+		return nil, fmt.Errorf("get approval rule: %w", err)
+	}
+	return rule, nil
+}
+
+// CreateRule adds an approval rule. Only admins manage rules.
+func (s *ApprovalService) CreateRule(ctx context.Context, principal *Principal, rule *repository.ApprovalRule) (err error) {
+	ctx, span := tracing.Start(ctx, "ApprovalService.CreateRule")
+	defer func() { tracing.End(span, err) }()
+
+	if !isAdmin(principal) {
+		return ErrForbidden
+	}
+	if err := s.normalizeRule(ctx, rule); err != nil {
+		return err
+	}
+
+	rule.CreatedBy = principal.UserID
+	if err := s.approvalRepo.CreateRule(ctx, rule); err != nil {
+		return fmt.Errorf("create approval rule: %w", err)
+	}
+	return nil
+}
+
+// UpdateRule replaces an approval rule. Orders already waiting keep the
+// chain they were given when placed.
+func (s *ApprovalService) UpdateRule(ctx context.Context, principal *Principal, rule *repository.ApprovalRule) (err error) {
+	ctx, span := tracing.Start(ctx, "ApprovalService.UpdateRule")
+	defer func() { tracing.End(span, err) }()
+
+	if !isAdmin(principal) {
+		return ErrForbidden
+	}
+	if err := s.normalizeRule(ctx, rule); err != nil {
+		return err
+	}
+
+	if err := s.approvalRepo.UpdateRule(ctx, rule); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrApprovalRuleNotFound
+		}
+		return fmt.Errorf("update approval rule: %w", err)
+	}
+	return nil
+}
+
+// DeleteRule removes an approval rule.
+func (s *ApprovalService) DeleteRule(ctx context.Context, principal *Principal, id int64) (err error) {
+	ctx, span := tracing.Start(ctx, "ApprovalService.DeleteRule")
+	defer func() { tracing.End(span, err) }()
+
+	if !isAdmin(principal) {
+		return ErrForbidden
+	}
+
+	deleted, err := s.approvalRepo.DeleteRule(ctx, id)
+	if err != nil {
+		return fmt.Errorf("delete approval rule: %w", err)
+	}
+	if !deleted {
+		return ErrApprovalRuleNotFound
+	}
+	return nil
+}
+
+// Inbox lists the orders waiting for a decision the caller may take:
+// managers see manager steps of their restaurant, area managers see area
+// manager steps and admins see every pending step. Nobody but an admin is
+// offered an order they placed themselves.
+func (s *ApprovalService) Inbox(ctx context.Context, principal *Principal) (pending []repository.PendingApproval, err error) {
+	ctx, span := tracing.Start(ctx, "ApprovalService.Inbox")
+	defer func() { tracing.End(span, err) }()
+
+	var filter repository.ApprovalFilter
+	switch {
+	case isAdmin(principal):
+	case isAreaManager(principal):
+		filter = repository.ApprovalFilter{ApproverRole: RoleAreaManager, ExcludeCreator: principal.UserID}
+	case isManager(principal):
+		filter = repository.ApprovalFilter{ApproverRole: RoleManager, RestaurantID: principal.RestaurantID, ExcludeCreator: principal.UserID}
+	default:
+		return nil, ErrForbidden
+	}
+
+	pending, err = s.approvalRepo.ListPending(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("list pending approvals: %w", err)
+	}
+	return pending, nil
+}
+
+// Approve signs off the pending step of a purchase order. The order is
+// placed once its last step is approved.
+func (s *ApprovalService) Approve(ctx context.Context, principal *Principal, purchaseOrderID int64, comment string) (po *repository.PurchaseOrder, err error) {
+	ctx, span := tracing.Start(ctx, "ApprovalService.Approve")
+	defer func() { tracing.End(span, err) }()
+
+	return s.decide(ctx, principal, purchaseOrderID, true, comment)
+}
+
+// Reject rejects a purchase order at its pending step.
+func (s *ApprovalService) Reject(ctx context.Context, principal *Principal, purchaseOrderID int64, comment string) (po *repository.PurchaseOrder, err error) {
+	ctx, span := tracing.Start(ctx, "ApprovalService.Reject")
+	defer func() { tracing.End(span, err) }()
+
+	return s.decide(ctx, principal, purchaseOrderID, false, comment)
+}
+
+// ExpireStale expires orders whose pending step outlived the approval TTL
+// and returns how many were expired.
+func (s *ApprovalService) ExpireStale(ctx context.Context) (expired int64, err error) {
+	ctx, span := tracing.Start(ctx, "ApprovalService.ExpireStale")
+	defer func() { tracing.End(span, err) }()
+
+	expired, err = s.approvalRepo.Expire(ctx, time.Now().UTC())
+	if err != nil {
+		return 0, fmt.Errorf("expire approvals: %w", err)
+	}
+	if expired > 0 {
+		approvalDecisions.Add(float64(expired), repository.ApprovalExpired)
+	}
+	return expired, nil
+}
+
+func (s *ApprovalService) decide(ctx context.Context, principal *Principal, purchaseOrderID int64, approve bool, comment string) (*repository.PurchaseOrder, error) {
+	if !isApprover(principal) {
+		return nil, ErrForbidden
+	}
+	if purchaseOrderID <= 0 {
+		return nil, ErrOrderInvalidID
+	}
+
+	po, err := s.orderRepo.GetPurchaseOrder(ctx, purchaseOrderID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrOrderNotFound
+		}
+		return nil, fmt.Errorf("get purchase order: %w", err)
+	}
+
+	step, err := s.approvalRepo.Pending(ctx, po.ID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrApprovalNotPending
+		}
+		return nil, fmt.Errorf("get pending approval: %w", err)
+	}
+	if !canDecide(principal, step, po) {
+		return nil, ErrForbidden
+	}
+
+	status, err := s.approvalRepo.Decide(ctx, step.ID, principal.UserID, approve, strings.TrimSpace(comment), s.expiresAt(time.Now().UTC()))
+	if err != nil {
+		if errors.Is(err, repository.ErrConflict) {
+			return nil, ErrApprovalNotPending
+		}
+		return nil, fmt.Errorf("decide approval: %w", err)
+	}
+	po.Status = status
+
+	if approve {
+		approvalDecisions.Inc(repository.ApprovalApproved)
+	} else {
+		approvalDecisions.Inc(repository.ApprovalRejected)
+	}
+
+	po.Approvals, err = s.approvalRepo.ListByPurchaseOrder(ctx, po.ID)
+	if err != nil {
+		return nil, fmt.Errorf("list approvals: %w", err)
+	}
+	return po, nil
+}
+
+func (s *ApprovalService) normalizeRule(ctx context.Context, rule *repository.ApprovalRule) error {
+	rule.Name = strings.TrimSpace(rule.Name)
+	rule.IngredientType = strings.TrimSpace(rule.IngredientType)
+	if rule.Name == "" || rule.RestaurantID < 0 {
+		return ErrInvalidApprovalRule
+	}
+	if rule.MinTotal.Valid {
+		if rule.MinTotal.Decimal.IsNegative() {
+			return ErrInvalidApprovalRule
+		}
+		rule.MinTotal.Decimal = rule.MinTotal.Decimal.Round(amountPlaces)
+	}
+
+	steps := make([]approvalStep, 0, len(rule.Steps))
+	for _, role := range rule.Steps {
+		role = strings.ToLower(strings.TrimSpace(role))
+		if approverRank[role] == 0 {
+			return ErrInvalidApprovalRule
+		}
+		steps = append(steps, approvalStep{role: role})
+	}
+	steps = mergeApprovalSteps(steps)
+	if len(steps) == 0 {
+		return ErrInvalidApprovalRule
+	}
+	rule.Steps = rule.Steps[:0]
+	for _, step := range steps {
+		rule.Steps = append(rule.Steps, step.role)
+	}
+
+	if rule.RestaurantID != 0 {
+		exists, err := s.restaurantRepo.Exists(ctx, rule.RestaurantID)
+		if err != nil {
+			return fmt.Errorf("check restaurant: %w", err)
+		}
+		if !exists {
+			return ErrRestaurantNotFound
+		}
+	}
+	return nil
+}
+
+// placementSteps returns the approval steps the rules require for each
+// purchase order of placement, indexed like placement.PurchaseOrders.
+func (s *ApprovalService) placementSteps(ctx context.Context, restaurantID int64, placement *OrderPlacement) ([][]approvalStep, error) {
+	steps := make([][]approvalStep, len(placement.PurchaseOrders))
+
+	rules, err := s.approvalRepo.ListRules(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list approval rules: %w", err)
+	}
+	if len(rules) == 0 {
+		return steps, nil
+	}
+
+	var ingredientIDs []int64
+	for _, po := range placement.PurchaseOrders {
+		for _, line := range po.Lines {
+			ingredientIDs = append(ingredientIDs, line.IngredientID)
+		}
+	}
+	types, err := s.ingredientRepo.Types(ctx, ingredientIDs)
+	if err != nil {
+		return nil, fmt.Errorf("list ingredient types: %w", err)
+	}
+
+	for i := range placement.PurchaseOrders {
+		po := &placement.PurchaseOrders[i]
+		for _, rule := range rules {
+			if !ruleMatches(rule, restaurantID, po, types) {
+				continue
+			}
+			for _, role := range rule.Steps {
+				steps[i] = append(steps[i], approvalStep{role: role, reason: rule.Name})
+			}
+		}
+	}
+	return steps, nil
+}
+
+// chain builds the approval rows of a purchase order from the steps
+// required of it; nil when none are. The first step is pending from now on.
+func (s *ApprovalService) chain(steps []approvalStep, now time.Time) []repository.Approval {
+	steps = mergeApprovalSteps(steps)
+	if len(steps) == 0 {
+		return nil
+	}
+
+	approvals := make([]repository.Approval, 0, len(steps))
+	for i, step := range steps {
+		approval := repository.Approval{
+			ApproverRole: step.role,
+			Status:       repository.ApprovalWaiting,
+			Reason:       step.reason,
+		}
+		if i == 0 {
+			approval.Status = repository.ApprovalPending
+			approval.ExpiresAt = s.expiresAt(now)
+		}
+		approvals = append(approvals, approval)
+	}
+	return approvals
+}
+
+func (s *ApprovalService) expiresAt(now time.Time) time.Time {
+	if s.ttl <= 0 {
+		return time.Time{}
+	}
+	return now.Add(s.ttl)
+}
+
+func ruleMatches(rule repository.ApprovalRule, restaurantID int64, po *PlacedPurchaseOrder, types map[int64]string) bool {
+	if rule.RestaurantID != 0 && rule.RestaurantID != restaurantID {
+		return false
+	}
+	if rule.MinTotal.Valid && po.Totals.Subtotal.LessThan(rule.MinTotal.Decimal) {
+		return false
+	}
+	if rule.IngredientType == "" {
+		return true
+	}
+	for _, line := range po.Lines {
+		if strings.EqualFold(types[line.IngredientID], rule.IngredientType) {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeApprovalSteps orders steps by approver rank and merges steps of the
+// same role, joining their reasons.
+func mergeApprovalSteps(steps []approvalStep) []approvalStep {
+	byRole := make(map[string]int)
+	var merged []approvalStep
+	for _, step := range steps {
+		idx, seen := byRole[step.role]
+		if !seen {
+			byRole[step.role] = len(merged)
+			merged = append(merged, step)
+			continue
+		}
+		if step.reason != "" && !containsReason(merged[idx].reason, step.reason) {
+			if merged[idx].reason != "" {
+				merged[idx].reason += ", "
+			}
+			merged[idx].reason += step.reason
+		}
+	}
+
+	sort.SliceStable(merged, func(i, j int) bool {
+		return approverRank[merged[i].role] < approverRank[merged[j].role]
+	})
+	return merged
+}
+
+func containsReason(reasons, reason string) bool {
+	for _, existing := range strings.Split(reasons, ", ") {
+		if existing == reason {
+			return true
+		}
+	}
+	return false
+}
+
+// canDecide reports whether the principal may decide the pending step of
+// po. Admins may decide any step; otherwise the role must match the step,
+// managers only act for their own restaurant and nobody decides an order
+// they placed.
+func canDecide(p *Principal, step *repository.Approval, po *repository.PurchaseOrder) bool {
+	if isAdmin(p) {
+		return true
+	}
+	if po.CreatedBy != 0 && po.CreatedBy == p.UserID {
+		return false
+	}
+	switch step.ApproverRole {
+	case RoleManager:
+		return isManager(p) && p.RestaurantID == po.RestaurantID
+	case RoleAreaManager:
+		return isAreaManager(p)
+	default:
+		return false
+	}
+}
+
+func isApprover(p *Principal) bool {
+	return isAdmin(p) || isAreaManager(p) || isManager(p)
+}
+
+// isAreaManager reports whether the principal oversees several restaurants.
+// Areas are not modelled yet, so area managers act for every restaurant.
+func isAreaManager(p *Principal) bool {
+	return p != nil && p.IsUser() && p.Role == RoleAreaManager
+}
@@ -0,0 +1,226 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"mmispoc/internal/repository"
+	"mmispoc/internal/tracing"
+)
+
+// Budget periods. Weeks start on Monday; both are calendar periods in UTC.
+const (
+	BudgetPeriodWeek  = "week"
+	BudgetPeriodMonth = "month"
+)
+
+var (
+	// ErrInvalidBudget indicates an unknown period or a negative amount.
+	ErrInvalidBudget = errors.New("invalid budget")
+	// ErrBudgetNotFound indicates the restaurant has no budget for the period.
+	ErrBudgetNotFound = errors.New("budget not found")
+)
+
+// BudgetStatus reports spend against a budget for the current period.
+type BudgetStatus struct {
+	repository.Budget
+	PeriodStart time.Time
+	PeriodEnd   time.Time
+	// Committed is the net value of purchase orders that count against the
+	// budget; Pending is the value still waiting for approval.
+	Committed decimal.Decimal
+	Pending   decimal.Decimal
+	Remaining decimal.Decimal
+}
+
+// BudgetService manages restaurant spend budgets.
+type BudgetService struct {
+	budgetRepo     *repository.BudgetRepository
+	restaurantRepo *repository.RestaurantRepository
+}
+
+// NewBudget constructs a budget service.
+func NewBudget(budgetRepo *repository.BudgetRepository, restaurantRepo *repository.RestaurantRepository) *BudgetService {
+	return &BudgetService{budgetRepo: budgetRepo, restaurantRepo: restaurantRepo}
+}
+
+// GetBudgets returns every budget of a restaurant with the spend of the
+// current period. Admins and members of the restaurant may read them.
+func (s *BudgetService) GetBudgets(ctx context.Context, principal *Principal, restaurantID int64) (statuses []BudgetStatus, err error) {
+	ctx, span := tracing.Start(ctx, "BudgetService.GetBudgets")
+	defer func() { tracing.End(span, err) }()
+
+	if !canReadRestaurant(principal, restaurantID) {
+		return nil, ErrForbidden
+	}
+	if err := s.requireRestaurant(ctx, restaurantID); err != nil {
+		return nil, err
+	}
+
+	budgets, err := s.budgetRepo.ListByRestaurant(ctx, restaurantID)
+	if err != nil {
+		return nil, fmt.Errorf("list budgets: %w", err)
+	}
+
+	now := time.Now().UTC()
+	statuses = make([]BudgetStatus, 0, len(budgets))
+	for _, budget := range budgets {
+		from, to := budgetPeriod(budget.Period, now)
+		spend, err := s.budgetRepo.SpendByStatus(ctx, restaurantID, from, to)
+		if err != nil {
+			return nil, fmt.Errorf("budget spend: %w", err)
+		}
+
+		committed, pending := splitSpend(spend)
+		statuses = append(statuses, BudgetStatus{
+			Budget:      budget,
+			PeriodStart: from,
+			PeriodEnd:   to,
+			Committed:   committed,
+			Pending:     pending,
+			Remaining:   budget.Amount.Sub(committed),
+		})
+	}
+
+	return statuses, nil
+}
+
+// SetBudget creates or replaces a restaurant budget. Only admins set budgets.
+func (s *BudgetService) SetBudget(ctx context.Context, principal *Principal, budget *repository.Budget) (err error) {
+	ctx, span := tracing.Start(ctx, "BudgetService.SetBudget")
+	defer func() { tracing.End(span, err) }()
+
+	if !isAdmin(principal) {
+		return ErrForbidden
+	}
+	if !validBudgetPeriod(budget.Period) || budget.Amount.IsNegative() {
+		return ErrInvalidBudget
+	}
+	if err := s.requireRestaurant(ctx, budget.RestaurantID); err != nil {
+		return err
+	}
+
+	budget.Amount = budget.Amount.Round(amountPlaces)
+	budget.UpdatedBy = principal.UserID
+	if err := s.budgetRepo.Upsert(ctx, budget); err != nil {
+		return fmt.Errorf("set budget: %w", err)
+	}
+	return nil
+}
+
+// DeleteBudget removes a restaurant budget. Only admins remove budgets.
+func (s *BudgetService) DeleteBudget(ctx context.Context, principal *Principal, restaurantID int64, period string) (err error) {
+	ctx, span := tracing.Start(ctx, "BudgetService.DeleteBudget")
+	defer func() { tracing.End(span, err) }()
+
+	if !isAdmin(principal) {
+		return ErrForbidden
+	}
+	if !validBudgetPeriod(period) {
+		return ErrInvalidBudget
+	}
+
+	deleted, err := s.budgetRepo.Delete(ctx, restaurantID, period)
+	if err != nil {
+		return fmt.Errorf("delete budget: %w", err)
+	}
+	if !deleted {
+		return ErrBudgetNotFound
+	}
+	return nil
+}
+
+func (s *BudgetService) requireRestaurant(ctx context.Context, restaurantID int64) error {
+	if restaurantID <= 0 {
+		return ErrInvalidRestaurantID
+	}
+	exists, err := s.restaurantRepo.Exists(ctx, restaurantID)
+	if err != nil {
+		return fmt.Errorf("check restaurant: %w", err)
+	}
+	if !exists {
+		return ErrRestaurantNotFound
+	}
+	return nil
+}
+
+func validBudgetPeriod(period string) bool {
+	return period == BudgetPeriodWeek || period == BudgetPeriodMonth
+}
+
+// budgetPeriod returns the calendar week or month containing t.
+func budgetPeriod(period string, t time.Time) (from, to time.Time) {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	if period == BudgetPeriodMonth {
+		from = day.AddDate(0, 0, 1-day.Day())
+		return from, from.AddDate(0, 1, 0)
+	}
+	offset := (int(day.Weekday()) + 6) % 7
+	from = day.AddDate(0, 0, -offset)
+	return from, from.AddDate(0, 0, 7)
+}
+
+// splitSpend separates spend that counts against a budget from spend still
+// waiting for approval. Rejected and expired orders are never sent, so they
+// count towards neither.
+func splitSpend(spend map[string]decimal.Decimal) (committed, pending decimal.Decimal) {
+	for status, amount := range spend {
+		switch status {
+		case repository.PurchaseOrderPendingApproval:
+			pending = pending.Add(amount)
+		case repository.PurchaseOrderRejected, repository.PurchaseOrderExpired:
+		default:
+			committed = committed.Add(amount)
+		}
+	}
+	return committed, pending
+}
+
+// checkBudgets compares an order worth orderNet with the restaurant's
+// budgets. Exceeding a soft budget adds a warning to placement; exceeding a
+// hard one reports that the order needs approval.
+func checkBudgets(budgets []repository.Budget, orderNet decimal.Decimal, now time.Time, spend repository.SpendFunc, placement *OrderPlacement) (needsApproval bool, err error) {
+	for _, budget := range budgets {
+		from, to := budgetPeriod(budget.Period, now)
+		byStatus, err := spend(from, to)
+		if err != nil {
+			return false, fmt.Errorf("budget spend: %w", err)
+		}
+
+		committed, _ := splitSpend(byStatus)
+		if committed.Add(orderNet).LessThanOrEqual(budget.Amount) {
+			continue
+		}
+
+		limit := "soft"
+		if budget.HardLimit {
+			limit = "hard"
+			needsApproval = true
+		}
+		placement.Warnings = append(placement.Warnings, fmt.Sprintf(
+			"%s %sly budget of %s exceeded: %s committed and %s in this order",
+			limit, budget.Period, budget.Amount.StringFixed(amountPlaces),
+			committed.StringFixed(amountPlaces), orderNet.StringFixed(amountPlaces)))
+	}
+	return needsApproval, nil
+}
+
+// canReadRestaurant allows admins, members of the restaurant and internal
+// certificate callers to read restaurant-level data.
+func canReadRestaurant(p *Principal, restaurantID int64) bool {
+	return canAccessRestaurant(p, restaurantID, ScopeOrdersRead)
+}
+
+// canAccessRestaurant reports whether the principal holds scope and is an
+// admin, an internal certificate caller or a member of the restaurant.
+func canAccessRestaurant(p *Principal, restaurantID int64, scope string) bool {
+	if p == nil || !p.HasScope(scope) {
+		return false
+	}
+	return isAdmin(p) || p.IsClientCertificate() || (p.RestaurantID != 0 && p.RestaurantID == restaurantID)
+}
@@ -0,0 +1,204 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"mmispoc/internal/forecast"
+	"mmispoc/internal/repository"
+	"mmispoc/internal/tracing"
+)
+
+// ErrInvalidForecast indicates an unknown model or a history, horizon or
+// backtest length out of range.
+var ErrInvalidForecast = errors.New("invalid forecast request")
+
+const (
+	// DefaultForecastHistoryDays is how much order history forecasts learn
+	// from by default.
+	DefaultForecastHistoryDays = 56
+	maxForecastHistoryDays     = 365
+	// DefaultForecastHorizon is how many days ahead forecasts look by default.
+	DefaultForecastHorizon = 7
+	maxForecastHorizon     = 56
+
+	movingAverageWindowDays = 28
+	ewmaAlpha               = 0.1
+	dayOfWeekWeeks          = 8
+)
+
+// forecastModels lists the available models in the order they are reported.
+var forecastModels = []forecast.Model{
+	forecast.MovingAverage{Window: movingAverageWindowDays},
+	forecast.EWMA{Alpha: ewmaAlpha},
+	forecast.DayOfWeek{Weeks: dayOfWeekWeeks},
+}
+
+// ForecastRequest selects what to forecast. Zero values use the defaults.
+type ForecastRequest struct {
+	// IngredientID limits the forecast to one ingredient.
+	IngredientID int64
+	// Models names the models to run; empty runs every model.
+	Models      []string
+	HistoryDays int
+	Horizon     int
+	// BacktestDays, when positive, holds back the most recent days of the
+	// history to measure how well each model would have forecast them. It
+	// may be at most half the history.
+	BacktestDays int
+}
+
+// IngredientForecast is the demand forecast of one ingredient.
+type IngredientForecast struct {
+	IngredientID   int64
+	IngredientCode string
+	IngredientName string
+	// History is the daily demand the models learned from.
+	History forecast.Series
+	Models  []ModelForecast
+	// Best names the model with the lowest backtest mean absolute error; it
+	// is empty without a backtest.
+	Best string
+}
+
+// ModelForecast is what one model predicts for the days ahead.
+type ModelForecast struct {
+	Model string
+	// Start is the first forecast day; Daily holds one quantity per day.
+	Start    time.Time
+	Daily    []decimal.Decimal
+	Total    decimal.Decimal
+	Backtest *forecast.Metrics
+}
+
+// ForecastService forecasts ingredient demand from the order history of a
+// restaurant. Everything is computed in process.
+type ForecastService struct {
+	orderRepo      *repository.OrderRepository
+	restaurantRepo *repository.RestaurantRepository
+}
+
+// NewForecast constructs a forecast service.
+func NewForecast(orderRepo *repository.OrderRepository, restaurantRepo *repository.RestaurantRepository) *ForecastService {
+	return &ForecastService{
+		orderRepo:      orderRepo,
+		restaurantRepo: restaurantRepo,
+	}
+}
+
+// Forecast predicts the daily demand of every ingredient the restaurant
+// ordered during the history window, which ends with yesterday in UTC.
+// Ingredients without orders in the window are left out.
+func (s *ForecastService) Forecast(ctx context.Context, principal *Principal, restaurantID int64, req ForecastRequest) (forecasts []IngredientForecast, err error) {
+	ctx, span := tracing.Start(ctx, "ForecastService.Forecast")
+	defer func() { tracing.End(span, err) }()
+
+	if err := authorizeStock(ctx, s.restaurantRepo, principal, restaurantID, ScopeOrdersRead); err != nil {
+		return nil, err
+	}
+
+	if req.HistoryDays == 0 {
+		req.HistoryDays = DefaultForecastHistoryDays
+	}
+	if req.Horizon == 0 {
+		req.Horizon = DefaultForecastHorizon
+	}
+	switch {
+	case req.IngredientID < 0:
+		return nil, ErrInvalidForecast
+	case req.HistoryDays < 0, req.HistoryDays > maxForecastHistoryDays:
+		return nil, ErrInvalidForecast
+	case req.Horizon < 0, req.Horizon > maxForecastHorizon:
+		return nil, ErrInvalidForecast
+	case req.BacktestDays < 0, req.BacktestDays > req.HistoryDays/2:
+		return nil, ErrInvalidForecast
+	}
+	models, err := selectForecastModels(req.Models)
+	if err != nil {
+		return nil, err
+	}
+
+	end := time.Now().UTC().Truncate(24 * time.Hour)
+	start := end.AddDate(0, 0, -req.HistoryDays)
+	demand, err := s.orderRepo.DailyDemand(ctx, restaurantID, repository.DemandFilter{
+		IngredientID: req.IngredientID,
+		From:         start,
+		To:           end,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("load order history: %w", err)
+	}
+
+	for _, d := range demand {
+		if len(forecasts) == 0 || forecasts[len(forecasts)-1].IngredientID != d.IngredientID {
+			forecasts = append(forecasts, IngredientForecast{
+				IngredientID:   d.IngredientID,
+				IngredientCode: d.IngredientCode,
+				IngredientName: d.IngredientName,
+				History:        forecast.Series{Start: start, Values: make([]float64, req.HistoryDays)},
+			})
+		}
+		day := int(d.Day.Sub(start) / (24 * time.Hour))
+		if day >= 0 && day < req.HistoryDays {
+			forecasts[len(forecasts)-1].History.Values[day] += float64(d.Quantity)
+		}
+	}
+
+	for i := range forecasts {
+		f := &forecasts[i]
+		var bestError float64
+		for _, model := range models {
+			daily := model.Forecast(f.History, req.Horizon)
+			result := ModelForecast{
+				Model: model.Name(),
+				Start: end,
+				Daily: make([]decimal.Decimal, len(daily)),
+				Total: decimal.Zero,
+			}
+			for day, value := range daily {
+				result.Daily[day] = decimal.NewFromFloat(value).Round(quantityPlaces)
+				result.Total = result.Total.Add(result.Daily[day])
+			}
+
+			if req.BacktestDays > 0 {
+				metrics, err := forecast.Backtest(model, f.History, req.BacktestDays)
+				if err != nil {
+					return nil, fmt.Errorf("backtest %s: %w", model.Name(), err)
+				}
+				result.Backtest = &metrics
+				if f.Best == "" || metrics.MAE < bestError {
+					f.Best, bestError = model.Name(), metrics.MAE
+				}
+			}
+			f.Models = append(f.Models, result)
+		}
+	}
+
+	return forecasts, nil
+}
+
+// selectForecastModels returns the named models, or every model when none
+// is named.
+func selectForecastModels(names []string) ([]forecast.Model, error) {
+	if len(names) == 0 {
+		return forecastModels, nil
+	}
+
+	selected := make([]forecast.Model, 0, len(names))
+	for _, model := range forecastModels {
+		for _, name := range names {
+			if name == model.Name() {
+				selected = append(selected, model)
+				break
+			}
+		}
+	}
+	if len(selected) != len(names) {
+		return nil, ErrInvalidForecast
+	}
+	return selected, nil
+}
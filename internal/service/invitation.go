@@ -0,0 +1,153 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"mmispoc/internal/repository"
+	"mmispoc/internal/tracing"
+)
+
+// ErrInvitationRequired is returned by SignUp when open registration is disabled and no code was supplied.
+var ErrInvitationRequired = errors.New("invitation required")
+
+// ErrInvalidInvitation indicates the invitation code is unknown, used or expired.
+var ErrInvalidInvitation = errors.New("invalid invitation")
+
+// ErrInvalidRole indicates an unknown or disallowed role was requested.
+var ErrInvalidRole = errors.New("invalid role")
+
+const (
+	defaultInvitationTTL = 7 * 24 * time.Hour
+	maxInvitationTTL     = 30 * 24 * time.Hour
+	invitationCodeBytes  = 16
+)
+
+var invitationEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// CreatedInvitation is returned once on creation and is the only time the code is available.
+type CreatedInvitation struct {
+	repository.Invitation
+	Code string
+}
+
+// CreateInvitation issues a single-use signup code. Managers invite staff to
+// their own restaurant; admins may invite any role to any restaurant.
+func (s *UserService) CreateInvitation(ctx context.Context, actor *Principal, restaurantID int64, role string, expiresAt time.Time) (created *CreatedInvitation, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.CreateInvitation")
+	defer func() { tracing.End(span, err) }()
+
+	role = strings.ToLower(strings.TrimSpace(role))
+	if role == "" {
+		role = RoleStaff
+	}
+
+	switch {
+	case isAdmin(actor):
+		if role != RoleStaff && role != RoleManager && role != RoleAreaManager && role != RoleAdmin {
+			return nil, ErrInvalidRole
+		}
+	case isManager(actor):
+		if restaurantID == 0 {
+			restaurantID = actor.RestaurantID
+		}
+		if restaurantID != actor.RestaurantID {
+			return nil, ErrForbidden
+		}
+		if role != RoleStaff {
+			return nil, ErrInvalidRole
+		}
+	default:
+		return nil, ErrForbidden
+	}
+
+	if restaurantID <= 0 {
+		return nil, ErrInvalidRestaurantID
+	}
+
+	now := time.Now().UTC()
+	if expiresAt.IsZero() {
+		expiresAt = now.Add(defaultInvitationTTL)
+	}
+	if !expiresAt.After(now) || expiresAt.Sub(now) > maxInvitationTTL {
+		return nil, ErrInvalidExpiry
+	}
+
+	exists, err := s.restaurantRepo.Exists(ctx, restaurantID)
+	if err != nil {
+		return nil, fmt.Errorf("check restaurant: %w", err)
+	}
+	if !exists {
+		return nil, ErrRestaurantNotFound
+	}
+
+	raw := make([]byte, invitationCodeBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, fmt.Errorf("read random: %w", err)
+	}
+	code := strings.ToLower(invitationEncoding.EncodeToString(raw))
+
+	invitation := repository.Invitation{
+		RestaurantID: restaurantID,
+		Role:         role,
+		CreatedBy:    actor.UserID,
+		ExpiresAt:    expiresAt.UTC(),
+	}
+	if err := s.invitationRepo.Create(ctx, hashPassword(code), &invitation); err != nil {
+		return nil, fmt.Errorf("store invitation: %w", err)
+	}
+
+	return &CreatedInvitation{Invitation: invitation, Code: code}, nil
+}
+
+// ListInvitations returns the invitations of a restaurant the actor manages.
+func (s *UserService) ListInvitations(ctx context.Context, actor *Principal, restaurantID int64) (invitations []repository.Invitation, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.ListInvitations")
+	defer func() { tracing.End(span, err) }()
+
+	switch {
+	case isAdmin(actor):
+	case isManager(actor):
+		if restaurantID == 0 {
+			restaurantID = actor.RestaurantID
+		}
+		if restaurantID != actor.RestaurantID {
+			return nil, ErrForbidden
+		}
+	default:
+		return nil, ErrForbidden
+	}
+
+	if restaurantID <= 0 {
+		return nil, ErrInvalidRestaurantID
+	}
+
+	invitations, err = s.invitationRepo.ListByRestaurant(ctx, restaurantID)
+	if err != nil {
+		return nil, fmt.Errorf("list invitations: %w", err)
+	}
+
+	return invitations, nil
+}
+
+func (s *UserService) redeemInvitation(ctx context.Context, code, username, passwordHash string) (*repository.User, error) {
+	code = strings.ToLower(strings.TrimSpace(code))
+
+	user, err := s.invitationRepo.Redeem(ctx, hashPassword(code), username, passwordHash)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrInvitationUnavailable):
+			return nil, ErrInvalidInvitation
+		case errors.Is(err, repository.ErrConflict):
+			return nil, ErrUsernameTaken
+		}
+		return nil, fmt.Errorf("redeem invitation: %w", err)
+	}
+
+	return user, nil
+}
@@ -0,0 +1,49 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"mmispoc/internal/logging"
+	"mmispoc/internal/metrics"
+)
+
+var (
+	ordersCreated = metrics.Default.NewCounterVec("mmispoc_orders_created_total",
+		"Order requests stored successfully.")
+	orderLines = metrics.Default.NewCounterVec("mmispoc_order_lines_total",
+		"Order lines stored across all order requests.")
+	approvalDecisions = metrics.Default.NewCounterVec("mmispoc_approval_decisions_total",
+		"Approval steps decided, by outcome.", "decision")
+	stockMovements = metrics.Default.NewCounterVec("mmispoc_stock_movements_total",
+		"Stock movements posted to the ledger, by kind.", "kind")
+	receiptLines = metrics.Default.NewCounterVec("mmispoc_goods_receipt_lines_total",
+		"Goods receipt lines recorded, by outcome: accepted or the reject reason.", "outcome")
+	signups = metrics.Default.NewCounterVec("mmispoc_signups_total",
+		"Accounts created through signup, by registration method.", "method")
+	loginFailures = metrics.Default.NewCounterVec("mmispoc_login_failures_total",
+		"Failed password and two-factor login attempts, by reason.", "reason")
+)
+
+// recordLoginFailure counts and logs a failed login step under a bounded reason label.
+func recordLoginFailure(ctx context.Context, err error) {
+	if err == nil {
+		return
+	}
+
+	reason := "error"
+	switch {
+	case errors.Is(err, ErrInvalidCredentials):
+		reason = "invalid_credentials"
+	case errors.Is(err, ErrAccountDisabled):
+		reason = "account_disabled"
+	case errors.Is(err, ErrInvalidMFACode):
+		reason = "invalid_mfa_code"
+	case errors.Is(err, ErrMFAChallengeExhausted):
+		reason = "mfa_challenge_exhausted"
+	case errors.Is(err, ErrInvalidToken), errors.Is(err, ErrTokenExpired), errors.Is(err, ErrTokenRevoked):
+		reason = "invalid_token"
+	}
+	loginFailures.Inc(reason)
+	logging.FromContext(ctx).Info("login failed", "reason", reason)
+}
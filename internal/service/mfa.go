@@ -0,0 +1,288 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"mmispoc/internal/repository"
+	"mmispoc/internal/tracing"
+)
+
+// ErrMFAAlreadyEnabled is returned when enrolling a user that already confirmed two-factor authentication.
+var ErrMFAAlreadyEnabled = errors.New("two-factor authentication already enabled")
+
+// ErrMFANotEnrolled is returned when confirming without a pending enrolment.
+var ErrMFANotEnrolled = errors.New("two-factor enrolment not started")
+
+// ErrInvalidMFACode indicates the supplied TOTP or recovery code was rejected.
+var ErrInvalidMFACode = errors.New("invalid two-factor code")
+
+// ErrMFAChallengeExhausted is returned once a login challenge token was used
+// or too many codes were tried against it; the user must log in again.
+var ErrMFAChallengeExhausted = errors.New("two-factor challenge exhausted")
+
+const (
+	recoveryCodeCount  = 10
+	recoveryCodeLength = 10
+	// maxMFAChallengeAttempts bounds the codes tried against one login
+	// challenge token.
+	maxMFAChallengeAttempts = 5
+)
+
+// MFAEnrollment carries the secret a user scans into an authenticator app.
+type MFAEnrollment struct {
+	Secret string
+	URI    string
+}
+
+// MFAConfirmation is returned once enrolment is confirmed.
+type MFAConfirmation struct {
+	// RecoveryCodes are shown to the user once; only their hashes are stored.
+	RecoveryCodes []string
+	AccessToken   string
+}
+
+// ValidateEnrollmentToken accepts either a regular access token or the
+// enrolment token issued at login when the role policy requires a second factor.
+func (s *UserService) ValidateEnrollmentToken(ctx context.Context, token string) (user *repository.User, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.ValidateEnrollmentToken")
+	defer func() { tracing.End(span, err) }()
+
+	claims, err := s.parseToken(token)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Purpose != "" && claims.Purpose != tokenPurposeMFAEnroll {
+		return nil, ErrInvalidToken
+	}
+
+	user, err = s.repo.GetByID(ctx, claims.UserID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrInvalidToken
+		}
+		return nil, fmt.Errorf("fetch user: %w", err)
+	}
+	if user.Disabled() {
+		return nil, ErrAccountDisabled
+	}
+	if claims.Version != user.TokenVersion {
+		return nil, ErrTokenRevoked
+	}
+
+	return user, nil
+}
+
+// EnrollMFA generates a new TOTP secret for the user. The secret stays
+// inactive until ConfirmMFA verifies a code produced from it.
+func (s *UserService) EnrollMFA(ctx context.Context, user *repository.User) (enrollment *MFAEnrollment, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.EnrollMFA")
+	defer func() { tracing.End(span, err) }()
+
+	secret, err := generateTOTPSecret()
+	if err != nil {
+		return nil, fmt.Errorf("generate totp secret: %w", err)
+	}
+
+	if err := s.mfaRepo.SavePending(ctx, user.ID, secret); err != nil {
+		if errors.Is(err, repository.ErrConflict) {
+			return nil, ErrMFAAlreadyEnabled
+		}
+		return nil, fmt.Errorf("store totp secret: %w", err)
+	}
+
+	return &MFAEnrollment{
+		Secret: secret,
+		URI:    totpURI(user.Username, secret),
+	}, nil
+}
+
+// ConfirmMFA activates a pending enrolment, issues recovery codes and a fresh access token.
+func (s *UserService) ConfirmMFA(ctx context.Context, user *repository.User, code string) (confirmation *MFAConfirmation, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.ConfirmMFA")
+	defer func() { tracing.End(span, err) }()
+
+	mfa, err := s.mfaRepo.Get(ctx, user.ID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrMFANotEnrolled
+		}
+		return nil, fmt.Errorf("fetch mfa: %w", err)
+	}
+	if mfa.Enabled() {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	step, ok := matchTOTP(mfa.Secret, normalizeMFACode(code), time.Now().UTC())
+	if !ok {
+		return nil, ErrInvalidMFACode
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, fmt.Errorf("generate recovery codes: %w", err)
+	}
+
+	if err := s.mfaRepo.Enable(ctx, user.ID, step, hashes); err != nil {
+		if errors.Is(err, repository.ErrConflict) {
+			return nil, ErrMFAAlreadyEnabled
+		}
+		return nil, fmt.Errorf("enable mfa: %w", err)
+	}
+
+	token, err := s.generateToken(user, "", s.tokenTTL)
+	if err != nil {
+		return nil, fmt.Errorf("generate token: %w", err)
+	}
+
+	return &MFAConfirmation{RecoveryCodes: codes, AccessToken: token}, nil
+}
+
+// CompleteMFALogin exchanges a login challenge token and a TOTP or recovery
+// code for an access token. Each challenge accepts maxMFAChallengeAttempts
+// codes and is closed by the first correct one.
+func (s *UserService) CompleteMFALogin(ctx context.Context, challengeToken, code string) (token string, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.CompleteMFALogin")
+	defer func() { tracing.End(span, err) }()
+
+	defer func() { recordLoginFailure(ctx, err) }()
+
+	claims, err := s.parseToken(challengeToken)
+	if err != nil {
+		return "", err
+	}
+	if claims.Purpose != tokenPurposeMFAChallenge || claims.ID == "" {
+		return "", ErrInvalidToken
+	}
+
+	user, err := s.repo.GetByID(ctx, claims.UserID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return "", ErrInvalidToken
+		}
+		return "", fmt.Errorf("fetch user: %w", err)
+	}
+	if user.Disabled() {
+		return "", ErrAccountDisabled
+	}
+	if claims.Version != user.TokenVersion {
+		return "", ErrTokenRevoked
+	}
+
+	mfa, err := s.mfaRepo.Get(ctx, user.ID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return "", ErrInvalidToken
+		}
+		return "", fmt.Errorf("fetch mfa: %w", err)
+	}
+	if !mfa.Enabled() {
+		return "", ErrInvalidToken
+	}
+
+	reserved, err := s.mfaRepo.ReserveChallengeAttempt(ctx, claims.ID, user.ID, time.Unix(claims.Exp, 0), maxMFAChallengeAttempts)
+	if err != nil {
+		return "", fmt.Errorf("reserve challenge attempt: %w", err)
+	}
+	if !reserved {
+		return "", ErrMFAChallengeExhausted
+	}
+
+	if err := s.verifySecondFactor(ctx, mfa, code); err != nil {
+		return "", err
+	}
+
+	if err := s.mfaRepo.CloseChallenge(ctx, claims.ID, maxMFAChallengeAttempts); err != nil {
+		return "", fmt.Errorf("close challenge: %w", err)
+	}
+
+	token, err = s.generateToken(user, "", s.tokenTTL)
+	if err != nil {
+		return "", fmt.Errorf("generate token: %w", err)
+	}
+
+	return token, nil
+}
+
+func (s *UserService) mfaEnabled(ctx context.Context, userID int64) (bool, error) {
+	mfa, err := s.mfaRepo.Get(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return false, nil
+		}
+		return false, fmt.Errorf("fetch mfa: %w", err)
+	}
+	return mfa.Enabled(), nil
+}
+
+// verifySecondFactor accepts a current TOTP code, consuming its time step so
+// it cannot be replayed, or falls back to a single-use recovery code.
+func (s *UserService) verifySecondFactor(ctx context.Context, mfa *repository.MFA, code string) error {
+	code = normalizeMFACode(code)
+	if code == "" {
+		return ErrInvalidMFACode
+	}
+
+	if step, ok := matchTOTP(mfa.Secret, code, time.Now().UTC()); ok {
+		fresh, err := s.mfaRepo.MarkStepUsed(ctx, mfa.UserID, step)
+		if err != nil {
+			return fmt.Errorf("mark totp step: %w", err)
+		}
+		if !fresh {
+			return ErrInvalidMFACode
+		}
+		return nil
+	}
+
+	if len(code) != recoveryCodeLength {
+		return ErrInvalidMFACode
+	}
+
+	consumed, err := s.mfaRepo.ConsumeRecoveryCode(ctx, mfa.UserID, hashPassword(code))
+	if err != nil {
+		return fmt.Errorf("consume recovery code: %w", err)
+	}
+	if !consumed {
+		return ErrInvalidMFACode
+	}
+
+	return nil
+}
+
+func normalizeMFACode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	code = strings.ReplaceAll(code, "-", "")
+	return strings.ReplaceAll(code, " ", "")
+}
+
+// generateRecoveryCodes returns display codes (xxxxx-xxxxx) and the hashes to persist.
+func generateRecoveryCodes() ([]string, []string, error) {
+	const alphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	// rand.Int draws uniformly; reducing a random byte modulo the alphabet
+	// size would favour its first characters.
+	size := big.NewInt(int64(len(alphabet)))
+	for i := 0; i < recoveryCodeCount; i++ {
+		var b strings.Builder
+		for j := 0; j < recoveryCodeLength; j++ {
+			n, err := rand.Int(rand.Reader, size)
+			if err != nil {
+				return nil, nil, fmt.Errorf("read random: %w", err)
+			}
+			b.WriteByte(alphabet[n.Int64()])
+		}
+		code := b.String()
+
+		codes = append(codes, code[:recoveryCodeLength/2]+"-"+code[recoveryCodeLength/2:])
+		hashes = append(hashes, hashPassword(code))
+	}
+
+	return codes, hashes, nil
+}
@@ -8,12 +8,47 @@ import (
 	"time"
 
 	"mmispoc/internal/repository"
+	"mmispoc/internal/tracing"
 )
 
 // OrderItem represents a single incoming order line.
 type OrderItem struct {
 	IngredientID int64
 	Number       int
+	// SupplierID pins the line to a supplier; zero routes it to the
+	// preferred supplier of the ingredient.
+	SupplierID int64
+}
+
+// OrderPlacement describes how an order request was split into one purchase
+// order per supplier.
+type OrderPlacement struct {
+	PurchaseOrders []PlacedPurchaseOrder
+	Totals         OrderTotals
+	// Status is pending_approval when any purchase order of the request
+	// waits for approval and placed otherwise.
+	Status string
+	// Warnings explain budgets the order exceeds.
+	Warnings []string
+}
+
+// Lines returns the number of order lines across all purchase orders.
+func (p *OrderPlacement) Lines() int {
+	count := 0
+	for _, po := range p.PurchaseOrders {
+		count += len(po.Lines)
+	}
+	return count
+}
+
+// PlacedPurchaseOrder is the part of an order request sent to one supplier.
+// SupplierID is zero for lines no supplier delivers.
+type PlacedPurchaseOrder struct {
+	repository.PurchaseOrder
+	SupplierName string
+	// LineTerms holds the supplier terms applied to each entry of Lines.
+	LineTerms []repository.SupplierIngredient
+	Totals    OrderTotals
 }
 
 // OrderService orchestrates order creation.
@@ -21,14 +56,22 @@ type OrderService struct {
 	orderRepo      *repository.OrderRepository
 	restaurantRepo *repository.RestaurantRepository
 	ingredientRepo *repository.IngredientRepository
+	supplierRepo   *repository.SupplierRepository
+	priceRepo      *repository.PriceRepository
+	budgetRepo     *repository.BudgetRepository
+	approvals      *ApprovalService
 }
 
 // NewOrder constructs an order service.
-func NewOrder(orderRepo *repository.OrderRepository, restaurantRepo *repository.RestaurantRepository, ingredientRepo *repository.IngredientRepository) *OrderService {
+func NewOrder(orderRepo *repository.OrderRepository, restaurantRepo *repository.RestaurantRepository, ingredientRepo *repository.IngredientRepository, supplierRepo *repository.SupplierRepository, priceRepo *repository.PriceRepository, budgetRepo *repository.BudgetRepository, approvals *ApprovalService) *OrderService {
 	return &OrderService{
 		orderRepo:      orderRepo,
 		restaurantRepo: restaurantRepo,
 		ingredientRepo: ingredientRepo,
+		supplierRepo:   supplierRepo,
+		priceRepo:      priceRepo,
+		budgetRepo:     budgetRepo,
+		approvals:      approvals,
 	}
 }
 
@@ -51,62 +94,223 @@ var (
 	ErrOrderNotFound = errors.New("order not found")
 	// ErrOrderForbidden indicates the order is not owned by the requesting restaurant.
 	ErrOrderForbidden = errors.New("order access forbidden")
+	// ErrOrderSupplierNotOffering indicates the requested supplier does not deliver the ingredient.
+	ErrOrderSupplierNotOffering = errors.New("supplier does not deliver ingredient")
+	// ErrOrderBelowMinimum indicates a quantity below the supplier's minimum order quantity.
+	ErrOrderBelowMinimum = errors.New("quantity below supplier minimum")
+	// ErrOrderPackSize indicates a quantity that is not a whole number of supplier packs.
+	ErrOrderPackSize = errors.New("quantity is not a multiple of the supplier pack size")
 )
 
-// CreateOrders validates input and persists orders.
-func (s *OrderService) CreateOrders(ctx context.Context, restaurantID int64, items []OrderItem) error {
+// CreateOrders validates input, routes every line to a supplier and persists
+// one purchase order per supplier. Purchase orders matching an approval rule
+// wait in pending_approval, and so does every purchase order of a request
+// exceeding a hard budget; see checkBudgets. userID records who placed the
+// order and is zero for integrations authenticated with an API key.
+func (s *OrderService) CreateOrders(ctx context.Context, restaurantID, userID int64, items []OrderItem) (placement *OrderPlacement, err error) {
+	ctx, span := tracing.Start(ctx, "OrderService.CreateOrders")
+	defer func() { tracing.End(span, err) }()
+
 	if restaurantID <= 0 {
-		return ErrOrderInvalidRestaurantID
+		return nil, ErrOrderInvalidRestaurantID
 	}
 	if len(items) == 0 {
-		return ErrOrderEmptyItems
+		return nil, ErrOrderEmptyItems
 	}
 
 	exists, err := s.restaurantRepo.Exists(ctx, restaurantID)
 	if err != nil {
-		return fmt.Errorf("check restaurant: %w", err)
+		return nil, fmt.Errorf("check restaurant: %w", err)
 	}
 	if !exists {
-		return ErrOrderRestaurantNotFound
+		return nil, ErrOrderRestaurantNotFound
 	}
 
-	now := time.Now().UTC().UnixNano()
-
-	persistItems := make([]repository.Order, 0, len(items))
-	for idx, item := range items {
+	ingredientIDs := make([]int64, 0, len(items))
+	for _, item := range items {
 		if item.IngredientID <= 0 {
-			return ErrOrderInvalidIngredientID
+			return nil, ErrOrderInvalidIngredientID
 		}
 		if item.Number <= 0 {
-			return ErrOrderInvalidNumber
+			return nil, ErrOrderInvalidNumber
 		}
 
 		ingredientExists, err := s.ingredientRepo.Exists(ctx, item.IngredientID)
 		if err != nil {
-			return fmt.Errorf("check ingredient: %w", err)
+			return nil, fmt.Errorf("check ingredient: %w", err)
 		}
 		if !ingredientExists {
-			return ErrOrderIngredientNotFound
+			return nil, ErrOrderIngredientNotFound
+		}
+		ingredientIDs = append(ingredientIDs, item.IngredientID)
+	}
+
+	now := time.Now().UTC()
+	offers, err := s.supplierRepo.ListOffers(ctx, ingredientIDs)
+	if err != nil {
+		return nil, fmt.Errorf("list supplier offers: %w", err)
+	}
+	prices, err := s.priceRepo.ListActive(ctx, ingredientIDs, now)
+	if err != nil {
+		return nil, fmt.Errorf("list prices: %w", err)
+	}
+	taxRates, err := s.ingredientRepo.TaxRates(ctx, ingredientIDs)
+	if err != nil {
+		return nil, fmt.Errorf("list tax rates: %w", err)
+	}
+
+	book := newPriceBook(prices, taxRates)
+	book.applyToOffers(offers)
+
+	placement, err = routeOrderItems(restaurantID, userID, items, offers, now)
+	if err != nil {
+		return nil, err
+	}
+	book.apply(placement)
+
+	steps, err := s.approvals.placementSteps(ctx, restaurantID, placement)
+	if err != nil {
+		return nil, err
+	}
+	budgets, err := s.budgetRepo.ListByRestaurant(ctx, restaurantID)
+	if err != nil {
+		return nil, fmt.Errorf("list budgets: %w", err)
+	}
+	check := func(spend repository.SpendFunc, purchaseOrders []repository.PurchaseOrder) error {
+		overBudget, err := checkBudgets(budgets, placement.Totals.Subtotal, now, spend, placement)
+		if err != nil {
+			return err
+		}
+		for i := range purchaseOrders {
+			required := steps[i]
+			if overBudget {
+				required = append(required, budgetApproval)
+			}
+			purchaseOrders[i].Approvals = s.approvals.chain(required, now)
+		}
+		return nil
+	}
+
+	purchaseOrders := make([]repository.PurchaseOrder, len(placement.PurchaseOrders))
+	for i := range placement.PurchaseOrders {
+		purchaseOrders[i] = placement.PurchaseOrders[i].PurchaseOrder
+	}
+	if err := s.orderRepo.CreatePurchaseOrders(ctx, restaurantID, purchaseOrders, check); err != nil {
+		return nil, fmt.Errorf("store orders: %w", err)
+	}
+	for i := range placement.PurchaseOrders {
+		placement.PurchaseOrders[i].PurchaseOrder = purchaseOrders[i]
+	}
+	placement.Status = repository.PurchaseOrderPlaced
+	for _, po := range purchaseOrders {
+		if po.Status == repository.PurchaseOrderPendingApproval {
+			placement.Status = po.Status
 		}
+	}
+
+	ordersCreated.Inc()
+	orderLines.Add(float64(len(items)))
+
+	return placement, nil
+}
+
+// routeOrderItems assigns each item to a supplier and groups them into
+// purchase orders in the order suppliers first appear in the request. The
+// minimum order quantity applies to the total of an ingredient per supplier;
+// pack sizes apply to every line.
+func routeOrderItems(restaurantID, userID int64, items []OrderItem, offers []repository.SupplierIngredient, now time.Time) (*OrderPlacement, error) {
+	offersByIngredient := make(map[int64][]repository.SupplierIngredient)
+	for _, offer := range offers {
+		offersByIngredient[offer.IngredientID] = append(offersByIngredient[offer.IngredientID], offer)
+	}
+
+	type quantityKey struct{ supplierID, ingredientID int64 }
+	var keys []quantityKey
+	totals := make(map[quantityKey]int)
+	terms := make(map[quantityKey]repository.SupplierIngredient)
 
-		code := fmt.Sprintf("ORD-%d-%d-%d", restaurantID, now, idx)
-		persistItems = append(persistItems, repository.Order{
-			Code:         code,
+	placement := &OrderPlacement{}
+	groupIndex := make(map[int64]int)
+	stamp := now.UnixNano()
+
+	for idx, item := range items {
+		var chosen repository.SupplierIngredient
+		candidates := offersByIngredient[item.IngredientID]
+		if item.SupplierID != 0 {
+			found := false
+			for _, offer := range candidates {
+				if offer.SupplierID == item.SupplierID {
+					chosen, found = offer, true
+					break
+				}
+			}
+			if !found {
+				return nil, fmt.Errorf("%w: supplier %d, ingredient %d", ErrOrderSupplierNotOffering, item.SupplierID, item.IngredientID)
+			}
+		} else if best := selectOffer(candidates); best != nil {
+			chosen = *best
+		} else {
+			chosen = repository.SupplierIngredient{IngredientID: item.IngredientID, PackSize: 1}
+		}
+
+		if chosen.PackSize > 1 && item.Number%chosen.PackSize != 0 {
+			return nil, fmt.Errorf("%w: ingredient %d is sold in packs of %d", ErrOrderPackSize, item.IngredientID, chosen.PackSize)
+		}
+		key := quantityKey{chosen.SupplierID, item.IngredientID}
+		if _, seen := totals[key]; !seen {
+			keys = append(keys, key)
+		}
+		totals[key] += item.Number
+		terms[key] = chosen
+
+		gi, ok := groupIndex[chosen.SupplierID]
+		if !ok {
+			gi = len(placement.PurchaseOrders)
+			groupIndex[chosen.SupplierID] = gi
+			placement.PurchaseOrders = append(placement.PurchaseOrders, PlacedPurchaseOrder{
+				PurchaseOrder: repository.PurchaseOrder{
+					Code:         fmt.Sprintf("PO-%d-%d-%d", restaurantID, stamp, gi),
+					RestaurantID: restaurantID,
+					SupplierID:   chosen.SupplierID,
+					CreatedBy:    userID,
+				},
+				SupplierName: chosen.SupplierName,
+			})
+		}
+
+		po := &placement.PurchaseOrders[gi]
+		po.Lines = append(po.Lines, repository.Order{
+			Code:         fmt.Sprintf("ORD-%d-%d-%d", restaurantID, stamp, idx),
 			RestaurantID: restaurantID,
 			IngredientID: item.IngredientID,
 			Number:       item.Number,
+			CreatedBy:    userID,
+			SupplierID:   chosen.SupplierID,
 		})
+		po.LineTerms = append(po.LineTerms, chosen)
+
+		if chosen.SupplierID != 0 {
+			expected := now.AddDate(0, 0, chosen.LeadTimeDays).Truncate(24 * time.Hour)
+			if expected.After(po.ExpectedDeliveryOn) {
+				po.ExpectedDeliveryOn = expected
+			}
+		}
 	}
 
-	if err := s.orderRepo.CreateBulk(ctx, restaurantID, persistItems); err != nil {
-		return fmt.Errorf("store orders: %w", err)
+	for _, key := range keys {
+		if minimum := terms[key].MinOrderQty; totals[key] < minimum {
+			return nil, fmt.Errorf("%w: ingredient %d needs at least %d from supplier %d", ErrOrderBelowMinimum, key.ingredientID, minimum, key.supplierID)
+		}
 	}
 
-	return nil
+	return placement, nil
 }
 
 // GetOrdersByRestaurant returns all orders for a restaurant.
-func (s *OrderService) GetOrdersByRestaurant(ctx context.Context, restaurantID int64) ([]repository.Order, string, error) {
+func (s *OrderService) GetOrdersByRestaurant(ctx context.Context, restaurantID int64) (orders []repository.Order, restaurantName string, err error) {
+	ctx, span := tracing.Start(ctx, "OrderService.GetOrdersByRestaurant")
+	defer func() { tracing.End(span, err) }()
+
 	if restaurantID <= 0 {
 		return nil, "", ErrOrderInvalidRestaurantID
 	}
@@ -127,7 +331,7 @@ func (s *OrderService) GetOrdersByRestaurant(ctx context.Context, restaurantID i
 		return nil, "", fmt.Errorf("get restaurant name: %w", err)
 	}
 
-	orders, err := s.orderRepo.ListByRestaurant(ctx, restaurantID)
+	orders, err = s.orderRepo.ListByRestaurant(ctx, restaurantID)
 	if err != nil {
 		return nil, "", fmt.Errorf("list orders: %w", err)
 	}
@@ -136,7 +340,10 @@ func (s *OrderService) GetOrdersByRestaurant(ctx context.Context, restaurantID i
 }
 
 // GetOrder retrieves a single order ensuring ownership by restaurant.
-func (s *OrderService) GetOrder(ctx context.Context, orderID, restaurantID int64) (*repository.Order, error) {
+func (s *OrderService) GetOrder(ctx context.Context, orderID, restaurantID int64) (order *repository.Order, err error) {
+	ctx, span := tracing.Start(ctx, "OrderService.GetOrder")
+	defer func() { tracing.End(span, err) }()
+
 	if orderID <= 0 {
 		return nil, ErrOrderInvalidID
 	}
@@ -145,7 +352,7 @@ func (s *OrderService) GetOrder(ctx context.Context, orderID, restaurantID int64
 		return nil, ErrOrderInvalidRestaurantID
 	}
 
-	order, err := s.orderRepo.Get(ctx, orderID)
+	order, err = s.orderRepo.Get(ctx, orderID)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrOrderNotFound
@@ -0,0 +1,238 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"mmispoc/internal/repository"
+	"mmispoc/internal/tracing"
+)
+
+var (
+	// ErrInvalidPrice indicates a negative price or an empty effective period.
+	ErrInvalidPrice = errors.New("invalid price")
+	// ErrPriceNotAppendable indicates a price starting at or before the latest one.
+	ErrPriceNotAppendable = errors.New("price must start after the latest price")
+	// ErrInvalidTaxRate indicates a tax rate outside [0, 1).
+	ErrInvalidTaxRate = errors.New("invalid tax rate")
+)
+
+// Monetary amounts are rounded to cents; unit prices keep four decimals.
+const (
+	amountPlaces = 2
+	pricePlaces  = 4
+)
+
+// OrderTotals sums the net amount and tax of order lines. Lines without a
+// price are counted in Unpriced and left out of the sums.
+type OrderTotals struct {
+	Subtotal decimal.Decimal
+	Tax      decimal.Decimal
+	Total    decimal.Decimal
+	Unpriced int
+}
+
+// add accumulates one order line.
+func (t *OrderTotals) add(line repository.Order) {
+	net, tax, ok := OrderLineAmounts(line)
+	if !ok {
+		t.Unpriced++
+		return
+	}
+	t.Subtotal = t.Subtotal.Add(net)
+	t.Tax = t.Tax.Add(tax)
+	t.Total = t.Subtotal.Add(t.Tax)
+}
+
+// OrderLineAmounts returns the net amount and tax of a line from its
+// snapshotted price, each rounded to cents. ok is false for unpriced lines.
+func OrderLineAmounts(line repository.Order) (net, tax decimal.Decimal, ok bool) {
+	if !line.UnitPrice.Valid {
+		return decimal.Zero, decimal.Zero, false
+	}
+	net = line.UnitPrice.Decimal.Mul(decimal.NewFromInt(int64(line.Number))).Round(amountPlaces)
+	if line.TaxRate.Valid {
+		tax = net.Mul(line.TaxRate.Decimal).Round(amountPlaces)
+	}
+	return net, tax, true
+}
+
+// PricingService maintains ingredient price history and tax rates.
+type PricingService struct {
+	priceRepo      *repository.PriceRepository
+	ingredientRepo *repository.IngredientRepository
+	supplierRepo   *repository.SupplierRepository
+}
+
+// NewPricing constructs a pricing service.
+func NewPricing(priceRepo *repository.PriceRepository, ingredientRepo *repository.IngredientRepository, supplierRepo *repository.SupplierRepository) *PricingService {
+	return &PricingService{
+		priceRepo:      priceRepo,
+		ingredientRepo: ingredientRepo,
+		supplierRepo:   supplierRepo,
+	}
+}
+
+// ListPrices returns the price history of an ingredient, newest first.
+func (s *PricingService) ListPrices(ctx context.Context, principal *Principal, ingredientID int64) (prices []repository.IngredientPrice, err error) {
+	ctx, span := tracing.Start(ctx, "PricingService.ListPrices")
+	defer func() { tracing.End(span, err) }()
+
+	if !principal.HasScope(ScopeOrdersRead) {
+		return nil, ErrForbidden
+	}
+	if err := s.requireIngredient(ctx, ingredientID); err != nil {
+		return nil, err
+	}
+
+	prices, err = s.priceRepo.ListByIngredient(ctx, ingredientID)
+	if err != nil {
+		return nil, fmt.Errorf("list prices: %w", err)
+	}
+	return prices, nil
+}
+
+// SetPrice appends a general or supplier specific price to an ingredient's
+// history. A zero EffectiveFrom means now.
+func (s *PricingService) SetPrice(ctx context.Context, principal *Principal, price *repository.IngredientPrice) (err error) {
+	ctx, span := tracing.Start(ctx, "PricingService.SetPrice")
+	defer func() { tracing.End(span, err) }()
+
+	if !isAdmin(principal) {
+		return ErrForbidden
+	}
+
+	if price.EffectiveFrom.IsZero() {
+		price.EffectiveFrom = time.Now().UTC()
+	}
+	if price.Price.IsNegative() || (!price.EffectiveTo.IsZero() && !price.EffectiveTo.After(price.EffectiveFrom)) {
+		return ErrInvalidPrice
+	}
+	price.Price = price.Price.Round(pricePlaces)
+	price.CreatedBy = principal.UserID
+
+	if err := s.requireIngredient(ctx, price.IngredientID); err != nil {
+		return err
+	}
+	if price.SupplierID != 0 {
+		if _, err := s.supplierRepo.Get(ctx, price.SupplierID); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrSupplierNotFound
+			}
+			return fmt.Errorf("get supplier: %w", err)
+		}
+	}
+
+	return recordPrice(ctx, s.priceRepo, price)
+}
+
+// SetTaxRate changes the tax rate applied to new order lines of an ingredient.
+func (s *PricingService) SetTaxRate(ctx context.Context, principal *Principal, ingredientID int64, rate decimal.Decimal) (err error) {
+	ctx, span := tracing.Start(ctx, "PricingService.SetTaxRate")
+	defer func() { tracing.End(span, err) }()
+
+	if !isAdmin(principal) {
+		return ErrForbidden
+	}
+	if rate.IsNegative() || rate.GreaterThanOrEqual(decimal.NewFromInt(1)) {
+		return ErrInvalidTaxRate
+	}
+
+	updated, err := s.ingredientRepo.SetTaxRate(ctx, ingredientID, rate.Round(pricePlaces))
+	if err != nil {
+		return fmt.Errorf("set tax rate: %w", err)
+	}
+	if !updated {
+		return ErrIngredientNotFound
+	}
+	return nil
+}
+
+func (s *PricingService) requireIngredient(ctx context.Context, ingredientID int64) error {
+	if ingredientID <= 0 {
+		return ErrIngredientNotFound
+	}
+	exists, err := s.ingredientRepo.Exists(ctx, ingredientID)
+	if err != nil {
+		return fmt.Errorf("check ingredient: %w", err)
+	}
+	if !exists {
+		return ErrIngredientNotFound
+	}
+	return nil
+}
+
+func recordPrice(ctx context.Context, priceRepo *repository.PriceRepository, price *repository.IngredientPrice) error {
+	if err := priceRepo.Create(ctx, price); err != nil {
+		if errors.Is(err, repository.ErrConflict) {
+			return ErrPriceNotAppendable
+		}
+		return fmt.Errorf("record price: %w", err)
+	}
+	return nil
+}
+
+// priceBook resolves the price of an ingredient at one instant. A supplier
+// specific price takes precedence over the supplier's list price, which takes
+// precedence over the ingredient's general price.
+type priceBook struct {
+	prices   map[priceKey]decimal.Decimal
+	taxRates map[int64]decimal.Decimal
+}
+
+type priceKey struct{ ingredientID, supplierID int64 }
+
+func newPriceBook(prices []repository.IngredientPrice, taxRates map[int64]decimal.Decimal) *priceBook {
+	book := &priceBook{prices: make(map[priceKey]decimal.Decimal, len(prices)), taxRates: taxRates}
+	for _, p := range prices {
+		book.prices[priceKey{p.IngredientID, p.SupplierID}] = p.Price
+	}
+	return book
+}
+
+func (b *priceBook) lookup(terms repository.SupplierIngredient) (decimal.Decimal, bool) {
+	if terms.SupplierID != 0 {
+		if price, ok := b.prices[priceKey{terms.IngredientID, terms.SupplierID}]; ok {
+			return price, true
+		}
+		if terms.Price.IsPositive() {
+			return terms.Price, true
+		}
+	}
+	price, ok := b.prices[priceKey{terms.IngredientID, 0}]
+	return price, ok
+}
+
+// applyToOffers replaces the list price of supplier terms with the price in
+// effect, so routing compares what would actually be charged.
+func (b *priceBook) applyToOffers(offers []repository.SupplierIngredient) {
+	for i := range offers {
+		if price, ok := b.prices[priceKey{offers[i].IngredientID, offers[i].SupplierID}]; ok {
+			offers[i].Price = price
+		}
+	}
+}
+
+// apply snapshots prices and tax rates onto the lines of a placement and
+// computes its totals.
+func (b *priceBook) apply(placement *OrderPlacement) {
+	placement.Totals = OrderTotals{}
+	for i := range placement.PurchaseOrders {
+		po := &placement.PurchaseOrders[i]
+		po.Totals = OrderTotals{}
+		for j := range po.Lines {
+			line := &po.Lines[j]
+			if price, ok := b.lookup(po.LineTerms[j]); ok {
+				line.UnitPrice = decimal.NewNullDecimal(price)
+				line.TaxRate = decimal.NewNullDecimal(b.taxRates[line.IngredientID])
+			}
+			po.Totals.add(*line)
+			placement.Totals.add(*line)
+		}
+	}
+}
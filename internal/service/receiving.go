@@ -0,0 +1,326 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"mmispoc/internal/repository"
+	"mmispoc/internal/tracing"
+)
+
+var (
+	// ErrInvalidReceipt indicates a receipt without lines, negative or empty
+	// quantities, a line and lot listed twice or a missing or unknown reject
+	// reason.
+	ErrInvalidReceipt = errors.New("invalid goods receipt")
+	// ErrReceiptLineNotFound indicates a receipt line that matches no line,
+	// or more than one line, of the purchase order.
+	ErrReceiptLineNotFound = errors.New("receipt line not on order")
+	// ErrReceiptExceedsOrder indicates more goods received and rejected than
+	// are still outstanding on the line.
+	ErrReceiptExceedsOrder = errors.New("receipt exceeds outstanding quantity")
+	// ErrOrderNotReceivable indicates the purchase order is not awaiting
+	// delivery, for example because it still waits for approval or was
+	// already received in full.
+	ErrOrderNotReceivable = errors.New("order is not awaiting delivery")
+)
+
+// ReceiptLineInput is one line of a delivery. The order line is identified
+// by OrderID or, when the ingredient appears on a single line, IngredientID.
+// An order line delivered in several lots takes one input per lot.
+type ReceiptLineInput struct {
+	OrderID      int64
+	IngredientID int64
+	Received     decimal.Decimal
+	Rejected     decimal.Decimal
+	RejectReason string
+	LotNumber    string
+	// ExpiresOn is the expiry date printed on the lot, if any.
+	ExpiresOn time.Time
+}
+
+// ReceivingService records deliveries against purchase orders and reports
+// how suppliers delivered.
+type ReceivingService struct {
+	receiptRepo *repository.ReceiptRepository
+	orderRepo   *repository.OrderRepository
+}
+
+// NewReceiving constructs a receiving service.
+func NewReceiving(receiptRepo *repository.ReceiptRepository, orderRepo *repository.OrderRepository) *ReceivingService {
+	return &ReceivingService{
+		receiptRepo: receiptRepo,
+		orderRepo:   orderRepo,
+	}
+}
+
+// Receive records a delivery against a purchase order. Lines may cover
+// part of the order; later receipts cover the rest. Received quantities go
+// into stock, rejected ones are recorded with their reason, and the order
+// becomes partially_received or received once every line is settled.
+func (s *ReceivingService) Receive(ctx context.Context, principal *Principal, purchaseOrderID int64, note string, inputs []ReceiptLineInput) (receipt *repository.GoodsReceipt, po *repository.PurchaseOrder, err error) {
+	ctx, span := tracing.Start(ctx, "ReceivingService.Receive")
+	defer func() { tracing.End(span, err) }()
+
+	if len(inputs) == 0 {
+		return nil, nil, ErrInvalidReceipt
+	}
+	for i := range inputs {
+		input := &inputs[i]
+		input.Received = input.Received.Round(quantityPlaces)
+		input.Rejected = input.Rejected.Round(quantityPlaces)
+		input.RejectReason = strings.ToLower(strings.TrimSpace(input.RejectReason))
+		input.LotNumber = strings.TrimSpace(input.LotNumber)
+		switch {
+		case input.OrderID < 0, input.IngredientID < 0, input.OrderID == 0 && input.IngredientID == 0:
+			return nil, nil, ErrInvalidReceipt
+		case input.Received.IsNegative(), input.Rejected.IsNegative():
+			return nil, nil, ErrInvalidReceipt
+		case input.Received.IsZero() && input.Rejected.IsZero():
+			return nil, nil, ErrInvalidReceipt
+		case input.Rejected.IsZero() != (input.RejectReason == ""):
+			return nil, nil, ErrInvalidReceipt
+		case input.RejectReason != "" && !validRejectReason(input.RejectReason):
+			return nil, nil, ErrInvalidReceipt
+		}
+	}
+
+	return s.receive(ctx, principal, purchaseOrderID, note, func(receivable []repository.ReceivableLine) ([]repository.GoodsReceiptLine, error) {
+		type lotKey struct {
+			orderID   int64
+			lotNumber string
+			expiresOn time.Time
+		}
+		seen := make(map[lotKey]bool, len(inputs))
+		booked := make(map[int64]decimal.Decimal, len(inputs))
+		lines := make([]repository.GoodsReceiptLine, 0, len(inputs))
+		for _, input := range inputs {
+			line, err := matchReceivableLine(receivable, input)
+			if err != nil {
+				return nil, err
+			}
+			key := lotKey{line.OrderID, input.LotNumber, input.ExpiresOn}
+			if seen[key] {
+				return nil, ErrInvalidReceipt
+			}
+			seen[key] = true
+
+			booked[line.OrderID] = booked[line.OrderID].Add(input.Received).Add(input.Rejected)
+			if booked[line.OrderID].GreaterThan(line.Outstanding()) {
+				return nil, fmt.Errorf("%w: order line %d has %s outstanding", ErrReceiptExceedsOrder, line.OrderID, line.Outstanding())
+			}
+			lines = append(lines, repository.GoodsReceiptLine{
+				OrderID:      line.OrderID,
+				IngredientID: line.IngredientID,
+				Received:     input.Received,
+				Rejected:     input.Rejected,
+				RejectReason: input.RejectReason,
+				LotNumber:    input.LotNumber,
+				ExpiresOn:    input.ExpiresOn,
+			})
+		}
+		return lines, nil
+	})
+}
+
+// ReceiveAll records that everything still outstanding on a purchase order
+// arrived and was accepted.
+func (s *ReceivingService) ReceiveAll(ctx context.Context, principal *Principal, purchaseOrderID int64) (receipt *repository.GoodsReceipt, po *repository.PurchaseOrder, err error) {
+	ctx, span := tracing.Start(ctx, "ReceivingService.ReceiveAll")
+	defer func() { tracing.End(span, err) }()
+
+	return s.receive(ctx, principal, purchaseOrderID, "", func(receivable []repository.ReceivableLine) ([]repository.GoodsReceiptLine, error) {
+		var lines []repository.GoodsReceiptLine
+		for _, line := range receivable {
+			if outstanding := line.Outstanding(); outstanding.IsPositive() {
+				lines = append(lines, repository.GoodsReceiptLine{
+					OrderID:      line.OrderID,
+					IngredientID: line.IngredientID,
+					Received:     outstanding,
+					Rejected:     decimal.Zero,
+				})
+			}
+		}
+		if len(lines) == 0 {
+			return nil, ErrOrderNotReceivable
+		}
+		return lines, nil
+	})
+}
+
+// CloseShort settles everything still outstanding on a purchase order as
+// not delivered, for suppliers that shipped short and will not send the
+// rest. The order becomes received, so the missing quantities stop counting
+// as on order.
+func (s *ReceivingService) CloseShort(ctx context.Context, principal *Principal, purchaseOrderID int64, note string) (receipt *repository.GoodsReceipt, po *repository.PurchaseOrder, err error) {
+	ctx, span := tracing.Start(ctx, "ReceivingService.CloseShort")
+	defer func() { tracing.End(span, err) }()
+
+	return s.receive(ctx, principal, purchaseOrderID, note, func(receivable []repository.ReceivableLine) ([]repository.GoodsReceiptLine, error) {
+		var lines []repository.GoodsReceiptLine
+		for _, line := range receivable {
+			if outstanding := line.Outstanding(); outstanding.IsPositive() {
+				lines = append(lines, repository.GoodsReceiptLine{
+					OrderID:      line.OrderID,
+					IngredientID: line.IngredientID,
+					Received:     decimal.Zero,
+					Rejected:     outstanding,
+					RejectReason: repository.RejectNotDelivered,
+				})
+			}
+		}
+		if len(lines) == 0 {
+			return nil, ErrOrderNotReceivable
+		}
+		return lines, nil
+	})
+}
+
+func (s *ReceivingService) receive(ctx context.Context, principal *Principal, purchaseOrderID int64, note string, check repository.ReceiptCheck) (*repository.GoodsReceipt, *repository.PurchaseOrder, error) {
+	po, err := s.purchaseOrder(ctx, principal, purchaseOrderID, ScopeOrdersWrite)
+	if err != nil {
+		return nil, nil, err
+	}
+	if po.Status != repository.PurchaseOrderPlaced && po.Status != repository.PurchaseOrderPartiallyReceived {
+		return nil, nil, ErrOrderNotReceivable
+	}
+
+	receipt := &repository.GoodsReceipt{
+		PurchaseOrderID: po.ID,
+		Note:            strings.TrimSpace(note),
+		ReceivedBy:      principal.UserID,
+	}
+	status, err := s.receiptRepo.Create(ctx, receipt, check)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, nil, ErrOrderNotFound
+		case errors.Is(err, repository.ErrConflict):
+			return nil, nil, ErrOrderNotReceivable
+		case errors.Is(err, ErrInvalidReceipt), errors.Is(err, ErrReceiptLineNotFound),
+			errors.Is(err, ErrReceiptExceedsOrder), errors.Is(err, ErrOrderNotReceivable):
+			return nil, nil, err
+		}
+		return nil, nil, fmt.Errorf("create goods receipt: %w", err)
+	}
+
+	po.Status = status
+	if status == repository.PurchaseOrderReceived {
+		po.DeliveredAt = receipt.ReceivedAt
+	}
+
+	for _, line := range receipt.Lines {
+		if line.Received.IsPositive() {
+			receiptLines.Inc("accepted")
+		}
+		if line.RejectReason != "" {
+			receiptLines.Inc(line.RejectReason)
+		}
+	}
+	stockMovements.Add(float64(len(receipt.Movements)), repository.StockReceipt)
+
+	return receipt, po, nil
+}
+
+// Receipts returns a purchase order with its receipts, oldest first, and
+// the quantities received and rejected so far per line.
+func (s *ReceivingService) Receipts(ctx context.Context, principal *Principal, purchaseOrderID int64) (po *repository.PurchaseOrder, receipts []repository.GoodsReceipt, lines []repository.ReceivableLine, err error) {
+	ctx, span := tracing.Start(ctx, "ReceivingService.Receipts")
+	defer func() { tracing.End(span, err) }()
+
+	po, err = s.purchaseOrder(ctx, principal, purchaseOrderID, ScopeOrdersRead)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	receipts, err = s.receiptRepo.ListByPurchaseOrder(ctx, po.ID)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("list goods receipts: %w", err)
+	}
+	lines, err = s.receiptRepo.ReceivableLines(ctx, po.ID)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("list receivable lines: %w", err)
+	}
+	return po, receipts, lines, nil
+}
+
+// Discrepancies compares ordered and received quantities per supplier.
+// Admins and internal certificate callers may report on every restaurant;
+// other principals only on their own, which is the default.
+func (s *ReceivingService) Discrepancies(ctx context.Context, principal *Principal, filter repository.DiscrepancyFilter) (report []repository.SupplierDiscrepancy, err error) {
+	ctx, span := tracing.Start(ctx, "ReceivingService.Discrepancies")
+	defer func() { tracing.End(span, err) }()
+
+	if principal == nil || !principal.HasScope(ScopeOrdersRead) {
+		return nil, ErrForbidden
+	}
+	if !isAdmin(principal) && !principal.IsClientCertificate() {
+		if filter.RestaurantID == 0 {
+			filter.RestaurantID = principal.RestaurantID
+		}
+		if filter.RestaurantID == 0 || filter.RestaurantID != principal.RestaurantID {
+			return nil, ErrForbidden
+		}
+	}
+
+	report, err = s.receiptRepo.Discrepancies(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("report discrepancies: %w", err)
+	}
+	return report, nil
+}
+
+func (s *ReceivingService) purchaseOrder(ctx context.Context, principal *Principal, purchaseOrderID int64, scope string) (*repository.PurchaseOrder, error) {
+	if purchaseOrderID <= 0 {
+		return nil, ErrOrderInvalidID
+	}
+
+	po, err := s.orderRepo.GetPurchaseOrder(ctx, purchaseOrderID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrOrderNotFound
+		}
+		return nil, fmt.Errorf("get purchase order: %w", err)
+	}
+	if !canAccessRestaurant(principal, po.RestaurantID, scope) {
+		return nil, ErrOrderForbidden
+	}
+	return po, nil
+}
+
+// matchReceivableLine finds the order line a receipt line refers to.
+func matchReceivableLine(receivable []repository.ReceivableLine, input ReceiptLineInput) (repository.ReceivableLine, error) {
+	var (
+		match   repository.ReceivableLine
+		matches int
+	)
+	for _, line := range receivable {
+		if input.OrderID != 0 && line.OrderID != input.OrderID {
+			continue
+		}
+		if input.IngredientID != 0 && line.IngredientID != input.IngredientID {
+			continue
+		}
+		match = line
+		matches++
+	}
+	if matches != 1 {
+		return match, fmt.Errorf("%w: order line %d, ingredient %d", ErrReceiptLineNotFound, input.OrderID, input.IngredientID)
+	}
+	return match, nil
+}
+
+func validRejectReason(reason string) bool {
+	switch reason {
+	case repository.RejectDamaged, repository.RejectWrongItem, repository.RejectShortDated, repository.RejectNotDelivered:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,220 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"mmispoc/internal/repository"
+	"mmispoc/internal/tracing"
+)
+
+var (
+	// ErrInvalidParLevel indicates a par that is not positive or a reorder
+	// point that is negative or above the par.
+	ErrInvalidParLevel = errors.New("invalid par level")
+	// ErrParLevelNotFound indicates the restaurant has no par level for the ingredient.
+	ErrParLevelNotFound = errors.New("par level not found")
+)
+
+// OrderSuggestion is a quantity to order so that an ingredient gets back to
+// its par level.
+type OrderSuggestion struct {
+	repository.StockPosition
+	// Terms are those of the supplier the line is routed to; SupplierID is
+	// zero when no supplier delivers the ingredient.
+	Terms repository.SupplierIngredient
+	// Shortfall is the par minus the stock on hand and on order.
+	Shortfall decimal.Decimal
+	// Number is the shortfall rounded up to whole units, the supplier's
+	// minimum order quantity and whole packs.
+	Number int
+}
+
+// Packs returns the number of supplier packs the suggestion orders.
+func (s OrderSuggestion) Packs() int {
+	if s.Terms.PackSize <= 1 {
+		return s.Number
+	}
+	return s.Number / s.Terms.PackSize
+}
+
+// ReplenishmentService manages par levels and suggests what to order.
+// Members of a restaurant read them; managers, admins and internal
+// certificate callers set them.
+type ReplenishmentService struct {
+	parRepo        *repository.ParLevelRepository
+	restaurantRepo *repository.RestaurantRepository
+	ingredientRepo *repository.IngredientRepository
+	supplierRepo   *repository.SupplierRepository
+	priceRepo      *repository.PriceRepository
+}
+
+// NewReplenishment constructs a replenishment service.
+func NewReplenishment(parRepo *repository.ParLevelRepository, restaurantRepo *repository.RestaurantRepository, ingredientRepo *repository.IngredientRepository, supplierRepo *repository.SupplierRepository, priceRepo *repository.PriceRepository) *ReplenishmentService {
+	return &ReplenishmentService{
+		parRepo:        parRepo,
+		restaurantRepo: restaurantRepo,
+		ingredientRepo: ingredientRepo,
+		supplierRepo:   supplierRepo,
+		priceRepo:      priceRepo,
+	}
+}
+
+// ParLevels returns the par levels of a restaurant with the stock on hand
+// and on order of each ingredient.
+func (s *ReplenishmentService) ParLevels(ctx context.Context, principal *Principal, restaurantID int64) (positions []repository.StockPosition, err error) {
+	ctx, span := tracing.Start(ctx, "ReplenishmentService.ParLevels")
+	defer func() { tracing.End(span, err) }()
+
+	if err := authorizeStock(ctx, s.restaurantRepo, principal, restaurantID, ScopeInventoryRead); err != nil {
+		return nil, err
+	}
+
+	positions, err = s.parRepo.Positions(ctx, restaurantID)
+	if err != nil {
+		return nil, fmt.Errorf("list par levels: %w", err)
+	}
+	return positions, nil
+}
+
+// SetParLevel creates or replaces the par level of an ingredient. A zero
+// reorder point only reorders once the ingredient runs out; a reorder point
+// equal to the par tops the ingredient up whenever it falls below the par.
+func (s *ReplenishmentService) SetParLevel(ctx context.Context, principal *Principal, level *repository.ParLevel) (err error) {
+	ctx, span := tracing.Start(ctx, "ReplenishmentService.SetParLevel")
+	defer func() { tracing.End(span, err) }()
+
+	if err := authorizeStock(ctx, s.restaurantRepo, principal, level.RestaurantID, ScopeInventoryWrite); err != nil {
+		return err
+	}
+	if !canManageStock(principal) {
+		return ErrForbidden
+	}
+
+	level.Par = level.Par.Round(quantityPlaces)
+	level.ReorderPoint = level.ReorderPoint.Round(quantityPlaces)
+	if !level.Par.IsPositive() || level.ReorderPoint.IsNegative() || level.ReorderPoint.GreaterThan(level.Par) {
+		return ErrInvalidParLevel
+	}
+
+	exists, err := s.ingredientRepo.Exists(ctx, level.IngredientID)
+	if err != nil {
+		return fmt.Errorf("check ingredient: %w", err)
+	}
+	if !exists {
+		return ErrIngredientNotFound
+	}
+
+	level.UpdatedBy = principal.UserID
+	if err := s.parRepo.Upsert(ctx, level); err != nil {
+		return fmt.Errorf("set par level: %w", err)
+	}
+	return nil
+}
+
+// DeleteParLevel removes the par level of an ingredient, which is then no
+// longer suggested for ordering.
+func (s *ReplenishmentService) DeleteParLevel(ctx context.Context, principal *Principal, restaurantID, ingredientID int64) (err error) {
+	ctx, span := tracing.Start(ctx, "ReplenishmentService.DeleteParLevel")
+	defer func() { tracing.End(span, err) }()
+
+	if err := authorizeStock(ctx, s.restaurantRepo, principal, restaurantID, ScopeInventoryWrite); err != nil {
+		return err
+	}
+	if !canManageStock(principal) {
+		return ErrForbidden
+	}
+
+	deleted, err := s.parRepo.Delete(ctx, restaurantID, ingredientID)
+	if err != nil {
+		return fmt.Errorf("delete par level: %w", err)
+	}
+	if !deleted {
+		return ErrParLevelNotFound
+	}
+	return nil
+}
+
+// Suggestions returns what a restaurant should order: every ingredient whose
+// stock on hand and on order is at or below its reorder point, topped up to
+// its par. Lines are routed to suppliers the way order creation routes lines
+// without a supplier, and their quantities satisfy the supplier's minimum
+// order quantity and pack size, so they can be ordered as they are.
+func (s *ReplenishmentService) Suggestions(ctx context.Context, principal *Principal, restaurantID int64) (suggestions []OrderSuggestion, err error) {
+	ctx, span := tracing.Start(ctx, "ReplenishmentService.Suggestions")
+	defer func() { tracing.End(span, err) }()
+
+	if err := authorizeStock(ctx, s.restaurantRepo, principal, restaurantID, ScopeInventoryRead); err != nil {
+		return nil, err
+	}
+
+	positions, err := s.parRepo.Positions(ctx, restaurantID)
+	if err != nil {
+		return nil, fmt.Errorf("list stock positions: %w", err)
+	}
+
+	var (
+		short         []repository.StockPosition
+		ingredientIDs []int64
+	)
+	for _, position := range positions {
+		available := position.Available()
+		if available.GreaterThan(position.ReorderPoint) || !available.LessThan(position.Par) {
+			continue
+		}
+		short = append(short, position)
+		ingredientIDs = append(ingredientIDs, position.IngredientID)
+	}
+	if len(short) == 0 {
+		return nil, nil
+	}
+
+	offers, err := s.supplierRepo.ListOffers(ctx, ingredientIDs)
+	if err != nil {
+		return nil, fmt.Errorf("list supplier offers: %w", err)
+	}
+	prices, err := s.priceRepo.ListActive(ctx, ingredientIDs, time.Now().UTC())
+	if err != nil {
+		return nil, fmt.Errorf("list prices: %w", err)
+	}
+	newPriceBook(prices, nil).applyToOffers(offers)
+
+	offersByIngredient := make(map[int64][]repository.SupplierIngredient)
+	for _, offer := range offers {
+		offersByIngredient[offer.IngredientID] = append(offersByIngredient[offer.IngredientID], offer)
+	}
+
+	suggestions = make([]OrderSuggestion, 0, len(short))
+	for _, position := range short {
+		terms := repository.SupplierIngredient{IngredientID: position.IngredientID, PackSize: 1}
+		if best := selectOffer(offersByIngredient[position.IngredientID]); best != nil {
+			terms = *best
+		}
+
+		shortfall := position.Par.Sub(position.Available())
+		suggestions = append(suggestions, OrderSuggestion{
+			StockPosition: position,
+			Terms:         terms,
+			Shortfall:     shortfall,
+			Number:        suggestedNumber(shortfall, terms),
+		})
+	}
+	return suggestions, nil
+}
+
+// suggestedNumber rounds a shortfall up to whole units, then to the
+// supplier's minimum order quantity and to whole packs.
+func suggestedNumber(shortfall decimal.Decimal, terms repository.SupplierIngredient) int {
+	number := int(shortfall.Ceil().IntPart())
+	if number < terms.MinOrderQty {
+		number = terms.MinOrderQty
+	}
+	if pack := terms.PackSize; pack > 1 && number%pack != 0 {
+		number += pack - number%pack
+	}
+	return number
+}
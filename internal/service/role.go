@@ -0,0 +1,27 @@
+package service
+
+import "strings"
+
+// Roles assigned to users. Area managers approve orders across restaurants.
+const (
+	RoleStaff       = "staff"
+	RoleManager     = "manager"
+	RoleAreaManager = "area_manager"
+	RoleAdmin       = "admin"
+)
+
+// RolePolicy describes security requirements that depend on a user's role.
+type RolePolicy struct {
+	// MFARequiredRoles lists roles that must sign in with two-factor authentication.
+	MFARequiredRoles []string
+}
+
+// RequiresMFA reports whether users with the given role must use two-factor authentication.
+func (p RolePolicy) RequiresMFA(role string) bool {
+	for _, required := range p.MFARequiredRoles {
+		if strings.EqualFold(strings.TrimSpace(required), role) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,304 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"mmispoc/internal/repository"
+	"mmispoc/internal/tracing"
+)
+
+var (
+	// ErrInvalidStockMovement indicates an unknown kind, a zero or wrongly
+	// signed quantity or a transfer without a valid destination.
+	ErrInvalidStockMovement = errors.New("invalid stock movement")
+	// ErrInsufficientStock indicates a movement would take stock on hand below zero.
+	ErrInsufficientStock = errors.New("insufficient stock")
+	// ErrLotNotFound indicates a lot that does not hold the ingredient at the restaurant.
+	ErrLotNotFound = errors.New("lot not found")
+	// ErrInvalidLotQuery indicates a recall without a lot number or an
+	// expiry window out of range.
+	ErrInvalidLotQuery = errors.New("invalid lot query")
+)
+
+const (
+	// quantityPlaces is the precision of stock quantities.
+	quantityPlaces = 3
+
+	defaultMovementListLimit = 100
+	maxMovementListLimit     = 500
+
+	// DefaultExpiryWindowDays is how far ahead Expiring looks by default.
+	DefaultExpiryWindowDays = 3
+	maxExpiryWindowDays     = 365
+)
+
+// StockMovementInput describes a movement recorded by hand. Quantity is the
+// amount moved and must be positive, except for adjustments where its sign
+// gives the direction.
+type StockMovementInput struct {
+	IngredientID int64
+	Kind         string
+	Quantity     decimal.Decimal
+	// ToRestaurantID is the destination of a transfer.
+	ToRestaurantID int64
+	// LotID picks the lot to draw from or add to. Removals without one draw
+	// first-expiring-first-out.
+	LotID int64
+	// LotNumber and ExpiresOn describe the lot new stock is added to when
+	// LotID is zero.
+	LotNumber string
+	ExpiresOn time.Time
+	Note      string
+}
+
+// StockService exposes the stock ledger and stock on hand of restaurants.
+// Members of a restaurant, admins and internal certificate callers may read
+// and record stock.
+type StockService struct {
+	stockRepo      *repository.StockRepository
+	restaurantRepo *repository.RestaurantRepository
+	ingredientRepo *repository.IngredientRepository
+}
+
+// NewStock constructs a stock service.
+func NewStock(stockRepo *repository.StockRepository, restaurantRepo *repository.RestaurantRepository, ingredientRepo *repository.IngredientRepository) *StockService {
+	return &StockService{
+		stockRepo:      stockRepo,
+		restaurantRepo: restaurantRepo,
+		ingredientRepo: ingredientRepo,
+	}
+}
+
+// Levels returns the stock on hand of a restaurant.
+func (s *StockService) Levels(ctx context.Context, principal *Principal, restaurantID int64, filter repository.StockFilter) (levels []repository.StockLevel, err error) {
+	ctx, span := tracing.Start(ctx, "StockService.Levels")
+	defer func() { tracing.End(span, err) }()
+
+	if err := s.authorize(ctx, principal, restaurantID, ScopeInventoryRead); err != nil {
+		return nil, err
+	}
+
+	levels, err = s.stockRepo.Levels(ctx, restaurantID, filter)
+	if err != nil {
+		return nil, fmt.Errorf("list stock levels: %w", err)
+	}
+	return levels, nil
+}
+
+// Movements returns a page of the stock ledger of a restaurant, newest first.
+func (s *StockService) Movements(ctx context.Context, principal *Principal, restaurantID int64, filter repository.MovementFilter) (movements []repository.StockMovement, err error) {
+	ctx, span := tracing.Start(ctx, "StockService.Movements")
+	defer func() { tracing.End(span, err) }()
+
+	if err := s.authorize(ctx, principal, restaurantID, ScopeInventoryRead); err != nil {
+		return nil, err
+	}
+	if filter.Kind != "" && !validStockKind(filter.Kind) {
+		return nil, ErrInvalidStockMovement
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = defaultMovementListLimit
+	}
+	if filter.Limit > maxMovementListLimit {
+		filter.Limit = maxMovementListLimit
+	}
+
+	movements, err = s.stockRepo.Movements(ctx, restaurantID, filter)
+	if err != nil {
+		return nil, fmt.Errorf("list stock movements: %w", err)
+	}
+	return movements, nil
+}
+
+// Record books a movement into the ledger. A transfer produces two
+// movements: one out of restaurantID and one into the destination.
+func (s *StockService) Record(ctx context.Context, principal *Principal, restaurantID int64, input StockMovementInput) (movements []repository.StockMovement, err error) {
+	ctx, span := tracing.Start(ctx, "StockService.Record")
+	defer func() { tracing.End(span, err) }()
+
+	if err := s.authorize(ctx, principal, restaurantID, ScopeInventoryWrite); err != nil {
+		return nil, err
+	}
+
+	kind := strings.ToLower(strings.TrimSpace(input.Kind))
+	quantity := input.Quantity.Round(quantityPlaces)
+	switch {
+	case !validStockKind(kind), quantity.IsZero():
+		return nil, ErrInvalidStockMovement
+	case kind != repository.StockAdjustment && quantity.IsNegative():
+		return nil, ErrInvalidStockMovement
+	case (kind == repository.StockTransfer) != (input.ToRestaurantID != 0):
+		return nil, ErrInvalidStockMovement
+	case kind == repository.StockTransfer && input.ToRestaurantID == restaurantID:
+		return nil, ErrInvalidStockMovement
+	}
+	if input.IngredientID <= 0 {
+		return nil, ErrIngredientNotFound
+	}
+	lotNumber := strings.TrimSpace(input.LotNumber)
+	adds := kind == repository.StockReceipt || (kind == repository.StockAdjustment && quantity.IsPositive())
+	switch {
+	case input.LotID < 0:
+		return nil, ErrInvalidStockMovement
+	case (lotNumber != "" || !input.ExpiresOn.IsZero()) && (!adds || input.LotID != 0):
+		return nil, ErrInvalidStockMovement
+	}
+
+	exists, err := s.ingredientRepo.Exists(ctx, input.IngredientID)
+	if err != nil {
+		return nil, fmt.Errorf("check ingredient: %w", err)
+	}
+	if !exists {
+		return nil, ErrIngredientNotFound
+	}
+
+	movement := repository.StockMovement{
+		RestaurantID: restaurantID,
+		IngredientID: input.IngredientID,
+		Kind:         kind,
+		Quantity:     quantity,
+		LotID:        input.LotID,
+		LotNumber:    lotNumber,
+		ExpiresOn:    input.ExpiresOn,
+		Note:         strings.TrimSpace(input.Note),
+		CreatedBy:    principal.UserID,
+	}
+	switch kind {
+	case repository.StockConsumption, repository.StockWaste:
+		movement.Quantity = quantity.Neg()
+		movements = []repository.StockMovement{movement}
+	case repository.StockTransfer:
+		exists, err := s.restaurantRepo.Exists(ctx, input.ToRestaurantID)
+		if err != nil {
+			return nil, fmt.Errorf("check restaurant: %w", err)
+		}
+		if !exists {
+			return nil, ErrRestaurantNotFound
+		}
+
+		out := movement
+		out.Quantity = quantity.Neg()
+		out.CounterpartRestaurantID = input.ToRestaurantID
+		in := movement
+		in.RestaurantID = input.ToRestaurantID
+		in.CounterpartRestaurantID = restaurantID
+		in.LotID = 0
+		movements = []repository.StockMovement{out, in}
+	default:
+		movements = []repository.StockMovement{movement}
+	}
+
+	movements, err = s.stockRepo.Post(ctx, movements)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrInsufficientStock):
+			return nil, ErrInsufficientStock
+		case errors.Is(err, repository.ErrLotNotFound):
+			return nil, ErrLotNotFound
+		}
+		return nil, fmt.Errorf("post stock movement: %w", err)
+	}
+
+	stockMovements.Add(float64(len(movements)), kind)
+	return movements, nil
+}
+
+// Expiring returns the lots of a restaurant with stock on hand that expire
+// within the given number of days, including lots already expired, soonest
+// first. ingredientID optionally narrows the list to one ingredient.
+func (s *StockService) Expiring(ctx context.Context, principal *Principal, restaurantID, ingredientID int64, days int) (lots []repository.StockLot, err error) {
+	ctx, span := tracing.Start(ctx, "StockService.Expiring")
+	defer func() { tracing.End(span, err) }()
+
+	if err := s.authorize(ctx, principal, restaurantID, ScopeInventoryRead); err != nil {
+		return nil, err
+	}
+	if days < 0 || days > maxExpiryWindowDays {
+		return nil, ErrInvalidLotQuery
+	}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	lots, err = s.stockRepo.ListLots(ctx, repository.LotFilter{
+		RestaurantID: restaurantID,
+		IngredientID: ingredientID,
+		ExpiresBy:    today.AddDate(0, 0, days),
+		InStock:      true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list expiring lots: %w", err)
+	}
+	return lots, nil
+}
+
+// Recall finds the restaurants holding a supplier lot. Admins and internal
+// certificate callers search every restaurant; other principals only their
+// own. Lots that were used up are included when includeEmpty is set.
+func (s *StockService) Recall(ctx context.Context, principal *Principal, lotNumber string, ingredientID int64, includeEmpty bool) (lots []repository.StockLot, err error) {
+	ctx, span := tracing.Start(ctx, "StockService.Recall")
+	defer func() { tracing.End(span, err) }()
+
+	if principal == nil || !principal.HasScope(ScopeInventoryRead) {
+		return nil, ErrForbidden
+	}
+	lotNumber = strings.TrimSpace(lotNumber)
+	if lotNumber == "" {
+		return nil, ErrInvalidLotQuery
+	}
+
+	filter := repository.LotFilter{
+		IngredientID: ingredientID,
+		LotNumber:    lotNumber,
+		InStock:      !includeEmpty,
+	}
+	if !isAdmin(principal) && !principal.IsClientCertificate() {
+		if principal.RestaurantID == 0 {
+			return nil, ErrForbidden
+		}
+		filter.RestaurantID = principal.RestaurantID
+	}
+
+	lots, err = s.stockRepo.ListLots(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("list lots: %w", err)
+	}
+	return lots, nil
+}
+
+func (s *StockService) authorize(ctx context.Context, principal *Principal, restaurantID int64, scope string) error {
+	return authorizeStock(ctx, s.restaurantRepo, principal, restaurantID, scope)
+}
+
+// authorizeStock checks that the principal may use scope on the stock of
+// an existing restaurant.
+func authorizeStock(ctx context.Context, restaurantRepo *repository.RestaurantRepository, principal *Principal, restaurantID int64, scope string) error {
+	if restaurantID <= 0 {
+		return ErrInvalidRestaurantID
+	}
+	if !canAccessRestaurant(principal, restaurantID, scope) {
+		return ErrForbidden
+	}
+
+	exists, err := restaurantRepo.Exists(ctx, restaurantID)
+	if err != nil {
+		return fmt.Errorf("check restaurant: %w", err)
+	}
+	if !exists {
+		return ErrRestaurantNotFound
+	}
+	return nil
+}
+
+func validStockKind(kind string) bool {
+	switch kind {
+	case repository.StockReceipt, repository.StockConsumption, repository.StockWaste, repository.StockTransfer, repository.StockAdjustment:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,255 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"mmispoc/internal/repository"
+	"mmispoc/internal/tracing"
+)
+
+var (
+	// ErrStocktakeNotFound indicates the stocktake cannot be found.
+	ErrStocktakeNotFound = errors.New("stocktake not found")
+	// ErrStocktakeAlreadyOpen indicates the restaurant already counts its stock.
+	ErrStocktakeAlreadyOpen = errors.New("stocktake already open")
+	// ErrStocktakeClosed indicates the stocktake was already posted or cancelled.
+	ErrStocktakeClosed = errors.New("stocktake is not open")
+	// ErrInvalidStocktakeCount indicates counts without entries, a negative
+	// quantity, an ingredient listed twice or a device name that is too long.
+	ErrInvalidStocktakeCount = errors.New("invalid stocktake count")
+	// ErrInvalidStocktakeStatus indicates a listing filtered by an unknown status.
+	ErrInvalidStocktakeStatus = errors.New("invalid stocktake status")
+)
+
+const (
+	// defaultCountDevice names counts submitted without a device.
+	defaultCountDevice  = "default"
+	maxCountDeviceBytes = 64
+
+	defaultStocktakeListLimit = 20
+	maxStocktakeListLimit     = 100
+)
+
+// StocktakeService runs stocktakes. Members of a restaurant count its
+// stock; managers, admins and internal certificate callers post or cancel
+// the stocktake.
+type StocktakeService struct {
+	stocktakeRepo  *repository.StocktakeRepository
+	restaurantRepo *repository.RestaurantRepository
+	ingredientRepo *repository.IngredientRepository
+}
+
+// NewStocktake constructs a stocktake service.
+func NewStocktake(stocktakeRepo *repository.StocktakeRepository, restaurantRepo *repository.RestaurantRepository, ingredientRepo *repository.IngredientRepository) *StocktakeService {
+	return &StocktakeService{
+		stocktakeRepo:  stocktakeRepo,
+		restaurantRepo: restaurantRepo,
+		ingredientRepo: ingredientRepo,
+	}
+}
+
+// Open starts a stocktake for a restaurant, snapshotting its stock on hand.
+func (s *StocktakeService) Open(ctx context.Context, principal *Principal, restaurantID int64, note string) (st *repository.Stocktake, err error) {
+	ctx, span := tracing.Start(ctx, "StocktakeService.Open")
+	defer func() { tracing.End(span, err) }()
+
+	if err := authorizeStock(ctx, s.restaurantRepo, principal, restaurantID, ScopeInventoryWrite); err != nil {
+		return nil, err
+	}
+
+	st = &repository.Stocktake{
+		RestaurantID: restaurantID,
+		Note:         strings.TrimSpace(note),
+		OpenedBy:     principal.UserID,
+	}
+	if err := s.stocktakeRepo.Open(ctx, st); err != nil {
+		if errors.Is(err, repository.ErrConflict) {
+			return nil, ErrStocktakeAlreadyOpen
+		}
+		return nil, fmt.Errorf("open stocktake: %w", err)
+	}
+
+	return s.stocktakeRepo.Get(ctx, st.ID)
+}
+
+// List returns the stocktakes of a restaurant with their summaries, newest first.
+func (s *StocktakeService) List(ctx context.Context, principal *Principal, restaurantID int64, filter repository.StocktakeFilter) (stocktakes []repository.Stocktake, err error) {
+	ctx, span := tracing.Start(ctx, "StocktakeService.List")
+	defer func() { tracing.End(span, err) }()
+
+	if err := authorizeStock(ctx, s.restaurantRepo, principal, restaurantID, ScopeInventoryRead); err != nil {
+		return nil, err
+	}
+	switch filter.Status {
+	case "", repository.StocktakeOpen, repository.StocktakePosted, repository.StocktakeCancelled:
+	default:
+		return nil, ErrInvalidStocktakeStatus
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = defaultStocktakeListLimit
+	}
+	if filter.Limit > maxStocktakeListLimit {
+		filter.Limit = maxStocktakeListLimit
+	}
+
+	stocktakes, err = s.stocktakeRepo.List(ctx, restaurantID, filter)
+	if err != nil {
+		return nil, fmt.Errorf("list stocktakes: %w", err)
+	}
+	return stocktakes, nil
+}
+
+// Get returns a stocktake with its lines and their variances.
+func (s *StocktakeService) Get(ctx context.Context, principal *Principal, id int64) (st *repository.Stocktake, err error) {
+	ctx, span := tracing.Start(ctx, "StocktakeService.Get")
+	defer func() { tracing.End(span, err) }()
+
+	return s.get(ctx, principal, id, ScopeInventoryRead)
+}
+
+// SubmitCounts records what one device counted. Devices count independently
+// and their counts add up per ingredient; a device submitting an ingredient
+// again replaces its earlier count. The stocktake is returned as updated.
+func (s *StocktakeService) SubmitCounts(ctx context.Context, principal *Principal, id int64, device string, counts []repository.StocktakeCount) (st *repository.Stocktake, err error) {
+	ctx, span := tracing.Start(ctx, "StocktakeService.SubmitCounts")
+	defer func() { tracing.End(span, err) }()
+
+	if _, err := s.get(ctx, principal, id, ScopeInventoryWrite); err != nil {
+		return nil, err
+	}
+
+	device = strings.TrimSpace(device)
+	if device == "" {
+		device = defaultCountDevice
+	}
+	if len(device) > maxCountDeviceBytes || len(counts) == 0 {
+		return nil, ErrInvalidStocktakeCount
+	}
+
+	seen := make(map[int64]bool, len(counts))
+	for i := range counts {
+		count := &counts[i]
+		count.Quantity = count.Quantity.Round(quantityPlaces)
+		if count.IngredientID <= 0 || seen[count.IngredientID] || count.Quantity.IsNegative() {
+			return nil, ErrInvalidStocktakeCount
+		}
+		seen[count.IngredientID] = true
+
+		exists, err := s.ingredientRepo.Exists(ctx, count.IngredientID)
+		if err != nil {
+			return nil, fmt.Errorf("check ingredient: %w", err)
+		}
+		if !exists {
+			return nil, ErrIngredientNotFound
+		}
+	}
+
+	if err := s.stocktakeRepo.SaveCounts(ctx, id, device, principal.UserID, counts); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrStocktakeNotFound
+		case errors.Is(err, repository.ErrConflict):
+			return nil, ErrStocktakeClosed
+		}
+		return nil, fmt.Errorf("save stocktake counts: %w", err)
+	}
+
+	return s.stocktakeRepo.Get(ctx, id)
+}
+
+// Post closes a stocktake and adjusts stock on hand by the variance of every
+// counted ingredient. Ingredients nobody counted are left untouched.
+func (s *StocktakeService) Post(ctx context.Context, principal *Principal, id int64) (st *repository.Stocktake, movements []repository.StockMovement, err error) {
+	ctx, span := tracing.Start(ctx, "StocktakeService.Post")
+	defer func() { tracing.End(span, err) }()
+
+	if _, err := s.get(ctx, principal, id, ScopeInventoryWrite); err != nil {
+		return nil, nil, err
+	}
+	if !canManageStock(principal) {
+		return nil, nil, ErrForbidden
+	}
+
+	st, movements, err = s.stocktakeRepo.Post(ctx, id, principal.UserID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, nil, ErrStocktakeNotFound
+		case errors.Is(err, repository.ErrConflict):
+			return nil, nil, ErrStocktakeClosed
+		case errors.Is(err, repository.ErrInsufficientStock):
+			return nil, nil, ErrInsufficientStock
+		}
+		return nil, nil, fmt.Errorf("post stocktake: %w", err)
+	}
+
+	stockMovements.Add(float64(len(movements)), repository.StockAdjustment)
+	return st, movements, nil
+}
+
+// Cancel closes a stocktake without adjusting stock.
+func (s *StocktakeService) Cancel(ctx context.Context, principal *Principal, id int64) (st *repository.Stocktake, err error) {
+	ctx, span := tracing.Start(ctx, "StocktakeService.Cancel")
+	defer func() { tracing.End(span, err) }()
+
+	if _, err := s.get(ctx, principal, id, ScopeInventoryWrite); err != nil {
+		return nil, err
+	}
+	if !canManageStock(principal) {
+		return nil, ErrForbidden
+	}
+
+	if err := s.stocktakeRepo.Cancel(ctx, id, principal.UserID); err != nil {
+		if errors.Is(err, repository.ErrConflict) {
+			return nil, ErrStocktakeClosed
+		}
+		return nil, fmt.Errorf("cancel stocktake: %w", err)
+	}
+
+	return s.stocktakeRepo.Get(ctx, id)
+}
+
+// Variances aggregates the variances posted by a restaurant's stocktakes
+// per ingredient, largest shrinkage first.
+func (s *StocktakeService) Variances(ctx context.Context, principal *Principal, restaurantID int64, filter repository.VarianceFilter) (variances []repository.IngredientVariance, err error) {
+	ctx, span := tracing.Start(ctx, "StocktakeService.Variances")
+	defer func() { tracing.End(span, err) }()
+
+	if err := authorizeStock(ctx, s.restaurantRepo, principal, restaurantID, ScopeInventoryRead); err != nil {
+		return nil, err
+	}
+
+	variances, err = s.stocktakeRepo.Variances(ctx, restaurantID, filter)
+	if err != nil {
+		return nil, fmt.Errorf("list stocktake variances: %w", err)
+	}
+	return variances, nil
+}
+
+func (s *StocktakeService) get(ctx context.Context, principal *Principal, id int64, scope string) (*repository.Stocktake, error) {
+	if id <= 0 {
+		return nil, ErrStocktakeNotFound
+	}
+
+	st, err := s.stocktakeRepo.Get(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrStocktakeNotFound
+		}
+		return nil, fmt.Errorf("get stocktake: %w", err)
+	}
+	if !canAccessRestaurant(principal, st.RestaurantID, scope) {
+		return nil, ErrForbidden
+	}
+	return st, nil
+}
+
+// canManageStock reports whether the principal may post or cancel a
+// stocktake or set par levels; access to the restaurant is checked separately.
+func canManageStock(p *Principal) bool {
+	return isAdmin(p) || isManager(p) || (p != nil && p.IsClientCertificate())
+}
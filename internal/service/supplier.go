@@ -0,0 +1,305 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"mmispoc/internal/repository"
+	"mmispoc/internal/tracing"
+)
+
+var (
+	// ErrSupplierNotFound indicates the supplier does not exist or was deleted.
+	ErrSupplierNotFound = errors.New("supplier not found")
+	// ErrSupplierCodeTaken indicates another supplier already uses the code.
+	ErrSupplierCodeTaken = errors.New("supplier code already in use")
+	// ErrInvalidSupplier indicates a missing code or name or a malformed email.
+	ErrInvalidSupplier = errors.New("invalid supplier")
+	// ErrInvalidSupplierTerms indicates negative quantities, lead time or price.
+	ErrInvalidSupplierTerms = errors.New("invalid supplier terms")
+	// ErrIngredientNotFound indicates the ingredient does not exist.
+	ErrIngredientNotFound = errors.New("ingredient not found")
+	// ErrSupplierIngredientNotFound indicates the supplier does not deliver the ingredient.
+	ErrSupplierIngredientNotFound = errors.New("supplier does not deliver ingredient")
+)
+
+// SupplierService manages suppliers and the terms under which they deliver
+// ingredients. Anyone who may read orders may read suppliers; only admins
+// change them.
+type SupplierService struct {
+	repo           *repository.SupplierRepository
+	ingredientRepo *repository.IngredientRepository
+	priceRepo      *repository.PriceRepository
+}
+
+// NewSupplier constructs a supplier service.
+func NewSupplier(repo *repository.SupplierRepository, ingredientRepo *repository.IngredientRepository, priceRepo *repository.PriceRepository) *SupplierService {
+	return &SupplierService{repo: repo, ingredientRepo: ingredientRepo, priceRepo: priceRepo}
+}
+
+// List returns every active supplier.
+func (s *SupplierService) List(ctx context.Context, principal *Principal) (suppliers []repository.Supplier, err error) {
+	ctx, span := tracing.Start(ctx, "SupplierService.List")
+	defer func() { tracing.End(span, err) }()
+
+	if !principal.HasScope(ScopeOrdersRead) {
+		return nil, ErrForbidden
+	}
+
+	suppliers, err = s.repo.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list suppliers: %w", err)
+	}
+	return suppliers, nil
+}
+
+// Get returns an active supplier.
+func (s *SupplierService) Get(ctx context.Context, principal *Principal, id int64) (supplier *repository.Supplier, err error) {
+	ctx, span := tracing.Start(ctx, "SupplierService.Get")
+	defer func() { tracing.End(span, err) }()
+
+	if !principal.HasScope(ScopeOrdersRead) {
+		return nil, ErrForbidden
+	}
+
+	return s.get(ctx, id)
+}
+
+// Create registers a new supplier.
+func (s *SupplierService) Create(ctx context.Context, principal *Principal, supplier *repository.Supplier) (err error) {
+	ctx, span := tracing.Start(ctx, "SupplierService.Create")
+	defer func() { tracing.End(span, err) }()
+
+	if !isAdmin(principal) {
+		return ErrForbidden
+	}
+	if err := normalizeSupplier(supplier); err != nil {
+		return err
+	}
+
+	if err := s.repo.Create(ctx, supplier); err != nil {
+		if errors.Is(err, repository.ErrConflict) {
+			return ErrSupplierCodeTaken
+		}
+		return fmt.Errorf("create supplier: %w", err)
+	}
+	return nil
+}
+
+// Update replaces the contact details of a supplier.
+func (s *SupplierService) Update(ctx context.Context, principal *Principal, supplier *repository.Supplier) (err error) {
+	ctx, span := tracing.Start(ctx, "SupplierService.Update")
+	defer func() { tracing.End(span, err) }()
+
+	if !isAdmin(principal) {
+		return ErrForbidden
+	}
+	if err := normalizeSupplier(supplier); err != nil {
+		return err
+	}
+
+	if err := s.repo.Update(ctx, supplier); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrSupplierNotFound
+		case errors.Is(err, repository.ErrConflict):
+			return ErrSupplierCodeTaken
+		}
+		return fmt.Errorf("update supplier: %w", err)
+	}
+	return nil
+}
+
+// Delete retires a supplier. Existing orders keep referencing it but new
+// order lines are no longer routed to it.
+func (s *SupplierService) Delete(ctx context.Context, principal *Principal, id int64) (err error) {
+	ctx, span := tracing.Start(ctx, "SupplierService.Delete")
+	defer func() { tracing.End(span, err) }()
+
+	if !isAdmin(principal) {
+		return ErrForbidden
+	}
+
+	deleted, err := s.repo.Delete(ctx, id)
+	if err != nil {
+		return fmt.Errorf("delete supplier: %w", err)
+	}
+	if !deleted {
+		return ErrSupplierNotFound
+	}
+	return nil
+}
+
+// ListIngredients returns the terms of every ingredient a supplier delivers.
+func (s *SupplierService) ListIngredients(ctx context.Context, principal *Principal, supplierID int64) (terms []repository.SupplierIngredient, err error) {
+	ctx, span := tracing.Start(ctx, "SupplierService.ListIngredients")
+	defer func() { tracing.End(span, err) }()
+
+	if !principal.HasScope(ScopeOrdersRead) {
+		return nil, ErrForbidden
+	}
+	if _, err := s.get(ctx, supplierID); err != nil {
+		return nil, err
+	}
+
+	terms, err = s.repo.ListIngredients(ctx, supplierID)
+	if err != nil {
+		return nil, fmt.Errorf("list supplier ingredients: %w", err)
+	}
+	return terms, nil
+}
+
+// SetIngredient creates or replaces the terms under which a supplier
+// delivers an ingredient. A zero pack size means single units. A changed
+// price is appended to the supplier specific price history from now on.
+func (s *SupplierService) SetIngredient(ctx context.Context, principal *Principal, terms *repository.SupplierIngredient) (err error) {
+	ctx, span := tracing.Start(ctx, "SupplierService.SetIngredient")
+	defer func() { tracing.End(span, err) }()
+
+	if !isAdmin(principal) {
+		return ErrForbidden
+	}
+
+	terms.SKU = strings.TrimSpace(terms.SKU)
+	if terms.PackSize == 0 {
+		terms.PackSize = 1
+	}
+	if terms.PackSize < 0 || terms.LeadTimeDays < 0 || terms.MinOrderQty < 0 || terms.Price.IsNegative() {
+		return ErrInvalidSupplierTerms
+	}
+	terms.Price = terms.Price.Round(4)
+
+	supplier, err := s.get(ctx, terms.SupplierID)
+	if err != nil {
+		return err
+	}
+	exists, err := s.ingredientRepo.Exists(ctx, terms.IngredientID)
+	if err != nil {
+		return fmt.Errorf("check ingredient: %w", err)
+	}
+	if !exists {
+		return ErrIngredientNotFound
+	}
+
+	if err := s.recordPriceChange(ctx, principal, terms); err != nil {
+		return err
+	}
+
+	if err := s.repo.UpsertIngredient(ctx, terms); err != nil {
+		return fmt.Errorf("set supplier ingredient: %w", err)
+	}
+	terms.SupplierName = supplier.Name
+	return nil
+}
+
+// RemoveIngredient stops routing an ingredient to a supplier.
+func (s *SupplierService) RemoveIngredient(ctx context.Context, principal *Principal, supplierID, ingredientID int64) (err error) {
+	ctx, span := tracing.Start(ctx, "SupplierService.RemoveIngredient")
+	defer func() { tracing.End(span, err) }()
+
+	if !isAdmin(principal) {
+		return ErrForbidden
+	}
+
+	removed, err := s.repo.DeleteIngredient(ctx, supplierID, ingredientID)
+	if err != nil {
+		return fmt.Errorf("remove supplier ingredient: %w", err)
+	}
+	if !removed {
+		return ErrSupplierIngredientNotFound
+	}
+	return nil
+}
+
+// recordPriceChange appends the terms price to the history unless it equals
+// the supplier specific price currently in effect. A zero price with no
+// history means the price is unknown and is not recorded.
+func (s *SupplierService) recordPriceChange(ctx context.Context, principal *Principal, terms *repository.SupplierIngredient) error {
+	now := time.Now().UTC()
+	active, err := s.priceRepo.ListActive(ctx, []int64{terms.IngredientID}, now)
+	if err != nil {
+		return fmt.Errorf("list prices: %w", err)
+	}
+
+	known := false
+	for _, price := range active {
+		if price.SupplierID != terms.SupplierID {
+			continue
+		}
+		if price.Price.Equal(terms.Price) {
+			return nil
+		}
+		known = true
+	}
+	if !known && terms.Price.IsZero() {
+		return nil
+	}
+
+	return recordPrice(ctx, s.priceRepo, &repository.IngredientPrice{
+		IngredientID:  terms.IngredientID,
+		SupplierID:    terms.SupplierID,
+		Price:         terms.Price,
+		EffectiveFrom: now,
+		CreatedBy:     principal.UserID,
+	})
+}
+
+func (s *SupplierService) get(ctx context.Context, id int64) (*repository.Supplier, error) {
+	if id <= 0 {
+		return nil, ErrSupplierNotFound
+	}
+
+	supplier, err := s.repo.Get(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrSupplierNotFound
+		}
+		return nil, fmt.Errorf("get supplier: %w", err)
+	}
+	return supplier, nil
+}
+
+func normalizeSupplier(supplier *repository.Supplier) error {
+	supplier.Code = strings.ToUpper(strings.TrimSpace(supplier.Code))
+	supplier.Name = strings.TrimSpace(supplier.Name)
+	supplier.Email = strings.TrimSpace(supplier.Email)
+	supplier.Phone = strings.TrimSpace(supplier.Phone)
+
+	if supplier.Code == "" || supplier.Name == "" {
+		return ErrInvalidSupplier
+	}
+	if supplier.Email != "" && !strings.Contains(supplier.Email, "@") {
+		return ErrInvalidSupplier
+	}
+	return nil
+}
+
+// selectOffer picks the supplier for an order line: the preferred one, then
+// the cheapest, then the shortest lead time.
+func selectOffer(offers []repository.SupplierIngredient) *repository.SupplierIngredient {
+	var best *repository.SupplierIngredient
+	for i := range offers {
+		offer := &offers[i]
+		if best == nil || betterOffer(offer, best) {
+			best = offer
+		}
+	}
+	return best
+}
+
+func betterOffer(a, b *repository.SupplierIngredient) bool {
+	if a.Preferred != b.Preferred {
+		return a.Preferred
+	}
+	if cmp := a.Price.Cmp(b.Price); cmp != 0 {
+		return cmp < 0
+	}
+	if a.LeadTimeDays != b.LeadTimeDays {
+		return a.LeadTimeDays < b.LeadTimeDays
+	}
+	return a.SupplierID < b.SupplierID
+}
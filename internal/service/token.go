@@ -0,0 +1,160 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"mmispoc/internal/repository"
+)
+
+// Token purposes restrict what a signed token may be used for. Access tokens
+// carry no purpose; every other purpose is rejected by ValidateAccessToken.
+const (
+	tokenPurposeMFAChallenge = "mfa_challenge"
+	tokenPurposeMFAEnroll    = "mfa_enroll"
+)
+
+const (
+	mfaChallengeTTL = 5 * time.Minute
+	mfaEnrollTTL    = 15 * time.Minute
+)
+
+type tokenClaims struct {
+	UserID       int64  `json:"user_id"`
+	Username     string `json:"username,omitempty"`
+	RestaurantID int64  `json:"restaurant_id"`
+	Role         string `json:"role,omitempty"`
+	Purpose      string `json:"purpose,omitempty"`
+	Version      int    `json:"ver"`
+	Sub          string `json:"sub"`
+	Issued       int64  `json:"iat"`
+	Exp          int64  `json:"exp"`
+	// ID identifies the token; login challenges count attempts against it.
+	ID string `json:"jti,omitempty"`
+}
+
+func (s *UserService) generateToken(user *repository.User, purpose string, ttl time.Duration) (string, error) {
+	if len(s.tokenSecret) == 0 {
+		return "", errors.New("token secret not configured")
+	}
+
+	now := time.Now().UTC()
+	exp := now.Add(ttl)
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("read random: %w", err)
+	}
+
+	header := map[string]string{
+		"alg": "HS256",
+		"typ": "JWT",
+	}
+	claims := tokenClaims{
+		UserID:       user.ID,
+		Username:     user.Username,
+		RestaurantID: user.RestaurantID,
+		Role:         user.Role,
+		Purpose:      purpose,
+		Version:      user.TokenVersion,
+		Sub:          strconv.FormatInt(user.ID, 10),
+		Issued:       now.Unix(),
+		Exp:          exp.Unix(),
+		ID:           base64.RawURLEncoding.EncodeToString(id),
+	}
+
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return "", fmt.Errorf("marshal jwt header: %w", err)
+	}
+
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", fmt.Errorf("marshal jwt claims: %w", err)
+	}
+
+	encode := func(data []byte) string {
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+
+	unsigned := encode(headerJSON) + "." + encode(claimsJSON)
+
+	mac := hmac.New(sha256.New, s.tokenSecret)
+	if _, err := mac.Write([]byte(unsigned)); err != nil {
+		return "", fmt.Errorf("sign jwt: %w", err)
+	}
+	signature := encode(mac.Sum(nil))
+
+	return unsigned + "." + signature, nil
+}
+
+// parseToken verifies the signature and expiry of a token and returns its claims.
+func (s *UserService) parseToken(token string) (*tokenClaims, error) {
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return nil, ErrInvalidToken
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	unsigned := parts[0] + "." + parts[1]
+	expectedMAC := hmac.New(sha256.New, s.tokenSecret)
+	if _, err := expectedMAC.Write([]byte(unsigned)); err != nil {
+		return nil, ErrInvalidToken
+	}
+	if !hmac.Equal(expectedMAC.Sum(nil), sig) {
+		return nil, ErrInvalidToken
+	}
+
+	payloadBytes, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	var claims tokenClaims
+	if err := json.Unmarshal(payloadBytes, &claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	if claims.UserID == 0 && claims.Sub != "" {
+		if parsed, parseErr := strconv.ParseInt(claims.Sub, 10, 64); parseErr == nil {
+			claims.UserID = parsed
+		}
+	}
+	if claims.UserID == 0 {
+		return nil, ErrInvalidToken
+	}
+
+	if claims.Exp != 0 && time.Now().UTC().Unix() > claims.Exp {
+		return nil, ErrTokenExpired
+	}
+
+	return &claims, nil
+}
+
+// TokenSubject returns the user id of an unexpired access token with a valid
+// signature. It does not consult the database, so revoked tokens still
+// resolve; it exists to key per-user rate limits before authentication.
+func (s *UserService) TokenSubject(token string) (int64, bool) {
+	claims, err := s.parseToken(token)
+	if err != nil || claims.Purpose != "" {
+		return 0, false
+	}
+	return claims.UserID, true
+}
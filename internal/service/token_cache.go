@@ -0,0 +1,165 @@
+package service
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultTokenCacheTTL  = 30 * time.Second
+	defaultTokenCacheSize = 10000
+	// tokenStateRetryDelay spaces reconnect attempts of WatchTokenState.
+	tokenStateRetryDelay = 5 * time.Second
+)
+
+// TokenCacheStats reports how often token validation avoided a database read.
+type TokenCacheStats struct {
+	Hits      int64 `json:"hits"`
+	Misses    int64 `json:"misses"`
+	Evictions int64 `json:"evictions"`
+	Size      int   `json:"size"`
+}
+
+// tokenState is the per-user revocation state checked on every request.
+type tokenState struct {
+	version  int
+	disabled bool
+}
+
+type tokenVersionEntry struct {
+	state   tokenState
+	expires time.Time
+}
+
+// tokenVersionCache remembers the current token state per user. Changes
+// made through this process are written through immediately; changes made
+// by other replicas arrive through UserService.WatchTokenState. Should the
+// listener be down, the TTL bounds the revocation delay.
+type tokenVersionCache struct {
+	ttl     time.Duration
+	maxSize int
+
+	mu      sync.Mutex
+	entries map[int64]tokenVersionEntry
+	// generation counts writes and invalidations, so state read from the
+	// database before one of them is not cached over it.
+	generation uint64
+
+	hits      atomic.Int64
+	misses    atomic.Int64
+	evictions atomic.Int64
+}
+
+func newTokenVersionCache(ttl time.Duration, maxSize int) *tokenVersionCache {
+	if ttl <= 0 {
+		ttl = defaultTokenCacheTTL
+	}
+	if maxSize <= 0 {
+		maxSize = defaultTokenCacheSize
+	}
+	return &tokenVersionCache{
+		ttl:     ttl,
+		maxSize: maxSize,
+		entries: make(map[int64]tokenVersionEntry),
+	}
+}
+
+func (c *tokenVersionCache) get(userID int64, now time.Time) (tokenState, bool) {
+	c.mu.Lock()
+	entry, ok := c.entries[userID]
+	if ok && now.After(entry.expires) {
+		delete(c.entries, userID)
+		ok = false
+	}
+	c.mu.Unlock()
+
+	if !ok {
+		c.misses.Add(1)
+		return tokenState{}, false
+	}
+	c.hits.Add(1)
+	return entry.state, true
+}
+
+// set records state written through this process.
+func (c *tokenVersionCache) set(userID int64, state tokenState, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	c.storeLocked(userID, state, now)
+}
+
+// begin returns the generation to pass to fill before reading the database.
+func (c *tokenVersionCache) begin() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.generation
+}
+
+// fill caches state read from the database unless the cache was written or
+// invalidated since begin returned generation; the read may predate that
+// change.
+func (c *tokenVersionCache) fill(userID int64, state tokenState, generation uint64, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.generation != generation {
+		return
+	}
+	c.storeLocked(userID, state, now)
+}
+
+func (c *tokenVersionCache) storeLocked(userID int64, state tokenState, now time.Time) {
+	if _, exists := c.entries[userID]; !exists && len(c.entries) >= c.maxSize {
+		c.evictLocked(now)
+	}
+	c.entries[userID] = tokenVersionEntry{state: state, expires: now.Add(c.ttl)}
+}
+
+func (c *tokenVersionCache) invalidate(userID int64) {
+	c.mu.Lock()
+	c.generation++
+	delete(c.entries, userID)
+	c.mu.Unlock()
+}
+
+// clear drops every entry, e.g. after changes may have been missed.
+func (c *tokenVersionCache) clear() {
+	c.mu.Lock()
+	c.generation++
+	clear(c.entries)
+	c.mu.Unlock()
+}
+
+// evictLocked drops expired entries and, if the cache is still full, an arbitrary one.
+func (c *tokenVersionCache) evictLocked(now time.Time) {
+	for id, entry := range c.entries {
+		if now.After(entry.expires) {
+			delete(c.entries, id)
+			c.evictions.Add(1)
+		}
+	}
+	if len(c.entries) < c.maxSize {
+		return
+	}
+	for id := range c.entries {
+		delete(c.entries, id)
+		c.evictions.Add(1)
+		break
+	}
+}
+
+func (c *tokenVersionCache) stats() TokenCacheStats {
+	c.mu.Lock()
+	size := len(c.entries)
+	c.mu.Unlock()
+
+	return TokenCacheStats{
+		Hits:      c.hits.Load(),
+		Misses:    c.misses.Load(),
+		Evictions: c.evictions.Load(),
+		Size:      size,
+	}
+}
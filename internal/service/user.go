@@ -2,20 +2,17 @@ package service
 
 import (
 	"context"
-	"crypto/hmac"
 	"crypto/sha256"
-	"database/sql"
-	"encoding/base64"
 	"encoding/hex"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"regexp"
-	"strconv"
 	"strings"
 	"time"
 
+	"mmispoc/internal/logging"
 	"mmispoc/internal/repository"
+	"mmispoc/internal/tracing"
 )
 
 var (
@@ -46,6 +43,12 @@ var ErrInvalidToken = errors.New("invalid token")
 // ErrTokenExpired indicates the supplied token is expired.
 var ErrTokenExpired = errors.New("token expired")
 
+// ErrAccountDisabled indicates the user account has been deactivated.
+var ErrAccountDisabled = errors.New("account disabled")
+
+// ErrTokenRevoked indicates the token was issued before the user's tokens were revoked.
+var ErrTokenRevoked = errors.New("token revoked")
+
 const defaultTokenTTL = 15 * time.Minute
 
 // DefaultTokenTTL returns the default access token lifetime.
@@ -57,8 +60,43 @@ func DefaultTokenTTL() time.Duration {
 type UserService struct {
 	repo           *repository.UserRepository
 	restaurantRepo *repository.RestaurantRepository
+	mfaRepo        *repository.MFARepository
+	auditRepo      *repository.AuditRepository
+	invitationRepo *repository.InvitationRepository
 	tokenSecret    []byte
 	tokenTTL       time.Duration
+	policy         RolePolicy
+	versions       *tokenVersionCache
+	// openRegistration lets SignUp attach accounts to any restaurant without
+	// an invitation. Meant for local development only.
+	openRegistration bool
+}
+
+// UserConfig tunes token handling of the user service.
+type UserConfig struct {
+	TokenSecret string
+	TokenTTL    time.Duration
+	// TokenCacheTTL bounds how long a token version is trusted without
+	// re-reading it, i.e. the revocation delay seen by other replicas while
+	// WatchTokenState is not connected.
+	TokenCacheTTL time.Duration
+	Policy        RolePolicy
+	// OpenRegistration allows signup without an invitation.
+	OpenRegistration bool
+}
+
+// LoginResult describes the outcome of a password login. Exactly one of the
+// tokens is set.
+type LoginResult struct {
+	// AccessToken is issued when no second factor is needed.
+	AccessToken string
+	// ChallengeToken is issued when the account has two-factor authentication
+	// enabled and must be exchanged together with a code via CompleteMFALogin.
+	ChallengeToken string
+	// EnrollmentToken is issued when the role policy requires two-factor
+	// authentication the user has not enrolled yet. It is only accepted by the
+	// enrolment endpoints.
+	EnrollmentToken string
 }
 
 // UserProfile describes the authenticated user response.
@@ -71,23 +109,35 @@ type UserProfile struct {
 }
 
 // NewUser constructs the service.
-func NewUser(repo *repository.UserRepository, restaurantRepo *repository.RestaurantRepository, tokenSecret string, tokenTTL time.Duration) *UserService {
-	if tokenTTL <= 0 {
-		tokenTTL = defaultTokenTTL
+func NewUser(repo *repository.UserRepository, restaurantRepo *repository.RestaurantRepository, mfaRepo *repository.MFARepository, auditRepo *repository.AuditRepository, invitationRepo *repository.InvitationRepository, cfg UserConfig) *UserService {
+	if cfg.TokenTTL <= 0 {
+		cfg.TokenTTL = defaultTokenTTL
 	}
-	if tokenSecret == "" {
-		tokenSecret = "change-me"
+	if cfg.TokenSecret == "" {
+		cfg.TokenSecret = "change-me"
 	}
 	return &UserService{
 		repo:           repo,
 		restaurantRepo: restaurantRepo,
-		tokenSecret:    []byte(tokenSecret),
-		tokenTTL:       tokenTTL,
+		mfaRepo:        mfaRepo,
+		auditRepo:      auditRepo,
+		invitationRepo: invitationRepo,
+		tokenSecret:    []byte(cfg.TokenSecret),
+		tokenTTL:       cfg.TokenTTL,
+		policy:         cfg.Policy,
+		versions:       newTokenVersionCache(cfg.TokenCacheTTL, defaultTokenCacheSize),
+
+		openRegistration: cfg.OpenRegistration,
 	}
 }
 
-// SignUp validates input and persists a new user.
-func (s *UserService) SignUp(ctx context.Context, username, password string, restaurantID int64) (*repository.User, error) {
+// SignUp validates input and persists a new user. The account's restaurant
+// and role come from the invitation; a free-form restaurant id is only
+// accepted when open registration is enabled.
+func (s *UserService) SignUp(ctx context.Context, username, password, invitationCode string, restaurantID int64) (user *repository.User, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.SignUp")
+	defer func() { tracing.End(span, err) }()
+
 	username = strings.TrimSpace(username)
 	if !isValidUsername(username) {
 		return nil, ErrInvalidUsername
@@ -97,6 +147,19 @@ func (s *UserService) SignUp(ctx context.Context, username, password string, res
 		return nil, ErrInvalidPassword
 	}
 
+	if strings.TrimSpace(invitationCode) != "" {
+		user, err := s.redeemInvitation(ctx, invitationCode, username, hashPassword(password))
+		if err != nil {
+			return nil, err
+		}
+		signups.Inc("invitation")
+		return user, nil
+	}
+
+	if !s.openRegistration {
+		return nil, ErrInvitationRequired
+	}
+
 	if restaurantID <= 0 {
 		return nil, ErrInvalidRestaurantID
 	}
@@ -118,44 +181,75 @@ func (s *UserService) SignUp(ctx context.Context, username, password string, res
 	}
 
 	hashed := hashPassword(password)
-	user, err := s.repo.Create(ctx, username, hashed, restaurantID)
+	user, err = s.repo.Create(ctx, username, hashed, restaurantID, RoleStaff)
 	if err != nil {
 		if errors.Is(err, repository.ErrConflict) {
 			return nil, ErrUsernameTaken
 		}
 		return nil, fmt.Errorf("create user: %w", err)
 	}
+	signups.Inc("open")
 
 	return user, nil
 }
 
-// Authenticate validates credentials and issues a JWT access token.
-func (s *UserService) Authenticate(ctx context.Context, username, password string) (string, error) {
+// Authenticate validates credentials and either issues a JWT access token or,
+// when a second factor is involved, a short-lived token for the next step.
+func (s *UserService) Authenticate(ctx context.Context, username, password string) (result *LoginResult, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.Authenticate")
+	defer func() { tracing.End(span, err) }()
+
+	defer func() { recordLoginFailure(ctx, err) }()
+
 	username = strings.TrimSpace(username)
 	password = strings.TrimSpace(password)
 
 	if !isValidUsername(username) || !isValidPassword(password) {
-		return "", ErrInvalidCredentials
+		return nil, ErrInvalidCredentials
 	}
 
 	user, err := s.repo.GetByUsername(ctx, username)
 	if err != nil {
 		if errors.Is(err, repository.ErrNotFound) {
-			return "", ErrInvalidCredentials
+			return nil, ErrInvalidCredentials
 		}
-		return "", fmt.Errorf("fetch user: %w", err)
+		return nil, fmt.Errorf("fetch user: %w", err)
 	}
 
 	if user.PasswordHash != hashPassword(password) {
-		return "", ErrInvalidCredentials
+		return nil, ErrInvalidCredentials
 	}
 
-	token, err := s.generateToken(user)
+	if user.Disabled() {
+		return nil, ErrAccountDisabled
+	}
+
+	enabled, err := s.mfaEnabled(ctx, user.ID)
 	if err != nil {
-		return "", fmt.Errorf("generate token: %w", err)
+		return nil, err
 	}
 
-	return token, nil
+	switch {
+	case enabled:
+		token, err := s.generateToken(user, tokenPurposeMFAChallenge, mfaChallengeTTL)
+		if err != nil {
+			return nil, fmt.Errorf("generate challenge token: %w", err)
+		}
+		return &LoginResult{ChallengeToken: token}, nil
+	case s.policy.RequiresMFA(user.Role):
+		token, err := s.generateToken(user, tokenPurposeMFAEnroll, mfaEnrollTTL)
+		if err != nil {
+			return nil, fmt.Errorf("generate enrollment token: %w", err)
+		}
+		return &LoginResult{EnrollmentToken: token}, nil
+	}
+
+	token, err := s.generateToken(user, "", s.tokenTTL)
+	if err != nil {
+		return nil, fmt.Errorf("generate token: %w", err)
+	}
+
+	return &LoginResult{AccessToken: token}, nil
 }
 
 func isValidUsername(username string) bool {
@@ -175,8 +269,11 @@ func hashPassword(password string) string {
 }
 
 // GetProfile returns the profile for the supplied user id.
-func (s *UserService) GetProfile(ctx context.Context, userID int64) (*UserProfile, error) {
-	user, err := s.repo.GetByID(ctx, userID)
+func (s *UserService) GetProfile(ctx context.Context, userID int64) (profile *UserProfile, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.GetProfile")
+	defer func() { tracing.End(span, err) }()
+
+	user, restaurantName, err := s.repo.GetWithRestaurantName(ctx, userID)
 	if err != nil {
 		if errors.Is(err, repository.ErrNotFound) {
 			return nil, ErrInvalidToken
@@ -184,133 +281,124 @@ func (s *UserService) GetProfile(ctx context.Context, userID int64) (*UserProfil
 		return nil, fmt.Errorf("fetch user: %w", err)
 	}
 
-	profile := &UserProfile{
-		ID:           user.ID,
-		Username:     user.Username,
-		RestaurantID: user.RestaurantID,
-		CreatedAt:    user.CreatedAt,
-	}
-
-	if user.RestaurantID > 0 {
-		name, err := s.restaurantRepo.GetName(ctx, user.RestaurantID)
-		if err != nil {
-			if errors.Is(err, sql.ErrNoRows) {
-				return nil, ErrRestaurantNotFound
-			}
-			return nil, fmt.Errorf("fetch restaurant name: %w", err)
-		}
-		profile.RestaurantName = name
-	}
-
-	return profile, nil
+	return &UserProfile{
+		ID:             user.ID,
+		Username:       user.Username,
+		RestaurantID:   user.RestaurantID,
+		RestaurantName: restaurantName,
+		CreatedAt:      user.CreatedAt,
+	}, nil
 }
 
-// ValidateAccessToken verifies the supplied JWT access token and returns the authenticated user.
-func (s *UserService) ValidateAccessToken(ctx context.Context, token string) (*repository.User, error) {
-	token = strings.TrimSpace(token)
-	if token == "" {
-		return nil, ErrInvalidToken
-	}
+// ChangePassword replaces the user's password after verifying the current
+// one and revokes every previously issued token.
+func (s *UserService) ChangePassword(ctx context.Context, userID int64, currentPassword, newPassword string) (err error) {
+	ctx, span := tracing.Start(ctx, "UserService.ChangePassword")
+	defer func() { tracing.End(span, err) }()
 
-	parts := strings.Split(token, ".")
-	if len(parts) != 3 {
-		return nil, ErrInvalidToken
+	if !isValidPassword(newPassword) {
+		return ErrInvalidPassword
 	}
 
-	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	user, err := s.repo.GetByID(ctx, userID)
 	if err != nil {
-		return nil, ErrInvalidToken
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrInvalidToken
+		}
+		return fmt.Errorf("fetch user: %w", err)
 	}
 
-	unsigned := parts[0] + "." + parts[1]
-	expectedMAC := hmac.New(sha256.New, s.tokenSecret)
-	if _, err := expectedMAC.Write([]byte(unsigned)); err != nil {
-		return nil, ErrInvalidToken
-	}
-	if !hmac.Equal(expectedMAC.Sum(nil), sig) {
-		return nil, ErrInvalidToken
+	if user.PasswordHash != hashPassword(strings.TrimSpace(currentPassword)) {
+		return ErrInvalidCredentials
 	}
 
-	payloadBytes, err := base64.RawURLEncoding.DecodeString(parts[1])
+	version, err := s.repo.UpdatePassword(ctx, userID, hashPassword(strings.TrimSpace(newPassword)))
 	if err != nil {
-		return nil, ErrInvalidToken
+		return fmt.Errorf("update password: %w", err)
 	}
+	s.versions.set(userID, tokenState{version: version, disabled: user.Disabled()}, time.Now())
 
-	var claims struct {
-		UserID int64  `json:"user_id"`
-		Sub    string `json:"sub"`
-		Issued int64  `json:"iat"`
-		Exp    int64  `json:"exp"`
-	}
-	if err := json.Unmarshal(payloadBytes, &claims); err != nil {
-		return nil, ErrInvalidToken
-	}
+	return nil
+}
 
-	if claims.UserID == 0 && claims.Sub != "" {
-		if parsed, parseErr := strconv.ParseInt(claims.Sub, 10, 64); parseErr == nil {
-			claims.UserID = parsed
-		}
+// TokenCacheStats reports hit and miss counts of the token version cache.
+func (s *UserService) TokenCacheStats() TokenCacheStats {
+	return s.versions.stats()
+}
+
+// ValidateAccessToken verifies the supplied JWT access token and returns the
+// principal described by its signed claims. The only state consulted is the
+// user's token version, served from cache, so revocation takes effect without
+// reading the user row on every request.
+func (s *UserService) ValidateAccessToken(ctx context.Context, token string) (principal *Principal, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.ValidateAccessToken")
+	defer func() { tracing.End(span, err) }()
+
+	claims, err := s.parseToken(token)
+	if err != nil {
+		return nil, err
 	}
-	if claims.UserID == 0 {
+	if claims.Purpose != "" {
 		return nil, ErrInvalidToken
 	}
 
-	if claims.Exp != 0 && time.Now().UTC().Unix() > claims.Exp {
-		return nil, ErrTokenExpired
-	}
-
-	user, err := s.repo.GetByID(ctx, claims.UserID)
+	state, err := s.currentTokenState(ctx, claims.UserID)
 	if err != nil {
-		if errors.Is(err, repository.ErrNotFound) {
-			return nil, ErrInvalidToken
-		}
-		return nil, fmt.Errorf("fetch user: %w", err)
+		return nil, err
 	}
-
-	return user, nil
-}
-
-func (s *UserService) generateToken(user *repository.User) (string, error) {
-	if len(s.tokenSecret) == 0 {
-		return "", errors.New("token secret not configured")
+	if state.disabled {
+		return nil, ErrAccountDisabled
+	}
+	if claims.Version != state.version {
+		return nil, ErrTokenRevoked
 	}
 
-	now := time.Now().UTC()
-	exp := now.Add(s.tokenTTL)
+	return &Principal{
+		UserID:       claims.UserID,
+		Username:     claims.Username,
+		RestaurantID: claims.RestaurantID,
+		Role:         claims.Role,
+	}, nil
+}
 
-	header := map[string]string{
-		"alg": "HS256",
-		"typ": "JWT",
-	}
-	claims := map[string]interface{}{
-		"user_id":       user.ID,
-		"restaurant_id": user.RestaurantID,
-		"sub":           strconv.FormatInt(user.ID, 10),
-		"iat":           now.Unix(),
-		"exp":           exp.Unix(),
+func (s *UserService) currentTokenState(ctx context.Context, userID int64) (tokenState, error) {
+	now := time.Now()
+	if state, ok := s.versions.get(userID, now); ok {
+		return state, nil
 	}
 
-	headerJSON, err := json.Marshal(header)
+	generation := s.versions.begin()
+	version, disabled, err := s.repo.GetTokenState(ctx, userID)
 	if err != nil {
-		return "", fmt.Errorf("marshal jwt header: %w", err)
+		if errors.Is(err, repository.ErrNotFound) {
+			return tokenState{}, ErrInvalidToken
+		}
+		return tokenState{}, fmt.Errorf("fetch token state: %w", err)
 	}
 
-	claimsJSON, err := json.Marshal(claims)
-	if err != nil {
-		return "", fmt.Errorf("marshal jwt claims: %w", err)
-	}
+	state := tokenState{version: version, disabled: disabled}
+	s.versions.fill(userID, state, generation, now)
 
-	encode := func(data []byte) string {
-		return base64.RawURLEncoding.EncodeToString(data)
-	}
+	return state, nil
+}
 
-	unsigned := encode(headerJSON) + "." + encode(claimsJSON)
+// WatchTokenState keeps the token cache in step with revocations made by
+// other replicas until ctx ends, reconnecting after failures. Entries are
+// dropped whenever the listener (re)connects, as changes may have been
+// missed meanwhile. It holds one database connection.
+func (s *UserService) WatchTokenState(ctx context.Context) {
+	log := logging.FromContext(ctx)
+	for {
+		err := s.repo.ListenTokenState(ctx, s.versions.clear, s.versions.invalidate)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Warn("token state listener disconnected", "error", err, "retry_in", tokenStateRetryDelay.String())
 
-	mac := hmac.New(sha256.New, s.tokenSecret)
-	if _, err := mac.Write([]byte(unsigned)); err != nil {
-		return "", fmt.Errorf("sign jwt: %w", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(tokenStateRetryDelay):
+		}
 	}
-	signature := encode(mac.Sum(nil))
-
-	return unsigned + "." + signature, nil
 }
@@ -0,0 +1,289 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"mmispoc/internal/logging"
+	"mmispoc/internal/repository"
+	"mmispoc/internal/tracing"
+)
+
+// ErrUserNotFound indicates the administered user does not exist.
+var ErrUserNotFound = errors.New("user not found")
+
+// ErrSelfAdministration is returned when an administrator targets their own account.
+var ErrSelfAdministration = errors.New("cannot administer own account")
+
+const (
+	defaultUserListLimit = 50
+	maxUserListLimit     = 200
+)
+
+// Audit actions recorded for user administration.
+const (
+	auditUserDeactivated       = "user.deactivated"
+	auditUserReactivated       = "user.reactivated"
+	auditUserRestaurantChanged = "user.restaurant_changed"
+	auditUserDeleted           = "user.deleted"
+	auditUserBootstrapped      = "user.bootstrapped"
+)
+
+// bootstrapActor is recorded as the actor of the bootstrap admin's creation.
+var bootstrapActor = &Principal{Username: "bootstrap"}
+
+// ListUsers returns the users visible to the actor. Admins may list any
+// restaurant; managers are pinned to their own.
+func (s *UserService) ListUsers(ctx context.Context, actor *Principal, filter repository.UserFilter) (users []repository.User, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.ListUsers")
+	defer func() { tracing.End(span, err) }()
+
+	switch {
+	case isAdmin(actor):
+	case isManager(actor):
+		filter.RestaurantID = actor.RestaurantID
+	default:
+		return nil, ErrForbidden
+	}
+
+	if filter.Limit <= 0 {
+		filter.Limit = defaultUserListLimit
+	}
+	if filter.Limit > maxUserListLimit {
+		filter.Limit = maxUserListLimit
+	}
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+
+	users, err = s.repo.List(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("list users: %w", err)
+	}
+
+	return users, nil
+}
+
+// DeactivateUser disables an account and revokes its tokens.
+func (s *UserService) DeactivateUser(ctx context.Context, actor *Principal, userID int64) (err error) {
+	ctx, span := tracing.Start(ctx, "UserService.DeactivateUser")
+	defer func() { tracing.End(span, err) }()
+
+	return s.setDisabled(ctx, actor, userID, true)
+}
+
+// ReactivateUser re-enables a previously deactivated account.
+func (s *UserService) ReactivateUser(ctx context.Context, actor *Principal, userID int64) (err error) {
+	ctx, span := tracing.Start(ctx, "UserService.ReactivateUser")
+	defer func() { tracing.End(span, err) }()
+
+	return s.setDisabled(ctx, actor, userID, false)
+}
+
+func (s *UserService) setDisabled(ctx context.Context, actor *Principal, userID int64, disabled bool) error {
+	target, err := s.administeredUser(ctx, actor, userID)
+	if err != nil {
+		return err
+	}
+
+	version, err := s.repo.SetDisabled(ctx, target.ID, disabled)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrUserNotFound
+		}
+		return fmt.Errorf("set user disabled: %w", err)
+	}
+	s.versions.set(target.ID, tokenState{version: version, disabled: disabled}, time.Now())
+
+	action := auditUserReactivated
+	if disabled {
+		action = auditUserDeactivated
+	}
+	return s.audit(ctx, actor, action, target, "")
+}
+
+// AssignRestaurant moves a user to another restaurant. Only admins may do this.
+func (s *UserService) AssignRestaurant(ctx context.Context, actor *Principal, userID, restaurantID int64) (err error) {
+	ctx, span := tracing.Start(ctx, "UserService.AssignRestaurant")
+	defer func() { tracing.End(span, err) }()
+
+	if !isAdmin(actor) {
+		return ErrForbidden
+	}
+	if restaurantID <= 0 {
+		return ErrInvalidRestaurantID
+	}
+
+	target, err := s.administeredUser(ctx, actor, userID)
+	if err != nil {
+		return err
+	}
+
+	exists, err := s.restaurantRepo.Exists(ctx, restaurantID)
+	if err != nil {
+		return fmt.Errorf("check restaurant: %w", err)
+	}
+	if !exists {
+		return ErrRestaurantNotFound
+	}
+
+	version, err := s.repo.SetRestaurant(ctx, target.ID, restaurantID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrUserNotFound
+		}
+		return fmt.Errorf("set user restaurant: %w", err)
+	}
+	s.versions.set(target.ID, tokenState{version: version, disabled: target.Disabled()}, time.Now())
+
+	details := "from " + strconv.FormatInt(target.RestaurantID, 10) + " to " + strconv.FormatInt(restaurantID, 10)
+	return s.audit(ctx, actor, auditUserRestaurantChanged, target, details)
+}
+
+// DeleteUser removes an account permanently, anonymising references held by
+// orders, API keys and audit events. Only admins may do this.
+func (s *UserService) DeleteUser(ctx context.Context, actor *Principal, userID int64) (err error) {
+	ctx, span := tracing.Start(ctx, "UserService.DeleteUser")
+	defer func() { tracing.End(span, err) }()
+
+	if !isAdmin(actor) {
+		return ErrForbidden
+	}
+
+	target, err := s.administeredUser(ctx, actor, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.Delete(ctx, target.ID); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrUserNotFound
+		}
+		return fmt.Errorf("delete user: %w", err)
+	}
+	s.versions.invalidate(target.ID)
+
+	anonymised := &repository.User{Username: fmt.Sprintf("deleted-user-%d", target.ID)}
+	return s.audit(ctx, actor, auditUserDeleted, anonymised, "")
+}
+
+// administeredUser loads the target user and checks the actor may manage it.
+func (s *UserService) administeredUser(ctx context.Context, actor *Principal, userID int64) (*repository.User, error) {
+	if !isAdmin(actor) && !isManager(actor) {
+		return nil, ErrForbidden
+	}
+	if userID <= 0 {
+		return nil, ErrUserNotFound
+	}
+	if userID == actor.UserID {
+		return nil, ErrSelfAdministration
+	}
+
+	target, err := s.repo.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, fmt.Errorf("fetch user: %w", err)
+	}
+
+	if isAdmin(actor) {
+		return target, nil
+	}
+
+	// Managers only see staff of their own restaurant; anything else is
+	// reported as missing so restaurant membership does not leak.
+	if target.RestaurantID != actor.RestaurantID || target.Role != RoleStaff {
+		return nil, ErrUserNotFound
+	}
+
+	return target, nil
+}
+
+// BootstrapAdmin creates the first admin of a fresh deployment. It returns a
+// nil user without changes while any active admin exists, so the configured
+// account is only created once and is not recreated after it is disabled in
+// favour of another admin. restaurantID may be zero.
+func (s *UserService) BootstrapAdmin(ctx context.Context, username, password string, restaurantID int64) (user *repository.User, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.BootstrapAdmin")
+	defer func() { tracing.End(span, err) }()
+
+	exists, err := s.repo.HasActiveRole(ctx, RoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, nil
+	}
+
+	username = strings.TrimSpace(username)
+	if !isValidUsername(username) {
+		return nil, ErrInvalidUsername
+	}
+	if !isValidPassword(password) {
+		return nil, ErrInvalidPassword
+	}
+	if restaurantID < 0 {
+		return nil, ErrInvalidRestaurantID
+	}
+	if restaurantID > 0 {
+		exists, err := s.restaurantRepo.Exists(ctx, restaurantID)
+		if err != nil {
+			return nil, fmt.Errorf("check restaurant: %w", err)
+		}
+		if !exists {
+			return nil, ErrRestaurantNotFound
+		}
+	}
+
+	user, err = s.repo.Create(ctx, username, hashPassword(password), restaurantID, RoleAdmin)
+	if err != nil {
+		if !errors.Is(err, repository.ErrConflict) {
+			return nil, fmt.Errorf("create user: %w", err)
+		}
+		// Another replica starting with the same configuration may have
+		// just created the admin.
+		exists, checkErr := s.repo.HasActiveRole(ctx, RoleAdmin)
+		if checkErr != nil {
+			return nil, checkErr
+		}
+		if exists {
+			return nil, nil
+		}
+		return nil, ErrUsernameTaken
+	}
+
+	if err := s.audit(ctx, bootstrapActor, auditUserBootstrapped, user, ""); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (s *UserService) audit(ctx context.Context, actor *Principal, action string, target *repository.User, details string) error {
+	event := repository.AuditEvent{
+		ActorUserID:    actor.UserID,
+		ActorUsername:  actor.Username,
+		Action:         action,
+		TargetUserID:   target.ID,
+		TargetUsername: target.Username,
+		Details:        details,
+	}
+	if err := s.auditRepo.Record(ctx, event); err != nil {
+		return fmt.Errorf("record audit event: %w", err)
+	}
+	logging.FromContext(ctx).Info("user administered",
+		"action", action, "actor_user_id", actor.UserID, "target_user_id", target.ID)
+	return nil
+}
+
+func isAdmin(p *Principal) bool {
+	return p != nil && p.IsUser() && p.Role == RoleAdmin
+}
+
+func isManager(p *Principal) bool {
+	return p != nil && p.IsUser() && p.Role == RoleManager && p.RestaurantID > 0
+}
@@ -0,0 +1,176 @@
+// Package tlsutil builds the server TLS configuration, reloading the
+// certificate from disk when it is renewed.
+package tlsutil
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Client certificate policies.
+const (
+	ClientAuthNone     = "none"
+	ClientAuthOptional = "optional"
+	ClientAuthRequire  = "require"
+)
+
+// reloadCheckInterval bounds how often the certificate files are stat'ed.
+const reloadCheckInterval = 5 * time.Second
+
+// Config describes the files and client policy of a TLS listener.
+type Config struct {
+	CertFile string
+	KeyFile  string
+	// ClientCAFile is a PEM bundle used to verify client certificates.
+	ClientCAFile string
+	// ClientAuth is one of ClientAuthNone, ClientAuthOptional or ClientAuthRequire.
+	ClientAuth string
+}
+
+// ServerConfig returns a tls.Config serving the configured certificate. The
+// certificate is re-read whenever its files change, so renewals need no restart.
+func ServerConfig(cfg Config) (*tls.Config, error) {
+	reloader, err := NewCertReloader(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+	}
+
+	switch cfg.ClientAuth {
+	case "", ClientAuthNone:
+		return tlsConfig, nil
+	case ClientAuthOptional:
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	case ClientAuthRequire:
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	default:
+		return nil, fmt.Errorf("unknown client auth mode %q", cfg.ClientAuth)
+	}
+
+	bundle, err := os.ReadFile(cfg.ClientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("read client CA bundle: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(bundle) {
+		return nil, errors.New("client CA bundle contains no certificates")
+	}
+	tlsConfig.ClientCAs = pool
+
+	return tlsConfig, nil
+}
+
+// CertReloader serves a key pair from disk and reloads it when either file's
+// modification time changes. A failed reload keeps the previous certificate.
+type CertReloader struct {
+	certFile string
+	keyFile  string
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	certMod   time.Time
+	keyMod    time.Time
+	lastCheck time.Time
+}
+
+// NewCertReloader loads the key pair once and fails if it is unusable.
+func NewCertReloader(certFile, keyFile string) (*CertReloader, error) {
+	r := &CertReloader{certFile: certFile, keyFile: keyFile}
+	if err := r.reload(time.Now()); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetCertificate implements tls.Config.GetCertificate.
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(r.lastCheck) >= reloadCheckInterval {
+		if err := r.reloadIfChanged(now); err != nil {
+			slog.Error("reload TLS certificate", "error", err)
+		}
+	}
+	return r.cert, nil
+}
+
+func (r *CertReloader) reloadIfChanged(now time.Time) error {
+	r.lastCheck = now
+
+	certMod, keyMod, err := r.modTimes()
+	if err != nil {
+		return err
+	}
+	if certMod.Equal(r.certMod) && keyMod.Equal(r.keyMod) {
+		return nil
+	}
+
+	if err := r.reload(now); err != nil {
+		return err
+	}
+	slog.Info("reloaded TLS certificate", "cert_file", r.certFile)
+	return nil
+}
+
+func (r *CertReloader) reload(now time.Time) error {
+	certMod, keyMod, err := r.modTimes()
+	if err != nil {
+		return err
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("load key pair: %w", err)
+	}
+
+	r.cert = &cert
+	r.certMod = certMod
+	r.keyMod = keyMod
+	r.lastCheck = now
+	return nil
+}
+
+func (r *CertReloader) modTimes() (time.Time, time.Time, error) {
+	certInfo, err := os.Stat(r.certFile)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("stat certificate: %w", err)
+	}
+	keyInfo, err := os.Stat(r.keyFile)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("stat key: %w", err)
+	}
+	return certInfo.ModTime(), keyInfo.ModTime(), nil
+}
+
+// RedirectHandler sends plain HTTP requests to the HTTPS listener. httpsPort
+// is appended to the host unless it is the default 443.
+func RedirectHandler(httpsPort string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if httpsPort != "" && httpsPort != "443" {
+			host = net.JoinHostPort(strings.Trim(host, "[]"), httpsPort)
+		}
+
+		target := url.URL{Scheme: "https", Host: host, Path: r.URL.Path, RawQuery: r.URL.RawQuery}
+		http.Redirect(w, r, target.String(), http.StatusPermanentRedirect)
+	})
+}
@@ -0,0 +1,290 @@
+package tlsutil
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	httptransport "mmispoc/internal/transport/http"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+var testSerial int64
+
+func newTestCA(t *testing.T, name string) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testSerial++
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(testSerial),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue signs a leaf certificate and returns its PEM certificate and key.
+func (ca *testCA) issue(t *testing.T, subject pkix.Name, usage x509.ExtKeyUsage) ([]byte, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testSerial++
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(testSerial),
+		Subject:      subject,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func writeFile(t *testing.T, path string, data []byte, mod time.Time) {
+	t.Helper()
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, mod, mod); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func servedCommonName(t *testing.T, r *CertReloader) string {
+	t.Helper()
+	cert, err := r.GetCertificate(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return leaf.Subject.CommonName
+}
+
+func TestCertReloader(t *testing.T) {
+	ca := newTestCA(t, "test ca")
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	mod := time.Now().Add(-time.Minute)
+
+	certPEM, keyPEM := ca.issue(t, pkix.Name{CommonName: "first"}, x509.ExtKeyUsageServerAuth)
+	writeFile(t, certFile, certPEM, mod)
+	writeFile(t, keyFile, keyPEM, mod)
+
+	reloader, err := NewCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("NewCertReloader: %v", err)
+	}
+	if got := servedCommonName(t, reloader); got != "first" {
+		t.Fatalf("served %q, want first", got)
+	}
+
+	// forceCheck skips the interval between checks so the test need not wait.
+	forceCheck := func() {
+		reloader.mu.Lock()
+		reloader.lastCheck = time.Time{}
+		reloader.mu.Unlock()
+	}
+
+	t.Run("reloads rewritten files", func(t *testing.T) {
+		mod = mod.Add(time.Second)
+		certPEM, keyPEM := ca.issue(t, pkix.Name{CommonName: "second"}, x509.ExtKeyUsageServerAuth)
+		writeFile(t, certFile, certPEM, mod)
+		writeFile(t, keyFile, keyPEM, mod)
+
+		if got := servedCommonName(t, reloader); got != "first" {
+			t.Fatalf("served %q before the check interval elapsed, want first", got)
+		}
+		forceCheck()
+		if got := servedCommonName(t, reloader); got != "second" {
+			t.Fatalf("served %q after rewrite, want second", got)
+		}
+	})
+
+	t.Run("keeps the old certificate when the new pair is invalid", func(t *testing.T) {
+		// A certificate renewed before its key is written does not match.
+		mod = mod.Add(time.Second)
+		certPEM, _ := ca.issue(t, pkix.Name{CommonName: "third"}, x509.ExtKeyUsageServerAuth)
+		writeFile(t, certFile, certPEM, mod)
+
+		forceCheck()
+		if got := servedCommonName(t, reloader); got != "second" {
+			t.Fatalf("served %q after a mismatched rewrite, want second", got)
+		}
+
+		mod = mod.Add(time.Second)
+		writeFile(t, keyFile, []byte("not a key"), mod)
+		forceCheck()
+		if got := servedCommonName(t, reloader); got != "second" {
+			t.Fatalf("served %q after a corrupt key, want second", got)
+		}
+	})
+
+	t.Run("rejects an invalid pair at startup", func(t *testing.T) {
+		if _, err := NewCertReloader(certFile, keyFile); err == nil {
+			t.Fatal("NewCertReloader accepted a corrupt key")
+		}
+	})
+}
+
+func TestServerConfigClientAuth(t *testing.T) {
+	serverCA := newTestCA(t, "server ca")
+	clientCA := newTestCA(t, "client ca")
+	otherCA := newTestCA(t, "other ca")
+
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	caFile := filepath.Join(dir, "clients.pem")
+	certPEM, keyPEM := serverCA.issue(t, pkix.Name{CommonName: "localhost"}, x509.ExtKeyUsageServerAuth)
+	writeFile(t, certFile, certPEM, time.Now())
+	writeFile(t, keyFile, keyPEM, time.Now())
+	writeFile(t, caFile, clientCA.pem, time.Now())
+
+	tlsConfig, err := ServerConfig(Config{
+		CertFile:     certFile,
+		KeyFile:      keyFile,
+		ClientCAFile: caFile,
+		ClientAuth:   ClientAuthRequire,
+	})
+	if err != nil {
+		t.Fatalf("ServerConfig: %v", err)
+	}
+
+	scopes := []string{"orders:read", "inventory:read"}
+	auth := httptransport.NewAuthenticator(nil, nil, scopes)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := auth.Authenticate(w, r)
+		if !ok {
+			return
+		}
+		_ = json.NewEncoder(w).Encode(principal)
+	})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &http.Server{
+		Handler:   handler,
+		TLSConfig: tlsConfig,
+		ErrorLog:  log.New(io.Discard, "", 0),
+	}
+	go server.ServeTLS(listener, "", "")
+	t.Cleanup(func() { server.Close() })
+
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(serverCA.pem)
+	get := func(t *testing.T, certs []tls.Certificate) (*http.Response, error) {
+		t.Helper()
+		client := &http.Client{Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: roots, ServerName: "localhost", Certificates: certs},
+		}}
+		defer client.CloseIdleConnections()
+		return client.Get("https://" + listener.Addr().String() + "/")
+	}
+	clientCert := func(t *testing.T, ca *testCA, subject pkix.Name) []tls.Certificate {
+		t.Helper()
+		certPEM, keyPEM := ca.issue(t, subject, x509.ExtKeyUsageClientAuth)
+		cert, err := tls.X509KeyPair(certPEM, keyPEM)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return []tls.Certificate{cert}
+	}
+
+	t.Run("maps the subject to a principal", func(t *testing.T) {
+		subject := pkix.Name{CommonName: "pos-gateway", Organization: []string{"Kitchen"}}
+		resp, err := get(t, clientCert(t, clientCA, subject))
+		if err != nil {
+			t.Fatalf("request with a trusted client certificate: %v", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("status = %d, want 200", resp.StatusCode)
+		}
+
+		var principal struct {
+			UserID        int64
+			Scopes        []string
+			ClientSubject string
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&principal); err != nil {
+			t.Fatal(err)
+		}
+		if principal.ClientSubject != "CN=pos-gateway,O=Kitchen" {
+			t.Errorf("ClientSubject = %q, want CN=pos-gateway,O=Kitchen", principal.ClientSubject)
+		}
+		if !slices.Equal(principal.Scopes, scopes) {
+			t.Errorf("Scopes = %v, want %v", principal.Scopes, scopes)
+		}
+		if principal.UserID != 0 {
+			t.Errorf("UserID = %d, want a principal without a user", principal.UserID)
+		}
+	})
+
+	t.Run("rejects a certificate from another CA", func(t *testing.T) {
+		resp, err := get(t, clientCert(t, otherCA, pkix.Name{CommonName: "intruder"}))
+		if err == nil {
+			resp.Body.Close()
+			t.Fatalf("request with an untrusted client certificate got status %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("requires a client certificate", func(t *testing.T) {
+		resp, err := get(t, nil)
+		if err == nil {
+			resp.Body.Close()
+			t.Fatalf("request without a client certificate got status %d", resp.StatusCode)
+		}
+	})
+}
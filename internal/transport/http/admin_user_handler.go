@@ -0,0 +1,260 @@
+package httptransport
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"mmispoc/internal/repository"
+	"mmispoc/internal/service"
+)
+
+// AdminUsersHandler handles GET /admin/users requests.
+type AdminUsersHandler struct {
+	auth        *Authenticator
+	userService *service.UserService
+}
+
+// NewAdminUsersHandler builds the user listing handler.
+func NewAdminUsersHandler(auth *Authenticator, userService *service.UserService) http.Handler {
+	return &AdminUsersHandler{
+		auth:        auth,
+		userService: userService,
+	}
+}
+
+func (h *AdminUsersHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	principal, ok := h.auth.Authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	filter := repository.UserFilter{
+		Query:           query.Get("q"),
+		IncludeDisabled: query.Get("include_disabled") == "true",
+	}
+	for name, dest := range map[string]*int{"limit": &filter.Limit, "offset": &filter.Offset} {
+		if raw := query.Get(name); raw != "" {
+			value, err := strconv.Atoi(raw)
+			if err != nil || value < 0 {
+				writeError(w, http.StatusBadRequest, "invalid "+name)
+				return
+			}
+			*dest = value
+		}
+	}
+	if raw := query.Get("restaurant_id"); raw != "" {
+		restaurantID, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || restaurantID <= 0 {
+			writeError(w, http.StatusBadRequest, "invalid restaurant id")
+			return
+		}
+		filter.RestaurantID = restaurantID
+	}
+
+	users, err := h.userService.ListUsers(r.Context(), principal, filter)
+	if err != nil {
+		handleUserAdminError(w, r, err)
+		return
+	}
+
+	type userDTO struct {
+		ID           int64  `json:"id"`
+		Username     string `json:"username"`
+		RestaurantID int64  `json:"restaurant_id"`
+		Role         string `json:"role"`
+		CreatedAt    string `json:"created_at"`
+		DisabledAt   string `json:"disabled_at,omitempty"`
+	}
+
+	result := make([]userDTO, 0, len(users))
+	for _, user := range users {
+		dto := userDTO{
+			ID:           user.ID,
+			Username:     user.Username,
+			RestaurantID: user.RestaurantID,
+			Role:         user.Role,
+			CreatedAt:    user.CreatedAt.Format(time.RFC3339),
+		}
+		if user.Disabled() {
+			dto.DisabledAt = user.DisabledAt.Format(time.RFC3339)
+		}
+		result = append(result, dto)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"count": len(result),
+		"users": result,
+	})
+}
+
+// AdminUserStatusHandler handles POST /admin/users/{id}/deactivate and /reactivate requests.
+type AdminUserStatusHandler struct {
+	auth        *Authenticator
+	userService *service.UserService
+	disable     bool
+}
+
+// NewAdminUserStatusHandler builds a handler that deactivates (disable=true) or reactivates users.
+func NewAdminUserStatusHandler(auth *Authenticator, userService *service.UserService, disable bool) http.Handler {
+	return &AdminUserStatusHandler{
+		auth:        auth,
+		userService: userService,
+		disable:     disable,
+	}
+}
+
+func (h *AdminUserStatusHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	userID, err := pathID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid user id")
+		return
+	}
+
+	principal, ok := h.auth.Authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	if h.disable {
+		err = h.userService.DeactivateUser(r.Context(), principal, userID)
+	} else {
+		err = h.userService.ReactivateUser(r.Context(), principal, userID)
+	}
+	if err != nil {
+		handleUserAdminError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"id":       userID,
+		"disabled": h.disable,
+	})
+}
+
+// AdminUserRestaurantHandler handles PUT /admin/users/{id}/restaurant requests.
+type AdminUserRestaurantHandler struct {
+	auth        *Authenticator
+	userService *service.UserService
+}
+
+// NewAdminUserRestaurantHandler builds the restaurant reassignment handler.
+func NewAdminUserRestaurantHandler(auth *Authenticator, userService *service.UserService) http.Handler {
+	return &AdminUserRestaurantHandler{
+		auth:        auth,
+		userService: userService,
+	}
+}
+
+func (h *AdminUserRestaurantHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	userID, err := pathID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid user id")
+		return
+	}
+
+	principal, ok := h.auth.Authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	var payload struct {
+		RestaurantID int64 `json:"restaurant_id"`
+	}
+
+	if !decodeJSON(w, r, &payload) {
+		return
+	}
+
+	if err := h.userService.AssignRestaurant(r.Context(), principal, userID, payload.RestaurantID); err != nil {
+		handleUserAdminError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"id":            userID,
+		"restaurant_id": payload.RestaurantID,
+	})
+}
+
+// AdminUserDeleteHandler handles DELETE /admin/users/{id} requests.
+type AdminUserDeleteHandler struct {
+	auth        *Authenticator
+	userService *service.UserService
+}
+
+// NewAdminUserDeleteHandler builds the user deletion handler.
+func NewAdminUserDeleteHandler(auth *Authenticator, userService *service.UserService) http.Handler {
+	return &AdminUserDeleteHandler{
+		auth:        auth,
+		userService: userService,
+	}
+}
+
+func (h *AdminUserDeleteHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	userID, err := pathID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid user id")
+		return
+	}
+
+	principal, ok := h.auth.Authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.userService.DeleteUser(r.Context(), principal, userID); err != nil {
+		handleUserAdminError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"id":      userID,
+		"deleted": true,
+	})
+}
+
+func handleUserAdminError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, service.ErrForbidden):
+		writeError(w, http.StatusForbidden, "insufficient role")
+	case errors.Is(err, service.ErrSelfAdministration):
+		writeError(w, http.StatusBadRequest, "cannot administer own account")
+	case errors.Is(err, service.ErrUserNotFound):
+		writeError(w, http.StatusNotFound, "user not found")
+	case errors.Is(err, service.ErrInvalidRestaurantID):
+		writeError(w, http.StatusBadRequest, "invalid restaurant id")
+	case errors.Is(err, service.ErrRestaurantNotFound):
+		writeError(w, http.StatusBadRequest, "restaurant not found")
+	default:
+		writeInternalError(w, r, err)
+	}
+}
+
+// pathID parses the {id} wildcard of the matched route.
+func pathID(r *http.Request) (int64, error) {
+	return strconv.ParseInt(strings.TrimSpace(r.PathValue("id")), 10, 64)
+}
@@ -0,0 +1,168 @@
+package httptransport
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"mmispoc/internal/repository"
+	"mmispoc/internal/service"
+)
+
+// APIKeysHandler handles GET and POST /api-keys requests.
+type APIKeysHandler struct {
+	auth          *Authenticator
+	apiKeyService *service.APIKeyService
+}
+
+// NewAPIKeysHandler builds the API key listing and creation handler.
+func NewAPIKeysHandler(auth *Authenticator, apiKeyService *service.APIKeyService) http.Handler {
+	return &APIKeysHandler{
+		auth:          auth,
+		apiKeyService: apiKeyService,
+	}
+}
+
+func (h *APIKeysHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	principal, ok := h.auth.Authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		keys, err := h.apiKeyService.List(r.Context(), principal)
+		if err != nil {
+			handleAPIKeyError(w, r, err)
+			return
+		}
+
+		result := make([]map[string]interface{}, 0, len(keys))
+		for i := range keys {
+			result = append(result, apiKeyDTO(&keys[i]))
+		}
+
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"count":    len(result),
+			"api_keys": result,
+		})
+		return
+	}
+
+	var payload struct {
+		Description string   `json:"description"`
+		Scopes      []string `json:"scopes"`
+		ExpiresAt   string   `json:"expires_at"`
+	}
+
+	if !decodeJSON(w, r, &payload) {
+		return
+	}
+
+	var expiresAt time.Time
+	if payload.ExpiresAt != "" {
+		parsed, err := time.Parse(time.RFC3339, payload.ExpiresAt)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid expires_at")
+			return
+		}
+		expiresAt = parsed
+	}
+
+	created, err := h.apiKeyService.Create(r.Context(), principal, payload.Description, payload.Scopes, expiresAt)
+	if err != nil {
+		handleAPIKeyError(w, r, err)
+		return
+	}
+
+	response := apiKeyDTO(&created.APIKey)
+	response["key"] = created.Key
+	writeJSON(w, http.StatusCreated, response)
+}
+
+// APIKeyRevokeHandler handles DELETE /api-keys/{id} requests.
+type APIKeyRevokeHandler struct {
+	auth          *Authenticator
+	apiKeyService *service.APIKeyService
+}
+
+// NewAPIKeyRevokeHandler builds the API key revocation handler.
+func NewAPIKeyRevokeHandler(auth *Authenticator, apiKeyService *service.APIKeyService) http.Handler {
+	return &APIKeyRevokeHandler{
+		auth:          auth,
+		apiKeyService: apiKeyService,
+	}
+}
+
+func (h *APIKeyRevokeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	id, err := pathID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid api key id")
+		return
+	}
+
+	principal, ok := h.auth.Authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.apiKeyService.Revoke(r.Context(), principal, id); err != nil {
+		handleAPIKeyError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"id":      id,
+		"revoked": true,
+	})
+}
+
+func handleAPIKeyError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, service.ErrForbidden):
+		writeError(w, http.StatusForbidden, "only restaurant managers can manage api keys")
+	case errors.Is(err, service.ErrInvalidScope):
+		writeError(w, http.StatusBadRequest, "invalid scope")
+	case errors.Is(err, service.ErrInvalidExpiry):
+		writeError(w, http.StatusBadRequest, "expires_at must be in the future")
+	case errors.Is(err, service.ErrAPIKeyNotFound):
+		writeError(w, http.StatusNotFound, "api key not found")
+	default:
+		writeInternalError(w, r, err)
+	}
+}
+
+func apiKeyDTO(key *repository.APIKey) map[string]interface{} {
+	scopes := key.Scopes
+	if scopes == nil {
+		scopes = []string{}
+	}
+
+	dto := map[string]interface{}{
+		"id":            key.ID,
+		"prefix":        key.Prefix,
+		"restaurant_id": key.RestaurantID,
+		"description":   key.Description,
+		"scopes":        scopes,
+		"created_at":    key.CreatedAt.Format(time.RFC3339),
+	}
+	if !key.ExpiresAt.IsZero() {
+		dto["expires_at"] = key.ExpiresAt.Format(time.RFC3339)
+	}
+	if !key.LastUsedAt.IsZero() {
+		dto["last_used_at"] = key.LastUsedAt.Format(time.RFC3339)
+	}
+	if !key.RevokedAt.IsZero() {
+		dto["revoked_at"] = key.RevokedAt.Format(time.RFC3339)
+	}
+	return dto
+}
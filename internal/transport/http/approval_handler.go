@@ -0,0 +1,343 @@
+package httptransport
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"mmispoc/internal/repository"
+	"mmispoc/internal/service"
+)
+
+// ApprovalRulesHandler handles GET and POST /approval-rules requests.
+type ApprovalRulesHandler struct {
+	auth            *Authenticator
+	approvalService *service.ApprovalService
+}
+
+// NewApprovalRulesHandler builds the approval rule listing and creation handler.
+func NewApprovalRulesHandler(auth *Authenticator, approvalService *service.ApprovalService) http.Handler {
+	return &ApprovalRulesHandler{
+		auth:            auth,
+		approvalService: approvalService,
+	}
+}
+
+func (h *ApprovalRulesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	principal, ok := h.auth.Authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		rules, err := h.approvalService.ListRules(r.Context(), principal)
+		if err != nil {
+			handleApprovalError(w, r, err)
+			return
+		}
+
+		result := make([]map[string]interface{}, 0, len(rules))
+		for i := range rules {
+			result = append(result, approvalRuleDTO(&rules[i]))
+		}
+
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"count": len(result),
+			"rules": result,
+		})
+		return
+	}
+
+	var payload approvalRulePayload
+	if !decodeJSON(w, r, &payload) {
+		return
+	}
+
+	rule := payload.rule()
+	if err := h.approvalService.CreateRule(r.Context(), principal, rule); err != nil {
+		handleApprovalError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, approvalRuleDTO(rule))
+}
+
+// ApprovalRuleHandler handles GET, PUT and DELETE /approval-rules/{id} requests.
+type ApprovalRuleHandler struct {
+	auth            *Authenticator
+	approvalService *service.ApprovalService
+}
+
+// NewApprovalRuleHandler builds the single approval rule handler.
+func NewApprovalRuleHandler(auth *Authenticator, approvalService *service.ApprovalService) http.Handler {
+	return &ApprovalRuleHandler{
+		auth:            auth,
+		approvalService: approvalService,
+	}
+}
+
+func (h *ApprovalRuleHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPut && r.Method != http.MethodDelete {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	ruleID, err := pathID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid approval rule id")
+		return
+	}
+
+	principal, ok := h.auth.Authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		rule, err := h.approvalService.GetRule(r.Context(), principal, ruleID)
+		if err != nil {
+			handleApprovalError(w, r, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, approvalRuleDTO(rule))
+
+	case http.MethodPut:
+		var payload approvalRulePayload
+		if !decodeJSON(w, r, &payload) {
+			return
+		}
+
+		rule := payload.rule()
+		rule.ID = ruleID
+		if err := h.approvalService.UpdateRule(r.Context(), principal, rule); err != nil {
+			handleApprovalError(w, r, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, approvalRuleDTO(rule))
+
+	case http.MethodDelete:
+		if err := h.approvalService.DeleteRule(r.Context(), principal, ruleID); err != nil {
+			handleApprovalError(w, r, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"id":      ruleID,
+			"deleted": true,
+		})
+	}
+}
+
+// ApprovalInboxHandler handles GET /approvals requests.
+type ApprovalInboxHandler struct {
+	auth            *Authenticator
+	approvalService *service.ApprovalService
+}
+
+// NewApprovalInboxHandler builds the handler listing orders the caller may approve.
+func NewApprovalInboxHandler(auth *Authenticator, approvalService *service.ApprovalService) http.Handler {
+	return &ApprovalInboxHandler{
+		auth:            auth,
+		approvalService: approvalService,
+	}
+}
+
+func (h *ApprovalInboxHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	principal, ok := h.auth.Authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	pending, err := h.approvalService.Inbox(r.Context(), principal)
+	if err != nil {
+		handleApprovalError(w, r, err)
+		return
+	}
+
+	result := make([]map[string]interface{}, 0, len(pending))
+	for i := range pending {
+		item := &pending[i]
+		dto := map[string]interface{}{
+			"purchase_order_id": item.PurchaseOrder.ID,
+			"code":              item.PurchaseOrder.Code,
+			"restaurant_id":     item.PurchaseOrder.RestaurantID,
+			"supplier_id":       item.PurchaseOrder.SupplierID,
+			"created_by":        item.PurchaseOrder.CreatedBy,
+			"created_at":        item.PurchaseOrder.CreatedAt.Format(time.RFC3339),
+			"net":               item.Net.StringFixed(2),
+			"step":              approvalDTO(&item.Approval),
+		}
+		if item.SupplierName != "" {
+			dto["supplier_name"] = item.SupplierName
+		}
+		result = append(result, dto)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"count":     len(result),
+		"approvals": result,
+	})
+}
+
+// OrderDecisionHandler handles POST /orders/{id}/approve and /reject
+// requests, where id is a purchase order id.
+type OrderDecisionHandler struct {
+	auth            *Authenticator
+	approvalService *service.ApprovalService
+	approve         bool
+}
+
+// NewOrderDecisionHandler builds a handler that approves (approve=true) or rejects purchase orders.
+func NewOrderDecisionHandler(auth *Authenticator, approvalService *service.ApprovalService, approve bool) http.Handler {
+	return &OrderDecisionHandler{
+		auth:            auth,
+		approvalService: approvalService,
+		approve:         approve,
+	}
+}
+
+func (h *OrderDecisionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	purchaseOrderID, err := pathID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid order id")
+		return
+	}
+
+	principal, ok := h.auth.Authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	var payload struct {
+		Comment string `json:"comment"`
+	}
+	if r.ContentLength != 0 && !decodeJSON(w, r, &payload) {
+		return
+	}
+
+	var po *repository.PurchaseOrder
+	if h.approve {
+		po, err = h.approvalService.Approve(r.Context(), principal, purchaseOrderID, payload.Comment)
+	} else {
+		po, err = h.approvalService.Reject(r.Context(), principal, purchaseOrderID, payload.Comment)
+	}
+	if err != nil {
+		handleApprovalError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"id":        po.ID,
+		"code":      po.Code,
+		"status":    po.Status,
+		"approvals": approvalDTOs(po.Approvals),
+	})
+}
+
+type approvalRulePayload struct {
+	Name           string              `json:"name"`
+	RestaurantID   int64               `json:"restaurant_id"`
+	MinTotal       decimal.NullDecimal `json:"min_total"`
+	IngredientType string              `json:"ingredient_type"`
+	Steps          []string            `json:"steps"`
+}
+
+func (p approvalRulePayload) rule() *repository.ApprovalRule {
+	return &repository.ApprovalRule{
+		Name:           p.Name,
+		RestaurantID:   p.RestaurantID,
+		MinTotal:       p.MinTotal,
+		IngredientType: p.IngredientType,
+		Steps:          p.Steps,
+	}
+}
+
+func handleApprovalError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, service.ErrForbidden):
+		writeError(w, http.StatusForbidden, "insufficient role")
+	case errors.Is(err, service.ErrApprovalRuleNotFound):
+		writeError(w, http.StatusNotFound, "approval rule not found")
+	case errors.Is(err, service.ErrInvalidApprovalRule):
+		writeError(w, http.StatusBadRequest, "name and at least one step are required; steps must be manager, area_manager or admin and min_total must not be negative")
+	case errors.Is(err, service.ErrRestaurantNotFound):
+		writeError(w, http.StatusBadRequest, "restaurant not found")
+	case errors.Is(err, service.ErrOrderInvalidID):
+		writeError(w, http.StatusBadRequest, "invalid order id")
+	case errors.Is(err, service.ErrOrderNotFound):
+		writeError(w, http.StatusNotFound, "order not found")
+	case errors.Is(err, service.ErrApprovalNotPending):
+		writeError(w, http.StatusConflict, "order is not awaiting approval")
+	default:
+		writeInternalError(w, r, err)
+	}
+}
+
+func approvalRuleDTO(rule *repository.ApprovalRule) map[string]interface{} {
+	dto := map[string]interface{}{
+		"id":         rule.ID,
+		"name":       rule.Name,
+		"steps":      rule.Steps,
+		"created_at": rule.CreatedAt.Format(time.RFC3339),
+		"updated_at": rule.UpdatedAt.Format(time.RFC3339),
+	}
+	if rule.RestaurantID != 0 {
+		dto["restaurant_id"] = rule.RestaurantID
+	}
+	if rule.MinTotal.Valid {
+		dto["min_total"] = rule.MinTotal.Decimal.StringFixed(2)
+	}
+	if rule.IngredientType != "" {
+		dto["ingredient_type"] = rule.IngredientType
+	}
+	return dto
+}
+
+func approvalDTOs(approvals []repository.Approval) []map[string]interface{} {
+	result := make([]map[string]interface{}, 0, len(approvals))
+	for i := range approvals {
+		result = append(result, approvalDTO(&approvals[i]))
+	}
+	return result
+}
+
+func approvalDTO(approval *repository.Approval) map[string]interface{} {
+	dto := map[string]interface{}{
+		"step":          approval.Step,
+		"approver_role": approval.ApproverRole,
+		"status":        approval.Status,
+	}
+	if approval.Reason != "" {
+		dto["reason"] = approval.Reason
+	}
+	if !approval.ExpiresAt.IsZero() {
+		dto["expires_at"] = approval.ExpiresAt.Format(time.RFC3339)
+	}
+	if !approval.DecidedAt.IsZero() {
+		dto["decided_at"] = approval.DecidedAt.Format(time.RFC3339)
+		if approval.DecidedBy != 0 {
+			dto["decided_by"] = approval.DecidedBy
+		}
+	}
+	if approval.Comment != "" {
+		dto["comment"] = approval.Comment
+	}
+	return dto
+}
@@ -0,0 +1,149 @@
+package httptransport
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"mmispoc/internal/repository"
+	"mmispoc/internal/service"
+)
+
+// Authenticator resolves the caller of a request from a bearer JWT, an
+// X-API-Key header or a verified TLS client certificate into a
+// service.Principal.
+type Authenticator struct {
+	userService   *service.UserService
+	apiKeyService *service.APIKeyService
+	// clientCertScopes are granted to callers authenticated by a client
+	// certificate alone.
+	clientCertScopes []string
+}
+
+// NewAuthenticator builds an authenticator over the user and API key services.
+func NewAuthenticator(userService *service.UserService, apiKeyService *service.APIKeyService, clientCertScopes []string) *Authenticator {
+	return &Authenticator{
+		userService:      userService,
+		apiKeyService:    apiKeyService,
+		clientCertScopes: clientCertScopes,
+	}
+}
+
+// Authenticate returns the request principal or writes a 401/500 response and returns false.
+// A bearer token takes precedence when both credentials are supplied. A
+// verified client certificate authenticates the request only when no other
+// credential is present; otherwise its subject is recorded on the principal.
+func (a *Authenticator) Authenticate(w http.ResponseWriter, r *http.Request) (*service.Principal, bool) {
+	principal, ok := a.authenticate(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	principal.ClientSubject = clientSubject(r)
+	setRequestPrincipal(r, principal)
+	return principal, true
+}
+
+func (a *Authenticator) authenticate(w http.ResponseWriter, r *http.Request) (*service.Principal, bool) {
+	if clientSubject(r) != "" && r.Header.Get("Authorization") == "" && r.Header.Get("X-API-Key") == "" {
+		return &service.Principal{Scopes: a.clientCertScopes}, true
+	}
+
+	if apiKey := presentedAPIKey(r); apiKey != "" {
+		principal, err := a.authenticateAPIKey(r, apiKey)
+		if err != nil {
+			switch {
+			case errors.Is(err, service.ErrInvalidAPIKey):
+				writeError(w, http.StatusUnauthorized, "invalid api key")
+			default:
+				writeInternalError(w, r, err)
+			}
+			return nil, false
+		}
+		return principal, true
+	}
+
+	token, ok := bearerToken(r)
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "missing or invalid authorization header")
+		return nil, false
+	}
+
+	principal, err := a.userService.ValidateAccessToken(r.Context(), token)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidToken):
+			writeError(w, http.StatusUnauthorized, "invalid token")
+		case errors.Is(err, service.ErrTokenExpired):
+			writeError(w, http.StatusUnauthorized, "token expired")
+		case errors.Is(err, service.ErrAccountDisabled):
+			writeError(w, http.StatusForbidden, "account disabled")
+		case errors.Is(err, service.ErrTokenRevoked):
+			writeError(w, http.StatusUnauthorized, "token revoked")
+		default:
+			writeInternalError(w, r, err)
+		}
+		return nil, false
+	}
+
+	return principal, true
+}
+
+// authenticateAPIKey reuses the rate limiter's check of the key when there
+// was one, so each request looks its key up at most once.
+func (a *Authenticator) authenticateAPIKey(r *http.Request, apiKey string) (*service.Principal, error) {
+	verified, ok := r.Context().Value(verifiedAPIKeyKey{}).(*verifiedAPIKey)
+	if !ok || verified.raw != apiKey {
+		return a.apiKeyService.Authenticate(r.Context(), apiKey)
+	}
+	if verified.err != nil {
+		return nil, verified.err
+	}
+	return a.apiKeyService.AuthenticateVerified(r.Context(), verified.key)
+}
+
+type verifiedAPIKeyKey struct{}
+
+// verifiedAPIKey is the outcome of checking an X-API-Key header: the key
+// when it is valid, or service.ErrInvalidAPIKey.
+type verifiedAPIKey struct {
+	raw string
+	key *repository.APIKey
+	err error
+}
+
+// withVerifiedAPIKey records the outcome of checking apiKey for the
+// Authenticator further in.
+func withVerifiedAPIKey(r *http.Request, apiKey string, key *repository.APIKey, err error) *http.Request {
+	ctx := context.WithValue(r.Context(), verifiedAPIKeyKey{}, &verifiedAPIKey{raw: apiKey, key: key, err: err})
+	return r.WithContext(ctx)
+}
+
+// presentedAPIKey returns the X-API-Key the request authenticates with, or
+// "" when there is none or a bearer token takes precedence over it.
+func presentedAPIKey(r *http.Request) string {
+	if r.Header.Get("Authorization") != "" {
+		return ""
+	}
+	return strings.TrimSpace(r.Header.Get("X-API-Key"))
+}
+
+// clientSubject returns the subject of a client certificate the TLS
+// handshake verified against the configured CA bundle.
+func clientSubject(r *http.Request) string {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return ""
+	}
+	return r.TLS.VerifiedChains[0][0].Subject.String()
+}
+
+// bearerToken extracts the token from an "Authorization: Bearer" header.
+func bearerToken(r *http.Request) (string, bool) {
+	authHeader := strings.TrimSpace(r.Header.Get("Authorization"))
+	const bearerPrefix = "Bearer "
+	if authHeader == "" || !strings.HasPrefix(authHeader, bearerPrefix) {
+		return "", false
+	}
+	return strings.TrimSpace(authHeader[len(bearerPrefix):]), true
+}
@@ -0,0 +1,136 @@
+package httptransport
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"mmispoc/internal/repository"
+	"mmispoc/internal/service"
+)
+
+// RestaurantBudgetHandler handles GET, PUT and DELETE /restaurants/{id}/budget requests.
+type RestaurantBudgetHandler struct {
+	auth          *Authenticator
+	budgetService *service.BudgetService
+}
+
+// NewRestaurantBudgetHandler builds the restaurant budget handler.
+func NewRestaurantBudgetHandler(auth *Authenticator, budgetService *service.BudgetService) http.Handler {
+	return &RestaurantBudgetHandler{
+		auth:          auth,
+		budgetService: budgetService,
+	}
+}
+
+func (h *RestaurantBudgetHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPut && r.Method != http.MethodDelete {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	restaurantID, err := pathID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid restaurant id")
+		return
+	}
+
+	principal, ok := h.auth.Authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		statuses, err := h.budgetService.GetBudgets(r.Context(), principal, restaurantID)
+		if err != nil {
+			handleBudgetError(w, r, err)
+			return
+		}
+
+		result := make([]map[string]interface{}, 0, len(statuses))
+		for i := range statuses {
+			result = append(result, budgetStatusDTO(&statuses[i]))
+		}
+
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"restaurant_id": restaurantID,
+			"budgets":       result,
+		})
+
+	case http.MethodPut:
+		var payload struct {
+			Period    string          `json:"period"`
+			Amount    decimal.Decimal `json:"amount"`
+			HardLimit bool            `json:"hard_limit"`
+		}
+		if !decodeJSON(w, r, &payload) {
+			return
+		}
+
+		budget := &repository.Budget{
+			RestaurantID: restaurantID,
+			Period:       payload.Period,
+			Amount:       payload.Amount,
+			HardLimit:    payload.HardLimit,
+		}
+		if err := h.budgetService.SetBudget(r.Context(), principal, budget); err != nil {
+			handleBudgetError(w, r, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"restaurant_id": restaurantID,
+			"period":        budget.Period,
+			"amount":        budget.Amount.StringFixed(2),
+			"hard_limit":    budget.HardLimit,
+			"updated_at":    budget.UpdatedAt.Format(time.RFC3339),
+		})
+
+	case http.MethodDelete:
+		period := r.URL.Query().Get("period")
+		if err := h.budgetService.DeleteBudget(r.Context(), principal, restaurantID, period); err != nil {
+			handleBudgetError(w, r, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"restaurant_id": restaurantID,
+			"period":        period,
+			"deleted":       true,
+		})
+	}
+}
+
+func handleBudgetError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, service.ErrForbidden):
+		writeError(w, http.StatusForbidden, "insufficient role")
+	case errors.Is(err, service.ErrInvalidBudget):
+		writeError(w, http.StatusBadRequest, "period must be week or month and amount must not be negative")
+	case errors.Is(err, service.ErrBudgetNotFound):
+		writeError(w, http.StatusNotFound, "budget not found")
+	case errors.Is(err, service.ErrInvalidRestaurantID):
+		writeError(w, http.StatusBadRequest, "invalid restaurant id")
+	case errors.Is(err, service.ErrRestaurantNotFound):
+		writeError(w, http.StatusNotFound, "restaurant not found")
+	default:
+		writeInternalError(w, r, err)
+	}
+}
+
+func budgetStatusDTO(status *service.BudgetStatus) map[string]interface{} {
+	return map[string]interface{}{
+		"period":       status.Period,
+		"amount":       status.Amount.StringFixed(2),
+		"hard_limit":   status.HardLimit,
+		"period_start": status.PeriodStart.Format(time.RFC3339),
+		"period_end":   status.PeriodEnd.Format(time.RFC3339),
+		"committed":    status.Committed.StringFixed(2),
+		"pending":      status.Pending.StringFixed(2),
+		"remaining":    status.Remaining.StringFixed(2),
+		"exceeded":     status.Remaining.IsNegative(),
+	}
+}
@@ -0,0 +1,214 @@
+package httptransport
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSConfig describes which browser origins may call the API.
+type CORSConfig struct {
+	// AllowedOrigins lists exact origins such as "https://app.example.com",
+	// wildcard-subdomain patterns such as "https://*.example.com", or "*" for
+	// any origin. An empty list disables cross-origin access.
+	AllowedOrigins []string
+	// AllowCredentials lets browsers send cookies and Authorization headers.
+	// It cannot be combined with "*".
+	AllowCredentials bool
+	// MaxAge is how long browsers may cache a preflight response.
+	MaxAge time.Duration
+}
+
+// corsAllowedHeaders are the request headers the API reads.
+var corsAllowedHeaders = []string{
+	"Authorization",
+	"Content-Type",
+	"X-API-Key",
+	"X-Request-ID",
+	"traceparent",
+	"tracestate",
+}
+
+// corsExposedHeaders are the response headers scripts may read.
+var corsExposedHeaders = []string{
+	"X-Request-ID",
+	"RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "RateLimit-Policy", "Retry-After",
+}
+
+type corsPolicy struct {
+	anyOrigin   bool
+	exact       map[string]bool
+	suffixes    []originSuffix
+	credentials bool
+	maxAge      string
+	headers     map[string]bool
+}
+
+// originSuffix matches "scheme://<one or more labels>.domain".
+type originSuffix struct {
+	scheme string
+	domain string
+}
+
+func newCORSPolicy(cfg CORSConfig) *corsPolicy {
+	p := &corsPolicy{
+		exact:       make(map[string]bool),
+		credentials: cfg.AllowCredentials,
+		headers:     make(map[string]bool, len(corsAllowedHeaders)),
+	}
+	if cfg.MaxAge > 0 {
+		p.maxAge = strconv.Itoa(int(cfg.MaxAge.Seconds()))
+	}
+	for _, header := range corsAllowedHeaders {
+		p.headers[strings.ToLower(header)] = true
+	}
+
+	for _, origin := range cfg.AllowedOrigins {
+		origin = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(origin), "/"))
+		switch {
+		case origin == "*":
+			p.anyOrigin = true
+		case strings.Contains(origin, "://*."):
+			scheme, domain, _ := strings.Cut(origin, "://*.")
+			p.suffixes = append(p.suffixes, originSuffix{scheme: scheme, domain: domain})
+		case origin != "":
+			p.exact[origin] = true
+		}
+	}
+
+	return p
+}
+
+func (p *corsPolicy) allows(origin string) bool {
+	origin = strings.ToLower(origin)
+	if origin == "null" {
+		// Sandboxed documents and file:// pages; never trusted implicitly.
+		return p.exact["null"]
+	}
+	if p.anyOrigin || p.exact[origin] {
+		return true
+	}
+
+	scheme, host, ok := strings.Cut(origin, "://")
+	if !ok {
+		return false
+	}
+	for _, suffix := range p.suffixes {
+		if scheme == suffix.scheme && strings.HasSuffix(host, "."+suffix.domain) &&
+			len(host) > len(suffix.domain)+1 {
+			return true
+		}
+	}
+	return false
+}
+
+// withCORS applies the CORS policy. Simple requests from allowed origins get
+// the allow headers; requests from other origins pass through untouched and
+// are blocked by the browser. Preflights are answered here: they succeed only
+// for an allowed origin, an existing route, a method the route accepts and
+// headers the API reads, and fail with 403 otherwise.
+func withCORS(cfg CORSConfig, routes *routeTable, next http.Handler) http.Handler {
+	policy := newCORSPolicy(cfg)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+		if preflight {
+			w.Header().Add("Vary", "Origin")
+			w.Header().Add("Vary", "Access-Control-Request-Method")
+			w.Header().Add("Vary", "Access-Control-Request-Headers")
+			policy.preflight(w, r, routes)
+			return
+		}
+
+		w.Header().Add("Vary", "Origin")
+		if origin != "" && policy.allows(origin) {
+			policy.setAllowOrigin(w, origin)
+			w.Header().Set("Access-Control-Expose-Headers", strings.Join(corsExposedHeaders, ", "))
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (p *corsPolicy) preflight(w http.ResponseWriter, r *http.Request, routes *routeTable) {
+	origin := r.Header.Get("Origin")
+	if origin == "" || !p.allows(origin) {
+		writeError(w, http.StatusForbidden, "origin not allowed")
+		return
+	}
+
+	pattern, methods := routes.allowedMethods(r)
+	if methods == nil {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	if info := requestInfoFrom(r); info != nil {
+		info.route = pattern
+	}
+
+	requested := r.Header.Get("Access-Control-Request-Method")
+	if !methodAllowed(requested, methods) {
+		writeError(w, http.StatusForbidden, "method not allowed by CORS policy")
+		return
+	}
+
+	headers, ok := p.requestedHeaders(r.Header.Values("Access-Control-Request-Headers"))
+	if !ok {
+		writeError(w, http.StatusForbidden, "header not allowed by CORS policy")
+		return
+	}
+
+	p.setAllowOrigin(w, origin)
+	w.Header().Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+	if len(headers) > 0 {
+		w.Header().Set("Access-Control-Allow-Headers", strings.Join(headers, ", "))
+	}
+	if p.maxAge != "" {
+		w.Header().Set("Access-Control-Max-Age", p.maxAge)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (p *corsPolicy) setAllowOrigin(w http.ResponseWriter, origin string) {
+	if p.anyOrigin && !p.credentials {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		return
+	}
+	w.Header().Set("Access-Control-Allow-Origin", origin)
+	if p.credentials {
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+// requestedHeaders parses Access-Control-Request-Headers and reports whether
+// every header is one the API accepts.
+func (p *corsPolicy) requestedHeaders(values []string) ([]string, bool) {
+	var headers []string
+	for _, value := range values {
+		for _, header := range strings.Split(value, ",") {
+			header = strings.ToLower(strings.TrimSpace(header))
+			if header == "" {
+				continue
+			}
+			if !p.headers[header] {
+				return nil, false
+			}
+			headers = append(headers, header)
+		}
+	}
+	sort.Strings(headers)
+	return headers, true
+}
+
+func methodAllowed(method string, methods []string) bool {
+	for _, m := range methods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
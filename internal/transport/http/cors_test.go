@@ -0,0 +1,240 @@
+package httptransport
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+)
+
+func newCORSTestHandler(cfg CORSConfig) http.Handler {
+	mux := http.NewServeMux()
+	routes := newRouteTable(mux, 0, nil)
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	routes.handle("/orders/{id}/approve", ok, http.MethodPost)
+	routes.handle("/suppliers/{id}", ok, http.MethodGet, http.MethodPut, http.MethodDelete)
+	return withCORS(cfg, routes, mux)
+}
+
+func TestCORSOriginMatching(t *testing.T) {
+	cfg := CORSConfig{AllowedOrigins: []string{"https://app.example.com", "https://*.example.com", "http://localhost:3000"}}
+
+	tests := []struct {
+		name   string
+		origin string
+		allow  bool
+	}{
+		{"exact match", "https://app.example.com", true},
+		{"exact match ignores case", "HTTPS://App.Example.com", true},
+		{"exact match with port", "http://localhost:3000", true},
+		{"port mismatch", "http://localhost:3001", false},
+		{"wildcard subdomain", "https://shop.example.com", true},
+		{"wildcard nested subdomain", "https://a.b.example.com", true},
+		{"wildcard does not match apex", "https://example.com", false},
+		{"lookalike domain", "https://evil-example.com", false},
+		{"lookalike subdomain of other domain", "https://example.com.evil.net", false},
+		{"wildcard scheme mismatch", "http://shop.example.com", false},
+		{"exact scheme mismatch", "http://app.example.com", false},
+		{"null origin", "null", false},
+		{"no scheme", "shop.example.com", false},
+	}
+	handler := newCORSTestHandler(cfg)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/orders/1/approve", nil)
+			req.Header.Set("Origin", tt.origin)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			got := rec.Header().Get("Access-Control-Allow-Origin")
+			if tt.allow && got != tt.origin {
+				t.Fatalf("Access-Control-Allow-Origin = %q, want %q", got, tt.origin)
+			}
+			if !tt.allow && got != "" {
+				t.Fatalf("Access-Control-Allow-Origin = %q, want none", got)
+			}
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want the request to reach the handler", rec.Code)
+			}
+		})
+	}
+}
+
+func TestCORSNullOriginAllowedExplicitly(t *testing.T) {
+	handler := newCORSTestHandler(CORSConfig{AllowedOrigins: []string{"*", "null"}})
+	req := httptest.NewRequest(http.MethodPost, "/orders/1/approve", nil)
+	req.Header.Set("Origin", "null")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Fatalf("Access-Control-Allow-Origin = %q, want *", got)
+	}
+
+	handler = newCORSTestHandler(CORSConfig{AllowedOrigins: []string{"*"}})
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Fatalf("wildcard allowed the null origin: %q", got)
+	}
+}
+
+func TestCORSPreflight(t *testing.T) {
+	cfg := CORSConfig{
+		AllowedOrigins: []string{"https://app.example.com"},
+		MaxAge:         10 * time.Minute,
+	}
+
+	tests := []struct {
+		name        string
+		origin      string
+		path        string
+		method      string
+		headers     string
+		wantStatus  int
+		wantMethods string
+		wantHeaders string
+	}{
+		{
+			name: "allowed", origin: "https://app.example.com", path: "/orders/1/approve",
+			method: http.MethodPost, headers: "Content-Type, Authorization",
+			wantStatus: http.StatusNoContent, wantMethods: "POST", wantHeaders: "authorization, content-type",
+		},
+		{
+			name: "per-route methods", origin: "https://app.example.com", path: "/suppliers/7",
+			method:     http.MethodDelete,
+			wantStatus: http.StatusNoContent, wantMethods: "GET, PUT, DELETE",
+		},
+		{
+			name: "method the route does not accept", origin: "https://app.example.com", path: "/orders/1/approve",
+			method: http.MethodDelete, wantStatus: http.StatusForbidden,
+		},
+		{
+			name: "disallowed origin", origin: "https://evil.example.net", path: "/orders/1/approve",
+			method: http.MethodPost, wantStatus: http.StatusForbidden,
+		},
+		{
+			name: "missing origin", path: "/orders/1/approve",
+			method: http.MethodPost, wantStatus: http.StatusForbidden,
+		},
+		{
+			name: "header the API does not read", origin: "https://app.example.com", path: "/orders/1/approve",
+			method: http.MethodPost, headers: "X-Custom", wantStatus: http.StatusForbidden,
+		},
+		{
+			name: "unknown route", origin: "https://app.example.com", path: "/nowhere",
+			method: http.MethodGet, wantStatus: http.StatusNotFound,
+		},
+	}
+
+	handler := newCORSTestHandler(cfg)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodOptions, tt.path, nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			req.Header.Set("Access-Control-Request-Method", tt.method)
+			if tt.headers != "" {
+				req.Header.Set("Access-Control-Request-Headers", tt.headers)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			vary := rec.Header().Values("Vary")
+			for _, want := range []string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"} {
+				if !slices.Contains(vary, want) {
+					t.Errorf("Vary = %v, missing %s", vary, want)
+				}
+			}
+			if tt.wantStatus != http.StatusNoContent {
+				if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "" {
+					t.Fatalf("rejected preflight set Access-Control-Allow-Origin = %q", got)
+				}
+				return
+			}
+
+			if got := rec.Header().Get("Access-Control-Allow-Origin"); got != tt.origin {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.origin)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Methods"); got != tt.wantMethods {
+				t.Errorf("Access-Control-Allow-Methods = %q, want %q", got, tt.wantMethods)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Headers"); got != tt.wantHeaders {
+				t.Errorf("Access-Control-Allow-Headers = %q, want %q", got, tt.wantHeaders)
+			}
+			if got := rec.Header().Get("Access-Control-Max-Age"); got != "600" {
+				t.Errorf("Access-Control-Max-Age = %q, want 600", got)
+			}
+		})
+	}
+}
+
+func TestCORSCredentials(t *testing.T) {
+	tests := []struct {
+		name            string
+		cfg             CORSConfig
+		wantOrigin      string
+		wantCredentials string
+	}{
+		{
+			name:            "credentials echo the origin",
+			cfg:             CORSConfig{AllowedOrigins: []string{"https://app.example.com"}, AllowCredentials: true},
+			wantOrigin:      "https://app.example.com",
+			wantCredentials: "true",
+		},
+		{
+			name:       "no credentials with exact origin",
+			cfg:        CORSConfig{AllowedOrigins: []string{"https://app.example.com"}},
+			wantOrigin: "https://app.example.com",
+		},
+		{
+			name:       "wildcard without credentials",
+			cfg:        CORSConfig{AllowedOrigins: []string{"*"}},
+			wantOrigin: "*",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/orders/1/approve", nil)
+			req.Header.Set("Origin", "https://app.example.com")
+			rec := httptest.NewRecorder()
+			newCORSTestHandler(tt.cfg).ServeHTTP(rec, req)
+
+			if got := rec.Header().Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.wantOrigin)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Credentials"); got != tt.wantCredentials {
+				t.Errorf("Access-Control-Allow-Credentials = %q, want %q", got, tt.wantCredentials)
+			}
+			if vary := rec.Header().Values("Vary"); !slices.Contains(vary, "Origin") {
+				t.Errorf("Vary = %v, want Origin so caches keep per-origin responses apart", vary)
+			}
+			if got := rec.Header().Get("Access-Control-Expose-Headers"); got == "" {
+				t.Error("Access-Control-Expose-Headers missing")
+			}
+		})
+	}
+}
+
+func TestCORSPreflightWithoutMaxAge(t *testing.T) {
+	handler := newCORSTestHandler(CORSConfig{AllowedOrigins: []string{"https://app.example.com"}})
+	req := httptest.NewRequest(http.MethodOptions, "/orders/1/approve", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusNoContent)
+	}
+	if got := rec.Header().Get("Access-Control-Max-Age"); got != "" {
+		t.Fatalf("Access-Control-Max-Age = %q, want none", got)
+	}
+}
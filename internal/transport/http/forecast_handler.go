@@ -0,0 +1,170 @@
+package httptransport
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"mmispoc/internal/forecast"
+	"mmispoc/internal/service"
+)
+
+// RestaurantForecastHandler handles GET /restaurants/{id}/forecast requests.
+type RestaurantForecastHandler struct {
+	auth            *Authenticator
+	forecastService *service.ForecastService
+}
+
+// NewRestaurantForecastHandler builds the demand forecast handler.
+func NewRestaurantForecastHandler(auth *Authenticator, forecastService *service.ForecastService) http.Handler {
+	return &RestaurantForecastHandler{
+		auth:            auth,
+		forecastService: forecastService,
+	}
+}
+
+func (h *RestaurantForecastHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	restaurantID, err := pathID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid restaurant id")
+		return
+	}
+
+	principal, ok := h.auth.Authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	var req service.ForecastRequest
+	if raw := query.Get("ingredient_id"); raw != "" {
+		id, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || id <= 0 {
+			writeError(w, http.StatusBadRequest, "invalid ingredient_id")
+			return
+		}
+		req.IngredientID = id
+	}
+	for name, dest := range map[string]*int{"history_days": &req.HistoryDays, "horizon": &req.Horizon, "backtest_days": &req.BacktestDays} {
+		if raw := query.Get(name); raw != "" {
+			value, err := strconv.Atoi(raw)
+			if err != nil || value < 0 {
+				writeError(w, http.StatusBadRequest, "invalid "+name)
+				return
+			}
+			*dest = value
+		}
+	}
+	if raw := query.Get("model"); raw != "" {
+		for _, name := range strings.Split(raw, ",") {
+			req.Models = append(req.Models, strings.TrimSpace(name))
+		}
+	}
+
+	forecasts, err := h.forecastService.Forecast(r.Context(), principal, restaurantID, req)
+	if err != nil {
+		handleForecastError(w, r, err)
+		return
+	}
+
+	result := make([]map[string]interface{}, 0, len(forecasts))
+	for i := range forecasts {
+		result = append(result, ingredientForecastDTO(&forecasts[i]))
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"restaurant_id": restaurantID,
+		"count":         len(result),
+		"ingredients":   result,
+	})
+}
+
+func handleForecastError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, service.ErrForbidden):
+		writeError(w, http.StatusForbidden, "restaurant is not yours or scope is missing")
+	case errors.Is(err, service.ErrInvalidRestaurantID):
+		writeError(w, http.StatusBadRequest, "invalid restaurant id")
+	case errors.Is(err, service.ErrRestaurantNotFound):
+		writeError(w, http.StatusNotFound, "restaurant not found")
+	case errors.Is(err, service.ErrInvalidForecast):
+		writeError(w, http.StatusBadRequest, "model must list moving_average, ewma or day_of_week; history_days may be at most 365, horizon at most 56 and backtest_days at most half of history_days")
+	default:
+		writeInternalError(w, r, err)
+	}
+}
+
+func ingredientForecastDTO(f *service.IngredientForecast) map[string]interface{} {
+	var (
+		total      float64
+		activeDays int
+	)
+	for _, value := range f.History.Values {
+		total += value
+		if value > 0 {
+			activeDays++
+		}
+	}
+
+	models := make([]map[string]interface{}, 0, len(f.Models))
+	for _, m := range f.Models {
+		daily := make([]map[string]interface{}, 0, len(m.Daily))
+		for day, quantity := range m.Daily {
+			daily = append(daily, map[string]interface{}{
+				"date":     m.Start.AddDate(0, 0, day).Format(time.DateOnly),
+				"quantity": quantity.String(),
+			})
+		}
+
+		dto := map[string]interface{}{
+			"model": m.Model,
+			"total": m.Total.String(),
+			"daily": daily,
+		}
+		if m.Backtest != nil {
+			dto["backtest"] = forecastMetricsDTO(m.Backtest)
+		}
+		models = append(models, dto)
+	}
+
+	dto := map[string]interface{}{
+		"ingredient_id":   f.IngredientID,
+		"ingredient_code": f.IngredientCode,
+		"ingredient_name": f.IngredientName,
+		"history": map[string]interface{}{
+			"from":        f.History.Start.Format(time.DateOnly),
+			"to":          f.History.End().AddDate(0, 0, -1).Format(time.DateOnly),
+			"total":       strconv.FormatFloat(total, 'f', -1, 64),
+			"days":        len(f.History.Values),
+			"active_days": activeDays,
+		},
+		"models": models,
+	}
+	if f.Best != "" {
+		dto["best_model"] = f.Best
+	}
+	return dto
+}
+
+func forecastMetricsDTO(metrics *forecast.Metrics) map[string]interface{} {
+	dto := map[string]interface{}{
+		"days": metrics.Days,
+		"mae":  decimal.NewFromFloat(metrics.MAE).StringFixed(3),
+		"rmse": decimal.NewFromFloat(metrics.RMSE).StringFixed(3),
+		"bias": decimal.NewFromFloat(metrics.Bias).StringFixed(3),
+	}
+	if !math.IsNaN(metrics.WAPE) {
+		dto["wape"] = decimal.NewFromFloat(metrics.WAPE).StringFixed(4)
+	}
+	return dto
+}
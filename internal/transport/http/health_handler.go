@@ -0,0 +1,70 @@
+package httptransport
+
+import (
+	"net/http"
+
+	"mmispoc/internal/health"
+	"mmispoc/internal/logging"
+)
+
+// LivenessHandler handles GET /healthz. It only proves the process can serve
+// requests and never consults dependencies, so a database outage does not get
+// the container restarted.
+type LivenessHandler struct{}
+
+// NewLivenessHandler builds a liveness handler.
+func NewLivenessHandler() http.Handler {
+	return &LivenessHandler{}
+}
+
+func (h *LivenessHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// ReadinessHandler handles GET /readyz by running the registered health checks.
+type ReadinessHandler struct {
+	registry *health.Registry
+}
+
+// NewReadinessHandler builds a readiness handler.
+func NewReadinessHandler(registry *health.Registry) http.Handler {
+	return &ReadinessHandler{registry: registry}
+}
+
+func (h *ReadinessHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	report := h.registry.Ready(r.Context())
+	if report.ShuttingDown {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "shutting down"})
+		return
+	}
+
+	checks := make(map[string]string, len(report.Results))
+	for _, result := range report.Results {
+		if result.Error != nil {
+			logging.FromContext(r.Context()).Warn("readiness check failed", "check", result.Name, "error", result.Error)
+			checks[result.Name] = "failing"
+			continue
+		}
+		checks[result.Name] = "ok"
+	}
+
+	status, code := "ok", http.StatusOK
+	if !report.Ready {
+		status, code = "unavailable", http.StatusServiceUnavailable
+	}
+
+	writeJSON(w, code, map[string]interface{}{
+		"status": status,
+		"checks": checks,
+	})
+}
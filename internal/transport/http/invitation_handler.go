@@ -0,0 +1,127 @@
+package httptransport
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"mmispoc/internal/repository"
+	"mmispoc/internal/service"
+)
+
+// InvitationsHandler handles GET and POST /invitations requests.
+type InvitationsHandler struct {
+	auth        *Authenticator
+	userService *service.UserService
+}
+
+// NewInvitationsHandler builds the invitation listing and creation handler.
+func NewInvitationsHandler(auth *Authenticator, userService *service.UserService) http.Handler {
+	return &InvitationsHandler{
+		auth:        auth,
+		userService: userService,
+	}
+}
+
+func (h *InvitationsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	principal, ok := h.auth.Authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		var restaurantID int64
+		if raw := r.URL.Query().Get("restaurant_id"); raw != "" {
+			parsed, err := strconv.ParseInt(raw, 10, 64)
+			if err != nil || parsed <= 0 {
+				writeError(w, http.StatusBadRequest, "invalid restaurant id")
+				return
+			}
+			restaurantID = parsed
+		}
+
+		invitations, err := h.userService.ListInvitations(r.Context(), principal, restaurantID)
+		if err != nil {
+			handleInvitationError(w, r, err)
+			return
+		}
+
+		result := make([]map[string]interface{}, 0, len(invitations))
+		for i := range invitations {
+			result = append(result, invitationDTO(&invitations[i]))
+		}
+
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"count":       len(result),
+			"invitations": result,
+		})
+		return
+	}
+
+	var payload struct {
+		RestaurantID int64  `json:"restaurant_id"`
+		Role         string `json:"role"`
+		ExpiresAt    string `json:"expires_at"`
+	}
+
+	if !decodeJSON(w, r, &payload) {
+		return
+	}
+
+	var expiresAt time.Time
+	if payload.ExpiresAt != "" {
+		parsed, err := time.Parse(time.RFC3339, payload.ExpiresAt)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid expires_at")
+			return
+		}
+		expiresAt = parsed
+	}
+
+	created, err := h.userService.CreateInvitation(r.Context(), principal, payload.RestaurantID, payload.Role, expiresAt)
+	if err != nil {
+		handleInvitationError(w, r, err)
+		return
+	}
+
+	response := invitationDTO(&created.Invitation)
+	response["code"] = created.Code
+	writeJSON(w, http.StatusCreated, response)
+}
+
+func handleInvitationError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, service.ErrForbidden):
+		writeError(w, http.StatusForbidden, "insufficient role")
+	case errors.Is(err, service.ErrInvalidRole):
+		writeError(w, http.StatusBadRequest, "invalid role")
+	case errors.Is(err, service.ErrInvalidExpiry):
+		writeError(w, http.StatusBadRequest, "expires_at must be in the future and within 30 days")
+	case errors.Is(err, service.ErrInvalidRestaurantID):
+		writeError(w, http.StatusBadRequest, "invalid restaurant id")
+	case errors.Is(err, service.ErrRestaurantNotFound):
+		writeError(w, http.StatusBadRequest, "restaurant not found")
+	default:
+		writeInternalError(w, r, err)
+	}
+}
+
+func invitationDTO(inv *repository.Invitation) map[string]interface{} {
+	dto := map[string]interface{}{
+		"id":            inv.ID,
+		"restaurant_id": inv.RestaurantID,
+		"role":          inv.Role,
+		"expires_at":    inv.ExpiresAt.Format(time.RFC3339),
+		"created_at":    inv.CreatedAt.Format(time.RFC3339),
+	}
+	if !inv.UsedAt.IsZero() {
+		dto["used_at"] = inv.UsedAt.Format(time.RFC3339)
+	}
+	return dto
+}
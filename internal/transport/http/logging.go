@@ -0,0 +1,94 @@
+package httptransport
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+
+	"mmispoc/internal/logging"
+)
+
+const requestIDHeader = "X-Request-ID"
+
+const maxRequestIDLength = 128
+
+// withRequestLogging assigns every request a correlation id, taken from a
+// well-formed X-Request-ID header or generated, echoes it in the response,
+// stores a logger tagged with it and the trace id in the request context and
+// writes an access log line once the request completes.
+func withRequestLogging(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID(id) {
+			id = logging.NewRequestID()
+		}
+		w.Header().Set(requestIDHeader, id)
+
+		logger := slog.Default().With("request_id", id)
+		if sc := trace.SpanContextFromContext(r.Context()); sc.IsValid() {
+			logger = logger.With("trace_id", sc.TraceID().String(), "span_id", sc.SpanID().String())
+		}
+		ctx := logging.WithRequestID(r.Context(), id)
+		ctx = logging.WithLogger(ctx, logger)
+		r = r.WithContext(ctx)
+
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		status := rec.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		attrs := []slog.Attr{
+			slog.String("method", r.Method),
+			slog.String("route", requestRoute(r)),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", status),
+			slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+		}
+		if info := requestInfoFrom(r); info != nil && info.principal != nil {
+			p := info.principal
+			switch {
+			case p.IsAPIKey():
+				attrs = append(attrs, slog.Int64("api_key_id", p.APIKeyID))
+			case p.IsUser():
+				attrs = append(attrs, slog.Int64("user_id", p.UserID))
+			}
+			if p.ClientSubject != "" {
+				attrs = append(attrs, slog.String("client_subject", p.ClientSubject))
+			}
+		}
+
+		level := slog.LevelInfo
+		if status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		logger.LogAttrs(r.Context(), level, "http request", attrs...)
+	})
+}
+
+// validRequestID accepts short ids made of visible ASCII so a client cannot
+// inject control characters into logs.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// writeInternalError logs the cause of a 500 with the request logger and
+// writes a generic error body.
+func writeInternalError(w http.ResponseWriter, r *http.Request, err error) {
+	logging.FromContext(r.Context()).Error("internal server error", "error", err)
+	writeError(w, http.StatusInternalServerError, "internal server error")
+}
@@ -1,7 +1,6 @@
 package httptransport
 
 import (
-	"encoding/json"
 	"errors"
 	"net/http"
 
@@ -29,22 +28,38 @@ func (h *LoginHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		Password string `json:"password"`
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
-		writeError(w, http.StatusBadRequest, "invalid JSON payload")
+	if !decodeJSON(w, r, &payload) {
 		return
 	}
 
-	token, err := h.userService.Authenticate(r.Context(), payload.Username, payload.Password)
+	result, err := h.userService.Authenticate(r.Context(), payload.Username, payload.Password)
 	if err != nil {
 		if errors.Is(err, service.ErrInvalidCredentials) {
 			writeError(w, http.StatusUnauthorized, "invalid username or password")
 			return
 		}
-		handleServiceError(w, err)
+		if errors.Is(err, service.ErrAccountDisabled) {
+			writeError(w, http.StatusForbidden, "account disabled")
+			return
+		}
+		handleServiceError(w, r, err)
 		return
 	}
 
-	writeJSON(w, http.StatusOK, map[string]string{
-		"access_token": token,
-	})
+	switch {
+	case result.ChallengeToken != "":
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"mfa_required": true,
+			"mfa_token":    result.ChallengeToken,
+		})
+	case result.EnrollmentToken != "":
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"mfa_enrollment_required": true,
+			"enrollment_token":        result.EnrollmentToken,
+		})
+	default:
+		writeJSON(w, http.StatusOK, map[string]string{
+			"access_token": result.AccessToken,
+		})
+	}
 }
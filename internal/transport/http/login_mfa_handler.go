@@ -0,0 +1,59 @@
+package httptransport
+
+import (
+	"errors"
+	"net/http"
+
+	"mmispoc/internal/service"
+)
+
+// LoginMFAHandler handles POST /login/mfa requests.
+type LoginMFAHandler struct {
+	userService *service.UserService
+}
+
+// NewLoginMFAHandler builds the second login step handler.
+func NewLoginMFAHandler(userService *service.UserService) http.Handler {
+	return &LoginMFAHandler{userService: userService}
+}
+
+func (h *LoginMFAHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	var payload struct {
+		MFAToken string `json:"mfa_token"`
+		Code     string `json:"code"`
+	}
+
+	if !decodeJSON(w, r, &payload) {
+		return
+	}
+
+	token, err := h.userService.CompleteMFALogin(r.Context(), payload.MFAToken, payload.Code)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidToken):
+			writeError(w, http.StatusUnauthorized, "invalid mfa token")
+		case errors.Is(err, service.ErrTokenExpired):
+			writeError(w, http.StatusUnauthorized, "mfa token expired")
+		case errors.Is(err, service.ErrAccountDisabled):
+			writeError(w, http.StatusForbidden, "account disabled")
+		case errors.Is(err, service.ErrTokenRevoked):
+			writeError(w, http.StatusUnauthorized, "mfa token revoked")
+		case errors.Is(err, service.ErrInvalidMFACode):
+			writeError(w, http.StatusUnauthorized, "invalid two-factor code")
+		case errors.Is(err, service.ErrMFAChallengeExhausted):
+			writeError(w, http.StatusUnauthorized, "mfa token used up; log in again")
+		default:
+			writeInternalError(w, r, err)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{
+		"access_token": token,
+	})
+}
@@ -0,0 +1,63 @@
+package httptransport
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"mmispoc/internal/metrics"
+)
+
+var (
+	httpRequests = metrics.Default.NewCounterVec("mmispoc_http_requests_total",
+		"HTTP requests served, by method, route template and status class.", "method", "route", "status")
+	httpDuration = metrics.Default.NewHistogramVec("mmispoc_http_request_duration_seconds",
+		"HTTP request latency, by method and route template.", nil, "method", "route")
+	rateLimited = metrics.Default.NewCounterVec("mmispoc_http_rate_limited_total",
+		"Requests rejected with 429, by route template.", "route")
+)
+
+// statusRecorder captures the status code written by a handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(p []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(p)
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// withMetrics records request counts and latency. Requests are labelled with
+// the route template rather than the raw path, which keeps ids such as
+// /order/123 out of the label values.
+func withMetrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+
+		next.ServeHTTP(rec, r)
+
+		route := requestRoute(r)
+		status := rec.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		httpRequests.Inc(r.Method, route, strconv.Itoa(status/100)+"xx")
+		httpDuration.Observe(time.Since(start).Seconds(), r.Method, route)
+	})
+}
@@ -0,0 +1,124 @@
+package httptransport
+
+import (
+	"errors"
+	"net/http"
+
+	"mmispoc/internal/repository"
+	"mmispoc/internal/service"
+)
+
+// MFAEnrollHandler handles POST /me/2fa/enroll requests.
+type MFAEnrollHandler struct {
+	userService *service.UserService
+}
+
+// NewMFAEnrollHandler builds the TOTP enrolment handler.
+func NewMFAEnrollHandler(userService *service.UserService) http.Handler {
+	return &MFAEnrollHandler{userService: userService}
+}
+
+func (h *MFAEnrollHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	user, ok := authenticateEnrollment(w, r, h.userService)
+	if !ok {
+		return
+	}
+
+	enrollment, err := h.userService.EnrollMFA(r.Context(), user)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrMFAAlreadyEnabled):
+			writeError(w, http.StatusConflict, "two-factor authentication already enabled")
+		default:
+			writeInternalError(w, r, err)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{
+		"secret":      enrollment.Secret,
+		"otpauth_uri": enrollment.URI,
+	})
+}
+
+// MFAConfirmHandler handles POST /me/2fa/confirm requests.
+type MFAConfirmHandler struct {
+	userService *service.UserService
+}
+
+// NewMFAConfirmHandler builds the TOTP confirmation handler.
+func NewMFAConfirmHandler(userService *service.UserService) http.Handler {
+	return &MFAConfirmHandler{userService: userService}
+}
+
+func (h *MFAConfirmHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	user, ok := authenticateEnrollment(w, r, h.userService)
+	if !ok {
+		return
+	}
+
+	var payload struct {
+		Code string `json:"code"`
+	}
+
+	if !decodeJSON(w, r, &payload) {
+		return
+	}
+
+	confirmation, err := h.userService.ConfirmMFA(r.Context(), user, payload.Code)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrMFANotEnrolled):
+			writeError(w, http.StatusConflict, "two-factor enrolment not started")
+		case errors.Is(err, service.ErrMFAAlreadyEnabled):
+			writeError(w, http.StatusConflict, "two-factor authentication already enabled")
+		case errors.Is(err, service.ErrInvalidMFACode):
+			writeError(w, http.StatusBadRequest, "invalid two-factor code")
+		default:
+			writeInternalError(w, r, err)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"recovery_codes": confirmation.RecoveryCodes,
+		"access_token":   confirmation.AccessToken,
+	})
+}
+
+func authenticateEnrollment(w http.ResponseWriter, r *http.Request, userService *service.UserService) (*repository.User, bool) {
+	token, ok := bearerToken(r)
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "missing or invalid authorization header")
+		return nil, false
+	}
+
+	user, err := userService.ValidateEnrollmentToken(r.Context(), token)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidToken):
+			writeError(w, http.StatusUnauthorized, "invalid token")
+		case errors.Is(err, service.ErrTokenExpired):
+			writeError(w, http.StatusUnauthorized, "token expired")
+		case errors.Is(err, service.ErrAccountDisabled):
+			writeError(w, http.StatusForbidden, "account disabled")
+		case errors.Is(err, service.ErrTokenRevoked):
+			writeError(w, http.StatusUnauthorized, "token revoked")
+		default:
+			writeInternalError(w, r, err)
+		}
+		return nil, false
+	}
+
+	return user, true
+}
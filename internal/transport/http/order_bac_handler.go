@@ -12,14 +12,14 @@ import (
 
 // OrderBACHandler exposes GET /order-bac/{id}.
 type OrderBACHandler struct {
-	userService  *service.UserService
+	auth         *Authenticator
 	orderService *service.OrderService
 }
 
 // NewOrderBACHandler builds a handler for broken access control testing.
-func NewOrderBACHandler(userService *service.UserService, orderService *service.OrderService) http.Handler {
+func NewOrderBACHandler(auth *Authenticator, orderService *service.OrderService) http.Handler {
 	return &OrderBACHandler{
-		userService:  userService,
+		auth:         auth,
 		orderService: orderService,
 	}
 }
@@ -36,27 +36,9 @@ func (h *OrderBACHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	authHeader := strings.TrimSpace(r.Header.Get("Authorization"))
-	const bearerPrefix = "Bearer "
-	if authHeader == "" || !strings.HasPrefix(authHeader, bearerPrefix) {
-		writeError(w, http.StatusUnauthorized, "missing or invalid authorization header")
+	if _, ok := h.auth.Authenticate(w, r); !ok {
 		return
 	}
-	token := strings.TrimSpace(authHeader[len(bearerPrefix):])
-
-	if _, err := h.userService.ValidateAccessToken(r.Context(), token); err != nil {
-		switch {
-		case errors.Is(err, service.ErrInvalidToken):
-			writeError(w, http.StatusUnauthorized, "invalid token")
-			return
-		case errors.Is(err, service.ErrTokenExpired):
-			writeError(w, http.StatusUnauthorized, "token expired")
-			return
-		default:
-			writeError(w, http.StatusInternalServerError, "internal server error")
-			return
-		}
-	}
 
 	orders, restaurantName, err := h.orderService.GetOrdersByRestaurant(r.Context(), restaurantID)
 	if err != nil {
@@ -66,31 +48,39 @@ func (h *OrderBACHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		case errors.Is(err, service.ErrOrderRestaurantNotFound):
 			writeError(w, http.StatusNotFound, "restaurant not found")
 		default:
-			writeError(w, http.StatusInternalServerError, "internal server error")
+			writeInternalError(w, r, err)
 		}
 		return
 	}
 
 	type orderDTO struct {
-		ID           int64  `json:"id"`
-		Code         string `json:"code"`
-		RestaurantID int64  `json:"restaurant_id"`
-		IngredientID int64  `json:"ingredient_id"`
-		Number       int    `json:"number"`
-		CreatedAt    string `json:"created_at"`
-		UpdatedAt    string `json:"updated_at,omitempty"`
+		ID              int64  `json:"id"`
+		Code            string `json:"code"`
+		RestaurantID    int64  `json:"restaurant_id"`
+		IngredientID    int64  `json:"ingredient_id"`
+		Number          int    `json:"number"`
+		SupplierID      int64  `json:"supplier_id,omitempty"`
+		PurchaseOrderID int64  `json:"purchase_order_id,omitempty"`
+		UnitPrice       string `json:"unit_price,omitempty"`
+		TaxRate         string `json:"tax_rate,omitempty"`
+		LineTotal       string `json:"line_total,omitempty"`
+		CreatedAt       string `json:"created_at"`
+		UpdatedAt       string `json:"updated_at,omitempty"`
 	}
 
 	response := make([]orderDTO, 0, len(orders))
 	for _, order := range orders {
 		dto := orderDTO{
-			ID:           order.ID,
-			Code:         order.Code,
-			RestaurantID: order.RestaurantID,
-			IngredientID: order.IngredientID,
-			Number:       order.Number,
-			CreatedAt:    order.CreatedAt.Format(time.RFC3339),
+			ID:              order.ID,
+			Code:            order.Code,
+			RestaurantID:    order.RestaurantID,
+			IngredientID:    order.IngredientID,
+			Number:          order.Number,
+			SupplierID:      order.SupplierID,
+			PurchaseOrderID: order.PurchaseOrderID,
+			CreatedAt:       order.CreatedAt.Format(time.RFC3339),
 		}
+		dto.UnitPrice, dto.TaxRate, dto.LineTotal = orderLinePrice(order)
 		if !order.UpdatedAt.IsZero() {
 			dto.UpdatedAt = order.UpdatedAt.Format(time.RFC3339)
 		}
@@ -12,14 +12,14 @@ import (
 
 // OrderDetailHandler handles GET /order/{id} requests where id is restaurant id.
 type OrderDetailHandler struct {
-	userService  *service.UserService
+	auth         *Authenticator
 	orderService *service.OrderService
 }
 
 // NewOrderDetailHandler builds a handler.
-func NewOrderDetailHandler(userService *service.UserService, orderService *service.OrderService) http.Handler {
+func NewOrderDetailHandler(auth *Authenticator, orderService *service.OrderService) http.Handler {
 	return &OrderDetailHandler{
-		userService:  userService,
+		auth:         auth,
 		orderService: orderService,
 	}
 }
@@ -36,28 +36,17 @@ func (h *OrderDetailHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	authHeader := strings.TrimSpace(r.Header.Get("Authorization"))
-	const bearerPrefix = "Bearer "
-	if authHeader == "" || !strings.HasPrefix(authHeader, bearerPrefix) {
-		writeError(w, http.StatusUnauthorized, "missing or invalid authorization header")
+	principal, ok := h.auth.Authenticate(w, r)
+	if !ok {
 		return
 	}
-	token := strings.TrimSpace(authHeader[len(bearerPrefix):])
 
-	user, err := h.userService.ValidateAccessToken(r.Context(), token)
-	if err != nil {
-		switch {
-		case errors.Is(err, service.ErrInvalidToken):
-			writeError(w, http.StatusUnauthorized, "invalid token")
-		case errors.Is(err, service.ErrTokenExpired):
-			writeError(w, http.StatusUnauthorized, "token expired")
-		default:
-			writeError(w, http.StatusInternalServerError, "internal server error")
-		}
+	if !principal.HasScope(service.ScopeOrdersRead) {
+		writeError(w, http.StatusForbidden, "insufficient scope")
 		return
 	}
 
-	if user.RestaurantID != 0 && user.RestaurantID != restaurantID {
+	if principal.RestaurantID != 0 && principal.RestaurantID != restaurantID {
 		writeError(w, http.StatusForbidden, "order data does not belong to your restaurant")
 		return
 	}
@@ -70,31 +59,39 @@ func (h *OrderDetailHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		case errors.Is(err, service.ErrOrderRestaurantNotFound):
 			writeError(w, http.StatusNotFound, "restaurant not found")
 		default:
-			writeError(w, http.StatusInternalServerError, "internal server error")
+			writeInternalError(w, r, err)
 		}
 		return
 	}
 
 	type orderDTO struct {
-		ID           int64  `json:"id"`
-		Code         string `json:"code"`
-		RestaurantID int64  `json:"restaurant_id"`
-		IngredientID int64  `json:"ingredient_id"`
-		Number       int    `json:"number"`
-		CreatedAt    string `json:"created_at"`
-		UpdatedAt    string `json:"updated_at,omitempty"`
+		ID              int64  `json:"id"`
+		Code            string `json:"code"`
+		RestaurantID    int64  `json:"restaurant_id"`
+		IngredientID    int64  `json:"ingredient_id"`
+		Number          int    `json:"number"`
+		SupplierID      int64  `json:"supplier_id,omitempty"`
+		PurchaseOrderID int64  `json:"purchase_order_id,omitempty"`
+		UnitPrice       string `json:"unit_price,omitempty"`
+		TaxRate         string `json:"tax_rate,omitempty"`
+		LineTotal       string `json:"line_total,omitempty"`
+		CreatedAt       string `json:"created_at"`
+		UpdatedAt       string `json:"updated_at,omitempty"`
 	}
 
 	result := make([]orderDTO, 0, len(orders))
 	for _, order := range orders {
 		dto := orderDTO{
-			ID:           order.ID,
-			Code:         order.Code,
-			RestaurantID: order.RestaurantID,
-			IngredientID: order.IngredientID,
-			Number:       order.Number,
-			CreatedAt:    order.CreatedAt.Format(time.RFC3339),
+			ID:              order.ID,
+			Code:            order.Code,
+			RestaurantID:    order.RestaurantID,
+			IngredientID:    order.IngredientID,
+			Number:          order.Number,
+			SupplierID:      order.SupplierID,
+			PurchaseOrderID: order.PurchaseOrderID,
+			CreatedAt:       order.CreatedAt.Format(time.RFC3339),
 		}
+		dto.UnitPrice, dto.TaxRate, dto.LineTotal = orderLinePrice(order)
 		if !order.UpdatedAt.IsZero() {
 			dto.UpdatedAt = order.UpdatedAt.Format(time.RFC3339)
 		}
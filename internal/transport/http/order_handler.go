@@ -1,25 +1,25 @@
 package httptransport
 
 import (
-	"encoding/json"
 	"errors"
 	"net/http"
-	"strings"
+	"time"
 
+	"mmispoc/internal/repository"
 	"mmispoc/internal/service"
 )
 
 // OrderCreateHandler handles POST /order/create requests.
 type OrderCreateHandler struct {
 	orderService *service.OrderService
-	userService  *service.UserService
+	auth         *Authenticator
 }
 
 // NewOrderCreateHandler builds an order handler.
-func NewOrderCreateHandler(userService *service.UserService, orderService *service.OrderService) http.Handler {
+func NewOrderCreateHandler(auth *Authenticator, orderService *service.OrderService) http.Handler {
 	return &OrderCreateHandler{
 		orderService: orderService,
-		userService:  userService,
+		auth:         auth,
 	}
 }
 
@@ -29,43 +29,26 @@ func (h *OrderCreateHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	authHeader := r.Header.Get("Authorization")
-	const bearerPrefix = "Bearer "
-	if authHeader == "" {
-		writeError(w, http.StatusUnauthorized, "missing or invalid authorization header")
-		return
-	}
-
-	authHeader = strings.TrimSpace(authHeader)
-	if !strings.HasPrefix(authHeader, bearerPrefix) {
-		writeError(w, http.StatusUnauthorized, "missing or invalid authorization header")
-		return
-	}
-	accessToken := strings.TrimSpace(authHeader[len(bearerPrefix):])
-
 	var payload struct {
 		RestaurantID int64 `json:"restaurant_id"`
 		Orders       []struct {
 			IngredientID int64 `json:"ingredient_id"`
 			Number       int   `json:"number"`
+			SupplierID   int64 `json:"supplier_id"`
 		} `json:"orders"`
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
-		writeError(w, http.StatusBadRequest, "invalid JSON payload")
+	if !decodeJSON(w, r, &payload) {
 		return
 	}
 
-	user, err := h.userService.ValidateAccessToken(r.Context(), accessToken)
-	if err != nil {
-		switch {
-		case errors.Is(err, service.ErrInvalidToken):
-			writeError(w, http.StatusUnauthorized, "invalid token")
-		case errors.Is(err, service.ErrTokenExpired):
-			writeError(w, http.StatusUnauthorized, "token expired")
-		default:
-			writeError(w, http.StatusInternalServerError, "internal server error")
-		}
+	principal, ok := h.auth.Authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	if !principal.HasScope(service.ScopeOrdersWrite) {
+		writeError(w, http.StatusForbidden, "insufficient scope")
 		return
 	}
 
@@ -74,19 +57,21 @@ func (h *OrderCreateHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		items = append(items, service.OrderItem{
 			IngredientID: row.IngredientID,
 			Number:       row.Number,
+			SupplierID:   row.SupplierID,
 		})
 	}
 
-	if user.RestaurantID != 0 && payload.RestaurantID != 0 && user.RestaurantID != payload.RestaurantID {
+	if principal.RestaurantID != 0 && payload.RestaurantID != 0 && principal.RestaurantID != payload.RestaurantID {
 		writeError(w, http.StatusForbidden, "restaurant mismatch")
 		return
 	}
 
-	if payload.RestaurantID == 0 && user.RestaurantID != 0 {
-		payload.RestaurantID = user.RestaurantID
+	if payload.RestaurantID == 0 && principal.RestaurantID != 0 {
+		payload.RestaurantID = principal.RestaurantID
 	}
 
-	if err := h.orderService.CreateOrders(r.Context(), payload.RestaurantID, items); err != nil {
+	placement, err := h.orderService.CreateOrders(r.Context(), payload.RestaurantID, principal.UserID, items)
+	if err != nil {
 		switch {
 		case errors.Is(err, service.ErrOrderInvalidRestaurantID):
 			writeError(w, http.StatusBadRequest, "invalid restaurant id")
@@ -100,13 +85,85 @@ func (h *OrderCreateHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			writeError(w, http.StatusBadRequest, "invalid number")
 		case errors.Is(err, service.ErrOrderIngredientNotFound):
 			writeError(w, http.StatusBadRequest, "ingredient not found")
+		case errors.Is(err, service.ErrOrderSupplierNotOffering),
+			errors.Is(err, service.ErrOrderBelowMinimum),
+			errors.Is(err, service.ErrOrderPackSize):
+			writeError(w, http.StatusBadRequest, err.Error())
 		default:
-			writeError(w, http.StatusInternalServerError, "internal server error")
+			writeInternalError(w, r, err)
 		}
 		return
 	}
 
-	writeJSON(w, http.StatusCreated, map[string]interface{}{
-		"created": len(items),
+	// Orders held for approval have been accepted but not placed yet.
+	status := http.StatusCreated
+	if placement.Status == repository.PurchaseOrderPendingApproval {
+		status = http.StatusAccepted
+	}
+
+	warnings := placement.Warnings
+	if warnings == nil {
+		warnings = []string{}
+	}
+
+	writeJSON(w, status, map[string]interface{}{
+		"created":         placement.Lines(),
+		"status":          placement.Status,
+		"warnings":        warnings,
+		"purchase_orders": purchaseOrderDTOs(placement),
+		"totals":          totalsDTO(placement.Totals),
 	})
 }
+
+// purchaseOrderDTOs renders the per-supplier breakdown of an order request.
+func purchaseOrderDTOs(placement *service.OrderPlacement) []map[string]interface{} {
+	type lineDTO struct {
+		ID           int64  `json:"id"`
+		Code         string `json:"code"`
+		IngredientID int64  `json:"ingredient_id"`
+		Number       int    `json:"number"`
+		Packs        int    `json:"packs"`
+		SupplierSKU  string `json:"supplier_sku,omitempty"`
+		UnitPrice    string `json:"unit_price,omitempty"`
+		TaxRate      string `json:"tax_rate,omitempty"`
+		LineTotal    string `json:"line_total,omitempty"`
+	}
+
+	result := make([]map[string]interface{}, 0, len(placement.PurchaseOrders))
+	for _, po := range placement.PurchaseOrders {
+		lines := make([]lineDTO, 0, len(po.Lines))
+		for i, line := range po.Lines {
+			terms := po.LineTerms[i]
+			dto := lineDTO{
+				ID:           line.ID,
+				Code:         line.Code,
+				IngredientID: line.IngredientID,
+				Number:       line.Number,
+				Packs:        line.Number / terms.PackSize,
+				SupplierSKU:  terms.SKU,
+			}
+			dto.UnitPrice, dto.TaxRate, dto.LineTotal = orderLinePrice(line)
+			lines = append(lines, dto)
+		}
+
+		dto := map[string]interface{}{
+			"id":          po.ID,
+			"code":        po.Code,
+			"supplier_id": po.SupplierID,
+			"status":      po.Status,
+			"lines":       lines,
+			"totals":      totalsDTO(po.Totals),
+		}
+		if po.SupplierName != "" {
+			dto["supplier_name"] = po.SupplierName
+		}
+		if len(po.Approvals) > 0 {
+			dto["approvals"] = approvalDTOs(po.Approvals)
+		}
+		if !po.ExpectedDeliveryOn.IsZero() {
+			dto["expected_delivery_on"] = po.ExpectedDeliveryOn.Format(time.DateOnly)
+		}
+		result = append(result, dto)
+	}
+	return result
+}
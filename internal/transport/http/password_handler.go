@@ -0,0 +1,66 @@
+package httptransport
+
+import (
+	"errors"
+	"net/http"
+
+	"mmispoc/internal/service"
+)
+
+// PasswordHandler handles POST /me/password requests.
+type PasswordHandler struct {
+	auth        *Authenticator
+	userService *service.UserService
+}
+
+// NewPasswordHandler builds the password change handler.
+func NewPasswordHandler(auth *Authenticator, userService *service.UserService) http.Handler {
+	return &PasswordHandler{
+		auth:        auth,
+		userService: userService,
+	}
+}
+
+func (h *PasswordHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	principal, ok := h.auth.Authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	if !principal.IsUser() {
+		writeError(w, http.StatusForbidden, "password change requires a user token")
+		return
+	}
+
+	var payload struct {
+		CurrentPassword string `json:"current_password"`
+		NewPassword     string `json:"new_password"`
+	}
+
+	if !decodeJSON(w, r, &payload) {
+		return
+	}
+
+	if err := h.userService.ChangePassword(r.Context(), principal.UserID, payload.CurrentPassword, payload.NewPassword); err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidPassword):
+			writeError(w, http.StatusBadRequest, "invalid password")
+		case errors.Is(err, service.ErrInvalidCredentials):
+			writeError(w, http.StatusUnauthorized, "current password is incorrect")
+		case errors.Is(err, service.ErrInvalidToken):
+			writeError(w, http.StatusUnauthorized, "invalid token")
+		default:
+			writeInternalError(w, r, err)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"password_changed": true,
+	})
+}
@@ -0,0 +1,216 @@
+package httptransport
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"mmispoc/internal/repository"
+	"mmispoc/internal/service"
+)
+
+// IngredientPricesHandler handles GET and POST /ingredients/{id}/prices requests.
+type IngredientPricesHandler struct {
+	auth           *Authenticator
+	pricingService *service.PricingService
+}
+
+// NewIngredientPricesHandler builds the price history handler.
+func NewIngredientPricesHandler(auth *Authenticator, pricingService *service.PricingService) http.Handler {
+	return &IngredientPricesHandler{
+		auth:           auth,
+		pricingService: pricingService,
+	}
+}
+
+func (h *IngredientPricesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	ingredientID, err := pathID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid ingredient id")
+		return
+	}
+
+	principal, ok := h.auth.Authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		prices, err := h.pricingService.ListPrices(r.Context(), principal, ingredientID)
+		if err != nil {
+			handlePricingError(w, r, err)
+			return
+		}
+
+		now := time.Now().UTC()
+		result := make([]map[string]interface{}, 0, len(prices))
+		for i := range prices {
+			dto := priceDTO(&prices[i])
+			dto["active"] = prices[i].ActiveAt(now)
+			result = append(result, dto)
+		}
+
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"ingredient_id": ingredientID,
+			"count":         len(result),
+			"prices":        result,
+		})
+		return
+	}
+
+	var payload struct {
+		Price         decimal.Decimal `json:"price"`
+		SupplierID    int64           `json:"supplier_id"`
+		EffectiveFrom string          `json:"effective_from"`
+		EffectiveTo   string          `json:"effective_to"`
+	}
+	if !decodeJSON(w, r, &payload) {
+		return
+	}
+
+	price := &repository.IngredientPrice{
+		IngredientID: ingredientID,
+		SupplierID:   payload.SupplierID,
+		Price:        payload.Price,
+	}
+	for _, field := range []struct {
+		name  string
+		value string
+		dest  *time.Time
+	}{
+		{"effective_from", payload.EffectiveFrom, &price.EffectiveFrom},
+		{"effective_to", payload.EffectiveTo, &price.EffectiveTo},
+	} {
+		if field.value == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, field.value)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid "+field.name)
+			return
+		}
+		*field.dest = parsed.UTC()
+	}
+
+	if err := h.pricingService.SetPrice(r.Context(), principal, price); err != nil {
+		handlePricingError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, priceDTO(price))
+}
+
+// IngredientTaxRateHandler handles PUT /ingredients/{id}/tax-rate requests.
+type IngredientTaxRateHandler struct {
+	auth           *Authenticator
+	pricingService *service.PricingService
+}
+
+// NewIngredientTaxRateHandler builds the tax rate handler.
+func NewIngredientTaxRateHandler(auth *Authenticator, pricingService *service.PricingService) http.Handler {
+	return &IngredientTaxRateHandler{
+		auth:           auth,
+		pricingService: pricingService,
+	}
+}
+
+func (h *IngredientTaxRateHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	ingredientID, err := pathID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid ingredient id")
+		return
+	}
+
+	principal, ok := h.auth.Authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	var payload struct {
+		TaxRate decimal.Decimal `json:"tax_rate"`
+	}
+	if !decodeJSON(w, r, &payload) {
+		return
+	}
+
+	if err := h.pricingService.SetTaxRate(r.Context(), principal, ingredientID, payload.TaxRate); err != nil {
+		handlePricingError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"ingredient_id": ingredientID,
+		"tax_rate":      payload.TaxRate.String(),
+	})
+}
+
+func handlePricingError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, service.ErrForbidden):
+		writeError(w, http.StatusForbidden, "only administrators can change prices")
+	case errors.Is(err, service.ErrIngredientNotFound):
+		writeError(w, http.StatusNotFound, "ingredient not found")
+	case errors.Is(err, service.ErrSupplierNotFound):
+		writeError(w, http.StatusBadRequest, "supplier not found")
+	case errors.Is(err, service.ErrInvalidPrice):
+		writeError(w, http.StatusBadRequest, "price must not be negative and effective_to must follow effective_from")
+	case errors.Is(err, service.ErrPriceNotAppendable):
+		writeError(w, http.StatusConflict, "price must start after the latest price")
+	case errors.Is(err, service.ErrInvalidTaxRate):
+		writeError(w, http.StatusBadRequest, "tax_rate must be at least 0 and below 1")
+	default:
+		writeInternalError(w, r, err)
+	}
+}
+
+func priceDTO(price *repository.IngredientPrice) map[string]interface{} {
+	dto := map[string]interface{}{
+		"id":             price.ID,
+		"ingredient_id":  price.IngredientID,
+		"price":          price.Price.String(),
+		"effective_from": price.EffectiveFrom.Format(time.RFC3339),
+		"created_at":     price.CreatedAt.Format(time.RFC3339),
+	}
+	if price.SupplierID != 0 {
+		dto["supplier_id"] = price.SupplierID
+	}
+	if !price.EffectiveTo.IsZero() {
+		dto["effective_to"] = price.EffectiveTo.Format(time.RFC3339)
+	}
+	return dto
+}
+
+// totalsDTO renders order totals as decimal strings so clients never parse
+// money into floats.
+func totalsDTO(totals service.OrderTotals) map[string]interface{} {
+	dto := map[string]interface{}{
+		"subtotal": totals.Subtotal.StringFixed(2),
+		"tax":      totals.Tax.StringFixed(2),
+		"total":    totals.Total.StringFixed(2),
+	}
+	if totals.Unpriced > 0 {
+		dto["unpriced_lines"] = totals.Unpriced
+	}
+	return dto
+}
+
+// orderLinePrice fills the snapshotted price fields of an order line DTO.
+func orderLinePrice(line repository.Order) (unitPrice, taxRate, lineTotal string) {
+	net, tax, ok := service.OrderLineAmounts(line)
+	if !ok {
+		return "", "", ""
+	}
+	return line.UnitPrice.Decimal.String(), line.TaxRate.Decimal.String(), net.Add(tax).StringFixed(2)
+}
@@ -3,7 +3,6 @@ package httptransport
 import (
 	"errors"
 	"net/http"
-	"strings"
 	"time"
 
 	"mmispoc/internal/service"
@@ -11,12 +10,16 @@ import (
 
 // ProfileHandler handles GET /profile requests.
 type ProfileHandler struct {
+	auth        *Authenticator
 	userService *service.UserService
 }
 
 // NewProfileHandler builds a profile handler.
-func NewProfileHandler(userService *service.UserService) http.Handler {
-	return &ProfileHandler{userService: userService}
+func NewProfileHandler(auth *Authenticator, userService *service.UserService) http.Handler {
+	return &ProfileHandler{
+		auth:        auth,
+		userService: userService,
+	}
 }
 
 func (h *ProfileHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -25,35 +28,23 @@ func (h *ProfileHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	authHeader := strings.TrimSpace(r.Header.Get("Authorization"))
-	const bearerPrefix = "Bearer "
-	if authHeader == "" || !strings.HasPrefix(authHeader, bearerPrefix) {
-		writeError(w, http.StatusUnauthorized, "missing or invalid authorization header")
+	principal, ok := h.auth.Authenticate(w, r)
+	if !ok {
 		return
 	}
 
-	token := strings.TrimSpace(authHeader[len(bearerPrefix):])
-
-	user, err := h.userService.ValidateAccessToken(r.Context(), token)
-	if err != nil {
-		switch {
-		case errors.Is(err, service.ErrInvalidToken):
-			writeError(w, http.StatusUnauthorized, "invalid token")
-		case errors.Is(err, service.ErrTokenExpired):
-			writeError(w, http.StatusUnauthorized, "token expired")
-		default:
-			writeError(w, http.StatusInternalServerError, "internal server error")
-		}
+	if !principal.IsUser() {
+		writeError(w, http.StatusForbidden, "profile requires a user token")
 		return
 	}
 
-	profile, err := h.userService.GetProfile(r.Context(), user.ID)
+	profile, err := h.userService.GetProfile(r.Context(), principal.UserID)
 	if err != nil {
 		switch {
 		case errors.Is(err, service.ErrRestaurantNotFound):
 			writeError(w, http.StatusNotFound, "restaurant not found")
 		default:
-			writeError(w, http.StatusInternalServerError, "internal server error")
+			writeInternalError(w, r, err)
 		}
 		return
 	}
@@ -0,0 +1,186 @@
+package httptransport
+
+import (
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"mmispoc/internal/logging"
+	"mmispoc/internal/ratelimit"
+	"mmispoc/internal/service"
+)
+
+// RateLimitConfig configures per-client token buckets. A nil Store disables
+// rate limiting.
+type RateLimitConfig struct {
+	Store ratelimit.Store
+	// Default applies to routes missing from Routes; a zero Count leaves
+	// them unlimited.
+	Default ratelimit.Limit
+	// Routes maps route patterns, as registered on the mux, to their limit.
+	Routes map[string]ratelimit.Limit
+	// TrustedProxies are the peers whose X-Forwarded-For header is believed.
+	TrustedProxies []netip.Prefix
+}
+
+// rateLimiter takes a token from the caller's bucket before a route runs.
+// Callers are identified the same way the Authenticator would, without the
+// token revocation check: a correctly signed bearer token is keyed by its
+// user id, a valid API key by its id and a verified client certificate by
+// its subject. Anything else is keyed by client IP so that inventing
+// credentials never buys a fresh bucket. API key requests are charged to
+// the client IP before the key is looked up, so a flood of invented keys
+// stops at the IP bucket instead of costing a query each.
+type rateLimiter struct {
+	cfg           RateLimitConfig
+	userService   *service.UserService
+	apiKeyService *service.APIKeyService
+}
+
+func newRateLimiter(cfg RateLimitConfig, userService *service.UserService, apiKeyService *service.APIKeyService) *rateLimiter {
+	return &rateLimiter{cfg: cfg, userService: userService, apiKeyService: apiKeyService}
+}
+
+// wrap returns next guarded by the limit configured for pattern, or next
+// itself when the route is unlimited.
+func (l *rateLimiter) wrap(pattern string, next http.Handler) http.Handler {
+	if l == nil || l.cfg.Store == nil {
+		return next
+	}
+	limit, ok := l.cfg.Routes[pattern]
+	if !ok {
+		limit = l.cfg.Default
+	}
+	if limit.Count <= 0 {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if apiKey := presentedAPIKey(r); apiKey != "" {
+			ip := "ip:" + clientIP(r, l.cfg.TrustedProxies)
+			if !l.take(w, r, pattern, ip, limit) {
+				return
+			}
+			// The key is verified in full, secret included, so that anyone
+			// who merely knows a key's public prefix cannot drain its
+			// bucket. The outcome is handed on to the Authenticator.
+			key, err := l.apiKeyService.Verify(r.Context(), apiKey)
+			switch {
+			case err == nil:
+				r = withVerifiedAPIKey(r, apiKey, key, nil)
+				if !l.take(w, r, pattern, "api_key:"+strconv.FormatInt(key.ID, 10), limit) {
+					return
+				}
+			case errors.Is(err, service.ErrInvalidAPIKey):
+				r = withVerifiedAPIKey(r, apiKey, nil, err)
+			default:
+				logging.FromContext(r.Context()).Warn("rate limit api key lookup failed", "route", pattern, "error", err)
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if l.take(w, r, pattern, l.clientKey(r), limit) {
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// take spends a token from the bucket of client for pattern and reports
+// whether the request may proceed, answering 429 when it may not.
+func (l *rateLimiter) take(w http.ResponseWriter, r *http.Request, pattern, client string, limit ratelimit.Limit) bool {
+	decision, err := l.cfg.Store.Take(r.Context(), pattern+"|"+client, limit)
+	if err != nil {
+		// Fail open: an unavailable store must not take the API down.
+		logging.FromContext(r.Context()).Warn("rate limit store failed", "route", pattern, "error", err)
+		return true
+	}
+
+	setRateLimitHeaders(w, limit, decision)
+	if !decision.Allowed {
+		rateLimited.Inc(pattern)
+		w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(decision.RetryAfter)))
+		writeError(w, http.StatusTooManyRequests, "rate limit exceeded")
+		return false
+	}
+	return true
+}
+
+// clientKey identifies a caller that presents no API key. Credential
+// precedence matches the Authenticator so a request is always charged to
+// the identity it acts as.
+func (l *rateLimiter) clientKey(r *http.Request) string {
+	if r.Header.Get("Authorization") != "" {
+		if token, ok := bearerToken(r); ok {
+			if userID, ok := l.userService.TokenSubject(token); ok {
+				return "user:" + strconv.FormatInt(userID, 10)
+			}
+		}
+	} else if subject := clientSubject(r); subject != "" {
+		return "cert:" + subject
+	}
+
+	return "ip:" + clientIP(r, l.cfg.TrustedProxies)
+}
+
+// clientIP returns the address of the peer, or, when the peer is a trusted
+// proxy, the right-most X-Forwarded-For hop that is not itself trusted.
+// Hops left of that are supplied by the client and cannot be believed.
+func clientIP(r *http.Request, trusted []netip.Prefix) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return host
+	}
+	addr = addr.Unmap()
+	if !isTrustedProxy(addr, trusted) {
+		return addr.String()
+	}
+
+	var hops []string
+	for _, value := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(value, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+		addr = hop.Unmap()
+		if !isTrustedProxy(addr, trusted) {
+			break
+		}
+	}
+	return addr.String()
+}
+
+func isTrustedProxy(addr netip.Addr, trusted []netip.Prefix) bool {
+	for _, prefix := range trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// setRateLimitHeaders advertises the bucket state using the IETF RateLimit
+// header fields.
+func setRateLimitHeaders(w http.ResponseWriter, limit ratelimit.Limit, d ratelimit.Decision) {
+	h := w.Header()
+	h.Set("RateLimit-Limit", strconv.Itoa(d.Limit))
+	h.Set("RateLimit-Remaining", strconv.Itoa(d.Remaining))
+	h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(d.Reset)))
+	h.Set("RateLimit-Policy", strconv.Itoa(limit.Count)+";w="+strconv.Itoa(ceilSeconds(limit.Period)))
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,390 @@
+package httptransport
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"mmispoc/internal/repository"
+	"mmispoc/internal/service"
+)
+
+// OrderReceiptsHandler handles GET and POST /orders/{id}/receipts requests,
+// where id is a purchase order id.
+type OrderReceiptsHandler struct {
+	auth             *Authenticator
+	receivingService *service.ReceivingService
+}
+
+// NewOrderReceiptsHandler builds the goods receiving handler.
+func NewOrderReceiptsHandler(auth *Authenticator, receivingService *service.ReceivingService) http.Handler {
+	return &OrderReceiptsHandler{
+		auth:             auth,
+		receivingService: receivingService,
+	}
+}
+
+func (h *OrderReceiptsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	purchaseOrderID, err := pathID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid order id")
+		return
+	}
+
+	principal, ok := h.auth.Authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		po, receipts, lines, err := h.receivingService.Receipts(r.Context(), principal, purchaseOrderID)
+		if err != nil {
+			handleReceivingError(w, r, err)
+			return
+		}
+
+		result := make([]map[string]interface{}, 0, len(receipts))
+		for i := range receipts {
+			result = append(result, goodsReceiptDTO(&receipts[i]))
+		}
+
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"purchase_order": receivedOrderDTO(po),
+			"lines":          receivableLineDTOs(lines),
+			"receipts":       result,
+		})
+		return
+	}
+
+	var payload struct {
+		Note  string `json:"note"`
+		Lines []struct {
+			OrderID      int64           `json:"order_id"`
+			IngredientID int64           `json:"ingredient_id"`
+			Received     decimal.Decimal `json:"received"`
+			Rejected     decimal.Decimal `json:"rejected"`
+			RejectReason string          `json:"reject_reason"`
+			LotNumber    string          `json:"lot_number"`
+			ExpiresOn    string          `json:"expires_on"`
+		} `json:"lines"`
+	}
+	if !decodeJSON(w, r, &payload) {
+		return
+	}
+
+	inputs := make([]service.ReceiptLineInput, 0, len(payload.Lines))
+	for _, line := range payload.Lines {
+		var expiresOn time.Time
+		if line.ExpiresOn != "" {
+			parsed, err := time.Parse(time.DateOnly, line.ExpiresOn)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "invalid expires_on, expected YYYY-MM-DD")
+				return
+			}
+			expiresOn = parsed
+		}
+		inputs = append(inputs, service.ReceiptLineInput{
+			OrderID:      line.OrderID,
+			IngredientID: line.IngredientID,
+			Received:     line.Received,
+			Rejected:     line.Rejected,
+			RejectReason: line.RejectReason,
+			LotNumber:    line.LotNumber,
+			ExpiresOn:    expiresOn,
+		})
+	}
+
+	receipt, po, err := h.receivingService.Receive(r.Context(), principal, purchaseOrderID, payload.Note, inputs)
+	if err != nil {
+		handleReceivingError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{
+		"purchase_order": receivedOrderDTO(po),
+		"receipt":        goodsReceiptDTO(receipt),
+	})
+}
+
+// OrderDeliverHandler handles POST /orders/{id}/deliver requests, where id
+// is a purchase order id. It receives everything still outstanding.
+type OrderDeliverHandler struct {
+	auth             *Authenticator
+	receivingService *service.ReceivingService
+}
+
+// NewOrderDeliverHandler builds the handler that receives orders in full.
+func NewOrderDeliverHandler(auth *Authenticator, receivingService *service.ReceivingService) http.Handler {
+	return &OrderDeliverHandler{
+		auth:             auth,
+		receivingService: receivingService,
+	}
+}
+
+func (h *OrderDeliverHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	purchaseOrderID, err := pathID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid order id")
+		return
+	}
+
+	principal, ok := h.auth.Authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	receipt, po, err := h.receivingService.ReceiveAll(r.Context(), principal, purchaseOrderID)
+	if err != nil {
+		handleReceivingError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"purchase_order": receivedOrderDTO(po),
+		"receipt":        goodsReceiptDTO(receipt),
+	})
+}
+
+// OrderCloseHandler handles POST /orders/{id}/close requests, where id is a
+// purchase order id. It settles whatever is still outstanding as not
+// delivered.
+type OrderCloseHandler struct {
+	auth             *Authenticator
+	receivingService *service.ReceivingService
+}
+
+// NewOrderCloseHandler builds the handler that closes short-shipped orders.
+func NewOrderCloseHandler(auth *Authenticator, receivingService *service.ReceivingService) http.Handler {
+	return &OrderCloseHandler{
+		auth:             auth,
+		receivingService: receivingService,
+	}
+}
+
+func (h *OrderCloseHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	purchaseOrderID, err := pathID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid order id")
+		return
+	}
+
+	principal, ok := h.auth.Authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	var payload struct {
+		Note string `json:"note"`
+	}
+	if r.ContentLength != 0 && !decodeJSON(w, r, &payload) {
+		return
+	}
+
+	receipt, po, err := h.receivingService.CloseShort(r.Context(), principal, purchaseOrderID, payload.Note)
+	if err != nil {
+		handleReceivingError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"purchase_order": receivedOrderDTO(po),
+		"receipt":        goodsReceiptDTO(receipt),
+	})
+}
+
+// ReceivingDiscrepanciesHandler handles GET /reports/receiving-discrepancies requests.
+type ReceivingDiscrepanciesHandler struct {
+	auth             *Authenticator
+	receivingService *service.ReceivingService
+}
+
+// NewReceivingDiscrepanciesHandler builds the supplier discrepancy report handler.
+func NewReceivingDiscrepanciesHandler(auth *Authenticator, receivingService *service.ReceivingService) http.Handler {
+	return &ReceivingDiscrepanciesHandler{
+		auth:             auth,
+		receivingService: receivingService,
+	}
+}
+
+func (h *ReceivingDiscrepanciesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	principal, ok := h.auth.Authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	var filter repository.DiscrepancyFilter
+	for name, dest := range map[string]*int64{"restaurant_id": &filter.RestaurantID, "supplier_id": &filter.SupplierID} {
+		if raw := query.Get(name); raw != "" {
+			id, err := strconv.ParseInt(raw, 10, 64)
+			if err != nil || id <= 0 {
+				writeError(w, http.StatusBadRequest, "invalid "+name)
+				return
+			}
+			*dest = id
+		}
+	}
+	for name, dest := range map[string]*time.Time{"from": &filter.From, "to": &filter.To} {
+		if raw := query.Get(name); raw != "" {
+			value, err := time.Parse(time.RFC3339, raw)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "invalid "+name+", expected RFC 3339")
+				return
+			}
+			*dest = value
+		}
+	}
+
+	report, err := h.receivingService.Discrepancies(r.Context(), principal, filter)
+	if err != nil {
+		handleReceivingError(w, r, err)
+		return
+	}
+
+	suppliers := make([]map[string]interface{}, 0, len(report))
+	for _, item := range report {
+		rejected := map[string]string{}
+		totalRejected := decimal.Zero
+		for reason, quantity := range item.Rejected {
+			rejected[reason] = quantity.String()
+			totalRejected = totalRejected.Add(quantity)
+		}
+
+		dto := map[string]interface{}{
+			"supplier_id":      item.SupplierID,
+			"purchase_orders":  item.PurchaseOrders,
+			"lines":            item.Lines,
+			"discrepant_lines": item.DiscrepantLines,
+			"ordered":          item.Ordered.String(),
+			"received":         item.Received.String(),
+			"rejected":         totalRejected.String(),
+			"rejected_by":      rejected,
+			"outstanding":      item.Ordered.Sub(item.Received).Sub(totalRejected).String(),
+		}
+		if item.SupplierName != "" {
+			dto["supplier_name"] = item.SupplierName
+		}
+		if item.Ordered.IsPositive() {
+			dto["fill_rate"] = item.Received.Div(item.Ordered).StringFixed(4)
+		}
+		suppliers = append(suppliers, dto)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"count":     len(suppliers),
+		"suppliers": suppliers,
+	})
+}
+
+func handleReceivingError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, service.ErrOrderInvalidID):
+		writeError(w, http.StatusBadRequest, "invalid order id")
+	case errors.Is(err, service.ErrOrderNotFound):
+		writeError(w, http.StatusNotFound, "order not found")
+	case errors.Is(err, service.ErrOrderForbidden):
+		writeError(w, http.StatusForbidden, "order does not belong to your restaurant")
+	case errors.Is(err, service.ErrForbidden):
+		writeError(w, http.StatusForbidden, "report is limited to your restaurant or scope is missing")
+	case errors.Is(err, service.ErrOrderNotReceivable):
+		writeError(w, http.StatusConflict, "order is not awaiting delivery")
+	case errors.Is(err, service.ErrInvalidReceipt):
+		writeError(w, http.StatusBadRequest, "each line needs an order_id or ingredient_id, non-negative received and rejected quantities that are not both zero, and a reject_reason of damaged, wrong_item, short_dated or not_delivered exactly when something is rejected")
+	case errors.Is(err, service.ErrReceiptLineNotFound), errors.Is(err, service.ErrReceiptExceedsOrder):
+		writeError(w, http.StatusBadRequest, err.Error())
+	default:
+		writeInternalError(w, r, err)
+	}
+}
+
+func receivedOrderDTO(po *repository.PurchaseOrder) map[string]interface{} {
+	dto := map[string]interface{}{
+		"id":            po.ID,
+		"code":          po.Code,
+		"restaurant_id": po.RestaurantID,
+		"supplier_id":   po.SupplierID,
+		"status":        po.Status,
+	}
+	if !po.DeliveredAt.IsZero() {
+		dto["delivered_at"] = po.DeliveredAt.Format(time.RFC3339)
+	}
+	return dto
+}
+
+func receivableLineDTOs(lines []repository.ReceivableLine) []map[string]interface{} {
+	result := make([]map[string]interface{}, 0, len(lines))
+	for _, line := range lines {
+		result = append(result, map[string]interface{}{
+			"order_id":      line.OrderID,
+			"ingredient_id": line.IngredientID,
+			"ordered":       line.Ordered.String(),
+			"received":      line.Received.String(),
+			"rejected":      line.Rejected.String(),
+			"outstanding":   line.Outstanding().String(),
+		})
+	}
+	return result
+}
+
+func goodsReceiptDTO(receipt *repository.GoodsReceipt) map[string]interface{} {
+	lines := make([]map[string]interface{}, 0, len(receipt.Lines))
+	for _, line := range receipt.Lines {
+		dto := map[string]interface{}{
+			"id":            line.ID,
+			"order_id":      line.OrderID,
+			"ingredient_id": line.IngredientID,
+			"received":      line.Received.String(),
+			"rejected":      line.Rejected.String(),
+		}
+		if line.RejectReason != "" {
+			dto["reject_reason"] = line.RejectReason
+		}
+		if line.LotNumber != "" {
+			dto["lot_number"] = line.LotNumber
+		}
+		if !line.ExpiresOn.IsZero() {
+			dto["expires_on"] = line.ExpiresOn.Format(time.DateOnly)
+		}
+		lines = append(lines, dto)
+	}
+
+	dto := map[string]interface{}{
+		"id":                receipt.ID,
+		"purchase_order_id": receipt.PurchaseOrderID,
+		"received_at":       receipt.ReceivedAt.Format(time.RFC3339),
+		"lines":             lines,
+	}
+	if receipt.Note != "" {
+		dto["note"] = receipt.Note
+	}
+	if receipt.ReceivedBy != 0 {
+		dto["received_by"] = receipt.ReceivedBy
+	}
+	if receipt.Movements != nil {
+		dto["movements"] = stockMovementDTOs(receipt.Movements)
+	}
+	return dto
+}
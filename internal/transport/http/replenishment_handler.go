@@ -0,0 +1,268 @@
+package httptransport
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"mmispoc/internal/repository"
+	"mmispoc/internal/service"
+)
+
+// RestaurantParLevelsHandler handles GET /restaurants/{id}/par-levels requests.
+type RestaurantParLevelsHandler struct {
+	auth                 *Authenticator
+	replenishmentService *service.ReplenishmentService
+}
+
+// NewRestaurantParLevelsHandler builds the handler that lists par levels.
+func NewRestaurantParLevelsHandler(auth *Authenticator, replenishmentService *service.ReplenishmentService) http.Handler {
+	return &RestaurantParLevelsHandler{
+		auth:                 auth,
+		replenishmentService: replenishmentService,
+	}
+}
+
+func (h *RestaurantParLevelsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	restaurantID, err := pathID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid restaurant id")
+		return
+	}
+
+	principal, ok := h.auth.Authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	positions, err := h.replenishmentService.ParLevels(r.Context(), principal, restaurantID)
+	if err != nil {
+		handleReplenishmentError(w, r, err)
+		return
+	}
+
+	result := make([]map[string]interface{}, 0, len(positions))
+	for _, position := range positions {
+		dto := parLevelDTO(&position.ParLevel)
+		dto["on_hand"] = position.OnHand.String()
+		dto["on_order"] = position.OnOrder.String()
+		dto["available"] = position.Available().String()
+		result = append(result, dto)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"restaurant_id": restaurantID,
+		"count":         len(result),
+		"par_levels":    result,
+	})
+}
+
+// RestaurantParLevelHandler handles PUT and DELETE
+// /restaurants/{id}/par-levels/{ingredient_id} requests.
+type RestaurantParLevelHandler struct {
+	auth                 *Authenticator
+	replenishmentService *service.ReplenishmentService
+}
+
+// NewRestaurantParLevelHandler builds the handler that sets and removes a par level.
+func NewRestaurantParLevelHandler(auth *Authenticator, replenishmentService *service.ReplenishmentService) http.Handler {
+	return &RestaurantParLevelHandler{
+		auth:                 auth,
+		replenishmentService: replenishmentService,
+	}
+}
+
+func (h *RestaurantParLevelHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut && r.Method != http.MethodDelete {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	restaurantID, err := pathID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid restaurant id")
+		return
+	}
+	ingredientID, err := strconv.ParseInt(strings.TrimSpace(r.PathValue("ingredient_id")), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid ingredient id")
+		return
+	}
+
+	principal, ok := h.auth.Authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	if r.Method == http.MethodDelete {
+		if err := h.replenishmentService.DeleteParLevel(r.Context(), principal, restaurantID, ingredientID); err != nil {
+			handleReplenishmentError(w, r, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"restaurant_id": restaurantID,
+			"ingredient_id": ingredientID,
+			"deleted":       true,
+		})
+		return
+	}
+
+	var payload struct {
+		Par          decimal.Decimal `json:"par"`
+		ReorderPoint decimal.Decimal `json:"reorder_point"`
+	}
+	if !decodeJSON(w, r, &payload) {
+		return
+	}
+
+	level := &repository.ParLevel{
+		RestaurantID: restaurantID,
+		IngredientID: ingredientID,
+		Par:          payload.Par,
+		ReorderPoint: payload.ReorderPoint,
+	}
+	if err := h.replenishmentService.SetParLevel(r.Context(), principal, level); err != nil {
+		handleReplenishmentError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, parLevelDTO(level))
+}
+
+// RestaurantOrderSuggestionsHandler handles GET
+// /restaurants/{id}/order-suggestions requests.
+type RestaurantOrderSuggestionsHandler struct {
+	auth                 *Authenticator
+	replenishmentService *service.ReplenishmentService
+}
+
+// NewRestaurantOrderSuggestionsHandler builds the replenishment suggestion handler.
+func NewRestaurantOrderSuggestionsHandler(auth *Authenticator, replenishmentService *service.ReplenishmentService) http.Handler {
+	return &RestaurantOrderSuggestionsHandler{
+		auth:                 auth,
+		replenishmentService: replenishmentService,
+	}
+}
+
+func (h *RestaurantOrderSuggestionsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	restaurantID, err := pathID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid restaurant id")
+		return
+	}
+
+	principal, ok := h.auth.Authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	suggestions, err := h.replenishmentService.Suggestions(r.Context(), principal, restaurantID)
+	if err != nil {
+		handleReplenishmentError(w, r, err)
+		return
+	}
+
+	// draft follows the POST /order/create payload so it can be submitted
+	// unchanged or after editing.
+	type draftLine struct {
+		IngredientID int64 `json:"ingredient_id"`
+		Number       int   `json:"number"`
+		SupplierID   int64 `json:"supplier_id,omitempty"`
+	}
+	draft := make([]draftLine, 0, len(suggestions))
+	result := make([]map[string]interface{}, 0, len(suggestions))
+	for _, suggestion := range suggestions {
+		draft = append(draft, draftLine{
+			IngredientID: suggestion.IngredientID,
+			Number:       suggestion.Number,
+			SupplierID:   suggestion.Terms.SupplierID,
+		})
+
+		dto := map[string]interface{}{
+			"ingredient_id":   suggestion.IngredientID,
+			"ingredient_code": suggestion.IngredientCode,
+			"ingredient_name": suggestion.IngredientName,
+			"par":             suggestion.Par.String(),
+			"reorder_point":   suggestion.ReorderPoint.String(),
+			"on_hand":         suggestion.OnHand.String(),
+			"on_order":        suggestion.OnOrder.String(),
+			"shortfall":       suggestion.Shortfall.String(),
+			"number":          suggestion.Number,
+			"packs":           suggestion.Packs(),
+			"pack_size":       suggestion.Terms.PackSize,
+			"min_order_qty":   suggestion.Terms.MinOrderQty,
+		}
+		if suggestion.Terms.SupplierID != 0 {
+			dto["supplier_id"] = suggestion.Terms.SupplierID
+			dto["supplier_name"] = suggestion.Terms.SupplierName
+		}
+		if suggestion.Terms.SKU != "" {
+			dto["supplier_sku"] = suggestion.Terms.SKU
+		}
+		if suggestion.Terms.Price.IsPositive() {
+			dto["unit_price"] = suggestion.Terms.Price.String()
+		}
+		result = append(result, dto)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"restaurant_id": restaurantID,
+		"count":         len(result),
+		"suggestions":   result,
+		"draft": map[string]interface{}{
+			"restaurant_id": restaurantID,
+			"orders":        draft,
+		},
+	})
+}
+
+func handleReplenishmentError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, service.ErrForbidden):
+		writeError(w, http.StatusForbidden, "restaurant is not yours, scope is missing or only managers may change par levels")
+	case errors.Is(err, service.ErrInvalidRestaurantID):
+		writeError(w, http.StatusBadRequest, "invalid restaurant id")
+	case errors.Is(err, service.ErrRestaurantNotFound):
+		writeError(w, http.StatusNotFound, "restaurant not found")
+	case errors.Is(err, service.ErrIngredientNotFound):
+		writeError(w, http.StatusBadRequest, "ingredient not found")
+	case errors.Is(err, service.ErrInvalidParLevel):
+		writeError(w, http.StatusBadRequest, "par must be positive and reorder_point between 0 and par")
+	case errors.Is(err, service.ErrParLevelNotFound):
+		writeError(w, http.StatusNotFound, "par level not found")
+	default:
+		writeInternalError(w, r, err)
+	}
+}
+
+func parLevelDTO(level *repository.ParLevel) map[string]interface{} {
+	dto := map[string]interface{}{
+		"restaurant_id": level.RestaurantID,
+		"ingredient_id": level.IngredientID,
+		"par":           level.Par.String(),
+		"reorder_point": level.ReorderPoint.String(),
+		"updated_at":    level.UpdatedAt.Format(time.RFC3339),
+	}
+	if level.IngredientCode != "" {
+		dto["ingredient_code"] = level.IngredientCode
+		dto["ingredient_name"] = level.IngredientName
+	}
+	if level.UpdatedBy != 0 {
+		dto["updated_by"] = level.UpdatedBy
+	}
+	return dto
+}
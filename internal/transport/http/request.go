@@ -0,0 +1,65 @@
+package httptransport
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+)
+
+const (
+	// defaultMaxBodyBytes applies when the router is configured without a limit.
+	defaultMaxBodyBytes = 1 << 20
+	// sensitiveMaxBodyBytes bounds credential payloads, which are always small.
+	sensitiveMaxBodyBytes = 16 << 10
+)
+
+// limitBody rejects requests whose body exceeds limit. Declared lengths are
+// refused up front; chunked bodies fail while being decoded.
+func limitBody(limit int64, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength > limit {
+			writeError(w, http.StatusRequestEntityTooLarge, "request body too large")
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// decodeJSON strictly decodes a single JSON object from the request body into
+// dst. It writes the error response itself and reports whether decoding
+// succeeded: 415 for a missing or non-JSON content type, 413 for an oversized
+// body and 400 for malformed JSON, unknown fields or trailing data.
+func decodeJSON(w http.ResponseWriter, r *http.Request, dst interface{}) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		writeError(w, http.StatusUnsupportedMediaType, "content type must be application/json")
+		return false
+	}
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(dst); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, http.StatusRequestEntityTooLarge, "request body too large")
+			return false
+		}
+		writeError(w, http.StatusBadRequest, "invalid JSON payload")
+		return false
+	}
+
+	if err := dec.Decode(&struct{}{}); err != io.EOF {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, http.StatusRequestEntityTooLarge, "request body too large")
+			return false
+		}
+		writeError(w, http.StatusBadRequest, "request body must contain a single JSON object")
+		return false
+	}
+
+	return true
+}
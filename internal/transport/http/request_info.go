@@ -0,0 +1,58 @@
+package httptransport
+
+import (
+	"context"
+	"net/http"
+
+	"mmispoc/internal/service"
+)
+
+type requestInfoKey struct{}
+
+// requestInfo is filled in while a request is handled and read by the
+// middlewares once the handler returns. It is shared through the context
+// because each middleware that derives a context works on its own copy of
+// the *http.Request and would not see fields such as Pattern set further in.
+type requestInfo struct {
+	route     string
+	principal *service.Principal
+}
+
+// withRequestInfo attaches an empty requestInfo to the request context.
+func withRequestInfo(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), requestInfoKey{}, &requestInfo{})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// withRouteCapture wraps the mux and records the pattern that matched.
+func withRouteCapture(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mux.ServeHTTP(w, r)
+		if info := requestInfoFrom(r); info != nil {
+			info.route = r.Pattern
+		}
+	})
+}
+
+func requestInfoFrom(r *http.Request) *requestInfo {
+	info, _ := r.Context().Value(requestInfoKey{}).(*requestInfo)
+	return info
+}
+
+// requestRoute returns the route template of a handled request, never the raw
+// path, so it is safe to use as a metric label or span name.
+func requestRoute(r *http.Request) string {
+	if info := requestInfoFrom(r); info != nil && info.route != "" {
+		return info.route
+	}
+	return "unmatched"
+}
+
+// setRequestPrincipal records the authenticated principal for the access log.
+func setRequestPrincipal(r *http.Request, principal *service.Principal) {
+	if info := requestInfoFrom(r); info != nil {
+		info.principal = principal
+	}
+}
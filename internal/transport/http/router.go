@@ -1,30 +1,138 @@
 package httptransport
 
 import (
+	"log/slog"
 	"net/http"
 
+	"mmispoc/internal/health"
+	"mmispoc/internal/metrics"
 	"mmispoc/internal/service"
 )
 
+// RouterConfig tunes the middleware applied to every route.
+type RouterConfig struct {
+	CORS CORSConfig
+	// MaxBodyBytes limits request bodies; larger requests get 413.
+	MaxBodyBytes int64
+	// ClientCertScopes are granted to internal callers authenticated by a
+	// verified TLS client certificate alone.
+	ClientCertScopes []string
+	RateLimit        RateLimitConfig
+}
+
 // NewRouter wires HTTP routes.
-func NewRouter(userService *service.UserService, orderService *service.OrderService) http.Handler {
+func NewRouter(userService *service.UserService, orderService *service.OrderService, apiKeyService *service.APIKeyService, supplierService *service.SupplierService, pricingService *service.PricingService, budgetService *service.BudgetService, approvalService *service.ApprovalService, stockService *service.StockService, receivingService *service.ReceivingService, stocktakeService *service.StocktakeService, replenishmentService *service.ReplenishmentService, forecastService *service.ForecastService, healthRegistry *health.Registry, cfg RouterConfig) http.Handler {
 	mux := http.NewServeMux()
 
+	routes := newRouteTable(mux, cfg.MaxBodyBytes, newRateLimiter(cfg.RateLimit, userService, apiKeyService))
+	auth := NewAuthenticator(userService, apiKeyService, cfg.ClientCertScopes)
+
 	signupHandler := NewSignupHandler(userService)
 	loginHandler := NewLoginHandler(userService)
-	orderCreateHandler := NewOrderCreateHandler(userService, orderService)
-	orderBACHandler := NewOrderBACHandler(userService, orderService)
-	orderDetailHandler := NewOrderDetailHandler(userService, orderService)
-	profileHandler := NewProfileHandler(userService)
-
-	mux.Handle("/signup", signupHandler)
-	mux.Handle("/login", loginHandler)
-	mux.Handle("/profile", profileHandler)
-	mux.Handle("/order/create", orderCreateHandler)
-	mux.Handle("/order/", orderDetailHandler)
-	mux.Handle("/order-bac/", orderBACHandler)
-
-	return withDefaultHeaders(mux)
+	loginMFAHandler := NewLoginMFAHandler(userService)
+	mfaEnrollHandler := NewMFAEnrollHandler(userService)
+	mfaConfirmHandler := NewMFAConfirmHandler(userService)
+	orderCreateHandler := NewOrderCreateHandler(auth, orderService)
+	orderBACHandler := NewOrderBACHandler(auth, orderService)
+	orderDetailHandler := NewOrderDetailHandler(auth, orderService)
+	profileHandler := NewProfileHandler(auth, userService)
+	apiKeysHandler := NewAPIKeysHandler(auth, apiKeyService)
+	apiKeyRevokeHandler := NewAPIKeyRevokeHandler(auth, apiKeyService)
+	passwordHandler := NewPasswordHandler(auth, userService)
+	invitationsHandler := NewInvitationsHandler(auth, userService)
+	adminUsersHandler := NewAdminUsersHandler(auth, userService)
+	adminUserDeactivateHandler := NewAdminUserStatusHandler(auth, userService, true)
+	adminUserReactivateHandler := NewAdminUserStatusHandler(auth, userService, false)
+	adminUserRestaurantHandler := NewAdminUserRestaurantHandler(auth, userService)
+	adminUserDeleteHandler := NewAdminUserDeleteHandler(auth, userService)
+	suppliersHandler := NewSuppliersHandler(auth, supplierService)
+	supplierHandler := NewSupplierHandler(auth, supplierService)
+	supplierIngredientsHandler := NewSupplierIngredientsHandler(auth, supplierService)
+	supplierIngredientHandler := NewSupplierIngredientHandler(auth, supplierService)
+	ingredientPricesHandler := NewIngredientPricesHandler(auth, pricingService)
+	ingredientTaxRateHandler := NewIngredientTaxRateHandler(auth, pricingService)
+	restaurantBudgetHandler := NewRestaurantBudgetHandler(auth, budgetService)
+	approvalRulesHandler := NewApprovalRulesHandler(auth, approvalService)
+	approvalRuleHandler := NewApprovalRuleHandler(auth, approvalService)
+	approvalInboxHandler := NewApprovalInboxHandler(auth, approvalService)
+	orderApproveHandler := NewOrderDecisionHandler(auth, approvalService, true)
+	orderRejectHandler := NewOrderDecisionHandler(auth, approvalService, false)
+	orderDeliverHandler := NewOrderDeliverHandler(auth, receivingService)
+	orderReceiptsHandler := NewOrderReceiptsHandler(auth, receivingService)
+	orderCloseHandler := NewOrderCloseHandler(auth, receivingService)
+	receivingDiscrepanciesHandler := NewReceivingDiscrepanciesHandler(auth, receivingService)
+	restaurantStockHandler := NewRestaurantStockHandler(auth, stockService)
+	restaurantStockMovementsHandler := NewRestaurantStockMovementsHandler(auth, stockService)
+	restaurantStockExpiringHandler := NewRestaurantStockExpiringHandler(auth, stockService)
+	stockRecallHandler := NewStockRecallHandler(auth, stockService)
+	restaurantStocktakesHandler := NewRestaurantStocktakesHandler(auth, stocktakeService)
+	restaurantStocktakeVariancesHandler := NewRestaurantStocktakeVariancesHandler(auth, stocktakeService)
+	stocktakeHandler := NewStocktakeHandler(auth, stocktakeService)
+	stocktakeCountsHandler := NewStocktakeCountsHandler(auth, stocktakeService)
+	stocktakePostHandler := NewStocktakePostHandler(auth, stocktakeService)
+	stocktakeCancelHandler := NewStocktakeCancelHandler(auth, stocktakeService)
+	restaurantParLevelsHandler := NewRestaurantParLevelsHandler(auth, replenishmentService)
+	restaurantParLevelHandler := NewRestaurantParLevelHandler(auth, replenishmentService)
+	restaurantOrderSuggestionsHandler := NewRestaurantOrderSuggestionsHandler(auth, replenishmentService)
+	restaurantForecastHandler := NewRestaurantForecastHandler(auth, forecastService)
+
+	routes.handle("/healthz", NewLivenessHandler(), http.MethodGet, http.MethodHead)
+	routes.handle("/readyz", NewReadinessHandler(healthRegistry), http.MethodGet, http.MethodHead)
+	routes.handleSensitive("/signup", signupHandler, http.MethodPost)
+	routes.handleSensitive("/login", loginHandler, http.MethodPost)
+	routes.handleSensitive("/login/mfa", loginMFAHandler, http.MethodPost)
+	routes.handleSensitive("/me/2fa/enroll", mfaEnrollHandler, http.MethodPost)
+	routes.handleSensitive("/me/2fa/confirm", mfaConfirmHandler, http.MethodPost)
+	routes.handleSensitive("/me/password", passwordHandler, http.MethodPost)
+	routes.handleSensitive("/profile", profileHandler, http.MethodGet)
+	routes.handle("/order/create", orderCreateHandler, http.MethodPost)
+	routes.handle("/order/", orderDetailHandler, http.MethodGet)
+	routes.handle("/order-bac/", orderBACHandler, http.MethodGet)
+	routes.handleSensitive("/api-keys", apiKeysHandler, http.MethodGet, http.MethodPost)
+	routes.handle("/api-keys/{id}", apiKeyRevokeHandler, http.MethodDelete)
+	routes.handleSensitive("/invitations", invitationsHandler, http.MethodGet, http.MethodPost)
+	routes.handle("/admin/users", adminUsersHandler, http.MethodGet)
+	routes.handle("/admin/users/{id}", adminUserDeleteHandler, http.MethodDelete)
+	routes.handle("/admin/users/{id}/deactivate", adminUserDeactivateHandler, http.MethodPost)
+	routes.handle("/admin/users/{id}/reactivate", adminUserReactivateHandler, http.MethodPost)
+	routes.handle("/admin/users/{id}/restaurant", adminUserRestaurantHandler, http.MethodPut)
+	routes.handle("/suppliers", suppliersHandler, http.MethodGet, http.MethodPost)
+	routes.handle("/suppliers/{id}", supplierHandler, http.MethodGet, http.MethodPut, http.MethodDelete)
+	routes.handle("/suppliers/{id}/ingredients", supplierIngredientsHandler, http.MethodGet)
+	routes.handle("/suppliers/{id}/ingredients/{ingredient_id}", supplierIngredientHandler, http.MethodPut, http.MethodDelete)
+	routes.handle("/ingredients/{id}/prices", ingredientPricesHandler, http.MethodGet, http.MethodPost)
+	routes.handle("/ingredients/{id}/tax-rate", ingredientTaxRateHandler, http.MethodPut)
+	routes.handle("/restaurants/{id}/budget", restaurantBudgetHandler, http.MethodGet, http.MethodPut, http.MethodDelete)
+	routes.handle("/approval-rules", approvalRulesHandler, http.MethodGet, http.MethodPost)
+	routes.handle("/approval-rules/{id}", approvalRuleHandler, http.MethodGet, http.MethodPut, http.MethodDelete)
+	routes.handle("/approvals", approvalInboxHandler, http.MethodGet)
+	routes.handle("/orders/{id}/approve", orderApproveHandler, http.MethodPost)
+	routes.handle("/orders/{id}/reject", orderRejectHandler, http.MethodPost)
+	routes.handle("/orders/{id}/deliver", orderDeliverHandler, http.MethodPost)
+	routes.handle("/orders/{id}/receipts", orderReceiptsHandler, http.MethodGet, http.MethodPost)
+	routes.handle("/orders/{id}/close", orderCloseHandler, http.MethodPost)
+	routes.handle("/reports/receiving-discrepancies", receivingDiscrepanciesHandler, http.MethodGet)
+	routes.handle("/restaurants/{id}/stock", restaurantStockHandler, http.MethodGet)
+	routes.handle("/restaurants/{id}/stock/movements", restaurantStockMovementsHandler, http.MethodGet, http.MethodPost)
+	routes.handle("/restaurants/{id}/stock/expiring", restaurantStockExpiringHandler, http.MethodGet)
+	routes.handle("/stock/recall", stockRecallHandler, http.MethodGet)
+	routes.handle("/restaurants/{id}/stocktakes", restaurantStocktakesHandler, http.MethodGet, http.MethodPost)
+	routes.handle("/restaurants/{id}/stocktakes/variances", restaurantStocktakeVariancesHandler, http.MethodGet)
+	routes.handle("/stocktakes/{id}", stocktakeHandler, http.MethodGet)
+	routes.handle("/stocktakes/{id}/counts", stocktakeCountsHandler, http.MethodPost)
+	routes.handle("/stocktakes/{id}/post", stocktakePostHandler, http.MethodPost)
+	routes.handle("/stocktakes/{id}/cancel", stocktakeCancelHandler, http.MethodPost)
+	routes.handle("/restaurants/{id}/par-levels", restaurantParLevelsHandler, http.MethodGet)
+	routes.handle("/restaurants/{id}/par-levels/{ingredient_id}", restaurantParLevelHandler, http.MethodPut, http.MethodDelete)
+	routes.handle("/restaurants/{id}/order-suggestions", restaurantOrderSuggestionsHandler, http.MethodGet)
+	routes.handle("/restaurants/{id}/forecast", restaurantForecastHandler, http.MethodGet)
+	routes.handle("/metrics", metrics.Default.Handler(), http.MethodGet, http.MethodHead)
+
+	for _, pattern := range routes.unknownPatterns() {
+		slog.Warn("rate limit configured for unknown route", "route", pattern)
+	}
+
+	return withRequestInfo(withTracing(withRequestLogging(withMetrics(withDefaultHeaders(withSecurityHeaders(withCORS(cfg.CORS, routes, withRouteCapture(mux))))))))
 }
 
 func withDefaultHeaders(next http.Handler) http.Handler {
@@ -0,0 +1,62 @@
+package httptransport
+
+import "net/http"
+
+// routeTable registers handlers on the mux together with the methods they
+// accept, which is the information CORS preflights are answered from. Every
+// handler is wrapped with its rate limit and the request body limit.
+type routeTable struct {
+	mux          *http.ServeMux
+	methods      map[string][]string
+	maxBodyBytes int64
+	limiter      *rateLimiter
+}
+
+func newRouteTable(mux *http.ServeMux, maxBodyBytes int64, limiter *rateLimiter) *routeTable {
+	if maxBodyBytes <= 0 {
+		maxBodyBytes = defaultMaxBodyBytes
+	}
+	return &routeTable{mux: mux, methods: make(map[string][]string), maxBodyBytes: maxBodyBytes, limiter: limiter}
+}
+
+func (t *routeTable) handle(pattern string, handler http.Handler, methods ...string) {
+	t.mux.Handle(pattern, t.limiter.wrap(pattern, limitBody(t.maxBodyBytes, handler)))
+	t.methods[pattern] = methods
+}
+
+// handleSensitive registers a route that accepts or returns credentials: its
+// responses are never cached and its body limit is much smaller.
+func (t *routeTable) handleSensitive(pattern string, handler http.Handler, methods ...string) {
+	limit := int64(sensitiveMaxBodyBytes)
+	if t.maxBodyBytes < limit {
+		limit = t.maxBodyBytes
+	}
+	t.mux.Handle(pattern, t.limiter.wrap(pattern, noStore(limitBody(limit, handler))))
+	t.methods[pattern] = methods
+}
+
+// allowedMethods returns the methods of the route matching r, or nil when no
+// route matches.
+func (t *routeTable) allowedMethods(r *http.Request) (string, []string) {
+	_, pattern := t.mux.Handler(r)
+	methods, ok := t.methods[pattern]
+	if !ok {
+		return "", nil
+	}
+	return pattern, methods
+}
+
+// unknownPatterns returns the configured rate limit routes that were never
+// registered, which almost always means a typo in the configuration.
+func (t *routeTable) unknownPatterns() []string {
+	if t.limiter == nil {
+		return nil
+	}
+	var unknown []string
+	for pattern := range t.limiter.cfg.Routes {
+		if _, ok := t.methods[pattern]; !ok {
+			unknown = append(unknown, pattern)
+		}
+	}
+	return unknown
+}
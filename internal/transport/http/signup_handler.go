@@ -3,7 +3,6 @@ package httptransport
 import (
 	"encoding/json"
 	"errors"
-	"log"
 	"net/http"
 
 	"mmispoc/internal/service"
@@ -26,20 +25,19 @@ func (h *SignupHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var payload struct {
-		Username     string `json:"username"`
-		Password     string `json:"password"`
-		RestaurantID int64  `json:"restaurant_id"`
+		Username       string `json:"username"`
+		Password       string `json:"password"`
+		InvitationCode string `json:"invitation_code"`
+		RestaurantID   int64  `json:"restaurant_id"`
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
-		writeError(w, http.StatusBadRequest, "invalid JSON payload")
+	if !decodeJSON(w, r, &payload) {
 		return
 	}
 
-	user, err := h.userService.SignUp(r.Context(), payload.Username, payload.Password, payload.RestaurantID)
+	user, err := h.userService.SignUp(r.Context(), payload.Username, payload.Password, payload.InvitationCode, payload.RestaurantID)
 	if err != nil {
-		log.Printf("error: %v", err)
-		handleServiceError(w, err)
+		handleServiceError(w, r, err)
 		return
 	}
 
@@ -47,10 +45,11 @@ func (h *SignupHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		"id":            user.ID,
 		"username":      user.Username,
 		"restaurant_id": user.RestaurantID,
+		"role":          user.Role,
 	})
 }
 
-func handleServiceError(w http.ResponseWriter, err error) {
+func handleServiceError(w http.ResponseWriter, r *http.Request, err error) {
 	switch {
 	case errors.Is(err, service.ErrInvalidUsername):
 		writeError(w, http.StatusBadRequest, "invalid username")
@@ -62,8 +61,12 @@ func handleServiceError(w http.ResponseWriter, err error) {
 		writeError(w, http.StatusBadRequest, "restaurant not found")
 	case errors.Is(err, service.ErrUsernameTaken):
 		writeError(w, http.StatusConflict, "username already exists")
+	case errors.Is(err, service.ErrInvitationRequired):
+		writeError(w, http.StatusBadRequest, "invitation code required")
+	case errors.Is(err, service.ErrInvalidInvitation):
+		writeError(w, http.StatusBadRequest, "invalid or expired invitation code")
 	default:
-		writeError(w, http.StatusInternalServerError, "internal server error")
+		writeInternalError(w, r, err)
 	}
 }
 
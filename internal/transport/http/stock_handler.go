@@ -0,0 +1,432 @@
+package httptransport
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"mmispoc/internal/repository"
+	"mmispoc/internal/service"
+)
+
+// RestaurantStockHandler handles GET /restaurants/{id}/stock requests.
+type RestaurantStockHandler struct {
+	auth         *Authenticator
+	stockService *service.StockService
+}
+
+// NewRestaurantStockHandler builds the stock on hand handler.
+func NewRestaurantStockHandler(auth *Authenticator, stockService *service.StockService) http.Handler {
+	return &RestaurantStockHandler{
+		auth:         auth,
+		stockService: stockService,
+	}
+}
+
+func (h *RestaurantStockHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	restaurantID, err := pathID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid restaurant id")
+		return
+	}
+
+	principal, ok := h.auth.Authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	filter := repository.StockFilter{
+		Type:    query.Get("type"),
+		Query:   query.Get("q"),
+		InStock: query.Get("in_stock") == "true",
+	}
+	for _, raw := range query["ingredient_id"] {
+		for _, part := range strings.Split(raw, ",") {
+			id, err := strconv.ParseInt(strings.TrimSpace(part), 10, 64)
+			if err != nil || id <= 0 {
+				writeError(w, http.StatusBadRequest, "invalid ingredient id")
+				return
+			}
+			filter.IngredientIDs = append(filter.IngredientIDs, id)
+		}
+	}
+
+	levels, err := h.stockService.Levels(r.Context(), principal, restaurantID, filter)
+	if err != nil {
+		handleStockError(w, r, err)
+		return
+	}
+
+	result := make([]map[string]interface{}, 0, len(levels))
+	for _, level := range levels {
+		result = append(result, map[string]interface{}{
+			"ingredient_id":   level.IngredientID,
+			"ingredient_code": level.IngredientCode,
+			"ingredient_name": level.IngredientName,
+			"ingredient_type": level.IngredientType,
+			"on_hand":         level.OnHand.String(),
+			"updated_at":      level.UpdatedAt.Format(time.RFC3339),
+		})
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"restaurant_id": restaurantID,
+		"count":         len(result),
+		"stock":         result,
+	})
+}
+
+// RestaurantStockMovementsHandler handles GET and POST
+// /restaurants/{id}/stock/movements requests.
+type RestaurantStockMovementsHandler struct {
+	auth         *Authenticator
+	stockService *service.StockService
+}
+
+// NewRestaurantStockMovementsHandler builds the stock ledger handler.
+func NewRestaurantStockMovementsHandler(auth *Authenticator, stockService *service.StockService) http.Handler {
+	return &RestaurantStockMovementsHandler{
+		auth:         auth,
+		stockService: stockService,
+	}
+}
+
+func (h *RestaurantStockMovementsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	restaurantID, err := pathID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid restaurant id")
+		return
+	}
+
+	principal, ok := h.auth.Authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		filter, ok := movementFilter(w, r)
+		if !ok {
+			return
+		}
+
+		movements, err := h.stockService.Movements(r.Context(), principal, restaurantID, filter)
+		if err != nil {
+			handleStockError(w, r, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"restaurant_id": restaurantID,
+			"count":         len(movements),
+			"movements":     stockMovementDTOs(movements),
+		})
+		return
+	}
+
+	var payload struct {
+		IngredientID   int64           `json:"ingredient_id"`
+		Kind           string          `json:"kind"`
+		Quantity       decimal.Decimal `json:"quantity"`
+		ToRestaurantID int64           `json:"to_restaurant_id"`
+		LotID          int64           `json:"lot_id"`
+		LotNumber      string          `json:"lot_number"`
+		ExpiresOn      string          `json:"expires_on"`
+		Note           string          `json:"note"`
+	}
+	if !decodeJSON(w, r, &payload) {
+		return
+	}
+
+	var expiresOn time.Time
+	if payload.ExpiresOn != "" {
+		parsed, err := time.Parse(time.DateOnly, payload.ExpiresOn)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid expires_on, expected YYYY-MM-DD")
+			return
+		}
+		expiresOn = parsed
+	}
+
+	movements, err := h.stockService.Record(r.Context(), principal, restaurantID, service.StockMovementInput{
+		IngredientID:   payload.IngredientID,
+		Kind:           payload.Kind,
+		Quantity:       payload.Quantity,
+		ToRestaurantID: payload.ToRestaurantID,
+		LotID:          payload.LotID,
+		LotNumber:      payload.LotNumber,
+		ExpiresOn:      expiresOn,
+		Note:           payload.Note,
+	})
+	if err != nil {
+		handleStockError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{
+		"movements": stockMovementDTOs(movements),
+	})
+}
+
+func movementFilter(w http.ResponseWriter, r *http.Request) (repository.MovementFilter, bool) {
+	query := r.URL.Query()
+	filter := repository.MovementFilter{Kind: query.Get("kind")}
+
+	for name, dest := range map[string]*int64{"ingredient_id": &filter.IngredientID, "lot_id": &filter.LotID} {
+		if raw := query.Get(name); raw != "" {
+			id, err := strconv.ParseInt(raw, 10, 64)
+			if err != nil || id <= 0 {
+				writeError(w, http.StatusBadRequest, "invalid "+name)
+				return filter, false
+			}
+			*dest = id
+		}
+	}
+	for name, dest := range map[string]*time.Time{"from": &filter.From, "to": &filter.To} {
+		if raw := query.Get(name); raw != "" {
+			value, err := time.Parse(time.RFC3339, raw)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "invalid "+name+", expected RFC 3339")
+				return filter, false
+			}
+			*dest = value
+		}
+	}
+	for name, dest := range map[string]*int{"limit": &filter.Limit, "offset": &filter.Offset} {
+		if raw := query.Get(name); raw != "" {
+			value, err := strconv.Atoi(raw)
+			if err != nil || value < 0 {
+				writeError(w, http.StatusBadRequest, "invalid "+name)
+				return filter, false
+			}
+			*dest = value
+		}
+	}
+
+	return filter, true
+}
+
+// RestaurantStockExpiringHandler handles GET /restaurants/{id}/stock/expiring requests.
+type RestaurantStockExpiringHandler struct {
+	auth         *Authenticator
+	stockService *service.StockService
+}
+
+// NewRestaurantStockExpiringHandler builds the expiring-soon handler.
+func NewRestaurantStockExpiringHandler(auth *Authenticator, stockService *service.StockService) http.Handler {
+	return &RestaurantStockExpiringHandler{
+		auth:         auth,
+		stockService: stockService,
+	}
+}
+
+func (h *RestaurantStockExpiringHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	restaurantID, err := pathID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid restaurant id")
+		return
+	}
+
+	principal, ok := h.auth.Authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	days := service.DefaultExpiryWindowDays
+	if raw := query.Get("days"); raw != "" {
+		days, err = strconv.Atoi(raw)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid days")
+			return
+		}
+	}
+	var ingredientID int64
+	if raw := query.Get("ingredient_id"); raw != "" {
+		ingredientID, err = strconv.ParseInt(raw, 10, 64)
+		if err != nil || ingredientID <= 0 {
+			writeError(w, http.StatusBadRequest, "invalid ingredient id")
+			return
+		}
+	}
+
+	lots, err := h.stockService.Expiring(r.Context(), principal, restaurantID, ingredientID, days)
+	if err != nil {
+		handleStockError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"restaurant_id": restaurantID,
+		"days":          days,
+		"count":         len(lots),
+		"lots":          stockLotDTOs(lots),
+	})
+}
+
+// StockRecallHandler handles GET /stock/recall requests.
+type StockRecallHandler struct {
+	auth         *Authenticator
+	stockService *service.StockService
+}
+
+// NewStockRecallHandler builds the lot recall handler.
+func NewStockRecallHandler(auth *Authenticator, stockService *service.StockService) http.Handler {
+	return &StockRecallHandler{
+		auth:         auth,
+		stockService: stockService,
+	}
+}
+
+func (h *StockRecallHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	principal, ok := h.auth.Authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	var ingredientID int64
+	if raw := query.Get("ingredient_id"); raw != "" {
+		id, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || id <= 0 {
+			writeError(w, http.StatusBadRequest, "invalid ingredient id")
+			return
+		}
+		ingredientID = id
+	}
+
+	lots, err := h.stockService.Recall(r.Context(), principal, query.Get("lot_number"), ingredientID, query.Get("include_empty") == "true")
+	if err != nil {
+		handleStockError(w, r, err)
+		return
+	}
+
+	restaurants := make(map[int64]bool)
+	for _, lot := range lots {
+		restaurants[lot.RestaurantID] = true
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"lot_number":  query.Get("lot_number"),
+		"restaurants": len(restaurants),
+		"count":       len(lots),
+		"lots":        stockLotDTOs(lots),
+	})
+}
+
+func handleStockError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, service.ErrForbidden):
+		writeError(w, http.StatusForbidden, "stock does not belong to your restaurant or scope is missing")
+	case errors.Is(err, service.ErrInvalidRestaurantID):
+		writeError(w, http.StatusBadRequest, "invalid restaurant id")
+	case errors.Is(err, service.ErrRestaurantNotFound):
+		writeError(w, http.StatusNotFound, "restaurant not found")
+	case errors.Is(err, service.ErrIngredientNotFound):
+		writeError(w, http.StatusBadRequest, "ingredient not found")
+	case errors.Is(err, service.ErrInvalidStockMovement):
+		writeError(w, http.StatusBadRequest, "kind must be receipt, consumption, waste, transfer or adjustment; quantity must be positive (or non-zero for adjustments); transfers need another to_restaurant_id; lot_number and expires_on only describe new stock without a lot_id")
+	case errors.Is(err, service.ErrLotNotFound):
+		writeError(w, http.StatusBadRequest, "lot not found for this ingredient and restaurant")
+	case errors.Is(err, service.ErrInvalidLotQuery):
+		writeError(w, http.StatusBadRequest, "lot_number is required and days must be between 0 and 365")
+	case errors.Is(err, service.ErrInsufficientStock):
+		writeError(w, http.StatusConflict, "insufficient stock on hand")
+	default:
+		writeInternalError(w, r, err)
+	}
+}
+
+func stockMovementDTOs(movements []repository.StockMovement) []map[string]interface{} {
+	result := make([]map[string]interface{}, 0, len(movements))
+	for _, m := range movements {
+		dto := map[string]interface{}{
+			"id":            m.ID,
+			"restaurant_id": m.RestaurantID,
+			"ingredient_id": m.IngredientID,
+			"kind":          m.Kind,
+			"quantity":      m.Quantity.String(),
+			"created_at":    m.CreatedAt.Format(time.RFC3339),
+		}
+		if m.CounterpartRestaurantID != 0 {
+			dto["counterpart_restaurant_id"] = m.CounterpartRestaurantID
+		}
+		if m.PurchaseOrderID != 0 {
+			dto["purchase_order_id"] = m.PurchaseOrderID
+			dto["order_id"] = m.OrderID
+		}
+		if m.GoodsReceiptID != 0 {
+			dto["goods_receipt_id"] = m.GoodsReceiptID
+		}
+		if m.StocktakeID != 0 {
+			dto["stocktake_id"] = m.StocktakeID
+		}
+		if m.LotID != 0 {
+			dto["lot_id"] = m.LotID
+			if m.LotNumber != "" {
+				dto["lot_number"] = m.LotNumber
+			}
+			if !m.ExpiresOn.IsZero() {
+				dto["expires_on"] = m.ExpiresOn.Format(time.DateOnly)
+			}
+		}
+		if m.Note != "" {
+			dto["note"] = m.Note
+		}
+		if m.CreatedBy != 0 {
+			dto["created_by"] = m.CreatedBy
+		}
+		result = append(result, dto)
+	}
+	return result
+}
+
+func stockLotDTOs(lots []repository.StockLot) []map[string]interface{} {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+
+	result := make([]map[string]interface{}, 0, len(lots))
+	for _, lot := range lots {
+		dto := map[string]interface{}{
+			"id":              lot.ID,
+			"restaurant_id":   lot.RestaurantID,
+			"restaurant_name": lot.RestaurantName,
+			"ingredient_id":   lot.IngredientID,
+			"ingredient_code": lot.IngredientCode,
+			"ingredient_name": lot.IngredientName,
+			"lot_number":      lot.LotNumber,
+			"on_hand":         lot.OnHand.String(),
+			"received_at":     lot.ReceivedAt.Format(time.RFC3339),
+			"updated_at":      lot.UpdatedAt.Format(time.RFC3339),
+		}
+		if !lot.ExpiresOn.IsZero() {
+			dto["expires_on"] = lot.ExpiresOn.Format(time.DateOnly)
+			dto["expired"] = lot.ExpiresOn.Before(today)
+		}
+		result = append(result, dto)
+	}
+	return result
+}
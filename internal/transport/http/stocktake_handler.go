@@ -0,0 +1,450 @@
+package httptransport
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"mmispoc/internal/repository"
+	"mmispoc/internal/service"
+)
+
+// RestaurantStocktakesHandler handles GET and POST /restaurants/{id}/stocktakes requests.
+type RestaurantStocktakesHandler struct {
+	auth             *Authenticator
+	stocktakeService *service.StocktakeService
+}
+
+// NewRestaurantStocktakesHandler builds the handler that lists and opens stocktakes.
+func NewRestaurantStocktakesHandler(auth *Authenticator, stocktakeService *service.StocktakeService) http.Handler {
+	return &RestaurantStocktakesHandler{
+		auth:             auth,
+		stocktakeService: stocktakeService,
+	}
+}
+
+func (h *RestaurantStocktakesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	restaurantID, err := pathID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid restaurant id")
+		return
+	}
+
+	principal, ok := h.auth.Authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		query := r.URL.Query()
+		filter := repository.StocktakeFilter{Status: query.Get("status")}
+		for name, dest := range map[string]*int{"limit": &filter.Limit, "offset": &filter.Offset} {
+			if raw := query.Get(name); raw != "" {
+				value, err := strconv.Atoi(raw)
+				if err != nil || value < 0 {
+					writeError(w, http.StatusBadRequest, "invalid "+name)
+					return
+				}
+				*dest = value
+			}
+		}
+
+		stocktakes, err := h.stocktakeService.List(r.Context(), principal, restaurantID, filter)
+		if err != nil {
+			handleStocktakeError(w, r, err)
+			return
+		}
+
+		result := make([]map[string]interface{}, 0, len(stocktakes))
+		for i := range stocktakes {
+			dto := stocktakeDTO(&stocktakes[i])
+			dto["summary"] = stocktakeSummaryDTO(stocktakes[i].Summary)
+			result = append(result, dto)
+		}
+
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"restaurant_id": restaurantID,
+			"count":         len(result),
+			"stocktakes":    result,
+		})
+		return
+	}
+
+	var payload struct {
+		Note string `json:"note"`
+	}
+	if !decodeJSON(w, r, &payload) {
+		return
+	}
+
+	st, err := h.stocktakeService.Open(r.Context(), principal, restaurantID, payload.Note)
+	if err != nil {
+		handleStocktakeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, stocktakeDetailDTO(st))
+}
+
+// StocktakeHandler handles GET /stocktakes/{id} requests.
+type StocktakeHandler struct {
+	auth             *Authenticator
+	stocktakeService *service.StocktakeService
+}
+
+// NewStocktakeHandler builds the handler that shows a stocktake with its variances.
+func NewStocktakeHandler(auth *Authenticator, stocktakeService *service.StocktakeService) http.Handler {
+	return &StocktakeHandler{
+		auth:             auth,
+		stocktakeService: stocktakeService,
+	}
+}
+
+func (h *StocktakeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	id, err := pathID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid stocktake id")
+		return
+	}
+
+	principal, ok := h.auth.Authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	st, err := h.stocktakeService.Get(r.Context(), principal, id)
+	if err != nil {
+		handleStocktakeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, stocktakeDetailDTO(st))
+}
+
+// StocktakeCountsHandler handles POST /stocktakes/{id}/counts requests.
+type StocktakeCountsHandler struct {
+	auth             *Authenticator
+	stocktakeService *service.StocktakeService
+}
+
+// NewStocktakeCountsHandler builds the handler that records counts from a device.
+func NewStocktakeCountsHandler(auth *Authenticator, stocktakeService *service.StocktakeService) http.Handler {
+	return &StocktakeCountsHandler{
+		auth:             auth,
+		stocktakeService: stocktakeService,
+	}
+}
+
+func (h *StocktakeCountsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	id, err := pathID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid stocktake id")
+		return
+	}
+
+	principal, ok := h.auth.Authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	var payload struct {
+		Device string `json:"device"`
+		Counts []struct {
+			IngredientID int64           `json:"ingredient_id"`
+			Quantity     decimal.Decimal `json:"quantity"`
+		} `json:"counts"`
+	}
+	if !decodeJSON(w, r, &payload) {
+		return
+	}
+
+	counts := make([]repository.StocktakeCount, 0, len(payload.Counts))
+	for _, count := range payload.Counts {
+		counts = append(counts, repository.StocktakeCount{
+			IngredientID: count.IngredientID,
+			Quantity:     count.Quantity,
+		})
+	}
+
+	st, err := h.stocktakeService.SubmitCounts(r.Context(), principal, id, payload.Device, counts)
+	if err != nil {
+		handleStocktakeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, stocktakeDetailDTO(st))
+}
+
+// StocktakePostHandler handles POST /stocktakes/{id}/post requests.
+type StocktakePostHandler struct {
+	auth             *Authenticator
+	stocktakeService *service.StocktakeService
+}
+
+// NewStocktakePostHandler builds the handler that posts stocktake variances.
+func NewStocktakePostHandler(auth *Authenticator, stocktakeService *service.StocktakeService) http.Handler {
+	return &StocktakePostHandler{
+		auth:             auth,
+		stocktakeService: stocktakeService,
+	}
+}
+
+func (h *StocktakePostHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	id, err := pathID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid stocktake id")
+		return
+	}
+
+	principal, ok := h.auth.Authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	st, movements, err := h.stocktakeService.Post(r.Context(), principal, id)
+	if err != nil {
+		handleStocktakeError(w, r, err)
+		return
+	}
+
+	dto := stocktakeDetailDTO(st)
+	dto["movements"] = stockMovementDTOs(movements)
+	writeJSON(w, http.StatusOK, dto)
+}
+
+// StocktakeCancelHandler handles POST /stocktakes/{id}/cancel requests.
+type StocktakeCancelHandler struct {
+	auth             *Authenticator
+	stocktakeService *service.StocktakeService
+}
+
+// NewStocktakeCancelHandler builds the handler that cancels a stocktake.
+func NewStocktakeCancelHandler(auth *Authenticator, stocktakeService *service.StocktakeService) http.Handler {
+	return &StocktakeCancelHandler{
+		auth:             auth,
+		stocktakeService: stocktakeService,
+	}
+}
+
+func (h *StocktakeCancelHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	id, err := pathID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid stocktake id")
+		return
+	}
+
+	principal, ok := h.auth.Authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	st, err := h.stocktakeService.Cancel(r.Context(), principal, id)
+	if err != nil {
+		handleStocktakeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, stocktakeDetailDTO(st))
+}
+
+// RestaurantStocktakeVariancesHandler handles GET
+// /restaurants/{id}/stocktakes/variances requests.
+type RestaurantStocktakeVariancesHandler struct {
+	auth             *Authenticator
+	stocktakeService *service.StocktakeService
+}
+
+// NewRestaurantStocktakeVariancesHandler builds the shrinkage report handler.
+func NewRestaurantStocktakeVariancesHandler(auth *Authenticator, stocktakeService *service.StocktakeService) http.Handler {
+	return &RestaurantStocktakeVariancesHandler{
+		auth:             auth,
+		stocktakeService: stocktakeService,
+	}
+}
+
+func (h *RestaurantStocktakeVariancesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	restaurantID, err := pathID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid restaurant id")
+		return
+	}
+
+	principal, ok := h.auth.Authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	var filter repository.VarianceFilter
+	if raw := query.Get("ingredient_id"); raw != "" {
+		id, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || id <= 0 {
+			writeError(w, http.StatusBadRequest, "invalid ingredient_id")
+			return
+		}
+		filter.IngredientID = id
+	}
+	for name, dest := range map[string]*time.Time{"from": &filter.From, "to": &filter.To} {
+		if raw := query.Get(name); raw != "" {
+			value, err := time.Parse(time.RFC3339, raw)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "invalid "+name+", expected RFC 3339")
+				return
+			}
+			*dest = value
+		}
+	}
+
+	variances, err := h.stocktakeService.Variances(r.Context(), principal, restaurantID, filter)
+	if err != nil {
+		handleStocktakeError(w, r, err)
+		return
+	}
+
+	result := make([]map[string]interface{}, 0, len(variances))
+	for _, v := range variances {
+		result = append(result, map[string]interface{}{
+			"ingredient_id":   v.IngredientID,
+			"ingredient_code": v.IngredientCode,
+			"ingredient_name": v.IngredientName,
+			"stocktakes":      v.Stocktakes,
+			"expected":        v.Expected.String(),
+			"counted":         v.Counted.String(),
+			"variance":        v.Variance.String(),
+			"shrinkage":       v.Shrinkage.String(),
+		})
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"restaurant_id": restaurantID,
+		"count":         len(result),
+		"ingredients":   result,
+	})
+}
+
+func handleStocktakeError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, service.ErrForbidden):
+		writeError(w, http.StatusForbidden, "stocktake does not belong to your restaurant, scope is missing or only managers may close it")
+	case errors.Is(err, service.ErrInvalidRestaurantID):
+		writeError(w, http.StatusBadRequest, "invalid restaurant id")
+	case errors.Is(err, service.ErrRestaurantNotFound):
+		writeError(w, http.StatusNotFound, "restaurant not found")
+	case errors.Is(err, service.ErrStocktakeNotFound):
+		writeError(w, http.StatusNotFound, "stocktake not found")
+	case errors.Is(err, service.ErrStocktakeAlreadyOpen):
+		writeError(w, http.StatusConflict, "a stocktake is already open for this restaurant")
+	case errors.Is(err, service.ErrStocktakeClosed):
+		writeError(w, http.StatusConflict, "stocktake was already posted or cancelled")
+	case errors.Is(err, service.ErrInvalidStocktakeStatus):
+		writeError(w, http.StatusBadRequest, "status must be open, posted or cancelled")
+	case errors.Is(err, service.ErrInvalidStocktakeCount):
+		writeError(w, http.StatusBadRequest, "counts need at least one entry, each ingredient once with a non-negative quantity, and a device name of at most 64 bytes")
+	case errors.Is(err, service.ErrIngredientNotFound):
+		writeError(w, http.StatusBadRequest, "ingredient not found")
+	case errors.Is(err, service.ErrInsufficientStock):
+		writeError(w, http.StatusConflict, "stock changed since the snapshot; recount or cancel the stocktake")
+	default:
+		writeInternalError(w, r, err)
+	}
+}
+
+func stocktakeDTO(st *repository.Stocktake) map[string]interface{} {
+	dto := map[string]interface{}{
+		"id":            st.ID,
+		"restaurant_id": st.RestaurantID,
+		"status":        st.Status,
+		"opened_at":     st.OpenedAt.Format(time.RFC3339),
+	}
+	if st.Note != "" {
+		dto["note"] = st.Note
+	}
+	if st.OpenedBy != 0 {
+		dto["opened_by"] = st.OpenedBy
+	}
+	if st.ClosedBy != 0 {
+		dto["closed_by"] = st.ClosedBy
+	}
+	if !st.ClosedAt.IsZero() {
+		dto["closed_at"] = st.ClosedAt.Format(time.RFC3339)
+	}
+	return dto
+}
+
+// stocktakeDetailDTO adds the lines of a stocktake and a summary computed
+// from them.
+func stocktakeDetailDTO(st *repository.Stocktake) map[string]interface{} {
+	var summary repository.StocktakeSummary
+	lines := make([]map[string]interface{}, 0, len(st.Lines))
+	for _, line := range st.Lines {
+		dto := map[string]interface{}{
+			"ingredient_id":   line.IngredientID,
+			"ingredient_code": line.IngredientCode,
+			"ingredient_name": line.IngredientName,
+			"expected":        line.Expected.String(),
+			"devices":         line.Devices,
+		}
+		summary.Lines++
+		if line.Counted.Valid {
+			variance := line.Variance()
+			dto["counted"] = line.Counted.Decimal.String()
+			dto["variance"] = variance.String()
+			summary.Counted++
+			summary.Variance = summary.Variance.Add(variance)
+			if variance.IsNegative() {
+				summary.Shrinkage = summary.Shrinkage.Sub(variance)
+			}
+		}
+		if line.Adjustment.Valid {
+			dto["adjustment"] = line.Adjustment.Decimal.String()
+		}
+		lines = append(lines, dto)
+	}
+
+	dto := stocktakeDTO(st)
+	dto["lines"] = lines
+	dto["summary"] = stocktakeSummaryDTO(summary)
+	return dto
+}
+
+func stocktakeSummaryDTO(summary repository.StocktakeSummary) map[string]interface{} {
+	return map[string]interface{}{
+		"lines":     summary.Lines,
+		"counted":   summary.Counted,
+		"variance":  summary.Variance.String(),
+		"shrinkage": summary.Shrinkage.String(),
+	}
+}
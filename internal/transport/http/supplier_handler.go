@@ -0,0 +1,330 @@
+package httptransport
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"mmispoc/internal/repository"
+	"mmispoc/internal/service"
+)
+
+// SuppliersHandler handles GET and POST /suppliers requests.
+type SuppliersHandler struct {
+	auth            *Authenticator
+	supplierService *service.SupplierService
+}
+
+// NewSuppliersHandler builds the supplier listing and creation handler.
+func NewSuppliersHandler(auth *Authenticator, supplierService *service.SupplierService) http.Handler {
+	return &SuppliersHandler{
+		auth:            auth,
+		supplierService: supplierService,
+	}
+}
+
+func (h *SuppliersHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	principal, ok := h.auth.Authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		suppliers, err := h.supplierService.List(r.Context(), principal)
+		if err != nil {
+			handleSupplierError(w, r, err)
+			return
+		}
+
+		result := make([]map[string]interface{}, 0, len(suppliers))
+		for i := range suppliers {
+			result = append(result, supplierDTO(&suppliers[i]))
+		}
+
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"count":     len(result),
+			"suppliers": result,
+		})
+		return
+	}
+
+	var payload supplierPayload
+	if !decodeJSON(w, r, &payload) {
+		return
+	}
+
+	supplier := payload.supplier()
+	if err := h.supplierService.Create(r.Context(), principal, supplier); err != nil {
+		handleSupplierError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, supplierDTO(supplier))
+}
+
+// SupplierHandler handles GET, PUT and DELETE /suppliers/{id} requests.
+type SupplierHandler struct {
+	auth            *Authenticator
+	supplierService *service.SupplierService
+}
+
+// NewSupplierHandler builds the single supplier handler.
+func NewSupplierHandler(auth *Authenticator, supplierService *service.SupplierService) http.Handler {
+	return &SupplierHandler{
+		auth:            auth,
+		supplierService: supplierService,
+	}
+}
+
+func (h *SupplierHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPut && r.Method != http.MethodDelete {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	supplierID, err := pathID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid supplier id")
+		return
+	}
+
+	principal, ok := h.auth.Authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		supplier, err := h.supplierService.Get(r.Context(), principal, supplierID)
+		if err != nil {
+			handleSupplierError(w, r, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, supplierDTO(supplier))
+
+	case http.MethodPut:
+		var payload supplierPayload
+		if !decodeJSON(w, r, &payload) {
+			return
+		}
+
+		supplier := payload.supplier()
+		supplier.ID = supplierID
+		if err := h.supplierService.Update(r.Context(), principal, supplier); err != nil {
+			handleSupplierError(w, r, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, supplierDTO(supplier))
+
+	case http.MethodDelete:
+		if err := h.supplierService.Delete(r.Context(), principal, supplierID); err != nil {
+			handleSupplierError(w, r, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"id":      supplierID,
+			"deleted": true,
+		})
+	}
+}
+
+// SupplierIngredientsHandler handles GET /suppliers/{id}/ingredients requests.
+type SupplierIngredientsHandler struct {
+	auth            *Authenticator
+	supplierService *service.SupplierService
+}
+
+// NewSupplierIngredientsHandler builds the supplier catalogue handler.
+func NewSupplierIngredientsHandler(auth *Authenticator, supplierService *service.SupplierService) http.Handler {
+	return &SupplierIngredientsHandler{
+		auth:            auth,
+		supplierService: supplierService,
+	}
+}
+
+func (h *SupplierIngredientsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	supplierID, err := pathID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid supplier id")
+		return
+	}
+
+	principal, ok := h.auth.Authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	terms, err := h.supplierService.ListIngredients(r.Context(), principal, supplierID)
+	if err != nil {
+		handleSupplierError(w, r, err)
+		return
+	}
+
+	result := make([]map[string]interface{}, 0, len(terms))
+	for i := range terms {
+		result = append(result, supplierIngredientDTO(&terms[i]))
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"count":       len(result),
+		"ingredients": result,
+	})
+}
+
+// SupplierIngredientHandler handles PUT and DELETE
+// /suppliers/{id}/ingredients/{ingredient_id} requests.
+type SupplierIngredientHandler struct {
+	auth            *Authenticator
+	supplierService *service.SupplierService
+}
+
+// NewSupplierIngredientHandler builds the handler that maintains supplier terms.
+func NewSupplierIngredientHandler(auth *Authenticator, supplierService *service.SupplierService) http.Handler {
+	return &SupplierIngredientHandler{
+		auth:            auth,
+		supplierService: supplierService,
+	}
+}
+
+func (h *SupplierIngredientHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut && r.Method != http.MethodDelete {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	supplierID, err := pathID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid supplier id")
+		return
+	}
+	ingredientID, err := strconv.ParseInt(strings.TrimSpace(r.PathValue("ingredient_id")), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid ingredient id")
+		return
+	}
+
+	principal, ok := h.auth.Authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	if r.Method == http.MethodDelete {
+		if err := h.supplierService.RemoveIngredient(r.Context(), principal, supplierID, ingredientID); err != nil {
+			handleSupplierError(w, r, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"supplier_id":   supplierID,
+			"ingredient_id": ingredientID,
+			"deleted":       true,
+		})
+		return
+	}
+
+	var payload struct {
+		SKU          string          `json:"sku"`
+		PackSize     int             `json:"pack_size"`
+		LeadTimeDays int             `json:"lead_time_days"`
+		MinOrderQty  int             `json:"min_order_qty"`
+		Price        decimal.Decimal `json:"price"`
+		Preferred    bool            `json:"preferred"`
+	}
+	if !decodeJSON(w, r, &payload) {
+		return
+	}
+
+	terms := &repository.SupplierIngredient{
+		SupplierID:   supplierID,
+		IngredientID: ingredientID,
+		SKU:          payload.SKU,
+		PackSize:     payload.PackSize,
+		LeadTimeDays: payload.LeadTimeDays,
+		MinOrderQty:  payload.MinOrderQty,
+		Price:        payload.Price,
+		Preferred:    payload.Preferred,
+	}
+	if err := h.supplierService.SetIngredient(r.Context(), principal, terms); err != nil {
+		handleSupplierError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, supplierIngredientDTO(terms))
+}
+
+type supplierPayload struct {
+	Code  string `json:"code"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
+	Phone string `json:"phone"`
+}
+
+func (p supplierPayload) supplier() *repository.Supplier {
+	return &repository.Supplier{
+		Code:  p.Code,
+		Name:  p.Name,
+		Email: p.Email,
+		Phone: p.Phone,
+	}
+}
+
+func handleSupplierError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, service.ErrForbidden):
+		writeError(w, http.StatusForbidden, "only administrators can manage suppliers")
+	case errors.Is(err, service.ErrSupplierNotFound):
+		writeError(w, http.StatusNotFound, "supplier not found")
+	case errors.Is(err, service.ErrSupplierIngredientNotFound):
+		writeError(w, http.StatusNotFound, "supplier does not deliver ingredient")
+	case errors.Is(err, service.ErrIngredientNotFound):
+		writeError(w, http.StatusBadRequest, "ingredient not found")
+	case errors.Is(err, service.ErrSupplierCodeTaken):
+		writeError(w, http.StatusConflict, "supplier code already in use")
+	case errors.Is(err, service.ErrInvalidSupplier):
+		writeError(w, http.StatusBadRequest, "code and name are required and email must be valid")
+	case errors.Is(err, service.ErrInvalidSupplierTerms):
+		writeError(w, http.StatusBadRequest, "pack size, lead time, minimum quantity and price must not be negative")
+	default:
+		writeInternalError(w, r, err)
+	}
+}
+
+func supplierDTO(supplier *repository.Supplier) map[string]interface{} {
+	return map[string]interface{}{
+		"id":         supplier.ID,
+		"code":       supplier.Code,
+		"name":       supplier.Name,
+		"email":      supplier.Email,
+		"phone":      supplier.Phone,
+		"created_at": supplier.CreatedAt.Format(time.RFC3339),
+		"updated_at": supplier.UpdatedAt.Format(time.RFC3339),
+	}
+}
+
+func supplierIngredientDTO(terms *repository.SupplierIngredient) map[string]interface{} {
+	return map[string]interface{}{
+		"supplier_id":    terms.SupplierID,
+		"supplier_name":  terms.SupplierName,
+		"ingredient_id":  terms.IngredientID,
+		"sku":            terms.SKU,
+		"pack_size":      terms.PackSize,
+		"lead_time_days": terms.LeadTimeDays,
+		"min_order_qty":  terms.MinOrderQty,
+		"price":          terms.Price.String(),
+		"preferred":      terms.Preferred,
+		"updated_at":     terms.UpdatedAt.Format(time.RFC3339),
+	}
+}
@@ -0,0 +1,61 @@
+package httptransport
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+
+	"mmispoc/internal/tracing"
+)
+
+// withTracing starts a server span for every request, continuing the trace
+// of an incoming W3C traceparent header. The span is renamed to the route
+// template once the mux has matched the request.
+func withTracing(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracing.Tracer().Start(ctx, r.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", r.Method),
+				attribute.String("url.path", r.URL.Path),
+			),
+		)
+		defer span.End()
+
+		rec := &statusRecorder{ResponseWriter: w}
+		r = r.WithContext(ctx)
+		next.ServeHTTP(rec, r)
+
+		status := rec.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		route := requestRoute(r)
+
+		span.SetName(r.Method + " " + route)
+		span.SetAttributes(
+			attribute.String("http.route", route),
+			attribute.Int("http.response.status_code", status),
+		)
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+		if info := requestInfoFrom(r); info != nil && info.principal != nil {
+			p := info.principal
+			switch {
+			case p.IsAPIKey():
+				span.SetAttributes(attribute.Int64("mmispoc.api_key_id", p.APIKeyID))
+			case p.IsUser():
+				span.SetAttributes(attribute.Int64("enduser.id", p.UserID))
+			}
+			if p.ClientSubject != "" {
+				span.SetAttributes(attribute.String("tls.client.subject", p.ClientSubject))
+			}
+		}
+	})
+}